@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAckDuration is offered when acknowledging from the TUI
+const defaultAckDuration = "1h"
+
+// Ack silences the alerts of a problem device while someone works on it
+type Ack struct {
+	By      string    `json:"by"`
+	At      time.Time `json:"at"`
+	Until   time.Time `json:"until,omitzero"` // Zero: until the device recovers
+	Comment string    `json:"comment,omitempty"`
+}
+
+// AckStore keeps acknowledgements in memory, keyed by device ID. An ack ends
+// when it expires or when its device recovers, so the next problem alerts
+// again.
+type AckStore struct {
+	mu      sync.Mutex
+	acks    map[string]Ack
+	changed chan struct{}
+}
+
+func NewAckStore() *AckStore {
+	return &AckStore{acks: make(map[string]Ack), changed: make(chan struct{}, 1)}
+}
+
+// hasProblem reports whether device is something to be alerted about: not
+// connected, flapping, unhealthy, or failing its -probe
+func hasProblem(device *PhysicalDevice) bool {
+	switch {
+	case device.ConnectionState != "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTED", device.Flapping:
+		return true
+	case device.HealthStatus == "PHYSICAL_DEVICE_HEALTH_STATUS_WARNING",
+		device.HealthStatus == "PHYSICAL_DEVICE_HEALTH_STATUS_CRITICAL":
+		return true
+	default:
+		return device.Probe != nil && !device.Probe.Reachable
+	}
+}
+
+// parseAckDuration parses how long an ack lasts; empty means until the
+// device recovers
+func parseAckDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid duration %q (e.g. 30m or 2h)", value)
+	}
+	return duration, nil
+}
+
+// Acknowledge silences the alerts of device for duration, or until it
+// recovers when duration is 0
+func (as *AckStore) Acknowledge(device *PhysicalDevice, by string, duration time.Duration, comment string) (Ack, error) {
+	if !hasProblem(device) {
+		return Ack{}, fmt.Errorf("%s has no problem to acknowledge", device.Name)
+	}
+
+	ack := Ack{By: by, At: time.Now(), Comment: strings.TrimSpace(comment)}
+	if duration > 0 {
+		ack.Until = ack.At.Add(duration)
+	}
+
+	as.mu.Lock()
+	as.acks[device.ID] = ack
+	as.mu.Unlock()
+
+	as.notify()
+	return ack, nil
+}
+
+// Clear removes the ack of a device and reports whether it had one
+func (as *AckStore) Clear(deviceID string) bool {
+	as.mu.Lock()
+	_, ok := as.acks[deviceID]
+	delete(as.acks, deviceID)
+	as.mu.Unlock()
+
+	if ok {
+		as.notify()
+	}
+	return ok
+}
+
+// Changed receives after every Acknowledge or Clear, so the TUI can show
+// acks made through the web API
+func (as *AckStore) Changed() <-chan struct{} {
+	if as == nil {
+		return nil
+	}
+	return as.changed
+}
+
+func (as *AckStore) notify() {
+	select {
+	case as.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Expired reports whether an ack's time is up, so Annotate would drop it
+func (as *AckStore) Expired() bool {
+	if as == nil {
+		return false
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	now := time.Now()
+	for _, ack := range as.acks {
+		if !ack.Until.IsZero() && now.After(ack.Until) {
+			return true
+		}
+	}
+	return false
+}
+
+// Annotate returns a copy of data with the current acks, leaving data itself
+// untouched for concurrent readers. Acks of devices that recovered or whose
+// time is up are dropped. A nil *AckStore has no acks.
+func (as *AckStore) Annotate(data *GroupedDevices) *GroupedDevices {
+	if as == nil {
+		return data
+	}
+
+	as.mu.Lock()
+	defer as.mu.Unlock()
+
+	now := time.Now()
+	return mapDevices(data, func(device *PhysicalDevice) {
+		device.Ack = nil
+		ack, ok := as.acks[device.ID]
+		if !ok {
+			return
+		}
+		if !hasProblem(device) || (!ack.Until.IsZero() && now.After(ack.Until)) {
+			delete(as.acks, device.ID)
+			return
+		}
+		device.Ack = &ack
+	})
+}
+
+// GetAckDisplay describes the device's ack, e.g. "ACK (by alice, until 15:04)"
+func (pd *PhysicalDevice) GetAckDisplay() string {
+	if pd.Ack == nil {
+		return ""
+	}
+
+	until := "until recovered"
+	if !pd.Ack.Until.IsZero() {
+		layout := "15:04"
+		if pd.Ack.Until.Sub(time.Now()) > 24*time.Hour {
+			layout = "Jan 2 15:04"
+		}
+		until = "until " + formatTime(pd.Ack.Until, layout)
+	}
+	return fmt.Sprintf("ACK (by %s, %s)", pd.Ack.By, until)
+}
+
+// acknowledgeSelected removes the ack of the selected device, or asks how
+// long to acknowledge its problem for
+func (s *Scheduler) acknowledgeSelected() {
+	device := s.tui.Selected()
+	if device == nil {
+		s.tui.Flash("Select a device with the arrow keys or the mouse first", flashDuration)
+		s.tui.Redraw()
+		return
+	}
+
+	if device.Ack != nil {
+		s.acks.Clear(device.ID)
+		s.tui.Flash(fmt.Sprintf("Acknowledgement of %s removed", device.Name), flashDuration)
+		s.tui.Redraw()
+		return
+	}
+	if !hasProblem(device) {
+		s.tui.Flash(fmt.Sprintf("%s has no problem to acknowledge", device.Name), flashDuration)
+		s.tui.Redraw()
+		return
+	}
+
+	id := device.ID
+	s.onInput = func(text string) {
+		duration, err := parseAckDuration(text)
+		if err != nil {
+			s.tui.Flash(err.Error(), flashDuration)
+			s.tui.Redraw()
+			return
+		}
+		// The device may have changed while the field was open
+		current := findDevice(s.store.State().Data, id)
+		if current == nil {
+			s.tui.Redraw()
+			return
+		}
+		if _, err := s.acks.Acknowledge(current, localUsername(), duration, ""); err != nil {
+			s.tui.Flash(err.Error(), flashDuration)
+			s.tui.Redraw()
+		}
+	}
+	s.tui.StartInput(fmt.Sprintf("Acknowledge %s for (empty: until it recovers):", device.Name), defaultAckDuration)
+}
@@ -0,0 +1,711 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AlertConfig configures alerts on device problems. Alerts are only
+// evaluated when at least one destination, such as webhook, is set.
+type AlertConfig struct {
+	Webhook  string                     `json:"webhook"`  // POST every alert as JSON to this URL
+	Cooldown configDuration             `json:"cooldown"` // Minimum time between two alerts of a rule for one device
+	Rules    map[string]AlertRuleConfig `json:"rules"`    // Settings of the alert rules by name
+
+	// Alert when the management API stays unreachable this long; 0 is off
+	APIUnreachable configDuration `json:"api_unreachable"`
+
+	// Message templates of every channel that does not set its own
+	Templates MessageTemplates `json:"templates"`
+
+	PagerDuty PagerDutyConfig `json:"pagerduty"`
+	Opsgenie  OpsgenieConfig  `json:"opsgenie"`
+	Slack     ChatConfig      `json:"slack"`
+	Teams     ChatConfig      `json:"teams"`
+}
+
+// AlertRuleConfig overrides the alert settings of one rule
+type AlertRuleConfig struct {
+	Enabled  bool            `json:"enabled"` // Turns on an opt-in rule such as flapping
+	Disabled bool            `json:"disabled"`
+	Cooldown *configDuration `json:"cooldown,omitempty"`
+}
+
+// defaultAlertCooldown keeps a flapping device from raising an alert on
+// every poll
+const defaultAlertCooldown = 5 * time.Minute
+
+// defaultAPIUnreachable is how long the management API may fail before it
+// is alerted; a few failed polls are normal during upgrades
+const defaultAPIUnreachable = 5 * time.Minute
+
+// apiAlertKey identifies the alert about the management API itself
+const apiAlertKey = "api:unreachable"
+
+// alertRecheckInterval is how often active alerts are checked between polls,
+// so an alert held back by its cooldown is sent once the cooldown is over
+const alertRecheckInterval = 30 * time.Second
+
+// maxQueuedAlerts bounds the alerts waiting for delivery
+const maxQueuedAlerts = 256
+
+// Alert states
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert is a notification about a problem condition of a device: firing
+// when it starts, resolved when it clears
+type Alert struct {
+	Key           string    `json:"key"` // Device ID and rule, the same for every notification of one problem
+	Rule          string    `json:"rule"`
+	Status        string    `json:"status"`
+	Severity      string    `json:"severity"` // critical or warning
+	Summary       string    `json:"summary"`
+	DeviceID      string    `json:"device_id"`
+	DeviceName    string    `json:"device_name"`
+	LogicalDevice string    `json:"logical_device"`
+	Address       string    `json:"address,omitempty"`
+	URL           string    `json:"url,omitempty"` // Management UI page of the device, see -device_url
+	OldState      string    `json:"old_state,omitempty"`
+	NewState      string    `json:"new_state"` // State of the rule's field, e.g. DISCONNECTED, or REMOVED
+	Since         time.Time `json:"since"`     // When the condition started
+	Time          time.Time `json:"time"`
+
+	Device *PhysicalDevice `json:"-"` // Nil once the device is removed
+}
+
+// alertRule is a problem condition of a device. check returns a summary of
+// the problem, or "" when the device does not have it; state is the value of
+// the device field the rule looks at. Opt-in rules only alert when enabled
+// in the config file.
+type alertRule struct {
+	name     string
+	severity string
+	check    func(device *PhysicalDevice) string
+	state    func(device *PhysicalDevice) string
+	optIn    bool
+}
+
+// inventoryState is MISSING, UNKNOWN or EXPECTED
+func inventoryState(device *PhysicalDevice) string {
+	if device.Inventory == "" {
+		return "EXPECTED"
+	}
+	return device.Inventory
+}
+
+// flappingState is the connection state of a device, or FLAPPING
+func flappingState(device *PhysicalDevice) string {
+	if device.Flapping {
+		return "FLAPPING"
+	}
+	return device.GetConnectionStateDisplay()
+}
+
+var alertRules = []alertRule{
+	{"disconnected", "critical", func(device *PhysicalDevice) string {
+		if device.GetConnectionStateDisplay() != "DISCONNECTED" {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s) is disconnected", device.Name, device.LogicalDevice.Name)
+	}, (*PhysicalDevice).GetConnectionStateDisplay, false},
+	{"health_critical", "critical", func(device *PhysicalDevice) string {
+		if device.GetHealthStatusDisplay() != "CRITICAL" {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s) health is critical", device.Name, device.LogicalDevice.Name)
+	}, (*PhysicalDevice).GetHealthStatusDisplay, false},
+	{"flapping", "warning", func(device *PhysicalDevice) string {
+		if !device.Flapping {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s) is flapping between connection states", device.Name, device.LogicalDevice.Name)
+	}, flappingState, true},
+	{"missing", "critical", func(device *PhysicalDevice) string {
+		if device.Inventory != InventoryMissing {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s) is expected but missing from the management API", device.Name, device.LogicalDevice.Name)
+	}, inventoryState, false},
+	{"unknown", "warning", func(device *PhysicalDevice) string {
+		if device.Inventory != InventoryUnknown {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s) is not in the expected inventory", device.Name, device.LogicalDevice.Name)
+	}, inventoryState, false},
+	{"outdated", "warning", func(device *PhysicalDevice) string {
+		if !device.Outdated {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s) runs product version %s, below the target version", device.Name, device.LogicalDevice.Name, device.ProductVersion)
+	}, outdatedState, true},
+	// Checked against the previous polls in evaluate
+	{"removed", "warning", nil, nil, false},
+	// Checked per cluster in evaluate, see RoleAnomalies
+	{AnomalySplitBrain, "critical", nil, nil, false},
+	{AnomalyNoActive, "critical", nil, nil, false},
+	{AnomalyPriorityConflict, "warning", nil, nil, false},
+	{AnomalyPriorityInversion, "warning", nil, nil, false},
+}
+
+// clusterRules are the rules evaluated per cluster rather than per device
+var clusterRules = []string{AnomalySplitBrain, AnomalyNoActive, AnomalyPriorityConflict, AnomalyPriorityInversion}
+
+// AlertSender delivers alerts, e.g. to a webhook or a paging service
+type AlertSender interface {
+	Send(alert Alert) error
+}
+
+type alertSenderFactory func(config *Config) (AlertSender, error)
+
+type alertSenderRegistration struct {
+	name    string
+	factory alertSenderFactory
+}
+
+var alertSenderRegistry []alertSenderRegistration
+
+// registerAlertSender makes an alert destination available; call it from
+// init(). Factories return nil (and no error) when not configured.
+func registerAlertSender(name string, factory alertSenderFactory) {
+	alertSenderRegistry = append(alertSenderRegistry, alertSenderRegistration{name, factory})
+}
+
+// activeAlert is a firing condition; notified is unset while the cooldown or
+// an acknowledgement holds its alert back
+type activeAlert struct {
+	alert    Alert
+	notified bool
+}
+
+// Alerter turns poll results into alerts. A condition is alerted once while
+// it lasts, with a resolved notice when it clears, and at most once per
+// cooldown, so a flapping device does not flood the destinations.
+// Acknowledged devices are not alerted.
+type Alerter struct {
+	config  AlertConfig
+	links   func(id, name string) string
+	senders []AlertSender
+	logs    []failureLog // Delivery failures by sender, so each is logged once
+	mu      sync.Mutex
+	data    *GroupedDevices
+	active  map[string]*activeAlert
+	sent    map[string]time.Time      // Last firing alert by key
+	states  map[string]string         // Rule state of the previous poll by key
+	known   map[string]PhysicalDevice // Devices of the previous poll by ID
+	gone    map[string]PhysicalDevice // Removed devices by ID, until they or a replacement are back
+	apiHost string
+	apiURL  string
+	apiOK   time.Time // Last successful poll
+	apiDown time.Time // Start of the current API outage, zero while polls succeed
+	apiErr  error
+	api     *Alert // Alerted API outage
+	queue   chan Alert
+	leader  atomic.Pointer[LeaderElection] // Unset without -leader_lock
+	stop    chan struct{}
+	done    chan struct{}
+	errLog  failureLog
+}
+
+func init() {
+	registerExporter("alerts", func(config *Config) (Exporter, error) {
+		var senders []AlertSender
+		var names []string
+		for _, registration := range alertSenderRegistry {
+			sender, err := registration.factory(config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to configure %s alerts: %w", registration.name, err)
+			}
+			if sender != nil {
+				senders = append(senders, sender)
+				names = append(names, registration.name)
+			}
+		}
+		if len(senders) == 0 {
+			return nil, nil
+		}
+		return NewAlerter(config, senders, names), nil
+	})
+}
+
+func NewAlerter(config *Config, senders []AlertSender, names []string) *Alerter {
+	a := &Alerter{
+		config:  config.Alerts,
+		links:   managementLinks(config),
+		senders: senders,
+		logs:    make([]failureLog, len(senders)),
+		active:  make(map[string]*activeAlert),
+		sent:    make(map[string]time.Time),
+		states:  make(map[string]string),
+		gone:    make(map[string]PhysicalDevice),
+		apiHost: config.BaseURL,
+		queue:   make(chan Alert, maxQueuedAlerts),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		errLog:  failureLog{name: "alerts"},
+	}
+
+	for i, name := range names {
+		a.logs[i].name = "alerts: " + name
+	}
+
+	if u, err := url.Parse(config.BaseURL); err == nil && u.Host != "" {
+		a.apiHost = u.Host
+		a.apiURL = u.Scheme + "://" + u.Host + "/"
+	}
+
+	go a.run()
+
+	return a
+}
+
+// managementLinks returns the link to a device's page in the management UI:
+// -device_url, or the management UI itself without it
+func managementLinks(config *Config) func(id, name string) string {
+	template := config.DeviceURL
+	if template == "" {
+		if u, err := url.Parse(config.BaseURL); err == nil && u.Host != "" {
+			template = u.Scheme + "://" + u.Host + "/"
+		}
+	}
+	return func(id, name string) string {
+		return objectURL(config.BaseURL, template, id, name)
+	}
+}
+
+// checkAlertConfig describes what is wrong with the alerts section of the
+// config file
+func checkAlertConfig(config AlertConfig) []string {
+	var problems []string
+
+	known := make(map[string]bool)
+	var names []string
+	for _, rule := range alertRules {
+		known[rule.name] = true
+		names = append(names, rule.name)
+	}
+	for name, rule := range config.Rules {
+		if !known[name] {
+			problems = append(problems, fmt.Sprintf("unknown alert rule %q (available: %s)", name, strings.Join(names, ", ")))
+		}
+		if rule.Cooldown != nil && *rule.Cooldown < 0 {
+			problems = append(problems, fmt.Sprintf("alerts.rules.%s.cooldown must not be negative", name))
+		}
+	}
+
+	if config.Cooldown < 0 {
+		problems = append(problems, "alerts.cooldown must not be negative")
+	}
+	if config.APIUnreachable < 0 {
+		problems = append(problems, "alerts.api_unreachable must not be negative")
+	}
+	urls := []struct{ name, value string }{
+		{"alerts.webhook", config.Webhook},
+		{"alerts.pagerduty.url", config.PagerDuty.URL},
+		{"alerts.opsgenie.url", config.Opsgenie.URL},
+		{"alerts.slack.webhook_url", config.Slack.WebhookURL},
+		{"alerts.teams.webhook_url", config.Teams.WebhookURL},
+	}
+	for _, u := range urls {
+		if u.value != "" && !strings.HasPrefix(u.value, "http://") && !strings.HasPrefix(u.value, "https://") {
+			problems = append(problems, u.name+" must start with http:// or https://")
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+func (a *Alerter) enabled(rule alertRule) bool {
+	settings := a.config.Rules[rule.name]
+	return !settings.Disabled && (!rule.optIn || settings.Enabled)
+}
+
+// cooldown returns the cooldown of a rule
+func (a *Alerter) cooldown(rule string) time.Duration {
+	if settings, ok := a.config.Rules[rule]; ok && settings.Cooldown != nil {
+		return time.Duration(*settings.Cooldown)
+	}
+	return time.Duration(a.config.Cooldown)
+}
+
+// Export evaluates the alert rules against a successful poll. A failed poll
+// leaves the device alerts as they are, but an outage of the management API
+// that lasts is alerted on its own.
+func (a *Alerter) Export(result PollResult) {
+	a.mu.Lock()
+	if result.Err != nil {
+		if a.apiDown.IsZero() {
+			// The outage began after the last poll that got through
+			a.apiDown = result.Time
+			if !a.apiOK.IsZero() {
+				a.apiDown = a.apiOK
+			}
+		}
+		a.apiErr = result.Err
+	} else {
+		a.apiOK = result.Time
+		a.apiDown = time.Time{}
+	}
+	a.checkAPI(result.Time)
+	a.mu.Unlock()
+
+	if result.Data == nil {
+		return
+	}
+
+	a.Watch(result.Data)
+}
+
+// Unchanged records a successful poll that returned nothing new
+func (a *Alerter) Unchanged() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.apiOK = time.Now()
+}
+
+// checkAPI alerts an API outage longer than the api_unreachable setting and
+// resolves the alert on the first successful poll. Called with a.mu held.
+func (a *Alerter) checkAPI(now time.Time) {
+	if a.apiDown.IsZero() {
+		if a.api != nil {
+			alert := *a.api
+			alert.Status = AlertResolved
+			alert.Summary = fmt.Sprintf("Management API %s is reachable again (resolved)", a.apiHost)
+			alert.OldState, alert.NewState = alert.NewState, "REACHABLE"
+			alert.Time = now
+			a.api = nil
+			a.enqueue(alert)
+		}
+		return
+	}
+
+	after := time.Duration(a.config.APIUnreachable)
+	if a.api != nil || after <= 0 || now.Sub(a.apiDown) < after {
+		return
+	}
+
+	a.api = &Alert{
+		Key:        apiAlertKey,
+		Rule:       "api_unreachable",
+		Status:     AlertFiring,
+		Severity:   "critical",
+		Summary:    fmt.Sprintf("Management API %s unreachable for %s: %v", a.apiHost, now.Sub(a.apiDown).Round(time.Second), a.apiErr),
+		DeviceName: "management API",
+		Address:    a.apiHost,
+		URL:        a.apiURL,
+		OldState:   "REACHABLE",
+		NewState:   "UNREACHABLE",
+		Since:      a.apiDown,
+		Time:       now,
+	}
+	a.enqueue(*a.api)
+}
+
+// Watch evaluates the alert rules against data re-annotated between polls,
+// e.g. a device that stopped flapping
+func (a *Alerter) Watch(data *GroupedDevices) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.data = data
+	a.evaluate(time.Now())
+}
+
+// evaluate compares the conditions of a.data with the active alerts and
+// queues the notifications due. Called with a.mu held.
+func (a *Alerter) evaluate(now time.Time) {
+	seen := make(map[string]bool)
+	states := make(map[string]string)
+	resolutions := make(map[string]resolution)
+
+	for _, group := range a.data.LogicalDeviceGroups {
+		for i := range group.PhysicalDevices {
+			device := &group.PhysicalDevices[i]
+			for _, rule := range alertRules {
+				if rule.check == nil || !a.enabled(rule) {
+					continue
+				}
+				key := device.ID + ":" + rule.name
+				states[key] = rule.state(device)
+
+				summary := rule.check(device)
+				if summary == "" {
+					continue
+				}
+				seen[key] = true
+
+				copied := *device
+				a.raise(rule, Alert{
+					Key:           key,
+					Summary:       summary,
+					DeviceID:      device.ID,
+					DeviceName:    device.Name,
+					LogicalDevice: device.LogicalDevice.Name,
+					Address:       device.Address,
+					URL:           a.links(device.ID, device.Name),
+					OldState:      a.states[key],
+					NewState:      states[key],
+					Device:        &copied,
+				}, device.Ack != nil, now)
+			}
+		}
+	}
+
+	for _, group := range a.data.LogicalDeviceGroups {
+		// Several priority conflicts or inversions of a cluster are one alert
+		found := make(map[string]string)
+		for _, anomaly := range group.RoleAnomalies() {
+			if found[anomaly.Rule] != "" {
+				found[anomaly.Rule] += "; "
+			}
+			found[anomaly.Rule] += anomaly.Summary
+		}
+
+		for _, name := range clusterRules {
+			rule := ruleNamed(name)
+			if !group.IsCluster || !a.enabled(rule) {
+				continue
+			}
+			key := "cluster:" + group.LogicalDevice.ID + ":" + name
+			states[key] = "OK"
+			if found[name] == "" {
+				continue
+			}
+			states[key] = strings.ToUpper(name)
+			seen[key] = true
+
+			a.raise(rule, Alert{
+				Key:           key,
+				Summary:       fmt.Sprintf("Cluster %s: %s", group.LogicalDevice.Name, found[name]),
+				DeviceName:    group.LogicalDevice.Name,
+				LogicalDevice: group.LogicalDevice.Name,
+				OldState:      a.states[key],
+				NewState:      states[key],
+			}, false, now)
+		}
+	}
+
+	current := indexDevices(a.data)
+	if rule := ruleNamed("removed"); a.enabled(rule) {
+		for id, device := range a.known {
+			if _, ok := current[id]; !ok {
+				a.gone[id] = device
+			}
+		}
+
+		for id, device := range a.gone {
+			key := id + ":" + rule.name
+			if _, ok := current[id]; ok {
+				delete(a.gone, id)
+				resolutions[key] = resolution{fmt.Sprintf("%s (%s) is back", device.Name, device.LogicalDevice.Name), "PRESENT"}
+				continue
+			}
+			if successor := findReplacement(current, device); successor != nil {
+				delete(a.gone, id)
+				resolutions[key] = resolution{fmt.Sprintf("%s (%s) was replaced, serial number %s -> %s",
+					device.Name, device.LogicalDevice.Name, device.SerialNumber, successor.SerialNumber), "REPLACED"}
+				continue
+			}
+
+			seen[key] = true
+			a.raise(rule, Alert{
+				Key:           key,
+				Summary:       fmt.Sprintf("%s (%s) disappeared from the management API", device.Name, device.LogicalDevice.Name),
+				DeviceID:      id,
+				DeviceName:    device.Name,
+				LogicalDevice: device.LogicalDevice.Name,
+				Address:       device.Address,
+				OldState:      "PRESENT",
+				NewState:      "REMOVED",
+			}, false, now)
+		}
+	} else {
+		clear(a.gone)
+	}
+	a.known = current
+
+	for key, active := range a.active {
+		if seen[key] {
+			continue
+		}
+		delete(a.active, key)
+
+		// Only conditions that were alerted get a resolved notice
+		if active.notified {
+			alert := active.alert
+			alert.Status = AlertResolved
+			alert.Summary += " (resolved)"
+			alert.OldState, alert.NewState = alert.NewState, "REMOVED"
+			alert.Device = nil
+			if r, ok := resolutions[key]; ok {
+				alert.Summary = r.summary + " (resolved)"
+				alert.NewState = r.state
+			} else if state, ok := states[key]; ok {
+				alert.NewState = state
+			}
+			if device := findDevice(a.data, alert.DeviceID); device != nil {
+				copied := *device
+				alert.Device = &copied
+			}
+			alert.Time = now
+			a.enqueue(alert)
+		}
+	}
+	a.states = states
+
+	// Forget cooldowns that are over, so the map does not grow forever
+	for key, last := range a.sent {
+		if a.active[key] == nil && now.Sub(last) > a.maxCooldown() {
+			delete(a.sent, key)
+		}
+	}
+}
+
+// resolution describes how a condition without a device field cleared
+type resolution struct {
+	summary string
+	state   string
+}
+
+// raise records that the condition of alert is present and sends the alert
+// unless it was sent already, the cooldown of its rule holds it back, or the
+// device is acknowledged. Called with a.mu held.
+func (a *Alerter) raise(rule alertRule, alert Alert, acked bool, now time.Time) {
+	active := a.active[alert.Key]
+	if active == nil {
+		alert.Rule = rule.name
+		alert.Status = AlertFiring
+		alert.Severity = rule.severity
+		alert.Since = now
+		active = &activeAlert{alert: alert}
+		a.active[alert.Key] = active
+	}
+	active.alert.Summary = alert.Summary
+	active.alert.NewState = alert.NewState
+	active.alert.Device = alert.Device
+
+	// Repeats of a notified condition are deduplicated
+	if active.notified || acked {
+		return
+	}
+	if last, ok := a.sent[alert.Key]; ok && now.Sub(last) < a.cooldown(rule.name) {
+		return
+	}
+
+	active.notified = true
+	a.sent[alert.Key] = now
+	sent := active.alert
+	sent.Time = now
+	a.enqueue(sent)
+}
+
+// ruleNamed returns the alert rule with the given name, e.g. one of the
+// rules without a device to check
+func ruleNamed(name string) alertRule {
+	for _, rule := range alertRules {
+		if rule.name == name {
+			return rule
+		}
+	}
+	return alertRule{}
+}
+
+// findReplacement returns the device of devices with the name and logical
+// device of a removed one, e.g. after an RMA swap
+func findReplacement(devices map[string]PhysicalDevice, removed PhysicalDevice) *PhysicalDevice {
+	for _, device := range devices {
+		if device.Name == removed.Name && device.LogicalDevice.ID == removed.LogicalDevice.ID {
+			return &device
+		}
+	}
+	return nil
+}
+
+func (a *Alerter) maxCooldown() time.Duration {
+	longest := time.Duration(a.config.Cooldown)
+	for name := range a.config.Rules {
+		longest = max(longest, a.cooldown(name))
+	}
+	return longest
+}
+
+func (a *Alerter) enqueue(alert Alert) {
+	select {
+	case a.queue <- alert:
+	default:
+		a.errLog.set(fmt.Errorf("alert queue full, dropped %s alert for %s", alert.Status, alert.DeviceName))
+	}
+}
+
+// Close delivers the queued alerts and stops
+func (a *Alerter) Close() {
+	close(a.stop)
+	<-a.done
+}
+
+func (a *Alerter) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(alertRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case alert := <-a.queue:
+			a.send(alert)
+		case now := <-ticker.C:
+			a.recheck(now)
+		case <-a.stop:
+			for {
+				select {
+				case alert := <-a.queue:
+					a.send(alert)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// SetLeader holds the alerts back while another instance leads. The sinks
+// only export to the leader, but the rechecks between polls run anyway.
+func (a *Alerter) SetLeader(leader *LeaderElection) {
+	a.leader.Store(leader)
+}
+
+// recheck sends the alerts that became due since the last poll, such as
+// ones held back by their cooldown or an API outage that lasts
+func (a *Alerter) recheck(now time.Time) {
+	if !a.leader.Load().Leading() {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.data != nil {
+		a.evaluate(now)
+	}
+	a.checkAPI(now)
+}
+
+// send delivers an alert to every destination, unless another instance
+// took the lead since it was queued
+func (a *Alerter) send(alert Alert) {
+	if !a.leader.Load().Leading() {
+		return
+	}
+	for i, sender := range a.senders {
+		a.logs[i].set(sender.Send(alert))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingSender counts the alerts it is asked to deliver
+type countingSender struct {
+	sent atomic.Int32
+}
+
+func (s *countingSender) Send(alert Alert) error {
+	s.sent.Add(1)
+	return nil
+}
+
+// failingSender fails every delivery
+type failingSender struct{}
+
+func (failingSender) Send(alert Alert) error {
+	return errors.New("connection refused")
+}
+
+func TestAlerterLogsSenderFailureOnce(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	backgroundLog = true
+	defer func() {
+		log.SetOutput(os.Stderr)
+		backgroundLog = false
+	}()
+
+	cm := NewConfigManager()
+	cm.setDefaults()
+	working := &countingSender{}
+	alerter := NewAlerter(cm.config, []AlertSender{failingSender{}, working}, []string{"webhook", "slack"})
+	for i := 0; i < 3; i++ {
+		alerter.send(Alert{Key: "pd-2:disconnected", Status: AlertFiring})
+	}
+	alerter.Close()
+
+	if sent := working.sent.Load(); sent != 3 {
+		t.Errorf("working sender got %d alerts, want 3", sent)
+	}
+	if n := strings.Count(logged.String(), "alerts: webhook: connection refused"); n != 1 {
+		t.Errorf("failure logged %d times, want once:\n%s", n, logged.String())
+	}
+}
+
+func TestAlerterFollowerSendsNothing(t *testing.T) {
+	cm := NewConfigManager()
+	cm.setDefaults()
+	config := cm.config
+	config.Alerts.APIUnreachable = configDuration(time.Minute)
+
+	sender := &countingSender{}
+	alerter := NewAlerter(config, []AlertSender{sender}, []string{"test"})
+	alerter.SetLeader(&LeaderElection{}) // Another instance holds the lock
+
+	// State left from leading before, with a disconnected device and an
+	// API outage that lasts
+	now := time.Now()
+	alerter.Watch(GroupDevicesByLogicalDevice(&APIResponse{PhysicalDevices: testDevices}))
+	alerter.mu.Lock()
+	alerter.apiDown = now.Add(-time.Hour)
+	alerter.mu.Unlock()
+
+	alerter.recheck(now)
+	alerter.Close()
+
+	if sent := sender.sent.Load(); sent != 0 {
+		t.Errorf("follower sent %d alerts, want none", sent)
+	}
+}
+
+func TestAlerterLeaderRechecksAPIOutage(t *testing.T) {
+	cm := NewConfigManager()
+	cm.setDefaults()
+	config := cm.config
+	config.Alerts.APIUnreachable = configDuration(time.Minute)
+
+	sender := &countingSender{}
+	alerter := NewAlerter(config, []AlertSender{sender}, []string{"test"})
+
+	now := time.Now()
+	alerter.mu.Lock()
+	alerter.apiDown = now.Add(-time.Hour)
+	alerter.mu.Unlock()
+
+	alerter.recheck(now)
+	alerter.Close()
+
+	if sent := sender.sent.Load(); sent != 1 {
+		t.Errorf("leader sent %d alerts, want the API outage", sent)
+	}
+}
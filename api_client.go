@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/tls"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"sync"
 	"time"
 )
 
@@ -17,8 +23,20 @@ type APIClient struct {
 	base_url        string
 	devicesEndpoint string
 	loginEndpoint   string
+	streamEndpoint  string
+	authMu          sync.Mutex // Guards the session, which the stream goroutine reads
 	authCookie      *http.Cookie
 	authenticated   bool
+	sessionIssued   time.Time
+	sessionExpiry   time.Time // Zero when the API does not say
+	etag            string
+	lastModified    string
+	lastResponse    *APIResponse
+	lastTiming      *RequestTiming
+	vault           *VaultCredentials // Nil unless credentials come from Vault
+	username        string            // Used for the current session
+	audit           *AuditLog
+	recorder        *Recorder // Set by -record
 }
 
 type LoginRequest struct {
@@ -32,7 +50,11 @@ type LimitData struct {
 
 type APIError struct {
 	StatusCode int
-	Message    string
+	Code       int    // Error code from a structured error body
+	Message    string // Message from a structured error body, or the raw body
+	Details    []json.RawMessage
+	Structured bool          // The API returned a JSON error body
+	RetryAfter time.Duration // Retry-After of a 429 response
 	Endpoint   string
 }
 
@@ -40,32 +62,98 @@ func (e *APIError) Error() string {
 	return fmt.Sprintf("API error: %d %s (endpoint: %s)", e.StatusCode, e.Message, e.Endpoint)
 }
 
+// isAuthError reports whether err is the API rejecting the credentials,
+// which retrying will not fix
+func isAuthError(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
 func NewAPIClient(config *Config) *APIClient {
 	cookieJar, _ := cookiejar.New(nil)
 
+	// Already checked by validateConfig
+	tlsConfig, _ := newTLSConfig(config.TLS)
+
+	// Compression is negotiated explicitly in makeDevicesRequest
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		TLSClientConfig:    tlsConfig,
+		DisableCompression: true,
 	}
+	setTransportTimeouts(transport, config)
 
+	// No client.Timeout: requestContext bounds each request as a whole
 	client := &http.Client{
-		Timeout:   config.RequestTimeout,
 		Transport: transport,
 		Jar:       cookieJar,
 	}
 
+	var vault *VaultCredentials
+	if config.VaultPath != "" {
+		// Already checked by validateConfig
+		vault, _ = NewVaultCredentials(config.VaultPath)
+	}
+
 	loginEndpoint := config.BaseURL + "Login"
 	devicesEndpoint := config.BaseURL + "ListPhysicalDevices"
+	streamEndpoint := config.StreamEndpoint
+	if streamEndpoint == "" {
+		streamEndpoint = config.BaseURL + "SubscribePhysicalDevices"
+	}
 
 	return &APIClient{
 		client:          client,
 		config:          config,
 		loginEndpoint:   loginEndpoint,
 		devicesEndpoint: devicesEndpoint,
+		streamEndpoint:  streamEndpoint,
 		authenticated:   false,
+		vault:           vault,
 	}
 }
 
-func (ac *APIClient) Login(login, password string) error {
+// Authenticate logs in with the configured credentials and records the
+// attempt as event (AuthLogin, AuthRenew or AuthReauth) in the audit log.
+// With -vault_path the credentials are fetched from Vault on every login, so
+// rotated credentials are picked up by the next session renewal or
+// re-authentication.
+func (ac *APIClient) Authenticate(ctx context.Context, event string) (err error) {
+	username, password := ac.config.Username, ac.config.Password
+	defer func() { ac.audit.Record(event, username, ac.loginEndpoint, err) }()
+
+	if ac.vault != nil {
+		username, password, err = ac.vault.Credentials(ctx, username)
+		if err != nil {
+			return err
+		}
+	}
+
+	ac.username = username
+	return ac.Login(ctx, username, password)
+}
+
+// SetAuditLog makes Authenticate record every login attempt to audit
+func (ac *APIClient) SetAuditLog(audit *AuditLog) {
+	ac.audit = audit
+}
+
+// SetRecorder records every device list response for -record
+func (ac *APIClient) SetRecorder(recorder *Recorder) {
+	ac.recorder = recorder
+}
+
+// Username returns the user of the last login
+func (ac *APIClient) Username() string {
+	return ac.username
+}
+
+func (ac *APIClient) Login(ctx context.Context, login, password string) (err error) {
+	ctx, span := otel.StartSpan(ctx, "api.login", "endpoint", ac.loginEndpoint)
+	defer func() { span.End(err) }()
+
 	loginReq := LoginRequest{
 		Login:    login,
 		Password: password,
@@ -76,14 +164,17 @@ func (ac *APIClient) Login(login, password string) error {
 		return fmt.Errorf("failed to marshal login request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", ac.loginEndpoint, bytes.NewBuffer(jsonData))
+	ctx, cancel := ac.requestContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ac.loginEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create login request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "go-api-monitor/1.0")
+	ac.setCommonHeaders(req)
 
 	resp, err := ac.client.Do(req)
 	if err != nil {
@@ -92,50 +183,93 @@ func (ac *APIClient) Login(login, password string) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(body),
-			Endpoint:   ac.loginEndpoint,
-		}
+		return newAPIError(resp, ac.loginEndpoint)
 	}
 
 	for _, cookie := range resp.Cookies() {
 		if cookie.Name == "Authorization" || cookie.Name == "Autorization" {
+			ac.authMu.Lock()
 			ac.authCookie = cookie
 			ac.authenticated = true
-			break
+			ac.sessionIssued = time.Now()
+			ac.sessionExpiry = sessionExpiry(cookie, ac.sessionIssued)
+			ac.authMu.Unlock()
+			return nil
 		}
 	}
 
+	return fmt.Errorf("no Authorization cookie received from login response")
+}
+
+// session returns the Authorization cookie, nil when not logged in
+func (ac *APIClient) session() *http.Cookie {
+	ac.authMu.Lock()
+	defer ac.authMu.Unlock()
+
 	if !ac.authenticated {
-		return fmt.Errorf("no Authorization cookie received from login response")
+		return nil
 	}
+	return ac.authCookie
+}
 
-	return nil
+// expireSession marks the session as rejected, so the next request logs in
+// again
+func (ac *APIClient) expireSession() {
+	ac.authMu.Lock()
+	defer ac.authMu.Unlock()
+
+	ac.authenticated = false
+}
+
+// setTransportTimeouts applies the connection phase timeouts of config.
+// RequestTimeout is not one of them: a slow download of a large device list
+// is only cut off by requestContext.
+func setTransportTimeouts(transport *http.Transport, config *Config) {
+	transport.DialContext = (&net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = config.HeaderTimeout
 }
 
-func (ac *APIClient) FetchDevices() (*APIResponse, error) {
+// requestContext derives a per-request context bounded by RequestTimeout, so a
+// single call can neither outlive the caller's context nor hang indefinitely
+func (ac *APIClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ac.config.RequestTimeout > 0 {
+		return context.WithTimeout(ctx, ac.config.RequestTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (ac *APIClient) FetchDevices(ctx context.Context) (*APIResponse, error) {
 	limitata := LimitData{Limit: 10000}
 	jsonData, err := json.Marshal(limitata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal devices request: %w", err)
 	}
 
-	if !ac.authenticated {
-		return nil, fmt.Errorf("not authenticated - please login first")
+	if !ac.IsAuthenticated() {
+		// The initial login may have failed while the API was unreachable
+		if err := ac.Authenticate(ctx, AuthLogin); err != nil {
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+	} else if ac.sessionNeedsRenewal() {
+		// The current session is still valid, so a failed renewal is retried
+		// on the next poll instead of failing this one
+		ac.Authenticate(ctx, AuthRenew)
 	}
 
-	response, err := ac.makeDevicesRequest(jsonData)
+	response, err := ac.makeDevicesRequest(ctx, jsonData)
 	if err != nil {
 		if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusUnauthorized {
-			ac.authenticated = false
+			ac.expireSession()
 
-			if reAuthErr := ac.Login(ac.config.Username, ac.config.Password); reAuthErr != nil {
+			if reAuthErr := ac.Authenticate(ctx, AuthReauth); reAuthErr != nil {
 				return nil, fmt.Errorf("failed to re-authenticate: %w", reAuthErr)
 			}
 
-			response, err = ac.makeDevicesRequest(jsonData)
+			response, err = ac.makeDevicesRequest(ctx, jsonData)
 			if err != nil {
 				return nil, fmt.Errorf("failed after re-authentication: %w", err)
 			}
@@ -147,27 +281,65 @@ func (ac *APIClient) FetchDevices() (*APIResponse, error) {
 	return response, nil
 }
 
-func (ac *APIClient) makeDevicesRequest(jsonData []byte) (*APIResponse, error) {
-	req, err := http.NewRequest("POST", ac.devicesEndpoint, bytes.NewBuffer(jsonData))
+func (ac *APIClient) makeDevicesRequest(ctx context.Context, jsonData []byte) (response *APIResponse, err error) {
+	ctx, span := otel.StartSpan(ctx, "api.request", "endpoint", ac.devicesEndpoint)
+	defer func() { span.End(err) }()
+
+	ctx, cancel := ac.requestContext(ctx)
+	defer cancel()
+
+	ctx, timing := traceRequest(ctx)
+	defer func() {
+		timing.finish()
+		ac.lastTiming = timing
+		if ac.config.Debug {
+			log.Printf("debug: %s: %s", ac.devicesEndpoint, timing)
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ac.devicesEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "go-api-monitor/1.0")
+	ac.setCommonHeaders(req)
+
+	if cookie := ac.session(); cookie != nil {
+		req.AddCookie(cookie)
+	}
 
-	if ac.authCookie != nil {
-		req.AddCookie(ac.authCookie)
+	if ac.config.Gzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	// Send validators from the previous response so an unchanged list comes back as 304
+	if ac.lastResponse != nil {
+		if ac.etag != "" {
+			req.Header.Set("If-None-Match", ac.etag)
+		}
+		if ac.lastModified != "" {
+			req.Header.Set("If-Modified-Since", ac.lastModified)
+		}
 	}
 
 	resp, err := ac.client.Do(req)
 	if err != nil {
+		ac.recorder.Record(0, nil, err.Error())
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && ac.lastResponse != nil {
+		ac.recorder.Record(resp.StatusCode, nil, "")
+		cached := *ac.lastResponse
+		cached.NotModified = true
+		return &cached, nil
+	}
+
 	if resp.StatusCode == http.StatusUnauthorized {
+		ac.recorder.Record(resp.StatusCode, nil, "authentication expired")
 		return nil, &APIError{
 			StatusCode: resp.StatusCode,
 			Message:    "authentication expired",
@@ -176,42 +348,66 @@ func (ac *APIClient) makeDevicesRequest(jsonData []byte) (*APIResponse, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    string(body),
-			Endpoint:   ac.devicesEndpoint,
+		apiErr := newAPIError(resp, ac.devicesEndpoint)
+		ac.recorder.Record(resp.StatusCode, nil, apiErr.Message)
+		return nil, apiErr
+	}
+
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress response: %w", err)
 		}
+		defer gzipReader.Close()
+		reader = gzipReader
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(reader)
 	if err != nil {
+		ac.recorder.Record(0, nil, err.Error())
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
+	ac.recorder.Record(resp.StatusCode, body, "")
 
 	var apiResponse APIResponse
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
 		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
 	}
 
+	ac.etag = resp.Header.Get("ETag")
+	ac.lastModified = resp.Header.Get("Last-Modified")
+	ac.lastResponse = &apiResponse
+
 	return &apiResponse, nil
 }
 
-func (ac *APIClient) FetchDevicesWithRetry(maxRetries int) (*APIResponse, error) {
+func (ac *APIClient) FetchDevicesWithRetry(ctx context.Context, maxRetries int) (response *APIResponse, err error) {
+	ctx, span := otel.StartSpan(ctx, "api.fetch_devices")
+	defer func() { span.End(err) }()
+
 	var lastErr error
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
 			waitTime := time.Duration(attempt) * time.Second
-			time.Sleep(waitTime)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 		}
 
-		response, err := ac.FetchDevices()
+		response, err := ac.FetchDevices(ctx)
 		if err == nil {
 			return response, nil
 		}
 		lastErr = err
 
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
 			break
 		}
@@ -220,24 +416,24 @@ func (ac *APIClient) FetchDevicesWithRetry(maxRetries int) (*APIResponse, error)
 	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries+1, lastErr)
 }
 
-func (ac *APIClient) TestConnection() error {
+func (ac *APIClient) TestConnection(ctx context.Context) error {
 	limitata := LimitData{Limit: 10000}
 	jsonData, err := json.Marshal(limitata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal devices request: %w", err)
 	}
 
-	err = ac.makeTestRequest(jsonData)
+	err = ac.makeTestRequest(ctx, jsonData)
 	if err != nil {
 
 		if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusUnauthorized {
-			ac.authenticated = false
+			ac.expireSession()
 
-			if reAuthErr := ac.Login(ac.config.Username, ac.config.Password); reAuthErr != nil {
+			if reAuthErr := ac.Authenticate(ctx, AuthReauth); reAuthErr != nil {
 				return fmt.Errorf("failed to re-authenticate during test: %w", reAuthErr)
 			}
 
-			err = ac.makeTestRequest(jsonData)
+			err = ac.makeTestRequest(ctx, jsonData)
 			if err != nil {
 				return fmt.Errorf("test failed after re-authentication: %w", err)
 			}
@@ -249,23 +445,25 @@ func (ac *APIClient) TestConnection() error {
 	return nil
 }
 
-func (ac *APIClient) makeTestRequest(jsonData []byte) error {
-	req, err := http.NewRequest("POST", ac.devicesEndpoint, bytes.NewBuffer(jsonData))
+func (ac *APIClient) makeTestRequest(ctx context.Context, jsonData []byte) error {
+	ctx, cancel := ac.requestContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ac.devicesEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create test request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("User-Agent", "go-api-monitor/1.0")
+	ac.setCommonHeaders(req)
 
-	if ac.authCookie != nil {
-		req.AddCookie(ac.authCookie)
+	if cookie := ac.session(); cookie != nil {
+		req.AddCookie(cookie)
 	}
 
 	resp, err := ac.client.Do(req)
 	if err != nil {
-		fmt.Printf("\n\nerr: %v\n\n", err)
 		return fmt.Errorf("connection test failed: %w", err)
 	}
 	defer resp.Body.Close()
@@ -279,16 +477,107 @@ func (ac *APIClient) makeTestRequest(jsonData []byte) error {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return &APIError{
-			StatusCode: resp.StatusCode,
-			Message:    resp.Status,
-			Endpoint:   ac.devicesEndpoint,
-		}
+		return newAPIError(resp, ac.devicesEndpoint)
 	}
 
 	return nil
 }
 
+// SubscribeDeviceChanges opens the change-stream endpoint and sends a signal on
+// events for every change notification received. The stream is read as
+// newline-delimited JSON or server-sent events; only the fact that something
+// changed is reported, the caller is expected to refetch the device list.
+// It blocks until the stream ends, fails or ctx is cancelled.
+func (ac *APIClient) SubscribeDeviceChanges(ctx context.Context, events chan<- struct{}) error {
+	cookie := ac.session()
+	if cookie == nil {
+		return fmt.Errorf("not authenticated - please login first")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ac.streamEndpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create stream request: %w", err)
+	}
+
+	req.Header.Set("Accept", "text/event-stream, application/x-ndjson")
+	ac.setCommonHeaders(req)
+	req.AddCookie(cookie)
+
+	// The stream is long-lived, so it must not inherit the per-request timeout
+	streamClient := &http.Client{
+		Transport: ac.client.Transport,
+		Jar:       ac.client.Jar,
+	}
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to open stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp, ac.streamEndpoint)
+	}
+
+	// Report the connection itself so the caller catches up on missed changes
+	select {
+	case events <- struct{}{}:
+	default:
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		// Skip SSE keep-alives, comments and non-data fields
+		if len(line) == 0 || line[0] == ':' {
+			continue
+		}
+		if bytes.HasPrefix(line, []byte("event:")) || bytes.HasPrefix(line, []byte("id:")) ||
+			bytes.HasPrefix(line, []byte("retry:")) {
+			continue
+		}
+
+		select {
+		case events <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			// A refresh is already pending
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("stream read failed: %w", err)
+	}
+
+	return fmt.Errorf("stream closed by server")
+}
+
+// sessionNeedsRenewal reports whether the session is close enough to expiry,
+// or old enough for -session_renew, to log in again before the next request
+func (ac *APIClient) sessionNeedsRenewal() bool {
+	ac.authMu.Lock()
+	defer ac.authMu.Unlock()
+
+	renewAt, ok := sessionRenewAt(ac.sessionIssued, ac.sessionExpiry, ac.config.SessionRenew)
+	return ok && !time.Now().Before(renewAt)
+}
+
+// setCommonHeaders applies the configured User-Agent and extra headers
+func (ac *APIClient) setCommonHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", ac.config.UserAgent)
+	for name, value := range ac.config.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// LastTiming returns the phase timings of the most recent devices request,
+// or nil before the first one
+func (ac *APIClient) LastTiming() *RequestTiming {
+	return ac.lastTiming
+}
+
 func (ac *APIClient) GetEndpoint() string {
 	return ac.devicesEndpoint
 }
@@ -297,30 +586,68 @@ func (ac *APIClient) UpdateConfig(config *Config) {
 	ac.config = config
 	ac.base_url = config.BaseURL
 
-	ac.client.Timeout = config.RequestTimeout
-
 	transport := ac.client.Transport.(*http.Transport)
+	setTransportTimeouts(transport, config)
 
-	if transport.TLSClientConfig == nil {
-		transport.TLSClientConfig = &tls.Config{}
+	if tlsConfig, err := newTLSConfig(config.TLS); err == nil {
+		transport.TLSClientConfig = tlsConfig
 	}
-	transport.TLSClientConfig.InsecureSkipVerify = true
 
 }
 
+// CheckReachable sends an unauthenticated request to the base URL. Any HTTP
+// response counts, since only the connection and TLS setup are checked.
+func (ac *APIClient) CheckReachable(ctx context.Context) error {
+	ctx, cancel := ac.requestContext(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ac.config.BaseURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	ac.setCommonHeaders(req)
+
+	resp, err := ac.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// SessionExpiry returns when the current session expires, or the zero time
+// when the API does not say
+func (ac *APIClient) SessionExpiry() time.Time {
+	ac.authMu.Lock()
+	defer ac.authMu.Unlock()
+
+	return ac.sessionExpiry
+}
+
 func (ac *APIClient) IsAuthenticated() bool {
+	ac.authMu.Lock()
+	defer ac.authMu.Unlock()
+
 	return ac.authenticated
 }
 
 func (ac *APIClient) Logout() {
+	ac.authMu.Lock()
 	ac.authenticated = false
 	ac.authCookie = nil
+	ac.sessionIssued = time.Time{}
+	ac.sessionExpiry = time.Time{}
+	ac.authMu.Unlock()
+	ac.etag = ""
+	ac.lastModified = ""
+	ac.lastResponse = nil
 }
 
 func (ac *APIClient) GetStats() map[string]interface{} {
 	return map[string]interface{}{
 		"endpoint":      ac.devicesEndpoint,
 		"timeout":       ac.config.RequestTimeout,
-		"authenticated": ac.authenticated,
+		"authenticated": ac.IsAuthenticated(),
 	}
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// apiErrorBody is the JSON error body returned by the management API
+type apiErrorBody struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Details []json.RawMessage `json:"details"`
+}
+
+// newAPIError builds an APIError from a failed response. The structured error
+// body is used when the API sent one; otherwise the raw body, or the status
+// line for an empty body, becomes the message.
+func newAPIError(resp *http.Response, endpoint string) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   endpoint,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var parsed apiErrorBody
+	if err := json.Unmarshal(body, &parsed); err == nil && parsed.Message != "" {
+		apiErr.Code = parsed.Code
+		apiErr.Message = parsed.Message
+		apiErr.Details = parsed.Details
+		apiErr.Structured = true
+		return apiErr
+	}
+
+	apiErr.Message = strings.TrimSpace(string(body))
+	if apiErr.Message == "" {
+		apiErr.Message = resp.Status
+	}
+
+	return apiErr
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date. It returns 0 when the header is missing or malformed.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay.Round(time.Second)
+		}
+	}
+
+	return 0
+}
+
+// RetryAfter returns how long the API asked to wait before the next request
+// when err is a rate-limit response, and 0 otherwise
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// ErrorCategory groups poll errors by what the user can do about them
+type ErrorCategory int
+
+const (
+	ErrorUnknown ErrorCategory = iota
+	ErrorTimeout
+	ErrorRefused
+	ErrorHostNotFound
+	ErrorNetworkUnreachable
+	ErrorConnectionReset
+	ErrorCertificate
+	ErrorTLS
+	ErrorAuth
+	ErrorForbidden
+	ErrorNotFound
+	ErrorRateLimited
+	ErrorBadGateway
+	ErrorUnavailable
+	ErrorServer
+	ErrorClient
+)
+
+var errorCategoryTitles = map[ErrorCategory]string{
+	ErrorUnknown:            "Error",
+	ErrorTimeout:            "Connection timeout",
+	ErrorRefused:            "Connection refused",
+	ErrorHostNotFound:       "Host not found",
+	ErrorNetworkUnreachable: "Network unreachable",
+	ErrorConnectionReset:    "Connection reset",
+	ErrorCertificate:        "Certificate error",
+	ErrorTLS:                "TLS/SSL error",
+	ErrorAuth:               "Authentication failed",
+	ErrorForbidden:          "Access denied",
+	ErrorNotFound:           "Endpoint not found",
+	ErrorRateLimited:        "Rate limited",
+	ErrorBadGateway:         "Bad gateway",
+	ErrorUnavailable:        "Service unavailable",
+	ErrorServer:             "Server error",
+	ErrorClient:             "Request rejected",
+}
+
+func (c ErrorCategory) String() string {
+	return errorCategoryTitles[c]
+}
+
+// ClassifyError determines the category of an error returned by APIClient
+// from its type and status code
+func ClassifyError(err error) ErrorCategory {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusUnauthorized:
+			return ErrorAuth
+		case code == http.StatusForbidden:
+			return ErrorForbidden
+		case code == http.StatusNotFound:
+			return ErrorNotFound
+		case code == http.StatusTooManyRequests:
+			return ErrorRateLimited
+		case code == http.StatusBadGateway:
+			return ErrorBadGateway
+		case code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout:
+			return ErrorUnavailable
+		case code >= 500:
+			return ErrorServer
+		case code >= 400:
+			return ErrorClient
+		}
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalidCert x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var verifyErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var pinErr *PinMismatchError
+
+	switch {
+	case errors.As(err, &dnsErr):
+		return ErrorHostNotFound
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorRefused
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return ErrorNetworkUnreachable
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorConnectionReset
+	case errors.As(err, &unknownAuthority), errors.As(err, &invalidCert),
+		errors.As(err, &hostnameErr), errors.As(err, &verifyErr), errors.As(err, &pinErr):
+		return ErrorCertificate
+	case errors.As(err, &recordErr):
+		return ErrorTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTimeout
+	}
+
+	return ErrorUnknown
+}
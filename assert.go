@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Conditions accepted by -assert
+const (
+	AssertAllConnected = "all-connected"
+	AssertNoCritical   = "no-critical"
+)
+
+// CheckAssertion evaluates an -assert condition against data. The returned
+// error names the devices that violate it.
+func CheckAssertion(assertion string, data *GroupedDevices) error {
+	var failing []string
+
+	for _, group := range sortedGroups(data) {
+		for _, device := range group.PhysicalDevices {
+			var ok bool
+			switch assertion {
+			case AssertAllConnected:
+				ok = device.GetConnectionStateDisplay() == "CONNECTED"
+			case AssertNoCritical:
+				ok = device.GetHealthStatusDisplay() != "CRITICAL"
+			default:
+				return fmt.Errorf("unknown assertion %q", assertion)
+			}
+
+			if !ok {
+				failing = append(failing, fmt.Sprintf("%s/%s", group.LogicalDevice.Name, device.Name))
+			}
+		}
+	}
+
+	if len(failing) > 0 {
+		return fmt.Errorf("assertion %s failed for %d of %d devices: %s",
+			assertion, len(failing), data.TotalDevices, strings.Join(failing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Asset is one row of the asset inventory written by export inventory, for
+// reconciling a CMDB with what the management API knows
+type Asset struct {
+	Name            string `json:"name"`
+	SerialNumber    string `json:"serial_number"`
+	Model           string `json:"model"`
+	SoftwareVersion string `json:"software_version"`
+	ProductVersion  string `json:"product_version"`
+	Address         string `json:"address"`
+	LogicalDevice   string `json:"logical_device"`
+	ID              string `json:"id"`
+}
+
+// assetColumns are the CSV columns of export inventory, in Asset's order
+var assetColumns = []string{
+	"name", "serial_number", "model", "software_version", "product_version", "address", "logical_device", "id",
+}
+
+// Assets lists the devices of data as assets, sorted by logical device. The
+// MISSING rows of the expected inventory are no assets and left out.
+func Assets(data *GroupedDevices) []Asset {
+	assets := []Asset{}
+	for _, group := range sortedGroups(data) {
+		for _, device := range group.PhysicalDevices {
+			if device.Inventory == InventoryMissing {
+				continue
+			}
+			assets = append(assets, Asset{
+				Name:            device.Name,
+				SerialNumber:    device.SerialNumber,
+				Model:           device.Model,
+				SoftwareVersion: device.SoftwareVersion,
+				ProductVersion:  device.ProductVersion,
+				Address:         device.Address,
+				LogicalDevice:   group.LogicalDevice.Name,
+				ID:              device.ID,
+			})
+		}
+	}
+	return assets
+}
+
+// WriteAssets writes the asset inventory of data as csv or json
+func WriteAssets(w io.Writer, format string, data *GroupedDevices) error {
+	assets := Assets(data)
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(assets)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(assetColumns); err != nil {
+			return err
+		}
+		for _, asset := range assets {
+			row := []string{
+				asset.Name, asset.SerialNumber, asset.Model, asset.SoftwareVersion,
+				asset.ProductVersion, asset.Address, asset.LogicalDevice, asset.ID,
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// runExportInventory writes the asset inventory once
+func (app *Application) runExportInventory() error {
+	format, err := app.oneShotFormat("csv", "csv", "json")
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if err := app.apiClient.Authenticate(ctx, AuthLogin); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	grouped, err := app.fetchForReport(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch devices: %w", err)
+	}
+
+	out, closeOutput, err := app.createOutput()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	if err := WriteAssets(out, format, grouped); err != nil {
+		return fmt.Errorf("failed to write inventory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Authentication events recorded by AuditLog
+const (
+	AuthLogin  = "login"          // First login of the process or command
+	AuthRenew  = "renew"          // Session renewed before it expired
+	AuthReauth = "reauthenticate" // Login after the API rejected the session
+)
+
+// AuditRecord is one authentication attempt, written as a JSON line to the
+// audit file
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Result   string    `json:"result"` // success or failure
+	User     string    `json:"user"`
+	Endpoint string    `json:"endpoint"`
+	Status   int       `json:"status,omitempty"` // HTTP status of a rejected login
+	Error    string    `json:"error,omitempty"`
+}
+
+// AuditLog records authentication events to the log, when it does not share
+// the terminal with the TUI, and to the -audit_log file. A nil *AuditLog
+// records nothing.
+type AuditLog struct {
+	mu    sync.Mutex
+	file  *os.File
+	toLog bool
+}
+
+// NewAuditLog opens the audit file of config, if any
+func NewAuditLog(config *Config) (*AuditLog, error) {
+	audit := &AuditLog{toLog: config.Daemon || config.LogFile != ""}
+
+	if config.AuditLog != "" {
+		file, err := os.OpenFile(config.AuditLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		audit.file = file
+	}
+
+	return audit, nil
+}
+
+// Record writes the outcome of an authentication attempt
+func (a *AuditLog) Record(event, user, endpoint string, err error) {
+	if a == nil {
+		return
+	}
+
+	record := AuditRecord{
+		Time:     time.Now(),
+		Event:    event,
+		Result:   "success",
+		User:     user,
+		Endpoint: endpoint,
+	}
+	if err != nil {
+		record.Result = "failure"
+		record.Error = err.Error()
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			record.Status = apiErr.StatusCode
+		}
+	}
+
+	if a.toLog {
+		if err != nil {
+			log.Printf("audit: event=%s result=%s user=%q endpoint=%s error=%q", record.Event, record.Result, record.User, record.Endpoint, record.Error)
+		} else {
+			log.Printf("audit: event=%s result=%s user=%q endpoint=%s", record.Event, record.Result, record.User, record.Endpoint)
+		}
+	}
+
+	if a.file != nil {
+		line, _ := json.Marshal(record)
+
+		a.mu.Lock()
+		a.file.Write(append(line, '\n'))
+		a.mu.Unlock()
+	}
+}
+
+// Close closes the audit file
+func (a *AuditLog) Close() {
+	if a != nil && a.file != nil {
+		a.file.Close()
+	}
+}
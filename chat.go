@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ChatConfig configures alerts to a chat channel's incoming webhook
+type ChatConfig struct {
+	WebhookURL string           `json:"webhook_url"`
+	Templates  MessageTemplates `json:"templates"`
+}
+
+// SlackSender posts alerts to a Slack incoming webhook as Block Kit messages
+type SlackSender struct {
+	url    string
+	format *messageFormat
+	client *http.Client
+}
+
+// TeamsSender posts alerts to a Microsoft Teams incoming webhook as
+// MessageCards
+type TeamsSender struct {
+	url    string
+	format *messageFormat
+	client *http.Client
+}
+
+func init() {
+	registerAlertSender("slack", func(config *Config) (AlertSender, error) {
+		slack := config.Alerts.Slack
+		if slack.WebhookURL == "" {
+			return nil, nil
+		}
+		format, err := loadMessageFormat("slack", slack.Templates, config.Alerts.Templates)
+		if err != nil {
+			return nil, err
+		}
+		return &SlackSender{url: slack.WebhookURL, format: format, client: &http.Client{Timeout: webhookTimeout}}, nil
+	})
+	registerAlertSender("teams", func(config *Config) (AlertSender, error) {
+		teams := config.Alerts.Teams
+		if teams.WebhookURL == "" {
+			return nil, nil
+		}
+		format, err := loadMessageFormat("teams", teams.Templates, config.Alerts.Templates)
+		if err != nil {
+			return nil, err
+		}
+		return &TeamsSender{url: teams.WebhookURL, format: format, client: &http.Client{Timeout: webhookTimeout}}, nil
+	})
+}
+
+// alertColor is the hex color of an alert in chat messages, as in the HTML
+// report: red for critical, amber for warnings, green once resolved
+func alertColor(alert Alert) string {
+	switch {
+	case alert.Status == AlertResolved:
+		return "1A7F37"
+	case alert.Severity == "critical":
+		return "CF222E"
+	default:
+		return "B58100"
+	}
+}
+
+// alertTitle is the headline of an alert, e.g. "FIRING: fw-b (cluster-1) is
+// disconnected"
+func alertTitle(alert Alert) string {
+	return strings.ToUpper(alert.Status) + ": " + strings.TrimSuffix(alert.Summary, " (resolved)")
+}
+
+// alertFacts are the details shown under the title
+func alertFacts(alert Alert) [][2]string {
+	facts := [][2]string{{"Device", alert.DeviceName}}
+	if alert.LogicalDevice != "" {
+		facts = append(facts, [2]string{"Logical device", alert.LogicalDevice})
+	}
+	if alert.Address != "" {
+		facts = append(facts, [2]string{"Address", alert.Address})
+	}
+	facts = append(facts,
+		[2]string{"Rule", alert.Rule},
+		[2]string{"Since", formatTime(alert.Since, "2006-01-02 15:04:05")},
+	)
+	if alert.Status == AlertResolved {
+		facts = append(facts, [2]string{"Resolved", formatTime(alert.Time, "2006-01-02 15:04:05")})
+	}
+	return facts
+}
+
+func (ss *SlackSender) Send(alert Alert) error {
+	title := ss.format.Title(alert, alertTitle(alert))
+
+	var fields []string
+	for _, fact := range alertFacts(alert) {
+		fields = append(fields, fmt.Sprintf("*%s:* %s", fact[0], fact[1]))
+	}
+	details := map[string]any{
+		"type":     "context",
+		"elements": []map[string]string{{"type": "mrkdwn", "text": strings.Join(fields, "  ·  ")}},
+	}
+	if ss.format.HasBody() {
+		details = map[string]any{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": ss.format.Body(alert, strings.Join(fields, "\n"))},
+		}
+	}
+
+	blocks := []any{
+		map[string]any{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": "*" + title + "*"},
+		},
+		details,
+	}
+	if alert.URL != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "actions",
+			"elements": []map[string]any{{
+				"type": "button",
+				"text": map[string]string{"type": "plain_text", "text": "Open in management UI"},
+				"url":  alert.URL,
+			}},
+		})
+	}
+
+	// Blocks inside an attachment get the colored side bar
+	message := map[string]any{
+		"text": title,
+		"attachments": []map[string]any{{
+			"color":  "#" + alertColor(alert),
+			"blocks": blocks,
+		}},
+	}
+	return postJSON(ss.client, ss.url, nil, message)
+}
+
+func (ts *TeamsSender) Send(alert Alert) error {
+	title := ts.format.Title(alert, alertTitle(alert))
+
+	var facts []map[string]string
+	for _, fact := range alertFacts(alert) {
+		facts = append(facts, map[string]string{"name": fact[0], "value": fact[1]})
+	}
+	section := map[string]any{"facts": facts}
+	if ts.format.HasBody() {
+		section = map[string]any{"text": ts.format.Body(alert, "")}
+	}
+
+	card := map[string]any{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"themeColor": alertColor(alert),
+		"summary":    title,
+		"title":      title,
+		"sections":   []map[string]any{section},
+	}
+	if alert.URL != "" {
+		card["potentialAction"] = []map[string]any{{
+			"@type":   "OpenUri",
+			"name":    "Open in management UI",
+			"targets": []map[string]string{{"os": "default", "uri": alert.URL}},
+		}}
+	}
+	return postJSON(ts.client, ts.url, nil, card)
+}
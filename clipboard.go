@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// clipboardHelpers are the clipboard programs tried, in order, in a local
+// session
+var clipboardHelpers = [][]string{
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"pbcopy"},
+	{"clip.exe"},
+}
+
+// CopyToClipboard puts text on the system clipboard and returns how it got
+// there. A local session uses the first clipboard helper that works. Over
+// SSH, or without a helper, the terminal is asked to copy it with OSC 52,
+// which most modern terminal emulators support.
+func (dm *DisplayManager) CopyToClipboard(text string) string {
+	if os.Getenv("SSH_CONNECTION") == "" {
+		for _, helper := range clipboardHelpers {
+			if _, err := exec.LookPath(helper[0]); err != nil {
+				continue
+			}
+
+			cmd := exec.Command(helper[0], helper[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			if cmd.Run() == nil {
+				return helper[0]
+			}
+		}
+	}
+
+	if dm.screen != nil {
+		dm.screen.SetClipboard([]byte(text))
+	}
+	return "OSC 52"
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Sync link states of a ClusterSummary
+const (
+	SyncLinkUp            = "UP"
+	SyncLinkDown          = "DOWN"
+	SyncLinkNotConfigured = "NOT CONFIGURED"
+)
+
+// ClusterSummary is the HA state of a cluster group at a glance, so the
+// operator does not have to work it out from the node rows
+type ClusterSummary struct {
+	Active           *PhysicalDevice // The ACTIVE node, nil without one
+	ActiveConnected  bool
+	Standby          int // STANDBY nodes
+	StandbyConnected int // Connected and not suspended, so ready to take over
+	StandbySuspended int
+	SyncLink         string // SyncLinkUp, SyncLinkDown or SyncLinkNotConfigured
+}
+
+// ClusterSummary summarizes the roles and connection states of the nodes of
+// a cluster group. The API reports no sync link state, so the link counts as
+// UP while the ACTIVE node and a STANDBY node, with their sync link
+// addresses configured, are both connected.
+func (g *LogicalDeviceGroup) ClusterSummary() ClusterSummary {
+	summary := ClusterSummary{SyncLink: SyncLinkNotConfigured}
+	configured, standbyUp := false, false
+	for i := range g.PhysicalDevices {
+		device := &g.PhysicalDevices[i]
+		if device.AsNode == nil {
+			continue
+		}
+		configured = configured || device.AsNode.SyncLinkIP != ""
+
+		connected := device.GetConnectionStateDisplay() == "CONNECTED"
+		switch device.GetRoleDisplay() {
+		case "ACTIVE":
+			if summary.Active == nil || (connected && !summary.ActiveConnected) {
+				summary.Active = device
+				summary.ActiveConnected = connected
+			}
+		case "STANDBY":
+			summary.Standby++
+			standbyUp = standbyUp || connected
+			switch {
+			case device.Suspended():
+				summary.StandbySuspended++
+			case connected:
+				summary.StandbyConnected++
+			}
+		}
+	}
+
+	if configured {
+		summary.SyncLink = SyncLinkDown
+		if summary.ActiveConnected && standbyUp {
+			summary.SyncLink = SyncLinkUp
+		}
+	}
+	return summary
+}
+
+// Degraded reports whether the cluster lacks a connected ACTIVE node, a
+// connected STANDBY node to fail over to (a suspended one does not count),
+// or a working sync link
+func (s ClusterSummary) Degraded() bool {
+	return !s.ActiveConnected || s.StandbyConnected == 0 || s.SyncLink == SyncLinkDown
+}
+
+// String describes the summary, e.g. "ACTIVE: fw-a │ STANDBY: 1/2 connected │
+// SYNC LINK: UP"
+func (s ClusterSummary) String() string {
+	active := "none"
+	switch {
+	case s.Active != nil && s.ActiveConnected:
+		active = s.Active.Name
+	case s.Active != nil:
+		active = fmt.Sprintf("%s (%s)", s.Active.Name, s.Active.GetConnectionStateDisplay())
+	}
+
+	standby := fmt.Sprintf("STANDBY: %d/%d connected", s.StandbyConnected, s.Standby)
+	if s.StandbySuspended > 0 {
+		standby += fmt.Sprintf(" (%d suspended)", s.StandbySuspended)
+	}
+	parts := []string{"ACTIVE: " + active, standby, "SYNC LINK: " + s.SyncLink}
+	return strings.Join(parts, " │ ")
+}
+
+// renderClusterSummary draws the summary line under a cluster's header, red
+// while the cluster is degraded, and a line for each role anomaly, yellow for
+// priority misconfigurations
+func (dm *DisplayManager) renderClusterSummary(group *LogicalDeviceGroup) {
+	summary := group.ClusterSummary()
+	color := dm.getColor(ColorGreen)
+	state := "OK"
+	if summary.Degraded() {
+		color = dm.getColor(ColorRed) + dm.getColor(ColorBold)
+		state = "DEGRADED"
+	}
+
+	lines := []string{fmt.Sprintf("  %sCLUSTER %s%s  %s", color, state, dm.getColor(ColorReset), summary)}
+	for _, anomaly := range group.RoleAnomalies() {
+		color := dm.getColor(ColorRed)
+		if anomaly.Warning() {
+			color = dm.getColor(ColorYellow)
+		}
+		lines = append(lines, fmt.Sprintf("  %s%s!! %s%s  %s", color, dm.getColor(ColorBold),
+			anomaly.Title, dm.getColor(ColorReset), anomaly.Summary))
+	}
+
+	for _, text := range lines {
+		text = truncateString(text, max(0, dm.termWidth-4))
+		dm.printLine(fmt.Sprintf("│ %s%s │", text, strings.Repeat(" ", max(0, dm.termWidth-displayWidth(text)-4))))
+	}
+}
+
+// Role anomalies of a cluster, named after their alert rules
+const (
+	AnomalySplitBrain        = "split_brain"        // More than one connected ACTIVE node
+	AnomalyNoActive          = "no_active"          // No connected ACTIVE node
+	AnomalyPriorityConflict  = "priority_conflict"  // Nodes sharing a priority
+	AnomalyPriorityInversion = "priority_inversion" // A healthy STANDBY node has a higher priority than the ACTIVE one
+)
+
+// RoleAnomaly is a dangerous HA state of a cluster
+type RoleAnomaly struct {
+	Rule    string // One of the Anomaly constants
+	Title   string // e.g. SPLIT BRAIN
+	Summary string
+	Devices []string // IDs of the nodes involved
+}
+
+// Warning reports whether the anomaly is a priority misconfiguration, which
+// is worth knowing about but does not break the cluster by itself
+func (a RoleAnomaly) Warning() bool {
+	return a.Rule == AnomalyPriorityConflict || a.Rule == AnomalyPriorityInversion
+}
+
+// RoleAnomalies returns the dangerous HA states of a cluster group: two
+// ACTIVE nodes, none, nodes with the same priority, which leaves the
+// election to chance, or an ACTIVE node with a lower priority than a
+// healthy STANDBY node, after a failover without preemption or from a
+// misconfiguration. The lower number is the higher priority. Only connected
+// nodes count as ACTIVE, since a disconnected node may still report the role
+// it had before a failover.
+func (g *LogicalDeviceGroup) RoleAnomalies() []RoleAnomaly {
+	if !g.IsCluster {
+		return nil
+	}
+
+	var active, standby []*PhysicalDevice
+	priorities := make(map[int][]*PhysicalDevice)
+	for i := range g.PhysicalDevices {
+		device := &g.PhysicalDevices[i]
+		if device.AsNode == nil {
+			continue
+		}
+		priorities[device.AsNode.Priority] = append(priorities[device.AsNode.Priority], device)
+		if device.GetConnectionStateDisplay() != "CONNECTED" {
+			continue
+		}
+		switch device.GetRoleDisplay() {
+		case "ACTIVE":
+			active = append(active, device)
+		case "STANDBY":
+			// Only a node that could take over makes the priorities matter
+			if !device.Suspended() && device.GetHealthStatusDisplay() != "WARNING" && device.GetHealthStatusDisplay() != "CRITICAL" {
+				standby = append(standby, device)
+			}
+		}
+	}
+
+	var anomalies []RoleAnomaly
+	switch {
+	case len(active) > 1:
+		anomalies = append(anomalies, RoleAnomaly{AnomalySplitBrain, "SPLIT BRAIN",
+			fmt.Sprintf("%s are all ACTIVE", nodeNames(active)), nodeIDs(active)})
+	case len(active) == 0:
+		anomalies = append(anomalies, RoleAnomaly{AnomalyNoActive, "NO ACTIVE NODE",
+			"no connected node is ACTIVE", nil})
+	default:
+		for _, node := range standby {
+			if node.AsNode.Priority < active[0].AsNode.Priority {
+				anomalies = append(anomalies, RoleAnomaly{AnomalyPriorityInversion, "PRIORITY INVERSION",
+					fmt.Sprintf("ACTIVE %s has priority %d, healthy STANDBY %s priority %d",
+						active[0].Name, active[0].AsNode.Priority, node.Name, node.AsNode.Priority),
+					[]string{active[0].ID, node.ID}})
+			}
+		}
+	}
+
+	var conflicts []int
+	for priority, nodes := range priorities {
+		if len(nodes) > 1 {
+			conflicts = append(conflicts, priority)
+		}
+	}
+	slices.Sort(conflicts)
+	for _, priority := range conflicts {
+		nodes := priorities[priority]
+		anomalies = append(anomalies, RoleAnomaly{AnomalyPriorityConflict, "PRIORITY CONFLICT",
+			fmt.Sprintf("%s share priority %d", nodeNames(nodes), priority), nodeIDs(nodes)})
+	}
+	return anomalies
+}
+
+// priorityWarnings returns the IDs of the nodes of group whose priority is
+// part of a misconfiguration, to mark them in the priority column
+func priorityWarnings(group *LogicalDeviceGroup) map[string]bool {
+	warned := make(map[string]bool)
+	for _, anomaly := range group.RoleAnomalies() {
+		if anomaly.Warning() {
+			for _, id := range anomaly.Devices {
+				warned[id] = true
+			}
+		}
+	}
+	return warned
+}
+
+func nodeNames(nodes []*PhysicalDevice) string {
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func nodeIDs(nodes []*PhysicalDevice) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return ids
+}
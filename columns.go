@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Column describes a device table column shared by the TUI and the report formats
+type Column struct {
+	Key       string
+	Title     string
+	BaseWidth int     // Minimum width in the TUI
+	Weight    float64 // Share of extra terminal width the column grows by
+	Value     func(device *PhysicalDevice) string
+}
+
+// availableColumns lists every column that can be selected with -columns
+var availableColumns = []Column{
+	{"name", "Device Name", 25, 0.2, func(d *PhysicalDevice) string { return d.Name }},
+	{"model", "Model", 15, 0.1, func(d *PhysicalDevice) string { return d.Model }},
+	{"status", "Status", 15, 0.1, func(d *PhysicalDevice) string {
+		if d.Flapping {
+			return "FLAPPING"
+		}
+		return d.GetConnectionStateDisplay()
+	}},
+	{"address", "Address", 12, 0.2, func(d *PhysicalDevice) string { return d.Address }},
+	{"reachable", "Reachable", 12, 0.05, func(d *PhysicalDevice) string { return d.GetReachableDisplay() }},
+	// Fixed width: the newest times are on the right, where truncation cuts
+	{"trend", "RTT Trend", probeHistoryLen, 0, func(d *PhysicalDevice) string {
+		if d.Probe == nil {
+			return "-"
+		}
+		return sparkline(d.Probe.History)
+	}},
+	{"priority", "Priority", 13, 0.1, func(d *PhysicalDevice) string {
+		if d.AsNode == nil {
+			return "-"
+		}
+		return strconv.Itoa(d.AsNode.Priority)
+	}},
+	{"version", "Version", 8, 0.3, func(d *PhysicalDevice) string { return d.GetProductVersionDisplay() }},
+	{"software_version", "Software", 8, 0.3, func(d *PhysicalDevice) string { return d.GetSoftwareVersionDisplay() }},
+	{"role", "Role", 8, 0.05, func(d *PhysicalDevice) string { return d.GetRoleDisplay() }},
+	{"suspend", "Suspend", 8, 0.05, func(d *PhysicalDevice) string {
+		if d.AsNode == nil {
+			return "-"
+		}
+		return d.GetSuspendModeDisplay()
+	}},
+	{"sync_link", "Sync Link", 16, 0.1, func(d *PhysicalDevice) string { return d.GetSyncLinkDisplay() }},
+	{"serial", "Serial Number", 14, 0.1, func(d *PhysicalDevice) string { return d.SerialNumber }},
+	{"labels", "Labels", 16, 0.2, func(d *PhysicalDevice) string { return d.GetLabelsDisplay() }},
+	{"description", "Description", 16, 0.3, func(d *PhysicalDevice) string { return d.Description }},
+	{"note", "Note", 16, 0.3, func(d *PhysicalDevice) string {
+		if d.Note == nil {
+			return ""
+		}
+		return d.Note.Text
+	}},
+	{"health", "Health", 10, 0.05, func(d *PhysicalDevice) string { return d.GetHealthStatusDisplay() }},
+	{"last_connected", "Last Connected", 16, 0.05, func(d *PhysicalDevice) string { return d.GetLastConnectedDisplay() }},
+}
+
+// defaultColumns is the column set shown when -columns is not given
+const defaultColumns = "name,model,status,address,priority,version"
+
+// ParseColumns resolves a comma-separated list of column keys
+func ParseColumns(spec string) ([]Column, error) {
+	var columns []Column
+
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+
+		column, ok := findColumn(key)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (available: %s)", key, columnKeys())
+		}
+		columns = append(columns, column)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("at least one column is required")
+	}
+
+	return columns, nil
+}
+
+func findColumn(key string) (Column, bool) {
+	for _, column := range availableColumns {
+		if column.Key == key {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+func columnKeys() string {
+	keys := make([]string, len(availableColumns))
+	for i, column := range availableColumns {
+		keys[i] = column.Key
+	}
+	return strings.Join(keys, ", ")
+}
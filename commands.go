@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// command is a subcommand of the CLI. All commands share the global flags,
+// which may be given before or after the command name.
+type command struct {
+	name    string
+	summary string
+	run     func(app *Application) error
+}
+
+// defaultCommand runs when no command is given
+const defaultCommand = "monitor"
+
+var commands = []command{
+	{"monitor", "Show the live device table (default)", (*Application).runMonitor},
+	{"list", "Print the device list once as a text table", (*Application).runList},
+	{"check", "Check the devices once and exit non-zero on failure (-assert, default all-connected)", (*Application).runCheck},
+	{"export", "Write the device list as csv or json (-output, default csv)", (*Application).runExport},
+	{"export inventory", "Write the asset list (name, serial, model, versions, address) as csv or json", (*Application).runExportInventory},
+	{"report", "Write an html or markdown status report (-output, default html)", (*Application).runStatusReport},
+	{"report sla", "Write device availability from -history_file between -from and -to as text, csv or html", (*Application).runSLAReport},
+	{"login", "Log in with the configured credentials and exit", (*Application).runLogin},
+	{"config validate", "Check the configuration and exit", (*Application).runConfigValidate},
+	{"mockserver", "Serve a mock management API (-fixture, -mock_listen) for tests and demos", (*Application).runMockServer},
+}
+
+// findCommand returns the command with the given name, or nil
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// commandUsage lists the commands for printUsage
+func commandUsage() string {
+	var b strings.Builder
+	for _, cmd := range commands {
+		fmt.Fprintf(&b, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	return b.String()
+}
+
+// oneShotFormat picks the -output format for a one-shot command: fallback
+// when -output was left at tui, otherwise one of allowed
+func (app *Application) oneShotFormat(fallback string, allowed ...string) (string, error) {
+	format := app.config.OutputFormat
+	if format == "tui" {
+		return fallback, nil
+	}
+	if !slices.Contains(allowed, format) {
+		return "", fmt.Errorf("%s does not support -output %s (use %s)", app.config.Command, format, strings.Join(allowed, " or "))
+	}
+	return format, nil
+}
+
+func (app *Application) runList() error {
+	format, err := app.oneShotFormat("text", "text")
+	if err != nil {
+		return err
+	}
+	return app.runOnce(format)
+}
+
+func (app *Application) runCheck() error {
+	if app.config.Assert == "" {
+		app.config.Assert = AssertAllConnected
+	}
+	return app.runOnce("tui")
+}
+
+func (app *Application) runExport() error {
+	format, err := app.oneShotFormat("csv", "csv", "json")
+	if err != nil {
+		return err
+	}
+	return app.runOnce(format)
+}
+
+func (app *Application) runStatusReport() error {
+	format, err := app.oneShotFormat("html", "html", "markdown")
+	if err != nil {
+		return err
+	}
+	return app.runOnce(format)
+}
+
+// runLogin checks the credentials against the API without fetching devices
+func (app *Application) runLogin() error {
+	ctx := context.Background()
+
+	if err := app.apiClient.Authenticate(ctx, AuthLogin); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	fmt.Printf("Logged in to %s as %s", app.config.BaseURL, app.apiClient.Username())
+	if expiry := app.apiClient.SessionExpiry(); !expiry.IsZero() {
+		fmt.Printf(", session valid until %s", formatTime(expiry, time.RFC3339))
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// runConfigValidate reports every problem of the configuration and checks
+// that the API answers at the base URL, without logging in
+func (app *Application) runConfigValidate() error {
+	var problems []string
+	if app.configErr != nil {
+		problems = app.configErr.Problems
+	}
+
+	// Probing needs a usable URL and TLS settings
+	_, tlsErr := newTLSConfig(app.config.TLS)
+	if checkBaseURL(app.config.BaseURL) == nil && tlsErr == nil {
+		apiClient := app.apiClient
+		if apiClient == nil {
+			apiClient = NewAPIClient(app.config)
+		}
+		if err := apiClient.CheckReachable(context.Background()); err != nil {
+			problems = append(problems, fmt.Sprintf("%s is not reachable: %v", app.config.BaseURL, err))
+		}
+	}
+
+	if len(problems) == 0 {
+		fmt.Println("Configuration OK")
+		return nil
+	}
+
+	fmt.Printf("Configuration has %d problem(s):\n", len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+	return fmt.Errorf("invalid configuration")
+}
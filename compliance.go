@@ -0,0 +1,87 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ApplyTargetVersion marks the devices of response whose product version is
+// below -target_version as outdated. Devices that report no version are not
+// marked: nothing is known about them.
+func ApplyTargetVersion(response *APIResponse, config *Config) {
+	if config.TargetVersion == "" {
+		return
+	}
+	for i := range response.PhysicalDevices {
+		device := &response.PhysicalDevices[i]
+		device.Outdated = device.ProductVersion != "" && compareVersions(device.ProductVersion, config.TargetVersion) < 0
+	}
+}
+
+// checkTargetVersion rejects a -target_version without a number to compare
+func checkTargetVersion(version string) error {
+	if version != "" && !strings.ContainsFunc(version, unicode.IsDigit) {
+		return fmt.Errorf("target version %q has no version number (e.g. R81.20 or 11.1.2)", version)
+	}
+	return nil
+}
+
+// compareVersions orders versions such as R81.10 < R81.20 or 11.1.2 < 11.10.0
+// by their runs of digits as numbers and the text between them as is
+func compareVersions(a, b string) int {
+	partsA, partsB := versionParts(a), versionParts(b)
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		x, errX := strconv.Atoi(partsA[i])
+		y, errY := strconv.Atoi(partsB[i])
+		order := 0
+		if errX == nil && errY == nil {
+			order = cmp.Compare(x, y)
+		} else {
+			order = strings.Compare(strings.ToLower(partsA[i]), strings.ToLower(partsB[i]))
+		}
+		if order != 0 {
+			return order
+		}
+	}
+	return cmp.Compare(len(partsA), len(partsB))
+}
+
+// versionParts splits a version into runs of digits and of other characters
+func versionParts(version string) []string {
+	var parts []string
+	start := 0
+	for i, r := range version {
+		if i > start && unicode.IsDigit(r) != unicode.IsDigit(rune(version[i-1])) {
+			parts = append(parts, version[start:i])
+			start = i
+		}
+	}
+	if start < len(version) {
+		parts = append(parts, version[start:])
+	}
+	return parts
+}
+
+// countOutdated returns how many devices of data are below -target_version
+func countOutdated(data *GroupedDevices) int {
+	outdated := 0
+	for _, group := range data.LogicalDeviceGroups {
+		for i := range group.PhysicalDevices {
+			if group.PhysicalDevices[i].Outdated {
+				outdated++
+			}
+		}
+	}
+	return outdated
+}
+
+// outdatedState is OUTDATED or CURRENT, for the outdated alert rule
+func outdatedState(device *PhysicalDevice) string {
+	if device.Outdated {
+		return "OUTDATED"
+	}
+	return "CURRENT"
+}
@@ -3,14 +3,34 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type ConfigManager struct {
-	config *Config
+	config      *Config
+	problems    []string // Found while reading the environment, reported by validateConfig
+	warnings    []string // Logged by LoadConfig; the configuration is still used
+	envRead     map[string]bool
+	snapshots   []configSnapshot
+	printConfig bool
+}
+
+// ValidationError lists every problem found in the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
 }
 
 // durationValue is a custom flag type that accepts both duration strings and plain numbers (seconds)
@@ -47,6 +67,36 @@ func newDurationValue(val time.Duration, p *time.Duration) *durationValue {
 	return &durationValue{value: p}
 }
 
+// headerValue is a repeatable flag that adds "Name: value" request headers
+type headerValue struct {
+	headers *map[string]string
+}
+
+func (h *headerValue) String() string {
+	if h.headers == nil {
+		return ""
+	}
+	var pairs []string
+	for name, value := range *h.headers {
+		pairs = append(pairs, name+": "+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+func (h *headerValue) Set(s string) error {
+	name, value, ok := strings.Cut(s, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("invalid header: %s (use 'Name: value')", s)
+	}
+
+	if *h.headers == nil {
+		*h.headers = make(map[string]string)
+	}
+	(*h.headers)[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	return nil
+}
+
 // NewConfigManager creates a new configuration manager
 func NewConfigManager() *ConfigManager {
 	return &ConfigManager{
@@ -54,19 +104,52 @@ func NewConfigManager() *ConfigManager {
 	}
 }
 
-// LoadConfig loads configuration from command line flags and environment variables
+// LoadConfig loads configuration from environment variables, the config
+// file and command line flags, each overriding the ones before
 func (cm *ConfigManager) LoadConfig() (*Config, error) {
 	// Set default values
 	cm.setDefaults()
+	cm.snapshot("default")
 
-	// Parse environment variables first
+	// Environment variables override defaults only
 	cm.parseEnvironmentVariables()
+	cm.checkUnusedEnv()
+	cm.snapshot("env")
+
+	// The config file overrides environment variables. A broken file is
+	// reported with the other problems by validateConfig.
+	if path := configFilePath(); path != "" {
+		if err := cm.loadConfigFile(path); err != nil {
+			cm.problems = append(cm.problems, err.Error())
+		}
+		cm.config.ConfigFile = path
+	}
+	cm.snapshot("file")
 
-	// Parse command line flags (these override environment variables)
+	// Parse command line flags (these override everything else)
 	cm.parseCommandLineFlags()
+	cm.snapshot("flag")
+
+	// Secrets mounted as files replace the plain values
+	cm.loadSecretFiles()
+	cm.snapshot("secret file")
 
 	// Validate configuration
-	if err := cm.validateConfig(); err != nil {
+	err := cm.validateConfig()
+	for _, warning := range cm.warnings {
+		log.Printf("warning: %s", warning)
+	}
+
+	if cm.printConfig {
+		cm.PrintConfig(os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration validation failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
@@ -78,111 +161,595 @@ func (cm *ConfigManager) setDefaults() {
 	cm.config.BaseURL = ""
 	// cm.config.APIEndpoint = "ListPhysicalDevices"
 	cm.config.PollInterval = 5 * time.Second
-	cm.config.RequestTimeout = 1 * time.Second
+	cm.config.MaxPollInterval = 1 * time.Minute
+	cm.config.PollJitter = 0
+	cm.config.RenderInterval = 1 * time.Second
+	cm.config.SnapshotDir = "."
+	cm.config.SnapshotFormat = "json"
+	cm.config.OutputFormat = "tui"
+	cm.config.OutputFile = ""
+	cm.config.ColumnSpec = defaultColumns
+	cm.config.WebListen = ""
+	cm.config.WebUsername = "admin"
+	// Large device lists over a WAN take seconds to download; the phases
+	// before the response fail fast
+	cm.config.RequestTimeout = 30 * time.Second
+	cm.config.DialTimeout = 5 * time.Second
+	cm.config.TLSHandshakeTimeout = 5 * time.Second
+	cm.config.HeaderTimeout = 10 * time.Second
 	cm.config.ShowTimestamp = true
 	cm.config.ColorOutput = true
+	cm.config.StatusIndicators = "color"
+	cm.config.GroupOrder = "name"
 	cm.config.Username = "admin"
 	cm.config.Password = "admin"
+	cm.config.StreamEnabled = false
+	cm.config.StreamEndpoint = ""
+	cm.config.Gzip = true
+	cm.config.UserAgent = "go-api-monitor/" + shortVersion()
+	cm.config.SSHCommand = defaultSSHCommand
+	cm.config.NotesFile = defaultNotesFile()
+	cm.config.StateFile = defaultStateFile()
+	cm.config.SSHUser = localUsername()
+	cm.config.Alerts.Cooldown = configDuration(defaultAlertCooldown)
+	cm.config.Alerts.APIUnreachable = configDuration(defaultAPIUnreachable)
+	cm.config.FlapThreshold = 3
+	cm.config.FlapWindow = 10 * time.Minute
+	cm.config.LeaderLease = 30 * time.Second
+	cm.config.PollLogMaxSize = defaultPollLogMaxSize
+	cm.config.ReplaySpeed = 1
+	cm.config.MockListen = "127.0.0.1:8080"
 }
 
-// parseEnvironmentVariables reads configuration from environment variables
+// parseEnvironmentVariables reads configuration from environment variables:
+// the OpenTelemetry ones and a PT_<KEY> variable for every setting, see
+// parseConfigEnvironment
 func (cm *ConfigManager) parseEnvironmentVariables() {
-	if base_url := os.Getenv("PT_BASE_URL"); base_url != "" {
-		cm.config.BaseURL = base_url
-	}
+	applyTelemetryEnvironment(&cm.config.Telemetry)
+	cm.parseConfigEnvironment()
+}
 
-	if interval := os.Getenv("PT_POLL_INTERVAL"); interval != "" {
-		// Try parsing as duration first (e.g., "30s", "1m")
-		if duration, err := time.ParseDuration(interval); err == nil {
-			cm.config.PollInterval = duration
-		} else if seconds, err := strconv.Atoi(interval); err == nil {
-			// Try parsing as plain number (seconds)
-			cm.config.PollInterval = time.Duration(seconds) * time.Second
-		}
+// lookupEnv returns an environment variable, noting that it is a known
+// setting for checkUnusedEnv
+func (cm *ConfigManager) lookupEnv(name string) (string, bool) {
+	if cm.envRead == nil {
+		cm.envRead = map[string]bool{}
 	}
+	cm.envRead[name] = true
+	return os.LookupEnv(name)
+}
 
-	if timeout := os.Getenv("PT_REQUEST_TIMEOUT"); timeout != "" {
-		if timeout, err := strconv.Atoi(timeout); err == nil {
-			cm.config.RequestTimeout = time.Duration(timeout) * time.Second
-		}
-
-		// if duration, err := time.Duration(timeout); err == nil {
-		// 	cm.config.RequestTimeout = duration
-		// }
+// checkUnusedEnv warns about PT_* environment variables that are no setting,
+// usually misspelled ones that would otherwise be ignored silently
+func (cm *ConfigManager) checkUnusedEnv() {
+	known := []string{"PT_CONFIG"}
+	for name := range cm.envRead {
+		known = append(known, name)
 	}
 
-	if noColor := os.Getenv("PT_NO_COLOR"); noColor != "" {
-		if value, err := strconv.ParseBool(noColor); err == nil {
-			cm.config.ColorOutput = !value
+	var unused []string
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, "PT_") && !slices.Contains(known, name) {
+			unused = append(unused, name)
 		}
 	}
+	sort.Strings(unused)
 
-	if noTimestamp := os.Getenv("NO_TIMESTAMP"); noTimestamp != "" {
-		if value, err := strconv.ParseBool(noTimestamp); err == nil {
-			cm.config.ShowTimestamp = !value
+	for _, name := range unused {
+		warning := fmt.Sprintf("environment variable %s is not used", name)
+		if suggestion := closestName(name, known); suggestion != "" {
+			warning += fmt.Sprintf(" (did you mean %s?)", suggestion)
 		}
+		cm.warnings = append(cm.warnings, warning)
 	}
+}
 
-	if username := os.Getenv("PT_API_USERNAME"); username != "" {
-		cm.config.Username = username
-	}
-
-	if password := os.Getenv("PT_API_PASSWORD"); password != "" {
-		cm.config.Password = password
-	}
+// invalidEnv records an environment variable whose value could not be parsed;
+// the variable is ignored and validateConfig reports it
+func (cm *ConfigManager) invalidEnv(name, value string) {
+	cm.problems = append(cm.problems, fmt.Sprintf("%s: invalid value %q", name, value))
 }
 
 // parseCommandLineFlags parses command line arguments
 func (cm *ConfigManager) parseCommandLineFlags() {
 	var (
-		base_url = flag.String("base_url", cm.config.BaseURL, "Base URL (REQUIRED) (https://<mgmt>/api/v2/)") // noColor  = flag.Bool("no-color", !cm.config.ColorOutput, "Disable colored output")
-		username = flag.String("username", cm.config.Username, "API username for authentication")
-		password = flag.String("password", cm.config.Password, "API password for authentication")
-		showHelp = flag.Bool("help", false, "Show help message")
+		base_url       = flag.String("base_url", cm.config.BaseURL, "Base URL (REQUIRED) (https://<mgmt>/api/v2/)") // noColor  = flag.Bool("no-color", !cm.config.ColorOutput, "Disable colored output")
+		username       = flag.String("username", cm.config.Username, "API username for authentication")
+		password       = flag.String("password", cm.config.Password, "API password for authentication")
+		vaultPath      = flag.String("vault_path", cm.config.VaultPath, "Read the API username and password from this Vault KV secret (uses VAULT_ADDR, VAULT_TOKEN)")
+		passwordFile   = flag.String("password_file", cm.config.PasswordFile, "Read the API password from this file (Docker/Kubernetes secret, systemd credential)")
+		stream         = flag.Bool("stream", cm.config.StreamEnabled, "Subscribe to device change events (falls back to polling)")
+		streamEndpoint = flag.String("stream_endpoint", cm.config.StreamEndpoint, "Change-stream endpoint (default: <base_url>SubscribePhysicalDevices)")
+		gzip           = flag.Bool("gzip", cm.config.Gzip, "Request gzip-compressed API responses")
+		jitter         = flag.Int("jitter", cm.config.PollJitter, "Random delay added to each poll, in percent of the poll interval (0-100)")
+		snapshotDir    = flag.String("snapshot_dir", cm.config.SnapshotDir, "Directory for snapshots written with the 'w' key")
+		snapshotFormat = flag.String("snapshot_format", cm.config.SnapshotFormat, "Snapshot file format (json, csv)")
+		output         = flag.String("output", cm.config.OutputFormat, "Output mode: tui, or html, csv, markdown, json, text to print a one-shot report and exit")
+		columns        = flag.String("columns", cm.config.ColumnSpec, "Comma-separated device columns ("+columnKeys()+")")
+		tlsMinVersion  = flag.String("tls_min_version", cm.config.TLS.MinVersion, "Minimum TLS version for the API connection (1.2, 1.3)")
+		tlsCiphers     = flag.String("tls_ciphers", strings.Join(cm.config.TLS.CipherSuites, ","), "Comma-separated TLS 1.2 cipher suites (IANA names)")
+		tlsServerName  = flag.String("tls_server_name", cm.config.TLS.ServerName, "TLS server name (SNI) when it differs from the base URL host")
+		tlsPins        = flag.String("tls_pins", strings.Join(cm.config.TLS.Pins, ","), "Comma-separated server certificate pins (sha256/<base64 SPKI hash>)")
+		tlsCAFile      = flag.String("tls_ca_file", cm.config.TLS.CAFile, "Verify the API certificate with the CA certificates of this PEM file instead of the system's")
+		tlsInsecure    = flag.Bool("tls_insecure", cm.config.TLS.Insecure, "Accept any API certificate; prefer -tls_pins for a self-signed one")
+		userAgent      = flag.String("user_agent", cm.config.UserAgent, "User-Agent sent to the API")
+		assert         = flag.String("assert", cm.config.Assert, "Check the devices once and exit non-zero on failure: all-connected, no-critical")
+		outputFile     = flag.String("output_file", cm.config.OutputFile, "Write the report to this file instead of stdout")
+		webListen      = flag.String("web_listen", cm.config.WebListen, "Serve a read-only web dashboard on this address (e.g., :8080)")
+		otlpEndpoint   = flag.String("otlp_endpoint", cm.config.Telemetry.Endpoint, "Export the monitor's own traces and metrics to this OTLP/HTTP endpoint")
+		quiet          = flag.Bool("quiet", cm.config.Quiet, "Without a terminal, print only changes (device events, poll errors starting and ending)")
+		ascii          = flag.Bool("ascii", cm.config.ASCIIBorders, "Draw borders with plain ASCII characters (for legacy consoles)")
+		indicators     = flag.String("status_indicators", cm.config.StatusIndicators, "Mark connection state, health and role with symbols (✓ ~ ✗) or labels ([OK] [WARN] [DOWN]) besides color")
+		timezone       = flag.String("timezone", cm.config.Timezone, "Show timestamps in this time zone (e.g., UTC, Europe/Moscow) (default: Local)")
+		timeFormat     = flag.String("time_format", cm.config.TimeFormat, "Show timestamps in this Go layout (e.g., \"02.01.2006 15:04:05\"), or rfc3339, iso")
+		relativeTime   = flag.Bool("relative_time", cm.config.RelativeTime, "Show the times of the device table, header and event log as \"12m ago\" (toggle with 't')")
+		deviceURL      = flag.String("device_url", cm.config.DeviceURL, "Link device names to this management UI page ({host}, {id}, {name} are replaced)")
+		logicalURL     = flag.String("logical_device_url", cm.config.LogicalURL, "Link logical device names to this management UI page ({host}, {id}, {name} are replaced)")
+		labelFilter    = flag.String("label_filter", cm.config.LabelFilter, "Only monitor devices with these labels from the config file (site=msk,owner=netops)")
+		groupBy        = flag.String("group_by", cm.config.GroupBy, "Group logical devices in the TUI by this label (e.g., site)")
+		groupOrder     = flag.String("group_order", cm.config.GroupOrder, "Sort logical devices in the TUI by name, or by severity: the worst device state first (toggle with 'o')")
+		targetVersion  = flag.String("target_version", cm.config.TargetVersion, "Mark devices below this product version OUTDATED and count them in the header (e.g., R81.20)")
+		collapse       = flag.Bool("collapse_healthy", cm.config.CollapseHealthy, "Show each logical device without problems on a single line in the TUI (toggle with 'c')")
+		notesFile      = flag.String("notes_file", cm.config.NotesFile, "File for device notes written with the 'n' key")
+		stateFile      = flag.String("state_file", cm.config.StateFile, "File keeping the last known devices, shown at startup until the first poll succeeds (empty: off)")
+		inventoryFile  = flag.String("inventory_file", cm.config.InventoryFile, "CSV of expected devices (name, serial_number, logical_device columns); others are flagged MISSING or UNKNOWN")
+		historyFile    = flag.String("history_file", cm.config.HistoryFile, "Append device connection state changes to this file as JSON lines, for report sla")
+		reportFrom     = flag.String("from", cm.config.ReportFrom, "Start of the report sla period (e.g., 2026-01-01 or \"2026-01-01 08:00\") (default: 30 days before -to)")
+		reportTo       = flag.String("to", cm.config.ReportTo, "End of the report sla period (default: now)")
+		pollLog        = flag.String("poll_log", cm.config.PollLog, "Append a CSV row per device and poll (time, device, state, health, role) to this file, rotated daily")
+		pollLogMaxSize = flag.Int("poll_log_max_size", cm.config.PollLogMaxSize, "Also rotate -poll_log when it reaches this size in MB (0: daily only)")
+		webAckToken    = flag.String("web_ack_token", cm.config.WebAckToken, "Allow acknowledging device problems through the web API with this bearer token")
+		webUsername    = flag.String("web_username", cm.config.WebUsername, "Username for -web_password (default: admin)")
+		webPassword    = flag.String("web_password", cm.config.WebPassword, "Require this basic auth password for the web server (the health checks stay open)")
+		webPassFile    = flag.String("web_password_file", cm.config.WebPasswordFile, "Read -web_password from this file")
+		webToken       = flag.String("web_token", cm.config.WebToken, "Require this bearer token for the web server (the health checks stay open)")
+		webTokenFile   = flag.String("web_token_file", cm.config.WebTokenFile, "Read -web_token from this file")
+		webTLSCert     = flag.String("web_tls_cert", cm.config.WebTLSCert, "Serve the web server over HTTPS with this PEM certificate, reloaded when it changes")
+		webTLSKey      = flag.String("web_tls_key", cm.config.WebTLSKey, "PEM private key of -web_tls_cert")
+		flapThreshold  = flag.Int("flap_threshold", cm.config.FlapThreshold, "Mark devices FLAPPING that change connection state more than this many times within -flap_window (0: off)")
+		heartbeatURL   = flag.String("heartbeat_url", cm.config.HeartbeatURL, "Ping this dead man's switch URL after every poll, <url>/fail after failed ones (e.g., https://hc-ping.com/<uuid>)")
+		leaderLock     = flag.String("leader_lock", cm.config.LeaderLock, "Of the monitors sharing this lock, only the holder sends alerts and notifications: file:<path> or redis://host:port")
+		probe          = flag.String("probe", cm.config.Probe, "Check device addresses from this host: icmp (system ping) or tcp:<port>, shown in the reachable column")
+		sshCommand     = flag.String("ssh_command", cm.config.SSHCommand, "Command the 's' key runs for the selected device ({user}, {address}, {name}, {serial}, {id} are replaced)")
+		sshUser        = flag.String("ssh_user", cm.config.SSHUser, "User for {user} in -ssh_command")
+		daemon         = flag.Bool("daemon", cm.config.Daemon, "Run headless as a service: no TUI, log events, notify systemd")
+		demo           = flag.Bool("demo", cm.config.Demo, "Monitor a built-in simulated fleet instead of a management server (no -base_url needed)")
+		record         = flag.String("record", cm.config.Record, "Record every device list response with its time to this file, for -replay")
+		replay         = flag.String("replay", cm.config.Replay, "Monitor the responses of a -record file instead of a management server")
+		replaySpeed    = flag.Float64("replay_speed", cm.config.ReplaySpeed, "Play -replay this many times faster than it was recorded")
+		mockListen     = flag.String("mock_listen", cm.config.MockListen, "Address the mockserver command serves the mock API on")
+		mockFixture    = flag.String("fixture", cm.config.MockFixture, "ListPhysicalDevices response (JSON) for mockserver to serve, re-read on every request (default: the -demo fleet)")
+		mockErrorRate  = flag.Int("mock_error_rate", cm.config.MockErrorRate, "Percent of mockserver device list requests that fail with 500, 502 or 503")
+		mockAuthRate   = flag.Int("mock_auth_failure_rate", cm.config.MockAuthFailureRate, "Percent of mockserver device list requests that fail with 401, as for an expired session")
+		logFile        = flag.String("log_file", cm.config.LogFile, "Log file (default: stderr, captured by journald in daemon mode)")
+		auditLog       = flag.String("audit_log", cm.config.AuditLog, "Append every login, session renewal and auth failure to this file as JSON lines")
+		events         = flag.String("events", cm.config.Events, "Write every device event and poll error as a JSON line (jsonl) to stdout in daemon mode, or to -events_file")
+		eventsFile     = flag.String("events_file", cm.config.EventsFile, "File for -events (default: stdout)")
+		debug          = flag.Bool("debug", cm.config.Debug, "Log DNS, connect, TLS and time-to-first-byte durations of every poll")
+		_              = flag.String("config", "", "JSON config file, overriding environment variables and overridden by flags")
+		showHelp       = flag.Bool("help", false, "Show help message")
+		showVersion    = flag.Bool("version", false, "Print version and build information and exit")
+		printConfig    = flag.Bool("print_config", false, "Print the effective configuration, where each value came from and its environment variable, with secrets masked, and exit")
 	)
 
 	// Custom duration flag that accepts both duration strings and plain numbers
 	interval := newDurationValue(cm.config.PollInterval, &cm.config.PollInterval)
 	flag.Var(interval, "interval", "Poll interval (e.g., 30, 60, or 30s, 1m)")
 
+	maxInterval := newDurationValue(cm.config.MaxPollInterval, &cm.config.MaxPollInterval)
+	flag.Var(maxInterval, "max_interval", "Upper bound for the poll interval while the API keeps failing")
+
+	renderInterval := newDurationValue(cm.config.RenderInterval, &cm.config.RenderInterval)
+	flag.Var(renderInterval, "render_interval", "Redraw the TUI this often between polls, for the clock, countdown and relative times (0: on polls only)")
+
+	flag.Var(&headerValue{headers: &cm.config.Headers}, "header", "Extra request header 'Name: value' sent to the API (repeatable)")
+
+	sessionRenew := newDurationValue(cm.config.SessionRenew, &cm.config.SessionRenew)
+	flag.Var(sessionRenew, "session_renew", "Log in again after this long, even if the session has not expired (default: only before expiry)")
+
+	staleAfter := newDurationValue(cm.config.StaleAfter, &cm.config.StaleAfter)
+	flag.Var(staleAfter, "stale_after", "Fail /readyz without a successful poll, and /healthz without any poll, for this long (default: 3 poll intervals, at least 2 -max_interval)")
+
+	flapWindow := newDurationValue(cm.config.FlapWindow, &cm.config.FlapWindow)
+	flag.Var(flapWindow, "flap_window", "Time window for -flap_threshold")
+	leaderLease := newDurationValue(cm.config.LeaderLease, &cm.config.LeaderLease)
+	flag.Var(leaderLease, "leader_lease", "How long the -leader_lock holds without renewal, the longest time without a notifying monitor")
+	mockLatency := newDurationValue(cm.config.MockLatency, &cm.config.MockLatency)
+	flag.Var(mockLatency, "mock_latency", "Delay of every mockserver response")
+
+	requestTimeout := newDurationValue(cm.config.RequestTimeout, &cm.config.RequestTimeout)
+	flag.Var(requestTimeout, "request_timeout", "Give up an API request after this long, including reading the response (e.g., 500ms, 2s, 30)")
+	flag.Var(requestTimeout, "timeout", "Short for -request_timeout")
+	dialTimeout := newDurationValue(cm.config.DialTimeout, &cm.config.DialTimeout)
+	flag.Var(dialTimeout, "dial_timeout", "Timeout for connecting to the API (0: only -request_timeout)")
+	tlsTimeout := newDurationValue(cm.config.TLSHandshakeTimeout, &cm.config.TLSHandshakeTimeout)
+	flag.Var(tlsTimeout, "tls_handshake_timeout", "Timeout for the TLS handshake with the API (0: only -request_timeout)")
+	headerTimeout := newDurationValue(cm.config.HeaderTimeout, &cm.config.HeaderTimeout)
+	flag.Var(headerTimeout, "response_header_timeout", "Timeout from sending a request to the response headers (0: only -request_timeout)")
+
+	waitTimeout := newDurationValue(cm.config.WaitTimeout, &cm.config.WaitTimeout)
+	flag.Var(waitTimeout, "wait_timeout", "With -assert, keep polling until the assertion holds or this timeout passes")
+
 	flag.Usage = cm.printUsage
-	flag.Parse()
+	if err := cm.parseArgs(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		cm.printUsage()
+		os.Exit(2)
+	}
 
 	if *showHelp {
 		cm.printUsage()
 		os.Exit(0)
 	}
 
+	if *showVersion {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+
+	cm.printConfig = *printConfig
+
 	// Apply command line flag values
 	cm.config.BaseURL = *base_url
 	// cm.config.ColorOutput = !*noColor
 	cm.config.Username = *username
 	cm.config.Password = *password
+	cm.config.PasswordFile = *passwordFile
+	cm.config.VaultPath = *vaultPath
+	cm.config.StreamEnabled = *stream
+	cm.config.StreamEndpoint = *streamEndpoint
+	cm.config.Gzip = *gzip
+	cm.config.PollJitter = *jitter
+	cm.config.SnapshotDir = *snapshotDir
+	cm.config.SnapshotFormat = strings.ToLower(*snapshotFormat)
+	cm.config.OutputFormat = strings.ToLower(*output)
+	cm.config.OutputFile = *outputFile
+	cm.config.ColumnSpec = *columns
+	cm.config.WebListen = *webListen
+	cm.config.Telemetry.Endpoint = *otlpEndpoint
+	cm.config.ASCIIBorders = *ascii
+	cm.config.StatusIndicators = *indicators
+	cm.config.Timezone = *timezone
+	cm.config.TimeFormat = *timeFormat
+	cm.config.RelativeTime = *relativeTime
+	cm.config.DeviceURL = *deviceURL
+	cm.config.LogicalURL = *logicalURL
+	cm.config.LabelFilter = *labelFilter
+	cm.config.GroupBy = *groupBy
+	cm.config.GroupOrder = *groupOrder
+	cm.config.TargetVersion = *targetVersion
+	cm.config.CollapseHealthy = *collapse
+	cm.config.NotesFile = *notesFile
+	cm.config.StateFile = *stateFile
+	cm.config.InventoryFile = *inventoryFile
+	cm.config.HistoryFile = *historyFile
+	cm.config.ReportFrom = *reportFrom
+	cm.config.ReportTo = *reportTo
+	cm.config.PollLog = *pollLog
+	cm.config.PollLogMaxSize = *pollLogMaxSize
+	cm.config.WebAckToken = *webAckToken
+	cm.config.WebUsername = *webUsername
+	cm.config.WebPassword = *webPassword
+	cm.config.WebPasswordFile = *webPassFile
+	cm.config.WebToken = *webToken
+	cm.config.WebTokenFile = *webTokenFile
+	cm.config.WebTLSCert = *webTLSCert
+	cm.config.WebTLSKey = *webTLSKey
+	cm.config.FlapThreshold = *flapThreshold
+	cm.config.HeartbeatURL = *heartbeatURL
+	cm.config.LeaderLock = *leaderLock
+	cm.config.Probe = *probe
+	cm.config.SSHCommand = *sshCommand
+	cm.config.SSHUser = *sshUser
+	cm.config.Quiet = *quiet
+	cm.config.Debug = *debug
+	cm.config.Assert = strings.ToLower(*assert)
+	cm.config.UserAgent = *userAgent
+	cm.config.TLS.MinVersion = *tlsMinVersion
+	cm.config.TLS.CipherSuites = nil
+	if *tlsCiphers != "" {
+		cm.config.TLS.CipherSuites = strings.Split(*tlsCiphers, ",")
+	}
+	cm.config.TLS.ServerName = *tlsServerName
+	cm.config.TLS.Pins = nil
+	if *tlsPins != "" {
+		cm.config.TLS.Pins = strings.Split(*tlsPins, ",")
+	}
+	cm.config.TLS.CAFile = *tlsCAFile
+	cm.config.TLS.Insecure = *tlsInsecure
+	cm.config.Daemon = *daemon
+	cm.config.Demo = *demo
+	cm.config.Record = *record
+	cm.config.Replay = *replay
+	cm.config.ReplaySpeed = *replaySpeed
+	cm.config.MockListen = *mockListen
+	cm.config.MockFixture = *mockFixture
+	cm.config.MockErrorRate = *mockErrorRate
+	cm.config.MockAuthFailureRate = *mockAuthRate
+	cm.config.LogFile = *logFile
+	cm.config.AuditLog = *auditLog
+	cm.config.Events = *events
+	cm.config.EventsFile = *eventsFile
 	// Note: PollInterval is automatically set by the custom flag
 }
 
+// parseArgs parses the global flags and picks the command, whose words may
+// come before, after or between the flags
+func (cm *ConfigManager) parseArgs(args []string) error {
+	var words []string
+	for {
+		// Exits on a bad flag, as flag.Parse does
+		flag.CommandLine.Parse(args)
+
+		args = flag.Args()
+		if len(args) == 0 {
+			break
+		}
+		words = append(words, args[0])
+		args = args[1:]
+	}
+
+	name := strings.Join(words, " ")
+	if name == "" {
+		name = defaultCommand
+	}
+	if findCommand(name) == nil {
+		return fmt.Errorf("unknown command: %s", name)
+	}
+	cm.config.Command = name
+
+	return nil
+}
+
 // validateConfig validates the configuration values
+//
+// Every problem is collected, so one run reports all of them as a
+// *ValidationError.
 func (cm *ConfigManager) validateConfig() error {
-	if cm.config.BaseURL == "" {
-		return fmt.Errorf("base URL is required. Set it via -base_url flag or PT_BASE_URL environment variable")
+	problems := cm.problems
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
 	}
 
-	if !strings.HasSuffix(cm.config.BaseURL, "/") {
-		cm.config.BaseURL += "/"
+	if cm.config.Demo || cm.config.Replay != "" || cm.config.Command == "mockserver" {
+		// Set by startDemo or startReplay, or not needed
+	} else if cm.config.BaseURL == "" {
+		problem("base URL is required. Set it via -base_url flag or PT_BASE_URL environment variable")
+	} else {
+		if !strings.HasSuffix(cm.config.BaseURL, "/") {
+			cm.config.BaseURL += "/"
+		}
+		if err := checkBaseURL(cm.config.BaseURL); err != nil {
+			problem("%v", err)
+		}
+	}
+
+	if cm.config.Username == "" {
+		problem("username must not be empty")
+	} else if strings.TrimSpace(cm.config.Username) != cm.config.Username {
+		problem("username has leading or trailing spaces")
+	}
+	if cm.config.VaultPath != "" {
+		if _, err := NewVaultCredentials(cm.config.VaultPath); err != nil {
+			problem("%v", err)
+		}
+	} else if cm.config.Password == "" {
+		problem("password must not be empty")
+	}
+	if strings.ContainsAny(cm.config.Username+cm.config.Password, "\r\n\t") {
+		problem("credentials must not contain tabs or line breaks")
 	}
 
 	if cm.config.PollInterval < 1*time.Second {
-		return fmt.Errorf("poll interval must be at least 1 second")
+		problem("poll interval must be at least 1 second")
 	}
 
-	// if cm.config.RequestTimeout < 1*time.Second {
-	// 	return fmt.Errorf("request timeout must be at least 1 second")
-	// }
+	if cm.config.RequestTimeout <= 0 {
+		problem("request timeout must be positive")
+	}
+	if cm.config.DialTimeout < 0 || cm.config.TLSHandshakeTimeout < 0 || cm.config.HeaderTimeout < 0 {
+		problem("dial, TLS handshake and response header timeouts must not be negative")
+	}
 
-	// if cm.config.RequestTimeout > cm.config.PollInterval {
-	// 	cm.config.RequestTimeout = cm.config.PollInterval / 2
-	// }
+	if cm.config.MaxPollInterval < 0 || cm.config.SessionRenew < 0 || cm.config.WaitTimeout < 0 {
+		problem("max interval, session renew interval and wait timeout must not be negative")
+	}
 
+	if cm.config.RenderInterval < 0 || (cm.config.RenderInterval > 0 && cm.config.RenderInterval < 100*time.Millisecond) {
+		problem("render interval must be 0 (redraw on polls only) or at least 100ms")
+	}
+
+	if cm.config.PollJitter < 0 || cm.config.PollJitter > 100 {
+		problem("poll jitter must be between 0 and 100 percent")
+	}
+	if cm.config.PollLogMaxSize < 0 {
+		problem("poll log max size must not be negative")
+	}
+	if err := checkGroupOrder(cm.config.GroupOrder); err != nil {
+		problem("%v", err)
+	}
+	if err := checkStatusIndicators(cm.config.StatusIndicators); err != nil {
+		problem("%v", err)
+	}
+	if err := checkTargetVersion(cm.config.TargetVersion); err != nil {
+		problem("%v", err)
+	}
+	if _, err := loadTimezone(cm.config.Timezone); err != nil {
+		problem("%v", err)
+	}
+	if err := checkTimeFormat(cm.config.TimeFormat); err != nil {
+		problem("%v", err)
+	}
+	if cm.config.FlapThreshold < 0 {
+		problem("flap threshold must not be negative")
+	}
+	if cm.config.FlapThreshold > 0 && cm.config.FlapWindow <= 0 {
+		problem("flap window must be positive")
+	}
+
+	switch {
+	case cm.config.Demo && cm.config.Replay != "":
+		problem("demo and replay cannot be used together")
+	case cm.config.Record != "" && (cm.config.Demo || cm.config.Replay != ""):
+		problem("record needs a management server, not demo or replay")
+	}
+	if cm.config.ReplaySpeed <= 0 {
+		problem("replay speed must be positive")
+	}
+	if cm.config.MockErrorRate < 0 || cm.config.MockAuthFailureRate < 0 ||
+		cm.config.MockErrorRate+cm.config.MockAuthFailureRate > 100 {
+		problem("mock error and auth failure rates must be percentages adding up to at most 100")
+	}
+	if cm.config.MockLatency < 0 {
+		problem("mock latency must not be negative")
+	}
+
+	switch {
+	case cm.config.Events != "" && cm.config.Events != "jsonl":
+		problem("unsupported events format: %s (use jsonl)", cm.config.Events)
+	case cm.config.Events != "" && cm.config.EventsFile == "" && !cm.config.Daemon:
+		problem("events go to stdout only in daemon mode; use -daemon or -events_file")
+	}
+
+	if cm.config.SnapshotFormat != "json" && cm.config.SnapshotFormat != "csv" {
+		problem("snapshot format must be json or csv")
+	}
+
+	switch cm.config.OutputFormat {
+	case "tui", "html", "csv", "markdown", "text", "json":
+	default:
+		problem("unsupported output format: %s", cm.config.OutputFormat)
+	}
+
+	switch cm.config.Assert {
+	case "", AssertAllConnected, AssertNoCritical:
+	default:
+		problem("unsupported assertion: %s (use %s or %s)", cm.config.Assert, AssertAllConnected, AssertNoCritical)
+	}
+
+	if _, err := newTLSConfig(cm.config.TLS); err != nil {
+		problem("%v", err)
+	}
+	if cm.config.TLS.Insecure {
+		cm.warnings = append(cm.warnings, "tls_insecure accepts any API certificate, so an impostor gets the credentials; prefer tls_pins or tls_ca_file")
+	}
+
+	if cm.config.Influx.URL != "" && cm.config.Influx.Bucket == "" {
+		problem("influx.bucket is required when influx.url is set")
+	}
+
+	if u := cm.config.HeartbeatURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		problem("heartbeat URL must start with http:// or https://")
+	}
+
+	for _, value := range []string{cm.config.ReportFrom, cm.config.ReportTo} {
+		if value == "" {
+			continue
+		}
+		if _, err := ParseReportTime(value); err != nil {
+			problem("%v", err)
+		}
+	}
+
+	for _, template := range []string{cm.config.DeviceURL, cm.config.LogicalURL} {
+		if template != "" && !strings.HasPrefix(template, "http://") && !strings.HasPrefix(template, "https://") {
+			problem("link URL must start with http:// or https://: %s", template)
+		}
+	}
+
+	if strings.TrimSpace(cm.config.SSHCommand) == "" {
+		problem("ssh command must not be empty")
+	}
+
+	problems = append(problems, checkLabelRules(cm.config.Labels)...)
+	if _, err := parseLabelFilter(cm.config.LabelFilter); err != nil {
+		problem("%v", err)
+	}
+	if (cm.config.GroupBy != "" || cm.config.LabelFilter != "") && len(cm.config.Labels) == 0 {
+		problem("group_by and label_filter need label rules in the config file")
+	}
+	if _, err := LoadInventory(cm.config); err != nil {
+		problem("%v", err)
+	}
+	for i, item := range cm.config.ExpectedDevices {
+		if item.Name == "" && item.Serial == "" {
+			problem("expected_devices[%d] needs a name or serial", i)
+		}
+	}
+
+	for _, p := range checkAlertConfig(cm.config.Alerts) {
+		problem("%s", p)
+	}
+	if cm.config.ExecHook.Timeout < 0 || cm.config.ExecHook.Concurrency < 0 {
+		problem("exec_hook.timeout and exec_hook.concurrency must not be negative")
+	}
+	if cm.config.LeaderLock != "" {
+		if _, err := parseLeaderLock(cm.config.LeaderLock, cm.config.BaseURL); err != nil {
+			problem("%v", err)
+		}
+		if cm.config.LeaderLease < 3*time.Second {
+			problem("leader_lease must be at least 3s")
+		}
+	}
+	if cm.config.StaleAfter < 0 {
+		problem("stale after must not be negative")
+	}
+	if cm.config.WebAckToken != "" && cm.config.WebListen == "" {
+		problem("web_ack_token needs web_listen")
+	}
+	if (cm.config.WebTLSCert == "") != (cm.config.WebTLSKey == "") {
+		problem("web_tls_cert and web_tls_key must be set together")
+	}
+	if cm.config.WebListen != "" && webAuthRequired(cm.config) && cm.config.WebTLSCert == "" {
+		cm.warnings = append(cm.warnings, "the web server credentials are sent unencrypted without web_tls_cert")
+	}
+
+	if cm.config.Probe != "" {
+		method, _, err := parseProbe(cm.config.Probe)
+		if err != nil {
+			problem("%v", err)
+		} else if cm.config.ColumnSpec == defaultColumns {
+			// Show the probe next to the state the API reports
+			cm.config.ColumnSpec = strings.Replace(defaultColumns, "status,", "status,reachable,trend,", 1)
+		}
+		if method == "icmp" {
+			if _, err := exec.LookPath("ping"); err != nil {
+				problem("probe icmp needs the ping command: %v", err)
+			}
+		}
+	}
+
+	columns, err := ParseColumns(cm.config.ColumnSpec)
+	if err != nil {
+		problem("%v", err)
+	}
+	cm.config.Columns = columns
+
+	// A max below the base interval simply disables the backoff
+	if cm.config.MaxPollInterval < cm.config.PollInterval {
+		cm.config.MaxPollInterval = cm.config.PollInterval
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// checkBaseURL checks that the base URL is an absolute http(s) URL
+func checkBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("base URL must start with http:// or https://: %s", baseURL)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("base URL has no host: %s", baseURL)
+	}
 	return nil
 }
 
@@ -190,21 +757,97 @@ func (cm *ConfigManager) validateConfig() error {
 func (cm *ConfigManager) printUsage() {
 	fmt.Fprintf(os.Stderr, `Go API Monitor - Physical Devices Monitor
 
-Usage: %s [OPTIONS]
+Usage: %s [COMMAND] [OPTIONS]
 
 This application periodically polls the Physical Devices API PT NGFW.
 
+COMMANDS:
+%s
 OPTIONS:
-`, os.Args[0])
+`, os.Args[0], commandUsage())
 
 	flag.PrintDefaults()
 
 	fmt.Fprintf(os.Stderr, `
 ENVIRONMENT VARIABLES:
+  PT_CONFIG            JSON config file (keys match the options below, e.g. "base_url", "poll_interval")
+  PT_<KEY>             Any other config file key, nested keys joined with _ (e.g., PT_MQTT_BROKER, PT_ALERTS_SLACK_WEBHOOK_URL);
+                       -print_config lists them all
   PT_BASE_URL          API BASE URL (REQUIRED) (example: https://pt-mgmt/api/v2/)
   PT_POLL_INTERVAL     Poll interval in seconds or duration (e.g., "30", "60", "30s", "1m") (default: 5)
+  PT_MAX_POLL_INTERVAL Upper bound for the poll interval while the API keeps failing (default: 1m)
+  PT_RENDER_INTERVAL   Redraw the TUI this often between polls (default: 1s, 0: on polls only)
+  PT_POLL_JITTER       Random delay added to each poll, in percent of the poll interval (default: 0)
+  PT_FLAP_THRESHOLD    Mark devices FLAPPING that change connection state more than this many times (default: 3, 0: off)
+  PT_FLAP_WINDOW       Time window for PT_FLAP_THRESHOLD (default: 10m)
+  PT_SNAPSHOT_DIR      Directory for snapshots written with the 'w' key (default: .)
+  PT_SNAPSHOT_FORMAT   Snapshot file format: json or csv (default: json)
+  PT_OUTPUT            Output mode: tui, html, csv, markdown, json or text (default: tui)
+  PT_ASSERT            One-shot check: all-connected or no-critical
+  PT_WAIT_TIMEOUT      How long -assert waits for the condition (default: 0, check once)
+  PT_COLUMNS           Comma-separated device columns (default: name,model,status,address,priority,version)
+  PT_WEB_LISTEN        Serve a read-only web dashboard on this address (e.g., :8080)
+  PT_STALE_AFTER       Fail /readyz and /healthz after this long without polls (default: 3 poll intervals, at least 2 max intervals)
+  OTEL_EXPORTER_OTLP_ENDPOINT  OTLP/HTTP endpoint for the monitor's own traces and metrics
+  OTEL_SERVICE_NAME    Service name reported to OpenTelemetry (default: pt_device_monitor)
+  PT_QUIET             Without a terminal, print only changes (true/false) (default: false)
+  PT_ASCII             Draw borders with plain ASCII characters (true/false) (default: false)
+  PT_NO_COLOR          Draw without colors (true/false) (default: false)
+  PT_STATUS_INDICATORS Mark connection state, health and role besides color: color, symbols, labels (default: color)
+  PT_TIMEZONE          Show timestamps in this time zone (e.g., UTC, Europe/Moscow) (default: Local)
+  PT_TIME_FORMAT       Show timestamps in this Go layout (e.g., 02.01.2006 15:04:05), or rfc3339, iso
+  PT_RELATIVE_TIME     Show the times of the device table, header and event log as "12m ago" (true/false) (default: false)
+  PT_DEVICE_URL        Management UI page linked from device names ({host}, {id}, {name} are replaced)
+  PT_LOGICAL_DEVICE_URL  Management UI page linked from logical device names
+  PT_LABEL_FILTER      Only monitor devices with these labels (e.g., site=msk,owner=netops)
+  PT_GROUP_BY          Group logical devices in the TUI by this label (e.g., site)
+  PT_GROUP_ORDER       Sort logical devices in the TUI by name or severity (default: name)
+  PT_TARGET_VERSION    Mark devices below this product version OUTDATED and count them in the header (e.g., R81.20)
+  PT_COLLAPSE_HEALTHY  Show each logical device without problems on a single line in the TUI (true/false) (default: false)
+  PT_NOTES_FILE        File for device notes written with the 'n' key (default: <user config dir>/pt_device_monitor/notes.json)
+  PT_STATE_FILE        File keeping the last known devices, shown at startup; set empty to turn off (default: <user config dir>/pt_device_monitor/state.json)
+  PT_INVENTORY_FILE    CSV of expected devices; missing ones are shown as MISSING, others marked UNKNOWN
+  PT_HISTORY_FILE      File for device connection state changes as JSON lines, read by report sla
+  PT_POLL_LOG          File for a CSV row per device and poll, rotated daily
+  PT_POLL_LOG_MAX_SIZE Also rotate PT_POLL_LOG at this size in MB (default: 10, 0: daily only)
+  PT_WEB_ACK_TOKEN     Bearer token that allows acknowledging device problems through the web API
+  PT_WEB_USERNAME, PT_WEB_PASSWORD  Basic auth for the web server (default username: admin)
+  PT_WEB_TOKEN         Bearer token required by the web server
+  PT_WEB_TLS_CERT, PT_WEB_TLS_KEY  Serve the web server over HTTPS with this certificate and key
+  PT_HEARTBEAT_URL     Ping this URL after every poll, <url>/fail after failed ones (e.g., https://hc-ping.com/<uuid>)
+  PT_LEADER_LOCK       Only the monitor holding this lock sends alerts and notifications: file:<path> or redis://host:port
+  PT_LEADER_LEASE      How long the leader lock holds without renewal (default: 30s)
+  PT_PROBE             Check device addresses from this host: icmp (system ping) or tcp:<port>
+  PT_SSH_COMMAND       Command the 's' key runs for the selected device (default: ssh {user}@{address})
+  PT_SSH_USER          User for {user} in PT_SSH_COMMAND (default: the local user)
+  PT_DAEMON            Run headless as a service (true/false) (default: false)
+  PT_DEMO              Monitor a built-in simulated fleet instead of a management server (true/false) (default: false)
+  PT_LOG_FILE          Log file (default: stderr)
+  PT_AUDIT_LOG         File for authentication events as JSON lines
+  PT_EVENTS            Write device events and poll errors as JSON lines: jsonl
+  PT_EVENTS_FILE       File for PT_EVENTS (default: stdout, daemon mode only)
+  PT_DEBUG             Log connection timings of every poll (true/false) (default: false)
+  PT_TLS_MIN_VERSION   Minimum TLS version for the API connection (1.2, 1.3)
+  PT_TLS_CIPHERS       Comma-separated TLS 1.2 cipher suites (IANA names)
+  PT_TLS_SERVER_NAME   TLS server name (SNI) when it differs from the base URL host
+  PT_TLS_PINS          Comma-separated server certificate pins (sha256/<base64 SPKI hash>)
+  PT_TLS_CA_FILE       Verify the API certificate with the CA certificates of this PEM file instead of the system's
+  PT_TLS_INSECURE      Accept any API certificate (true/false) (default: false)
+  PT_USER_AGENT        User-Agent sent to the API (default: go-api-monitor/<version>)
+  PT_HEADERS           Extra request headers, comma-separated Name=value pairs
+  PT_SESSION_RENEW     Log in again after this long, even if the session has not expired (default: only before expiry)
   PT_API_USERNAME      API username for authentication (default: admin)
   PT_API_PASSWORD      API password for authentication (default: admin)
+  PT_API_PASSWORD_FILE Read the API password from this file instead
+  PT_VAULT_PATH        Vault KV secret with the API username and password (e.g., secret/data/pt-monitor)
+  VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE, VAULT_CACERT  Vault connection, as for the vault CLI
+  PT_STREAM            Subscribe to device change events (true/false) (default: false)
+  PT_STREAM_ENDPOINT   Change-stream endpoint (default: <base_url>SubscribePhysicalDevices)
+  PT_GZIP              Request gzip-compressed API responses (true/false) (default: true)
+  PT_REQUEST_TIMEOUT   Give up an API request after this long, including reading the response (default: 30s)
+  PT_DIAL_TIMEOUT      Timeout for connecting to the API (default: 5s, 0: only PT_REQUEST_TIMEOUT)
+  PT_TLS_HANDSHAKE_TIMEOUT    Timeout for the TLS handshake (default: 5s, 0: only PT_REQUEST_TIMEOUT)
+  PT_RESPONSE_HEADER_TIMEOUT  Timeout from sending a request to the response headers (default: 10s, 0: only PT_REQUEST_TIMEOUT)
 
 EXAMPLES:
   # Basic usage with required base URL
@@ -216,27 +859,26 @@ EXAMPLES:
   # Use custom endpoint and interval (duration)
   %s -base_url https://my-api.com/api/v2/ -interval 1m30s
 
+  # Try it without a management server
+  %s -demo
+
+  # Save an HTML status report and exit
+  %s report -base_url https://my-api.com/api/v2/ -output_file status.html
+
+  # Wait up to 10 minutes for all devices to connect, exit 1 otherwise
+  %s check -base_url https://my-api.com/api/v2/ -wait_timeout 10m
+
   # Set configuration via environment variables
   export PT_BASE_URL="https://my-api.com/api/v2/"
   export PT_POLL_INTERVAL="60"
   %s
 
 KEYBOARD SHORTCUTS:
-  Ctrl+C    Exit the application
-
-`, os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+%s
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], shortcutsUsage())
 }
 
 // GetConfig returns the current configuration
 func (cm *ConfigManager) GetConfig() *Config {
 	return cm.config
 }
-
-// PrintConfig prints the current configuration (for debugging)
-func (cm *ConfigManager) PrintConfig() {
-	fmt.Printf("Configuration:\n")
-	fmt.Printf("  Base URL:         %s\n", cm.config.BaseURL)
-	fmt.Printf("  Poll Interval:    %v\n", cm.config.PollInterval)
-	fmt.Printf("  Username:         %s\n", cm.config.Username)
-	fmt.Println()
-}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configFilePath returns the config file given with -config or PT_CONFIG.
+// It is looked up before flag parsing because the file is loaded before the
+// flags that override it.
+func configFilePath() string {
+	args := os.Args[1:]
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return os.Getenv("PT_CONFIG")
+}
+
+// loadConfigFile reads a JSON config file on top of the current values. Keys
+// that are no setting, such as misspelled ones, are reported as problems.
+func (cm *ConfigManager) loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cm.config); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var file map[string]any
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	for _, problem := range unknownConfigKeys("", file, reflect.TypeOf(Config{})) {
+		cm.problems = append(cm.problems, fmt.Sprintf("config file %s: %s", path, problem))
+	}
+
+	return nil
+}
+
+// configKeys returns the config file keys of t, a config struct, with the
+// fields they set
+func configKeys(t reflect.Type) map[string]reflect.StructField {
+	keys := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		keys[name] = field
+	}
+	return keys
+}
+
+// unknownConfigKeys describes every key of object that t, a config struct,
+// has no field for, in nested settings and lists of them too. Like
+// encoding/json it matches keys regardless of case.
+func unknownConfigKeys(prefix string, object map[string]any, t reflect.Type) []string {
+	keys := configKeys(t)
+	names := slices.Sorted(maps.Keys(keys))
+
+	var problems []string
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		var field reflect.StructField
+		found := false
+		for name, f := range keys {
+			if strings.EqualFold(name, key) {
+				field, found = f, true
+				break
+			}
+		}
+
+		if !found {
+			problem := fmt.Sprintf("unknown option %q", prefix+key)
+			switch suggestion := closestName(key, names); {
+			case suggestion != "":
+				problem += fmt.Sprintf(" (did you mean %q?)", prefix+suggestion)
+			case prefix == "":
+				problem += "; valid options: " + strings.Join(names, ", ")
+			default:
+				problem += fmt.Sprintf("; valid options in %s: %s", strings.TrimSuffix(prefix, "."), strings.Join(names, ", "))
+			}
+			problems = append(problems, problem)
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer || fieldType.Kind() == reflect.Slice {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() != reflect.Struct {
+			continue
+		}
+
+		switch value := object[key].(type) {
+		case map[string]any:
+			problems = append(problems, unknownConfigKeys(prefix+key+".", value, fieldType)...)
+		case []any:
+			for i, item := range value {
+				if itemObject, ok := item.(map[string]any); ok {
+					problems = append(problems, unknownConfigKeys(fmt.Sprintf("%s%s[%d].", prefix, key, i), itemObject, fieldType)...)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// closestName returns the name most similar to name, if one is close enough
+// to be a likely typo of it
+func closestName(name string, names []string) string {
+	best, bestDistance := "", len(name)/3+2
+	for _, candidate := range names {
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(candidate)); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// configDuration accepts both duration strings ("30s") and plain seconds (30)
+type configDuration time.Duration
+
+func (d *configDuration) UnmarshalJSON(data []byte) error {
+	var seconds int
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = configDuration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	if duration, err := time.ParseDuration(text); err == nil {
+		*d = configDuration(duration)
+		return nil
+	}
+	if seconds, err := strconv.Atoi(text); err == nil {
+		*d = configDuration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	return fmt.Errorf("invalid duration format: %s (use either duration like '30s' or seconds like '30')", text)
+}
+
+// MarshalJSON writes a duration as text, e.g. "5m0s", for -print_config
+func (d configDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d configDuration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalJSON decodes a config file, reading durations in the same formats
+// as the command line flags. Fields missing from the file keep their values.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type plainConfig Config
+	file := struct {
+		*plainConfig
+		PollInterval    *configDuration `json:"poll_interval"`
+		MaxPollInterval *configDuration `json:"max_poll_interval"`
+		RenderInterval  *configDuration `json:"render_interval"`
+		RequestTimeout  *configDuration `json:"request_timeout"`
+		DialTimeout     *configDuration `json:"dial_timeout"`
+		TLSTimeout      *configDuration `json:"tls_handshake_timeout"`
+		HeaderTimeout   *configDuration `json:"response_header_timeout"`
+		SessionRenew    *configDuration `json:"session_renew_interval"`
+		FlapWindow      *configDuration `json:"flap_window"`
+		LeaderLease     *configDuration `json:"leader_lease"`
+		StaleAfter      *configDuration `json:"stale_after"`
+	}{
+		plainConfig: (*plainConfig)(c),
+	}
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+
+	if file.PollInterval != nil {
+		c.PollInterval = time.Duration(*file.PollInterval)
+	}
+	if file.MaxPollInterval != nil {
+		c.MaxPollInterval = time.Duration(*file.MaxPollInterval)
+	}
+	if file.RenderInterval != nil {
+		c.RenderInterval = time.Duration(*file.RenderInterval)
+	}
+	if file.RequestTimeout != nil {
+		c.RequestTimeout = time.Duration(*file.RequestTimeout)
+	}
+	if file.DialTimeout != nil {
+		c.DialTimeout = time.Duration(*file.DialTimeout)
+	}
+	if file.TLSTimeout != nil {
+		c.TLSHandshakeTimeout = time.Duration(*file.TLSTimeout)
+	}
+	if file.HeaderTimeout != nil {
+		c.HeaderTimeout = time.Duration(*file.HeaderTimeout)
+	}
+	if file.SessionRenew != nil {
+		c.SessionRenew = time.Duration(*file.SessionRenew)
+	}
+	if file.FlapWindow != nil {
+		c.FlapWindow = time.Duration(*file.FlapWindow)
+	}
+	if file.LeaderLease != nil {
+		c.LeaderLease = time.Duration(*file.LeaderLease)
+	}
+	if file.StaleAfter != nil {
+		c.StaleAfter = time.Duration(*file.StaleAfter)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loadTestConfigFile loads content as a config file over the defaults
+func loadTestConfigFile(t *testing.T, content string) *Config {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewConfigManager()
+	cm.setDefaults()
+	if err := cm.loadConfigFile(path); err != nil {
+		t.Fatalf("failed to load %s: %v", content, err)
+	}
+	if len(cm.problems) > 0 {
+		t.Errorf("problems with %s: %v", content, cm.problems)
+	}
+	return cm.config
+}
+
+func TestConfigFileDurations(t *testing.T) {
+	tests := []struct {
+		content string
+		field   func(config *Config) time.Duration
+		want    time.Duration
+	}{
+		{`{"leader_lease": "45s"}`, func(config *Config) time.Duration { return config.LeaderLease }, 45 * time.Second},
+		{`{"leader_lease": 60}`, func(config *Config) time.Duration { return config.LeaderLease }, time.Minute},
+		{`{"stale_after": "2m"}`, func(config *Config) time.Duration { return config.StaleAfter }, 2 * time.Minute},
+	}
+
+	for _, test := range tests {
+		config := loadTestConfigFile(t, test.content)
+		if got := test.field(config); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.content, got, test.want)
+		}
+	}
+}
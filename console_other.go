@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+// enableVirtualTerminal reports whether the terminal interprets escape codes,
+// which every supported non-Windows terminal does
+func enableVirtualTerminal() bool {
+	return true
+}
+
+// clearConsole is only needed for Windows consoles without VT support
+func clearConsole() {}
@@ -0,0 +1,58 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// cpUTF8 is the console code page for UTF-8 output
+const cpUTF8 = 65001
+
+var (
+	kernel32                       = windows.NewLazySystemDLL("kernel32.dll")
+	procFillConsoleOutputCharacter = kernel32.NewProc("FillConsoleOutputCharacterW")
+	procFillConsoleOutputAttribute = kernel32.NewProc("FillConsoleOutputAttribute")
+)
+
+// enableVirtualTerminal switches the console to UTF-8 and turns on VT escape
+// sequence processing. It reports false on consoles without VT support, such
+// as cmd.exe before Windows 10, where escape codes would print as garbage.
+func enableVirtualTerminal() bool {
+	handle := windows.Handle(os.Stdout.Fd())
+
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		// Not a console (pipe or file); escape codes are not interpreted anyway
+		return true
+	}
+
+	windows.SetConsoleOutputCP(cpUTF8)
+
+	mode |= windows.ENABLE_PROCESSED_OUTPUT | windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING
+	return windows.SetConsoleMode(handle, mode) == nil
+}
+
+// clearConsole blanks the console buffer and homes the cursor using the
+// console API, for consoles that do not understand escape codes
+func clearConsole() {
+	handle := windows.Handle(os.Stdout.Fd())
+
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(handle, &info); err != nil {
+		return
+	}
+
+	cells := uint32(info.Size.X) * uint32(info.Size.Y)
+	var home windows.Coord
+	var written uint32
+
+	procFillConsoleOutputCharacter.Call(uintptr(handle), uintptr(' '), uintptr(cells),
+		uintptr(*(*uint32)(unsafe.Pointer(&home))), uintptr(unsafe.Pointer(&written)))
+	procFillConsoleOutputAttribute.Call(uintptr(handle), uintptr(info.Attributes), uintptr(cells),
+		uintptr(*(*uint32)(unsafe.Pointer(&home))), uintptr(unsafe.Pointer(&written)))
+	windows.SetConsoleCursorPosition(handle, home)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ToggleContexts shows or hides the virtual contexts of the selected
+// device's logical device
+func (dm *DisplayManager) ToggleContexts() {
+	dm.contexts = !dm.contexts && dm.Selected() != nil
+	dm.flush()
+}
+
+// selectedGroup returns the logical device group of the selected device
+func (dm *DisplayManager) selectedGroup() *LogicalDeviceGroup {
+	device := dm.Selected()
+	if device == nil || dm.lastData == nil {
+		return nil
+	}
+	for i := range dm.lastData.LogicalDeviceGroups {
+		group := &dm.lastData.LogicalDeviceGroups[i]
+		if group.LogicalDevice.ID == device.LogicalDevice.ID {
+			return group
+		}
+	}
+	return nil
+}
+
+// contextLines lists the virtual contexts of a logical device as a table,
+// the default one first, as many as fit on the screen. The API reports no
+// state of its own for a context.
+func (dm *DisplayManager) contextLines(group *LogicalDeviceGroup) []string {
+	bold := dm.getColor(ColorBold)
+	dim := dm.getColor(ColorDim)
+	green := dm.getColor(ColorGreen)
+	reset := dm.getColor(ColorReset)
+
+	contexts := append([]VirtualContext(nil), group.LogicalDevice.VirtualContexts...)
+	sort.SliceStable(contexts, func(i, j int) bool {
+		if contexts[i].IsDefault != contexts[j].IsDefault {
+			return contexts[i].IsDefault
+		}
+		return contexts[i].Name < contexts[j].Name
+	})
+
+	lines := []string{
+		fmt.Sprintf("%sVirtual contexts of %s%s (%d)", bold, group.LogicalDevice.Name, reset, len(contexts)),
+		"",
+	}
+	if len(contexts) == 0 {
+		lines = append(lines, "No virtual contexts")
+	}
+
+	nameWidth := len("Name")
+	for _, vc := range contexts {
+		nameWidth = max(nameWidth, displayWidth(vc.Name))
+	}
+	nameWidth = min(nameWidth, max(8, dm.termWidth-30))
+
+	// Title, blank lines, header, hint and the box borders take 7 lines
+	room := max(1, dm.termHeight-headerLines-footerLines-7)
+	if len(contexts) > 0 {
+		lines = append(lines, dim+padString("Name", nameWidth, true)+"  Default  ID"+reset)
+	}
+	for i, vc := range contexts {
+		if i == room-1 && len(contexts) > room {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(contexts)-i))
+			break
+		}
+		isDefault := "       "
+		if vc.IsDefault {
+			isDefault = green + "yes" + reset + "    "
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s  %s", padString(truncateString(vc.Name, nameWidth), nameWidth, true), isDefault, vc.ID))
+	}
+
+	return append(lines, "", dim+"v: close"+reset)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// logPollResult writes what the TUI would show to the log in daemon mode.
+// Errors are logged when they first appear or change, recoveries once,
+// and device events as they happen.
+func (p *Presenter) logPollResult(data *GroupedDevices, events []DeviceEvent, err error) {
+	if err != nil {
+		message := err.Error()
+		if message != p.lastLogged {
+			log.Printf("poll failed: %s", message)
+			p.lastLogged = message
+		}
+		sdNotify("STATUS=Poll failed: " + message)
+		return
+	}
+
+	if p.lastLogged != "" {
+		log.Printf("poll recovered")
+		p.lastLogged = ""
+	}
+
+	for _, event := range events {
+		log.Print(formatEvent(event))
+	}
+
+	summary := NewReportSummary(data)
+	sdNotify(fmt.Sprintf("STATUS=%d devices, %d connected, %d disconnected",
+		summary.Total, summary.Connected, summary.Disconnected))
+}
+
+// formatEvent renders a device event as a single log line
+func formatEvent(event DeviceEvent) string {
+	var line string
+	switch event.Type {
+	case EventDeviceAdded:
+		line = fmt.Sprintf("device %s (%s) added", event.DeviceName, event.LogicalDevice)
+	case EventDeviceRemoved:
+		line = fmt.Sprintf("device %s (%s) removed", event.DeviceName, event.LogicalDevice)
+	case EventSerialChanged:
+		line = fmt.Sprintf("device %s (%s) serial number changed: %s -> %s",
+			event.DeviceName, event.LogicalDevice, event.From, event.To)
+	case EventFailover:
+		line = fmt.Sprintf("cluster %s failed over: ACTIVE %s -> %s", event.LogicalDevice, event.From, event.To)
+	default:
+		line = fmt.Sprintf("device %s (%s) %s: %s -> %s",
+			event.DeviceName, event.LogicalDevice, event.Field, event.From, event.To)
+	}
+	if event.Acknowledged {
+		line += " (acknowledged)"
+	}
+	return line
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// demoStep is how often the simulated fleet changes
+const demoStep = 4 * time.Second
+
+// demoOutageChance is the chance per step that the simulated API starts
+// failing, for demoOutage
+const (
+	demoOutageChance = 0.03
+	demoOutage       = 15 * time.Second
+)
+
+// Connection states, health states and roles of the management API
+const (
+	stateConnected    = "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTED"
+	stateConnecting   = "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTING"
+	stateDisconnected = "PHYSICAL_DEVICE_CONNECTION_STATE_DISCONNECTED"
+	healthHealthy     = "PHYSICAL_DEVICE_HEALTH_STATUS_HEALTHY"
+	healthWarning     = "PHYSICAL_DEVICE_HEALTH_STATUS_WARNING"
+	healthCritical    = "PHYSICAL_DEVICE_HEALTH_STATUS_CRITICAL"
+	roleActive        = "ACTIVE_STANDBY_ROLE_ACTIVE"
+	roleStandby       = "ACTIVE_STANDBY_ROLE_STANDBY"
+)
+
+// DemoFleet is a made-up set of clusters and standalone devices that keeps
+// changing: devices disconnect and come back, health gets worse and better,
+// clusters fail over, and now and then the whole API fails for a while
+type DemoFleet struct {
+	mu          sync.Mutex
+	devices     []PhysicalDevice
+	version     int       // Counts changes, sent as the ETag
+	outageUntil time.Time // The API answers 503 until then
+}
+
+// NewDemoFleet returns a fleet of three clusters and four standalone devices
+func NewDemoFleet() *DemoFleet {
+	now := time.Now().UTC()
+	fleet := &DemoFleet{}
+
+	contexts := func(names ...string) []VirtualContext {
+		var vcs []VirtualContext
+		for i, name := range names {
+			vcs = append(vcs, VirtualContext{ID: fmt.Sprintf("vc-%s", name), Name: name, IsDefault: i == 0})
+		}
+		return vcs
+	}
+
+	clusters := []struct {
+		name, prefix, model, version string
+		contexts                     []VirtualContext
+	}{
+		{"msk-core", "fw-msk", "PT NGFW 3010", "11.1.2", contexts("main", "dmz", "office", "voip")},
+		{"spb-dc", "fw-spb", "PT NGFW 2010", "11.1.2", contexts("main", "servers")},
+		{"ekb-edge", "fw-ekb", "PT NGFW 1010", "11.0.5", contexts("main")},
+	}
+	for c, cluster := range clusters {
+		logical := LogicalDevice{
+			ID:              fmt.Sprintf("ld-%d", c+1),
+			Name:            cluster.name,
+			TopologyType:    "TOPOLOGY_TYPE_ACTIVE_STANDBY",
+			VirtualContexts: cluster.contexts,
+		}
+		for n := 1; n <= 2; n++ {
+			role := roleActive
+			if n == 2 {
+				role = roleStandby
+			}
+			fleet.devices = append(fleet.devices, PhysicalDevice{
+				ID:              fmt.Sprintf("pd-%d%d", c+1, n),
+				LogicalDevice:   logical,
+				Name:            fmt.Sprintf("%s-%d", cluster.prefix, n),
+				Model:           cluster.model,
+				SerialNumber:    fmt.Sprintf("PT%d%05d", c+1, 10000+n*137),
+				ConnectionState: stateConnected,
+				Address:         fmt.Sprintf("10.%d.0.%d", c+1, n),
+				AddressType:     "PHYSICAL_DEVICE_ADDRESS_TYPE_IPV4",
+				LastConnectedAt: now.Format(time.RFC3339),
+				TopologyType:    "TOPOLOGY_TYPE_ACTIVE_STANDBY",
+				HealthStatus:    healthHealthy,
+				ProductVersion:  cluster.version,
+				SoftwareVersion: cluster.version,
+				AsNode: &AsNode{
+					SyncLinkIP:   fmt.Sprintf("192.168.%d.%d", c+1, n),
+					SyncLinkPort: 4001,
+					Priority:     n,
+					Role:         role,
+					SuspendMode:  "PHYSICAL_DEVICE_SUSPEND_MODE_OFF",
+				},
+			})
+		}
+	}
+
+	for s, name := range []string{"kzn", "nsk", "sochi", "lab"} {
+		state, health := stateConnected, healthHealthy
+		if name == "lab" {
+			state, health = stateConnecting, "PHYSICAL_DEVICE_HEALTH_STATUS_UNSPECIFIED"
+		}
+		fleet.devices = append(fleet.devices, PhysicalDevice{
+			ID: fmt.Sprintf("pd-s%d", s+1),
+			LogicalDevice: LogicalDevice{
+				ID:              fmt.Sprintf("ld-s%d", s+1),
+				Name:            "branch-" + name,
+				TopologyType:    "TOPOLOGY_TYPE_STANDALONE",
+				VirtualContexts: contexts("main"),
+			},
+			Name:            "fw-" + name,
+			Model:           "PT NGFW 1010",
+			SerialNumber:    fmt.Sprintf("PT9%05d", 20000+s*311),
+			ConnectionState: state,
+			Address:         fmt.Sprintf("172.16.%d.1", s+1),
+			AddressType:     "PHYSICAL_DEVICE_ADDRESS_TYPE_IPV4",
+			LastConnectedAt: now.Add(-time.Duration(s) * time.Hour).Format(time.RFC3339),
+			TopologyType:    "TOPOLOGY_TYPE_STANDALONE",
+			HealthStatus:    health,
+			ProductVersion:  "11.0.5",
+			SoftwareVersion: "11.0.5",
+		})
+	}
+
+	return fleet
+}
+
+// Run changes the fleet every demoStep, for as long as the process runs
+func (f *DemoFleet) Run() {
+	for range time.Tick(demoStep) {
+		f.step()
+	}
+}
+
+// step makes one random change
+func (f *DemoFleet) step() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(f.outageUntil) {
+		return
+	}
+	if rand.Float64() < demoOutageChance {
+		f.outageUntil = now.Add(demoOutage)
+		return
+	}
+
+	// Problems are fixed more often than they start, so most of the fleet
+	// stays healthy
+	var fine, troubled []*PhysicalDevice
+	for i := range f.devices {
+		if device := &f.devices[i]; device.ConnectionState == stateConnected && device.HealthStatus == healthHealthy {
+			fine = append(fine, device)
+		} else {
+			troubled = append(troubled, device)
+		}
+	}
+
+	f.version++
+	if len(troubled) > 0 && (len(fine) == 0 || rand.N(10) < 6) {
+		device := troubled[rand.N(len(troubled))]
+		switch device.ConnectionState {
+		case stateConnected:
+			device.HealthStatus = healthHealthy
+		case stateConnecting:
+			device.ConnectionState = stateConnected
+			device.HealthStatus = healthHealthy
+			device.LastConnectedAt = now.UTC().Format(time.RFC3339)
+		default:
+			// Devices come back through CONNECTING
+			device.ConnectionState = stateConnecting
+		}
+		return
+	}
+
+	device := fine[rand.N(len(fine))]
+	switch roll := rand.N(10); {
+	case device.AsNode != nil && device.AsNode.Role == roleActive && roll < 4:
+		f.failover(device)
+	case roll < 6:
+		device.HealthStatus = []string{healthWarning, healthCritical}[rand.N(2)]
+	default:
+		device.ConnectionState = stateDisconnected
+		device.HealthStatus = "PHYSICAL_DEVICE_HEALTH_STATUS_UNSPECIFIED"
+	}
+}
+
+// failover disconnects the ACTIVE node active and makes its peer ACTIVE;
+// call with f.mu held
+func (f *DemoFleet) failover(active *PhysicalDevice) {
+	for i := range f.devices {
+		peer := &f.devices[i]
+		if peer.LogicalDevice.ID == active.LogicalDevice.ID && peer.ID != active.ID &&
+			peer.ConnectionState == stateConnected {
+			peer.AsNode.Role = roleActive
+			active.AsNode.Role = roleStandby
+			active.ConnectionState = stateDisconnected
+			return
+		}
+	}
+}
+
+// Devices returns a copy of the current fleet, or an error during an outage.
+// It is the mockSource of the demo.
+func (f *DemoFleet) Devices() ([]PhysicalDevice, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Now().Before(f.outageUntil) {
+		return nil, "", fmt.Errorf("simulated outage of the demo API")
+	}
+
+	devices := make([]PhysicalDevice, len(f.devices))
+	for i, device := range f.devices {
+		if device.AsNode != nil {
+			node := *device.AsNode
+			device.AsNode = &node
+		}
+		devices[i] = device
+	}
+	return devices, strconv.Itoa(f.version), nil
+}
+
+// startDemo serves a DemoFleet on a local port for -demo and points the
+// config at it. The server runs until the process exits.
+func startDemo(config *Config) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start demo API: %w", err)
+	}
+
+	fleet := NewDemoFleet()
+	go fleet.Run()
+	go func() {
+		if err := http.Serve(listener, &MockServer{source: fleet.Devices}); err != nil {
+			log.Printf("demo API: %v", err)
+		}
+	}()
+
+	config.BaseURL = fmt.Sprintf("http://%s/api/v2/", listener.Addr())
+	// The last known devices of a real API must not be replaced by made-up ones
+	config.StateFile = ""
+	config.StreamEnabled = false
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ToggleDetails shows or hides the details of the selected device
+func (dm *DisplayManager) ToggleDetails() {
+	dm.details = !dm.details && dm.Selected() != nil
+	dm.flush()
+}
+
+// detailLines describes a device for the details overlay
+func (dm *DisplayManager) detailLines(device *PhysicalDevice) []string {
+	bold := dm.getColor(ColorBold)
+	dim := dm.getColor(ColorDim)
+	reset := dm.getColor(ColorReset)
+
+	status := device.GetConnectionStateDisplay()
+	if device.Flapping {
+		status += ", FLAPPING"
+	}
+	product := device.GetProductVersionDisplay()
+	if device.Outdated {
+		product = dm.getColor(ColorYellow) + product + " (OUTDATED, target " + dm.config.TargetVersion + ")" + reset
+	}
+	software := device.GetSoftwareVersionDisplay()
+	if device.VersionMismatch() {
+		software = dm.getColor(ColorYellow) + software + " (differs, upgrade not completed?)" + reset
+	}
+
+	lines := []string{
+		bold + device.Name + reset,
+		"",
+		fmt.Sprintf("Logical device  %s", device.LogicalDevice.Name),
+		fmt.Sprintf("Model           %s", device.Model),
+		fmt.Sprintf("Serial number   %s", device.SerialNumber),
+		fmt.Sprintf("Address         %s", device.Address),
+		fmt.Sprintf("Description     %s", device.Description),
+		fmt.Sprintf("Status          %s", status),
+		fmt.Sprintf("Health          %s", device.GetHealthStatusDisplay()),
+		fmt.Sprintf("Product version %s", product),
+		fmt.Sprintf("Software        %s", software),
+		fmt.Sprintf("Last connected  %s", device.GetLastConnectedDisplay()),
+	}
+	if role := device.GetRoleDisplay(); role != "" {
+		lines = append(lines, fmt.Sprintf("Role            %s", role))
+	}
+	if device.AsNode != nil {
+		suspend := device.GetSuspendModeDisplay()
+		if device.Suspended() {
+			suspend = dm.getColor(ColorYellow) + suspend + " (does not take over)" + reset
+		}
+		lines = append(lines,
+			fmt.Sprintf("Suspend mode    %s", suspend),
+			fmt.Sprintf("Sync link       %s", device.GetSyncLinkDisplay()),
+		)
+	}
+	if len(device.Labels) > 0 {
+		lines = append(lines, fmt.Sprintf("Labels          %s", device.GetLabelsDisplay()))
+	}
+	if device.Probe != nil {
+		lines = append(lines, fmt.Sprintf("Reachable       %s", device.GetReachableDisplay()))
+	}
+	if device.Ack != nil {
+		lines = append(lines, fmt.Sprintf("Acknowledged    %s", strings.TrimPrefix(device.GetAckDisplay(), "ACK ")))
+		if device.Ack.Comment != "" {
+			lines = append(lines, fmt.Sprintf("                %s", device.Ack.Comment))
+		}
+	}
+
+	lines = append(lines, "")
+	if device.Note != nil {
+		lines = append(lines,
+			fmt.Sprintf("Note            %s", device.Note.Text),
+			dim+fmt.Sprintf("                by %s, %s", device.Note.Author, formatTime(device.Note.Updated, "2006-01-02 15:04"))+reset,
+			"",
+		)
+	}
+
+	return append(lines, dim+"Enter: close │ n: edit note │ a: acknowledge"+reset)
+}
+
+// StartInput shows a one-line text field in the footer, filled with text
+func (dm *DisplayManager) StartInput(prompt, text string) {
+	dm.inputPrompt = prompt
+	dm.input = []rune(text)
+	dm.inputActive = true
+	dm.flush()
+}
+
+// Editing reports whether the text field takes the key presses
+func (dm *DisplayManager) Editing() bool {
+	return dm.inputActive
+}
+
+// InputKey edits the text field. Enter returns its text with accepted set,
+// Esc returns with accepted unset; both close the field.
+func (dm *DisplayManager) InputKey(ev *tcell.EventKey) (text string, done, accepted bool) {
+	switch ev.Key() {
+	case tcell.KeyEnter:
+		dm.inputActive = false
+		text, done, accepted = string(dm.input), true, true
+	case tcell.KeyEscape:
+		dm.inputActive = false
+		done = true
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(dm.input) > 0 {
+			dm.input = dm.input[:len(dm.input)-1]
+		}
+	case tcell.KeyCtrlU:
+		dm.input = dm.input[:0]
+	case tcell.KeyRune:
+		if unicode.IsPrint(ev.Rune()) {
+			dm.input = append(dm.input, ev.Rune())
+		}
+	}
+
+	dm.flush()
+	return text, done, accepted
+}
+
+// drawInput draws the text field over the footer's status line
+func (dm *DisplayManager) drawInput() {
+	if !dm.inputActive {
+		dm.screen.HideCursor()
+		return
+	}
+
+	y := dm.termHeight - 2
+	prefix := "│ " + dm.getColor(ColorBold) + dm.inputPrompt + dm.getColor(ColorReset) + " "
+	room := dm.termWidth - displayWidth(prefix) - 3
+
+	// Keep the end of a long text, where the cursor is, in view
+	text := dm.input
+	if len(text) > room {
+		text = text[len(text)-room:]
+	}
+
+	line := prefix + string(text)
+	line += strings.Repeat(" ", max(0, dm.termWidth-displayWidth(line)-2)) + " │"
+	if dm.ascii {
+		line = asciiBorders.Replace(line)
+	}
+
+	dm.drawLine(0, y, line)
+	dm.screen.ShowCursor(displayWidth(prefix)+len(text), y)
+}
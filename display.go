@@ -1,7 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"os"
 	"regexp"
 	"sort"
@@ -9,17 +13,55 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/gdamore/tcell/v2"
 	"golang.org/x/term"
 )
 
 type DisplayManager struct {
 	config       *Config
 	lastData     *GroupedDevices
+	restoredAt   time.Time // LastUpdated of data from the state file, until a poll succeeds
 	errorMessage string
+	lastError    error
 	termWidth    int
 	termHeight   int
 	startRow     int
 	linesDrawn   int
+	streaming    bool
+	interval     time.Duration
+	flashMessage string
+	flashUntil   time.Time
+	fullScreen   bool
+	vt           bool         // Terminal interprets escape codes
+	frame        bytes.Buffer // Frame being built without tcell, written in one piece by flush
+	ascii        bool // Draw borders with asciiBorders
+	screen       tcell.Screen
+	events       chan tcell.Event
+	lines        []string // Frame being built for the screen
+	scroll       int      // First visible device line when the frame does not fit
+	diagnostics  bool     // Show the connection timing overlay
+	timing       *RequestTiming
+	pollStats    PollStats
+	nextPoll     time.Time
+	rows         []frameRow // Device lines of the frame
+	selected     string     // ID of the selected device
+	details      bool       // Show the details of the selected device
+	eventLog     bool       // Show the recent device events
+	help         bool       // Show the keyboard shortcuts and the current view
+	contexts     bool       // Show the virtual contexts of the selected device's logical device
+	versions     bool       // Show how many devices run each version
+	stats        bool       // Show the fleet statistics
+	collapsed    map[string]bool // Logical devices collapsed (true) or expanded (false) with the space key
+	collapseHealthy bool         // Collapse the groups without problems that were not expanded
+	sortKey      string     // Column the devices of each group are sorted by, chosen by a click on its title
+	sortDesc     bool
+	groupOrder   string // name or severity, from -group_order; the 'o' key switches
+	search       string // Only devices matching this are shown, see SetSearch
+	recentEvents []DeviceEvent
+	failovers    map[string]DeviceEvent // Last failover by logical device name
+	inputActive  bool       // The footer shows a text field
+	inputPrompt  string
+	input        []rune
 }
 
 const (
@@ -35,6 +77,16 @@ const (
 	ColorDim    = "\033[2m"
 )
 
+// asciiBorders maps box-drawing characters to ASCII for consoles without
+// Unicode fonts or VT support
+var asciiBorders = strings.NewReplacer(
+	"─", "-", "│", "|",
+	"┌", "+", "┐", "+", "└", "+", "┘", "+",
+	"├", "+", "┤", "+", "┼", "+",
+	// RTT sparklines
+	"▁", "_", "▂", "_", "▃", ".", "▄", "-", "▅", "-", "▆", "=", "▇", "#", "█", "#", "×", "x",
+)
+
 func NewDisplayManager(config *Config) *DisplayManager {
 	width, height, err := term.GetSize(int(os.Stdout.Fd()))
 	if err != nil {
@@ -47,17 +99,33 @@ func NewDisplayManager(config *Config) *DisplayManager {
 		termHeight: height,
 		startRow:   -1, // Will be set on first render
 		linesDrawn: 0,
+		failovers:  make(map[string]DeviceEvent),
+		collapsed:  make(map[string]bool),
+		collapseHealthy: config.CollapseHealthy,
+		groupOrder:      config.GroupOrder,
 	}
 
+	// Legacy Windows consoles print escape codes literally, so colors and
+	// box drawing are dropped there
+	dm.vt = enableVirtualTerminal()
+	dm.ascii = config.ASCIIBorders || !dm.vt
+
 	return dm
 }
 
+// StartFullScreenMode takes over the terminal. Raw escape-code output is
+// only used when tcell cannot open the terminal.
 func (dm *DisplayManager) StartFullScreenMode() {
+	if term.IsTerminal(int(os.Stdout.Fd())) && dm.startScreen() == nil {
+		dm.fullScreen = true
+		return
+	}
+
 	dm.initFullScreen()
 }
 
 func (dm *DisplayManager) initFullScreen() {
-	if term.IsTerminal(int(os.Stdout.Fd())) {
+	if term.IsTerminal(int(os.Stdout.Fd())) && dm.vt {
 		// Clear entire screen
 		fmt.Print("\033[2J")
 		// Move cursor to top-left
@@ -66,20 +134,67 @@ func (dm *DisplayManager) initFullScreen() {
 		fmt.Print("\033[?25l")
 		// Enable alternate screen buffer (like top/htop)
 		fmt.Print("\033[?1049h")
+		dm.fullScreen = true
 	}
 }
 
 func (dm *DisplayManager) ClearScreen() {
-	// Clear entire screen and move cursor to top-left
-	fmt.Print("\033[2J\033[H")
+	dm.lines = dm.lines[:0]
+	dm.rows = dm.rows[:0]
 	dm.linesDrawn = 0
 }
 
+// writeFrame sends the frame built without tcell to the terminal in a single
+// write. Terminals that support synchronized output show it all at once,
+// others ignore the sequence.
+func (dm *DisplayManager) writeFrame() {
+	if len(dm.lines) == 0 {
+		return
+	}
+
+	// The frame overwrites the previous one from the top-left corner, so the
+	// screen is never blank in between
+	dm.frame.Reset()
+	if dm.vt {
+		dm.frame.WriteString("\033[H")
+	} else {
+		clearConsole()
+	}
+	// The last line ends without a newline, which would scroll a frame
+	// as high as the screen
+	dm.frameWrite(strings.Join(dm.visibleLines(), "\n"))
+	if dm.vt {
+		// Erase what is left of the previous, longer frame
+		dm.frame.WriteString("\033[J")
+		os.Stdout.Write(append(append([]byte("\033[?2026h"), dm.frame.Bytes()...), "\033[?2026l"...))
+	} else {
+		os.Stdout.Write(dm.frame.Bytes())
+	}
+	dm.frame.Reset()
+}
+
+// frameWrite adds text to the frame built without tcell; each line erases
+// the rest of the previous frame's line
+func (dm *DisplayManager) frameWrite(text string) {
+	if dm.vt {
+		text = strings.ReplaceAll(text, "\n", "\033[K\n")
+	}
+	dm.frame.WriteString(text)
+}
+
 func (dm *DisplayManager) MoveCursor() {
 	fmt.Print("\033[H")
 }
 func (dm *DisplayManager) RestoreTerminal() {
-	if term.IsTerminal(int(os.Stdout.Fd())) {
+	if dm.screen != nil {
+		dm.screen.Fini()
+		dm.screen = nil
+		dm.fullScreen = false
+		return
+	}
+
+	if dm.fullScreen {
+		dm.fullScreen = false
 		// Disable alternate screen buffer (return to normal terminal)
 		fmt.Print("\033[?1049l")
 		// Show cursor
@@ -91,7 +206,56 @@ func (dm *DisplayManager) RestoreTerminal() {
 	}
 }
 
+// LastData returns the most recent successfully fetched device data
+func (dm *DisplayManager) LastData() *GroupedDevices {
+	return dm.lastData
+}
+
+// SetLastKnown shows data saved by a previous run as last known data until
+// a poll succeeds
+func (dm *DisplayManager) SetLastKnown(data *GroupedDevices) {
+	dm.lastData = data
+	dm.restoredAt = data.LastUpdated
+}
+
+// Flash shows message in the footer for the given duration
+func (dm *DisplayManager) Flash(message string, duration time.Duration) {
+	dm.flashMessage = message
+	dm.flashUntil = time.Now().Add(duration)
+}
+
+// HasError reports whether the last render showed an error
+func (dm *DisplayManager) HasError() bool {
+	return dm.errorMessage != ""
+}
+
+// SetEffectiveInterval records the poll interval currently used by the scheduler
+func (dm *DisplayManager) SetEffectiveInterval(interval time.Duration) {
+	dm.interval = interval
+}
+
+// SetNextPoll records when the scheduler polls next, for the countdown in
+// the footer
+func (dm *DisplayManager) SetNextPoll(at time.Time) {
+	dm.nextPoll = at
+}
+
+// SetPollStats records the latency and success rate of recent polls
+func (dm *DisplayManager) SetPollStats(stats PollStats) {
+	dm.pollStats = stats
+}
+
+// SetStreaming records whether updates currently arrive via the change stream
+func (dm *DisplayManager) SetStreaming(streaming bool) {
+	dm.streaming = streaming
+}
+
 func (dm *DisplayManager) UpdateTerminalSize() {
+	if dm.screen != nil {
+		dm.termWidth, dm.termHeight = dm.screen.Size()
+		return
+	}
+
 	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
 		dm.termWidth = width
 		dm.termHeight = height
@@ -99,12 +263,20 @@ func (dm *DisplayManager) UpdateTerminalSize() {
 }
 
 func (dm *DisplayManager) printLine(text string) {
-	fmt.Println(text)
+	if dm.ascii {
+		text = asciiBorders.Replace(text)
+	}
 	dm.linesDrawn++
+	dm.lines = append(dm.lines, text)
 }
 
 func (dm *DisplayManager) printf(format string, args ...interface{}) {
-	fmt.Printf(format, args...)
+	text := fmt.Sprintf(format, args...)
+	if dm.ascii {
+		text = asciiBorders.Replace(text)
+	}
+
+	dm.lines = append(dm.lines, strings.Split(strings.TrimSuffix(text, "\n"), "\n")...)
 
 	for _, char := range format {
 		if char == '\n' {
@@ -116,7 +288,7 @@ func (dm *DisplayManager) printf(format string, args ...interface{}) {
 // displayWidth calculates the actual display width of a string, excluding ANSI escape sequences
 func displayWidth(s string) int {
 	// Remove ANSI escape sequences using regex
-	ansiRegex := regexp.MustCompile(`\033\[[0-9;]*[a-zA-Z]`)
+	ansiRegex := regexp.MustCompile(`\033\[[0-9;]*[a-zA-Z]|\033\]8;[^\033]*\033\\`)
 	cleanString := ansiRegex.ReplaceAllString(s, "")
 	// Use UTF-8 rune count instead of byte length to handle Unicode characters correctly
 	return utf8.RuneCountInString(cleanString)
@@ -124,38 +296,61 @@ func displayWidth(s string) int {
 
 // stripColors removes all ANSI color codes from a string
 func stripColors(s string) string {
-	ansiRegex := regexp.MustCompile(`\033\[[0-9;]*[a-zA-Z]`)
+	ansiRegex := regexp.MustCompile(`\033\[[0-9;]*[a-zA-Z]|\033\]8;[^\033]*\033\\`)
 	return ansiRegex.ReplaceAllString(s, "")
 }
 
 // Render renders the complete display
 func (dm *DisplayManager) Render(data *GroupedDevices, err error) {
-	dm.ClearScreen()
-
 	if err != nil {
 		dm.errorMessage = err.Error()
+		dm.lastError = err
 	} else {
 		dm.errorMessage = ""
+		dm.lastError = nil
 		dm.lastData = data
 	}
 
+	dm.Redraw()
+}
+
+// minTermWidth is the narrowest terminal the table is drawn in; a narrower
+// one, e.g. while a window is being resized, only gets a notice
+const minTermWidth = 20
+
+// Redraw renders the last known state again without changing it
+func (dm *DisplayManager) Redraw() {
+	dm.ClearScreen()
+
+	if dm.termWidth < minTermWidth {
+		dm.printLine("Terminal too small")
+		dm.flush()
+		return
+	}
+
 	dm.renderHeader()
 
 	if dm.errorMessage != "" {
 		dm.renderError()
 		if dm.lastData != nil {
-			lastUpdateTime := dm.lastData.LastUpdated.Format("2006-01-02 15:04:05")
+			lastUpdateTime := formatShownTime(dm.lastData.LastUpdated, "2006-01-02 15:04:05")
 			message := fmt.Sprintf("Last known data (from %s):", lastUpdateTime)
 			dm.renderSubheader(message)
 			dm.renderDeviceGroups(dm.lastData)
 		}
-	} else if data != nil {
-		dm.renderDeviceGroups(data)
+	} else if dm.lastData != nil {
+		// Re-annotated restored data keeps its time until a poll succeeds
+		if !dm.restoredAt.IsZero() && dm.lastData.LastUpdated.Equal(dm.restoredAt) {
+			lastUpdateTime := formatShownTime(dm.lastData.LastUpdated, "2006-01-02 15:04:05")
+			dm.renderSubheader(fmt.Sprintf("Last known data (from %s):", lastUpdateTime))
+		}
+		dm.renderDeviceGroups(dm.lastData)
 	} else {
 		dm.renderMessage("Waiting for data...")
 	}
 
 	dm.renderFooter()
+	dm.flush()
 }
 
 // renderHeader renders the application header
@@ -163,19 +358,39 @@ func (dm *DisplayManager) renderHeader() {
 	// Use actual terminal width or fallback to configured width
 	tableWidth := dm.termWidth
 
-	border := strings.Repeat("─", tableWidth-2) // -2 for border chars
+	border := strings.Repeat("─", max(0, tableWidth-2)) // -2 for border chars
 	dm.printf("┌%s┐\n", border)
 
-	title := "Physical Devices Monitor"
+	title := "Physical Devices Monitor " + shortVersion()
 	if dm.config.ShowTimestamp {
-		timestamp := time.Now().Format("2006-01-02 15:04:05")
-		totalDevices := 0
+		// The last successful poll: a response without changes confirms
+		// the data as well
+		updated := dm.pollStats.LastSuccess
+		totalDevices, outdated := 0, 0
 		if dm.lastData != nil {
 			totalDevices = dm.lastData.TotalDevices
+			outdated = countOutdated(dm.lastData)
+			if updated.IsZero() {
+				updated = dm.lastData.LastUpdated
+			}
 		}
 
-		title = fmt.Sprintf("%s - Last Updated: %s (Total: %d)",
+		timestamp := "-"
+		if !updated.IsZero() {
+			timestamp = formatShownTime(updated, "2006-01-02 15:04:05")
+		}
+
+		title = fmt.Sprintf("%s - Last Updated: %s (Total: %d",
 			title, timestamp, totalDevices)
+		if dm.config.TargetVersion != "" {
+			title += fmt.Sprintf(", Outdated: %d below %s", outdated, dm.config.TargetVersion)
+		}
+		title += ")"
+	}
+	title += dm.searchTitle()
+	// Connected devices of the last polls, so a brief dip stays visible
+	if trend := connectedTrend(dm.pollStats.Connected); trend != "" && displayWidth(title)+len(" - Connected: ")+displayWidth(trend) <= tableWidth-4 {
+		title += " - Connected: " + trend
 	}
 
 	padding := tableWidth - displayWidth(title) - 4 // -4 for "│ " and " │"
@@ -186,40 +401,37 @@ func (dm *DisplayManager) renderHeader() {
 	dm.printLine(line)
 
 	dm.printf("├%s┤\n", border)
+	dm.renderTableHeaders()
 }
 
-// simplifyErrorMessage extracts the essential part of an error message
-func (dm *DisplayManager) simplifyErrorMessage(errorMsg string) string {
-	// Define error patterns and their simplified messages
-	errorPatterns := map[string]string{
-		"context deadline exceeded": "Connection timeout",
-		"connection refused":        "Connection refused",
-		"no such host":              "Host not found",
-		"invalid credentials":       "Invalid credentials",
-		"unauthorized":              "Authentication failed",
-		"forbidden":                 "Access denied",
-		"not found":                 "Endpoint not found",
-		"internal server error":     "Server error",
-		"bad gateway":               "Bad gateway",
-		"service unavailable":       "Service unavailable",
-		"network is unreachable":    "Network unreachable",
-		"certificate":               "Certificate error",
-		"tls":                       "TLS/SSL error",
-		"timeout":                   "Connection timeout",
-		"connection reset":          "Connection reset",
-		"broken pipe":               "Connection broken",
-	}
-
-	errorLower := strings.ToLower(errorMsg)
-
-	// Check for known patterns
-	for pattern, simplifiedMsg := range errorPatterns {
-		if strings.Contains(errorLower, pattern) {
-			return simplifiedMsg
+// describeError turns a poll error into a short message: its category, plus
+// the API's own message when the API returned a structured error
+func (dm *DisplayManager) describeError(err error) string {
+	category := ClassifyError(err)
+
+	if category == ErrorRateLimited {
+		if delay := max(RetryAfter(err), dm.interval); delay > 0 {
+			return fmt.Sprintf("Rate limited, next attempt in %v", delay)
 		}
 	}
 
-	// If no pattern matches, try to extract the last meaningful part
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Structured {
+		return fmt.Sprintf("%s: %s", category, apiErr.Message)
+	}
+
+	var pinErr *PinMismatchError
+	if errors.As(err, &pinErr) && pinErr.Presented != "" {
+		return fmt.Sprintf("Certificate pin mismatch, server presented sha256/%s", pinErr.Presented)
+	}
+
+	if category != ErrorUnknown {
+		return category.String()
+	}
+
+	errorMsg := err.Error()
+
+	// Otherwise try to extract the last meaningful part
 	parts := strings.Split(errorMsg, ": ")
 	if len(parts) > 1 {
 		lastPart := parts[len(parts)-1]
@@ -261,7 +473,7 @@ func (dm *DisplayManager) renderError() {
 	resetColor := dm.getColor(ColorReset)
 
 	// Simplify the error message
-	simplifiedError := dm.simplifyErrorMessage(dm.errorMessage)
+	simplifiedError := dm.describeError(dm.lastError)
 
 	errorText := fmt.Sprintf("%sERROR: %s%s", errorColor, simplifiedError, resetColor)
 	tableWidth := dm.termWidth
@@ -273,7 +485,7 @@ func (dm *DisplayManager) renderError() {
 	paddedLine := fmt.Sprintf("│ %s%s │", errorText, strings.Repeat(" ", padding))
 	dm.printLine(paddedLine)
 	// Empty line
-	emptyLine := fmt.Sprintf("│%s│", strings.Repeat(" ", tableWidth-2))
+	emptyLine := fmt.Sprintf("│%s│", strings.Repeat(" ", max(0, tableWidth-2)))
 	dm.printLine(emptyLine)
 }
 
@@ -305,25 +517,56 @@ func (dm *DisplayManager) renderDeviceGroups(data *GroupedDevices) {
 		return
 	}
 
-	// Sort groups by logical device name
-	groups := make([]LogicalDeviceGroup, len(data.LogicalDeviceGroups))
-	copy(groups, data.LogicalDeviceGroups)
+	// Sort groups by logical device name, within their -group_by label
+	groups := append([]LogicalDeviceGroup(nil), dm.searchGroups(data.LogicalDeviceGroups)...)
+	if len(groups) == 0 {
+		dm.renderMessage(fmt.Sprintf("No devices match %q (/: search again, empty to show all)", dm.search))
+		return
+	}
+	groupBy := dm.config.GroupBy
 	sort.Slice(groups, func(i, j int) bool {
+		if groupBy != "" {
+			a, b := groupLabel(&groups[i], groupBy), groupLabel(&groups[j], groupBy)
+			if a != b {
+				// Devices without the label go last
+				return b == noLabel || (a != noLabel && a < b)
+			}
+		}
+		if dm.groupOrder == "severity" {
+			if order := compareSeverity(&groups[i], &groups[j]); order != 0 {
+				return order < 0
+			}
+		}
 		return groups[i].LogicalDevice.Name < groups[j].LogicalDevice.Name
 	})
 
+	label := ""
 	for i, group := range groups {
 		if i > 0 {
 
 			tableWidth := dm.termWidth
 
-			emptyLine := fmt.Sprintf("│%s│", strings.Repeat(" ", tableWidth-2))
+			emptyLine := fmt.Sprintf("│%s│", strings.Repeat(" ", max(0, tableWidth-2)))
 			dm.printLine(emptyLine)
 		}
+		if groupBy != "" && (i == 0 || groupLabel(&group, groupBy) != label) {
+			label = groupLabel(&group, groupBy)
+			dm.renderLabelHeader(strings.ToUpper(groupBy) + ": " + label)
+		}
 		dm.renderLogicalDeviceGroup(&group)
 	}
 }
 
+// renderLabelHeader starts the logical devices sharing a -group_by label
+func (dm *DisplayManager) renderLabelHeader(title string) {
+	color := dm.getColor(ColorPurple) + dm.getColor(ColorBold)
+	resetColor := dm.getColor(ColorReset)
+
+	title = "── " + title + " "
+	fill := max(0, dm.termWidth-displayWidth(title)-4)
+	dm.printLine(fmt.Sprintf("│ %s%s%s%s │", color, title, strings.Repeat("─", fill), resetColor))
+}
+
 func (dm *DisplayManager) renderLogicalDeviceGroup(group *LogicalDeviceGroup) {
 
 	topologyColor := dm.getColor(ColorBlue)
@@ -331,19 +574,40 @@ func (dm *DisplayManager) renderLogicalDeviceGroup(group *LogicalDeviceGroup) {
 	resetColor := dm.getColor(ColorReset)
 
 	topology := group.GetTopologyDisplayName()
+	name := dm.hyperlink(dm.objectURL(dm.config.LogicalURL, group.LogicalDevice.ID, group.LogicalDevice.Name), group.LogicalDevice.Name)
 	header := fmt.Sprintf("%sLOGICAL DEVICE: %s %s(%s)%s",
-		boldColor, group.LogicalDevice.Name, topologyColor, topology, resetColor)
+		boldColor, name, topologyColor, topology, resetColor)
+
+	failover := ""
+	if group.IsCluster {
+		failover = dm.failoverDisplay(group.LogicalDevice.Name)
+	}
 
+	// Many contexts are cut short; the 'v' key lists them all
 	contexts := group.GetVirtualContextsDisplay()
+	room := dm.termWidth - 4 - len(fmt.Sprintf("LOGICAL DEVICE: %s (%s) - Contexts: ", group.LogicalDevice.Name, topology))
+	if failover != "" {
+		room -= displayWidth(" - " + failover)
+	}
+	if displayWidth(contexts) > room {
+		contexts = truncateString(contexts, max(0, room-len(" (v: all)")))
+		contexts += " (v: all)"
+	}
 	if contexts != "" {
 		header += fmt.Sprintf(" - Contexts: %s", contexts)
 	}
+	if failover != "" {
+		header += fmt.Sprintf(" - %s%s%s", dm.getColor(ColorDim), failover, resetColor)
+	}
 
 	tableWidth := dm.termWidth
 
 	padding := tableWidth - len(fmt.Sprintf("LOGICAL DEVICE: %s (%s)", group.LogicalDevice.Name, topology)) - 4
 	if contexts != "" {
-		padding -= len(fmt.Sprintf(" - Contexts: %s", contexts))
+		padding -= displayWidth(fmt.Sprintf(" - Contexts: %s", contexts))
+	}
+	if failover != "" {
+		padding -= displayWidth(" - " + failover)
 	}
 	if padding < 0 {
 		padding = 0
@@ -351,56 +615,108 @@ func (dm *DisplayManager) renderLogicalDeviceGroup(group *LogicalDeviceGroup) {
 
 	line := fmt.Sprintf("│ %s%s │", header, strings.Repeat(" ", padding))
 	dm.printLine(line)
+	// A search leaves out devices, which still count for the cluster
+	if group.IsCluster {
+		dm.renderClusterSummary(dm.unsearched(group))
+	}
 
-	for i, device := range group.PhysicalDevices {
-		isLast := i == len(group.PhysicalDevices)-1
-		dm.renderPhysicalDevice(&device, isLast)
+	if dm.isCollapsed(group) && dm.screen != nil {
+		dm.renderCollapsedGroup(group)
+		return
+	}
+
+	warned := priorityWarnings(dm.unsearched(group))
+	devices := dm.sortedDevices(group.PhysicalDevices)
+	for i, device := range devices {
+		isLast := i == len(devices)-1
+		dm.renderPhysicalDevice(&device, isLast, warned[device.ID])
 	}
 }
 
+// renderCollapsedGroup sums up the devices of a collapsed group in a single
+// line: how many there are and the worst state among them. The line stands
+// for the group's first device.
+func (dm *DisplayManager) renderCollapsedGroup(group *LogicalDeviceGroup) {
+	treeChar := "└─"
+	if dm.ascii {
+		treeChar = "`-"
+	}
+
+	summary := fmt.Sprintf("%d devices", len(group.PhysicalDevices))
+	if len(group.PhysicalDevices) == 1 {
+		summary = "1 device"
+	}
+	state, class := worstState(group)
+	color := dm.getColor(ColorDim)
+	if class != classOK && state != "" {
+		summary += ", worst: " + dm.indicate(class, state)
+		color = dm.classColor(class)
+	} else {
+		summary = dm.indicate(class, summary+", all fine")
+	}
+	summary = fmt.Sprintf("%s (collapsed, space: expand)", summary)
+
+	text := fmt.Sprintf(" %s  %s", treeChar, summary)
+	padding := max(0, dm.termWidth-displayWidth(text)-4)
+	dm.rows = append(dm.rows, frameRow{line: len(dm.lines), device: &group.PhysicalDevices[0], group: group})
+	dm.printLine(fmt.Sprintf("│ %s%s%s%s │", color, text, dm.getColor(ColorReset), strings.Repeat(" ", padding)))
+}
+
+// renderTableHeaders writes the column titles, aligned with the device rows,
+// and marks the column the devices are sorted by
 func (dm *DisplayManager) renderTableHeaders() {
 	colWidths := dm.calculateColumnWidths()
 
-	treeCol := padString("", colWidths[0], true)
-	nameCol := padString("Device Name", colWidths[1], true)
-	modelCol := padString("Model", colWidths[2], true)
-	statusCol := padString("Status", colWidths[3], true)
-	addressCol := padString("Address", colWidths[4], true)
-	priorityCol := padString("Priority", colWidths[5], true)
-	versionCol := padString("Version", colWidths[6], true)
+	cells := make([]string, len(dm.config.Columns))
+	for i, column := range dm.config.Columns {
+		title := column.Title
+		if column.Key == dm.sortKey {
+			title += " " + dm.sortArrow()
+		}
+		cells[i] = padString(truncateString(title, colWidths[i+1]), colWidths[i+1], true)
+	}
 
-	headerRow := fmt.Sprintf("│ %s %s │ %s │ %s │ %s │ %s │ %s │",
-		treeCol, nameCol, modelCol, statusCol, addressCol, priorityCol, versionCol)
-	dm.printLine(headerRow)
+	row := fmt.Sprintf(" %s %s", strings.Repeat(" ", colWidths[0]), strings.Join(cells, " │ "))
+	row += strings.Repeat(" ", max(0, dm.termWidth-displayWidth(row)-4))
+	dm.printLine(fmt.Sprintf("│ %s%s%s │", dm.getColor(ColorBold), row, dm.getColor(ColorReset)))
 
-	separator := "├" + strings.Repeat("─", colWidths[0]+2) + "┼" +
-		strings.Repeat("─", colWidths[1]+2) + "┼" +
-		strings.Repeat("─", colWidths[2]+2) + "┼" +
-		strings.Repeat("─", colWidths[3]+2) + "┼" +
-		strings.Repeat("─", colWidths[4]+2) + "┼" +
-		strings.Repeat("─", colWidths[5]+2) + "┼" +
-		strings.Repeat("─", colWidths[6]+2) + "┤"
-	dm.printLine(separator)
+	// The separator crosses the column borders of the row above
+	separator := []rune("├─")
+	for _, char := range row {
+		if char == '│' {
+			separator = append(separator, '┼')
+		} else {
+			separator = append(separator, '─')
+		}
+	}
+	dm.printLine(string(append(separator, '─', '┤')))
 }
 
+// calculateColumnWidths returns the width of the tree column followed by the
+// widths of the configured columns
 func (dm *DisplayManager) calculateColumnWidths() []int {
 	// Base column widths
-	baseWidths := []int{3, 25, 15, 15, 12, 13, 8} // Tree, Name, Model, Status, Address, Priority, LastConnected
+	baseWidths := []int{3} // Tree
+	totalWeight := 0.0
+	for _, column := range dm.config.Columns {
+		baseWidths = append(baseWidths, column.BaseWidth)
+		totalWeight += column.Weight
+	}
 
 	totalBase := 0
 	for _, w := range baseWidths {
 		totalBase += w + 3 // +3 for " │ "
 	}
 
-	// If terminal is wider, expand name and address columns proportionally
+	// If terminal is wider, expand columns proportionally to their weight
 
 	extraSpace := dm.termWidth - totalBase
-	baseWidths[1] += int(float64(extraSpace) * 0.2)
-	baseWidths[2] += int(float64(extraSpace) * 0.1)
-	baseWidths[3] += int(float64(extraSpace) * 0.1)
-	baseWidths[4] += int(float64(extraSpace) * 0.2)
-	baseWidths[5] += int(float64(extraSpace) * 0.1)
-	baseWidths[6] += int(float64(extraSpace) * 0.3)
+	if totalWeight > 0 {
+		for i, column := range dm.config.Columns {
+			// Round down so a narrow terminal never gets a row wider than the screen
+			baseWidths[i+1] += int(math.Floor(float64(extraSpace) * column.Weight / totalWeight))
+		}
+	}
 
 	for i := range baseWidths {
 		if baseWidths[i] < 0 {
@@ -428,62 +744,117 @@ func padString(s string, width int, leftAlign bool) string {
 	return padding + s
 }
 
-// renderPhysicalDevice renders a single physical device with fixed columns
-func (dm *DisplayManager) renderPhysicalDevice(device *PhysicalDevice, isLast bool) {
+// renderPhysicalDevice renders a single physical device with fixed columns;
+// priorityWarning marks its priority as part of a misconfiguration
+func (dm *DisplayManager) renderPhysicalDevice(device *PhysicalDevice, isLast, priorityWarning bool) {
 	// Tree character
 	treeChar := "├─"
 	if isLast {
 		treeChar = "└─"
 	}
-
-	// Connection state color
-	connColor := dm.getConnectionStateColor(device.ConnectionState)
-	resetColor := dm.getColor(ColorReset)
-
-	// Format device info with fixed column widths
-	role := device.GetRoleDisplay()
-	deviceName := device.Name
-	if role != "" {
-		// Add color to role in brackets
-		roleColor := dm.getRoleColor(role)
-		deviceName += fmt.Sprintf(" [%s%s%s]", roleColor, role, resetColor)
+	if dm.ascii {
+		treeChar = "|-"
+		if isLast {
+			treeChar = "`-"
+		}
 	}
 
-	connectionState := device.GetConnectionStateDisplay()
-	productVersion := device.GetProductVersionDisplay()
+	resetColor := dm.getColor(ColorReset)
 
 	// Get column widths from term library calculation
 	colWidths := dm.calculateColumnWidths()
 
-	// Priority for cluster nodes
-	priority := "-"
-	if device.AsNode != nil {
-		if colWidths[5] < 12 {
-			priority = fmt.Sprintf("%d", device.AsNode.Priority)
-		} else {
-			priority = fmt.Sprintf("Priority: %d", device.AsNode.Priority)
+	// Fixed column widths using calculated sizes with proper color-aware padding
+	treeCol := padString(treeChar, colWidths[0], true)
+	cells := make([]string, len(dm.config.Columns))
+	for i, column := range dm.config.Columns {
+		width := colWidths[i+1]
+		value := column.Value(device)
+		color := ""
+
+		switch column.Key {
+		case "name":
+			role := device.GetRoleDisplay()
+			if role != "" {
+				// Add color to role in brackets; the role column carries its
+				// -status_indicators mark, the tag itself is one
+				roleColor := dm.classColor(roleClass(role))
+				value += fmt.Sprintf(" [%s%s%s]", roleColor, role, resetColor)
+			}
+			if device.Suspended() {
+				value += fmt.Sprintf(" [%sSUSPENDED%s]", dm.getColor(ColorYellow), resetColor)
+			}
+			if device.Inventory == InventoryUnknown {
+				value += fmt.Sprintf(" [%s%s%s]", dm.getColor(ColorYellow), InventoryUnknown, resetColor)
+			}
+			if device.Outdated {
+				value += fmt.Sprintf(" [%sOUTDATED%s]", dm.getColor(ColorYellow), resetColor)
+			}
+		case "status":
+			// Connection state color; acknowledged problems are no longer loud
+			value = dm.indicate(connectionClass(device), value)
+			color = dm.classColor(connectionClass(device))
+			if device.Flapping && color != "" {
+				color = dm.getColor(ColorPurple) + dm.getColor(ColorBold)
+			}
+			if device.Ack != nil && color != "" {
+				color = dm.getColor(ColorDim)
+			}
+		case "role":
+			color = dm.classColor(roleClass(value))
+			value = dm.indicate(roleClass(value), value)
+		case "health":
+			color = dm.classColor(healthClass(value))
+			value = dm.indicate(healthClass(value), value)
+		case "version", "software_version":
+			// The versions differ while an upgrade is incomplete
+			if device.VersionMismatch() {
+				icon := "⚠"
+				if dm.ascii {
+					icon = "!"
+				}
+				value += " " + icon
+				color = dm.getColor(ColorYellow)
+			}
+		case "suspend":
+			if device.Suspended() {
+				color = dm.getColor(ColorYellow)
+			}
+		case "trend":
+			color = dm.getColor(ColorCyan)
+		case "reachable":
+			if device.Probe != nil && device.Probe.Reachable {
+				color = dm.getColor(ColorGreen)
+			} else if device.Probe != nil {
+				color = dm.getColor(ColorRed)
+			}
+		case "priority":
+			// Priority for cluster nodes
+			if device.AsNode != nil && width >= 12 {
+				value = fmt.Sprintf("Priority: %d", device.AsNode.Priority)
+			}
+			if priorityWarning {
+				icon := "⚠"
+				if dm.ascii {
+					icon = "!"
+				}
+				value += " " + icon
+				color = dm.getColor(ColorYellow)
+			}
 		}
 
+		text := truncateString(value, width)
+		if column.Key == "name" {
+			text = dm.hyperlink(dm.objectURL(dm.config.DeviceURL, device.ID, device.Name), text)
+		}
+		cell := padString(text, width, true)
+		if color != "" {
+			cell = color + cell + resetColor
+		}
+		cells[i] = cell
 	}
 
-	// Fixed column widths using calculated sizes with proper color-aware padding
-	treeCol := padString(treeChar, colWidths[0], true)
-	nameCol := padString(truncateString(deviceName, colWidths[1]), colWidths[1], true)
-	modelCol := padString(truncateString(device.Model, colWidths[2]), colWidths[2], true)
-	statusCol := padString(truncateString(connectionState, colWidths[3]), colWidths[3], true)
-	addressCol := padString(truncateString(device.Address, colWidths[4]), colWidths[4], true)
-	priorityCol := padString(truncateString(priority, colWidths[5]), colWidths[5], true)
-	versionCol := padString(truncateString(productVersion, colWidths[6]), colWidths[6], true)
-
-	deviceRow := fmt.Sprintf(" %s %s │ %s │ %s%s%s │ %s │ %s │ %s",
-		treeCol,
-		nameCol,
-		modelCol,
-		connColor, statusCol, resetColor,
-		addressCol,
-		priorityCol,
-		versionCol,
-	)
+	deviceRow := fmt.Sprintf(" %s %s", treeCol, strings.Join(cells, " │ "))
 
 	padding := dm.termWidth - displayWidth(deviceRow) - 4 // -4 for "│ " and " │"
 
@@ -493,8 +864,24 @@ func (dm *DisplayManager) renderPhysicalDevice(device *PhysicalDevice, isLast bo
 
 	line := fmt.Sprintf("│ %s%s │", deviceRow, strings.Repeat(" ", padding))
 
+	if dm.screen != nil {
+		dm.rows = append(dm.rows, frameRow{line: len(dm.lines), device: device})
+	}
 	dm.printLine(line)
 
+	// Mark an acknowledged problem on a line of its own under the device
+	if ack := device.GetAckDisplay(); ack != "" {
+		branch := "│"
+		if isLast {
+			branch = " "
+		}
+		if device.Ack.Comment != "" {
+			ack += ": " + device.Ack.Comment
+		}
+		ack = truncateString(ack, max(0, dm.termWidth-12))
+		text := fmt.Sprintf("  %s    %s%s%s", branch, dm.getColor(ColorDim), ack, resetColor)
+		dm.printLine(fmt.Sprintf("│ %s%s │", text, strings.Repeat(" ", max(0, dm.termWidth-displayWidth(text)-4))))
+	}
 }
 
 // truncateString truncates a string to a maximum length, adding "..." if needed
@@ -565,7 +952,7 @@ func (dm *DisplayManager) renderFooter() {
 	// Use dynamic width
 	tableWidth := dm.termWidth
 
-	border := strings.Repeat("─", tableWidth-2)
+	border := strings.Repeat("─", max(0, tableWidth-2))
 	dm.printf("├%s┤\n", border)
 
 	if dm.errorMessage != "" {
@@ -574,13 +961,37 @@ func (dm *DisplayManager) renderFooter() {
 		color = dm.getColor(ColorGreen)
 	}
 
-	footerInfo := fmt.Sprintf("Poll Interval: %v │ Press Ctrl+C to exit │ MGMT: %s%s%s",
-		dm.config.PollInterval,
+	mode := fmt.Sprintf("Poll Interval: %v", dm.config.PollInterval)
+	if dm.interval > dm.config.PollInterval {
+		mode = fmt.Sprintf("Poll Interval: %v (backoff, base %v)", dm.interval, dm.config.PollInterval)
+	}
+	if dm.streaming {
+		mode = "Mode: stream"
+	} else if until := time.Until(dm.nextPoll).Round(time.Second); until > 0 {
+		mode = fmt.Sprintf("%s │ next in %v", mode, until)
+	}
+
+	if health := dm.pollHealth(); health != "" {
+		mode = fmt.Sprintf("%s │ %s", mode, health)
+	}
+
+	mgmt := extractHostFromURL(dm.config.BaseURL)
+	if dm.config.Demo {
+		mgmt = "DEMO (simulated)"
+	} else if dm.config.Replay != "" {
+		mgmt = replayName(dm.config)
+	}
+	footerInfo := fmt.Sprintf("%s │ w: snapshot │ Press Ctrl+C to exit │ MGMT: %s%s%s",
+		mode,
 		color,
-		extractHostFromURL(dm.config.BaseURL),
+		mgmt,
 		resetColor,
 	)
 
+	if dm.flashMessage != "" && time.Now().Before(dm.flashUntil) {
+		footerInfo = fmt.Sprintf("%s%s%s │ %s", dm.getColor(ColorCyan), dm.flashMessage, resetColor, footerInfo)
+	}
+
 	padding := tableWidth - displayWidth(footerInfo) - 4 // -4 for "│ " and " │"
 	if padding < 0 {
 		padding = 0
@@ -591,47 +1002,80 @@ func (dm *DisplayManager) renderFooter() {
 	dm.printf("└%s┘\n", border)
 }
 
+// pollHealth describes the last poll and the recent success rate, e.g.
+// "last poll 230ms │ 99.2% ok (1h)"
+func (dm *DisplayManager) pollHealth() string {
+	stats := dm.pollStats
+	if stats.Polls == 0 {
+		return ""
+	}
+
+	last := fmt.Sprintf("last poll %v", roundTiming(stats.LastLatency))
+	if stats.LastFailed {
+		last = "last poll failed"
+	}
+
+	window := stats.Window.Round(time.Minute)
+	if window < time.Minute {
+		window = max(stats.Window.Round(time.Second), time.Second)
+	}
+	// Drop zero trailing units: 1h0m0s reads as 1h, 5m0s as 5m
+	label := window.String()
+	if strings.HasSuffix(label, "m0s") {
+		label = strings.TrimSuffix(label, "0s")
+	}
+	if strings.HasSuffix(label, "h0m") {
+		label = strings.TrimSuffix(label, "0m")
+	}
+
+	rateColor := dm.getColor(ColorGreen)
+	switch {
+	case stats.SuccessRate < 90:
+		rateColor = dm.getColor(ColorRed)
+	case stats.SuccessRate < 99:
+		rateColor = dm.getColor(ColorYellow)
+	}
+
+	return fmt.Sprintf("%s │ %s%.1f%% ok%s (%s)", last, rateColor, stats.SuccessRate, dm.getColor(ColorReset), label)
+}
+
 // getColor returns color code if color output is enabled
 func (dm *DisplayManager) getColor(color string) string {
-	if dm.config.ColorOutput {
+	if dm.config.ColorOutput && dm.vt {
 		return color
 	}
 	return ""
 }
 
-// getConnectionStateColor returns appropriate color for connection state
-func (dm *DisplayManager) getConnectionStateColor(state string) string {
-	if !dm.config.ColorOutput {
-		return ""
+// extractHostFromURL extracts hostname from URL for display
+// hyperlink makes text an OSC 8 hyperlink to target. Links are only drawn
+// through tcell, which leaves them out where the terminal lacks support.
+func (dm *DisplayManager) hyperlink(target, text string) string {
+	if target == "" || dm.screen == nil {
+		return text
 	}
+	return "\033]8;;" + target + "\033\\" + text + "\033]8;;\033\\"
+}
 
-	switch state {
-	case "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTED":
-		return ColorGreen
-	case "PHYSICAL_DEVICE_CONNECTION_STATE_DISCONNECTED":
-		return ColorRed
-	default:
-		return ColorYellow
-	}
+// objectURL fills a -device_url or -logical_device_url template for one
+// object; an empty template disables links
+func (dm *DisplayManager) objectURL(template, id, name string) string {
+	return objectURL(dm.config.BaseURL, template, id, name)
 }
 
-// getRoleColor returns appropriate color for cluster role
-func (dm *DisplayManager) getRoleColor(role string) string {
-	if !dm.config.ColorOutput {
+// objectURL fills a link template with the host of baseURL and the ID and
+// name of an object
+func objectURL(baseURL, template, id, name string) string {
+	if template == "" {
 		return ""
 	}
-
-	switch role {
-	case "ACTIVE":
-		return ColorGreen
-	case "STANDBY":
-		return ColorYellow
-	default:
-		return ColorRed
-	}
+	return strings.NewReplacer(
+		"{host}", extractHostFromURL(baseURL),
+		"{id}", url.PathEscape(id),
+		"{name}", url.PathEscape(name),
+	).Replace(template)
 }
 
-// extractHostFromURL extracts hostname from URL for display
 func extractHostFromURL(url string) string {
 	if strings.HasPrefix(url, "https://") {
 		url = url[8:]
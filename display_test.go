@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// newTestDisplay returns a display drawing to a simulated screen of the
+// given size
+func newTestDisplay(t *testing.T, width, height int) (*DisplayManager, tcell.SimulationScreen) {
+	t.Helper()
+
+	cm := NewConfigManager()
+	cm.setDefaults()
+	dm := NewDisplayManager(cm.config)
+
+	screen := tcell.NewSimulationScreen("UTF-8")
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(width, height)
+	dm.screen = screen
+	dm.fullScreen = true
+	return dm, screen
+}
+
+func TestRenderTinyTerminal(t *testing.T) {
+	data := GroupDevicesByLogicalDevice(&APIResponse{PhysicalDevices: testDevices})
+
+	// Shrunk from the default size by a resize, which must not crash
+	for _, size := range [][2]int{{10, 5}, {1, 1}, {0, 0}, {minTermWidth, 5}} {
+		dm, screen := newTestDisplay(t, 120, 50)
+		dm.Render(data, nil)
+		screen.SetSize(size[0], size[1])
+		dm.Resize()
+
+		tooSmall := len(dm.lines) == 1 && dm.lines[0] == "Terminal too small"
+		if want := size[0] < minTermWidth; tooSmall != want {
+			t.Errorf("%dx%d: got %d lines starting %q, want the notice only: %v", size[0], size[1], len(dm.lines), dm.lines[0], want)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envNames are the environment variables of the settings not named after
+// their key; they predate the generic PT_<KEY> variables
+var envNames = map[string]string{
+	"username":               "PT_API_USERNAME",
+	"password":               "PT_API_PASSWORD",
+	"password_file":          "PT_API_PASSWORD_FILE",
+	"output_format":          "PT_OUTPUT",
+	"session_renew_interval": "PT_SESSION_RENEW",
+	"stream_enabled":         "PT_STREAM",
+	"color_output":           "PT_NO_COLOR",
+	"show_timestamp":         "NO_TIMESTAMP",
+	"tls.cipher_suites":      "PT_TLS_CIPHERS",
+}
+
+// envValues convert the variables whose text differs from the config file's
+// before they are parsed like the others
+var envValues = map[string]func(text string) string{
+	"poll_jitter":    func(text string) string { return strings.TrimSuffix(text, "%") }, // e.g. 10%
+	"color_output":   negateBool,
+	"show_timestamp": negateBool,
+	"headers":        canonicalHeaders,
+}
+
+// envClearable are the settings on by default that an empty variable turns
+// off; other empty variables are ignored
+var envClearable = map[string]bool{
+	"state_file": true,
+}
+
+// negateBool turns the value of a variable like PT_NO_COLOR into that of
+// its setting; invalid text is left for the setting to reject
+func negateBool(text string) string {
+	value, err := strconv.ParseBool(text)
+	if err != nil {
+		return text
+	}
+	return strconv.FormatBool(!value)
+}
+
+// canonicalHeaders writes the names of name=value headers the way the
+// -header flag stores them, so both set the same header
+func canonicalHeaders(text string) string {
+	pairs := strings.Split(text, ",")
+	for i, pair := range pairs {
+		if name, value, ok := strings.Cut(pair, "="); ok {
+			pairs[i] = http.CanonicalHeaderKey(strings.TrimSpace(name)) + "=" + value
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// envName returns the environment variable of the setting with key, e.g.
+// PT_ALERTS_SLACK_WEBHOOK_URL for alerts.slack.webhook_url
+func envName(key string) string {
+	if name, ok := envNames[key]; ok {
+		return name
+	}
+	return "PT_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// parseConfigEnvironment sets every setting from its PT_<KEY> variable, so
+// a container can be configured without a config file. Settings without a
+// config file key are read from the variable of their env tag.
+func (cm *ConfigManager) parseConfigEnvironment() {
+	config := reflect.ValueOf(cm.config).Elem()
+	eachSetting("", config, func(key string, field reflect.Value) {
+		cm.setFromEnv(key, envName(key), field)
+	})
+
+	for i := 0; i < config.NumField(); i++ {
+		if name := config.Type().Field(i).Tag.Get("env"); name != "" {
+			cm.setFromEnv("", name, config.Field(i))
+		}
+	}
+}
+
+// setFromEnv sets the setting with key from the variable name, if it is set
+func (cm *ConfigManager) setFromEnv(key, name string, field reflect.Value) {
+	text, ok := cm.lookupEnv(name)
+	if !ok || (text == "" && !envClearable[key]) {
+		return
+	}
+	if convert := envValues[key]; convert != nil {
+		text = convert(text)
+	}
+	if err := setConfigValue(field, text); err != nil {
+		cm.invalidEnv(name, text)
+	}
+}
+
+// eachSetting calls fn with the key and field of every setting of v, a
+// config struct, walked like flattenConfig
+func eachSetting(prefix string, v reflect.Value, fn func(key string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+		if value := v.Field(i); value.Kind() == reflect.Struct {
+			eachSetting(key+".", value, fn)
+		} else {
+			fn(key, value)
+		}
+	}
+}
+
+// setConfigValue parses text into a setting: durations as "30s" or seconds,
+// lists comma-separated, maps as name=value pairs and anything else, like
+// the label rules, as the JSON of the config file
+func setConfigValue(field reflect.Value, text string) error {
+	if t := field.Type(); t == reflect.TypeOf(time.Duration(0)) || t == reflect.TypeOf(configDuration(0)) {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			seconds, err := strconv.Atoi(text)
+			if err != nil {
+				return err
+			}
+			duration = time.Duration(seconds) * time.Second
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	case reflect.Int:
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(value))
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String || strings.HasPrefix(strings.TrimSpace(text), "[") {
+			return decodeSetting(text, field)
+		}
+		var values []string
+		for _, value := range strings.Split(text, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	case reflect.Map:
+		if strings.HasPrefix(strings.TrimSpace(text), "{") || field.Type() != reflect.TypeOf(map[string]string(nil)) {
+			return decodeSetting(text, field)
+		}
+		values := make(map[string]string)
+		for _, pair := range strings.Split(text, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q is not name=value", pair)
+			}
+			values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		return decodeSetting(text, field)
+	}
+	return nil
+}
+
+// decodeSetting decodes the JSON text into a setting, rejecting unknown keys
+// like the config file does
+func decodeSetting(text string, field reflect.Value) error {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(field.Addr().Interface())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnvironmentSettings(t *testing.T) {
+	t.Setenv("PT_BASE_URL", "https://mgmt.example.com/api/v2/")
+	t.Setenv("PT_POLL_INTERVAL", "45")
+	t.Setenv("PT_POLL_JITTER", "10%")
+	t.Setenv("PT_API_USERNAME", "monitor")
+	t.Setenv("PT_NO_COLOR", "true")
+	t.Setenv("NO_TIMESTAMP", "1")
+	t.Setenv("PT_STATE_FILE", "")
+	t.Setenv("PT_HEADERS", "x-tenant=msk, x-request-source = monitor")
+	t.Setenv("PT_TLS_PINS", "sha256/a,sha256/b")
+	t.Setenv("PT_ASSERT", "all-connected")
+	t.Setenv("PT_WAIT_TIMEOUT", "2m")
+
+	cm := NewConfigManager()
+	cm.setDefaults()
+	cm.parseEnvironmentVariables()
+	config := cm.config
+
+	if len(cm.problems) > 0 {
+		t.Errorf("problems: %v", cm.problems)
+	}
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"base_url", config.BaseURL, "https://mgmt.example.com/api/v2/"},
+		{"poll_interval", config.PollInterval, 45 * time.Second},
+		{"poll_jitter", config.PollJitter, 10},
+		{"username", config.Username, "monitor"},
+		{"color_output", config.ColorOutput, false},
+		{"show_timestamp", config.ShowTimestamp, false},
+		{"state_file", config.StateFile, ""},
+		{"headers", config.Headers, map[string]string{"X-Tenant": "msk", "X-Request-Source": "monitor"}},
+		{"tls.pins", config.TLS.Pins, []string{"sha256/a", "sha256/b"}},
+		{"assert", config.Assert, "all-connected"},
+		{"wait_timeout", config.WaitTimeout, 2 * time.Minute},
+	}
+	for _, check := range checks {
+		if !reflect.DeepEqual(check.got, check.want) {
+			t.Errorf("%s: got %v, want %v", check.name, check.got, check.want)
+		}
+	}
+}
+
+func TestEnvironmentInvalidValues(t *testing.T) {
+	t.Setenv("PT_POLL_INTERVAL", "soon")
+	t.Setenv("PT_NO_COLOR", "maybe")
+
+	cm := NewConfigManager()
+	cm.setDefaults()
+	cm.parseEnvironmentVariables()
+
+	if len(cm.problems) != 2 {
+		t.Errorf("got problems %v, want one for each variable", cm.problems)
+	}
+	if !cm.config.ColorOutput {
+		t.Error("invalid PT_NO_COLOR turned colors off")
+	}
+}
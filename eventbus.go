@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventBusConfig configures the event bus producer
+type EventBusConfig struct {
+	Type         string   `json:"type"`    // kafka or nats
+	Brokers      []string `json:"brokers"` // Kafka bootstrap brokers (host:port)
+	URL          string   `json:"url"`     // NATS server URL, e.g. nats://nats.local:4222
+	Topic        string   `json:"topic"`   // Kafka topic or NATS subject
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	PasswordFile string   `json:"password_file"` // Read the password from this file
+}
+
+// EventProducer delivers device events to a streaming backend
+type EventProducer interface {
+	// Send delivers events and returns those it could not deliver, in
+	// order, with the error
+	Send(events []DeviceEvent) ([]DeviceEvent, error)
+	Close()
+}
+
+// NewEventProducer creates the backend selected by config.Type
+func NewEventProducer(config EventBusConfig) (EventProducer, error) {
+	if config.Topic == "" {
+		config.Topic = "pt_device_monitor.events"
+	}
+
+	switch config.Type {
+	case "kafka":
+		if len(config.Brokers) == 0 {
+			return nil, fmt.Errorf("event_bus.brokers is required for kafka")
+		}
+		return newKafkaProducer(config), nil
+	case "nats":
+		if config.URL == "" {
+			return nil, fmt.Errorf("event_bus.url is required for nats")
+		}
+		return newNATSProducer(config), nil
+	default:
+		return nil, fmt.Errorf("unsupported event bus type: %q (use kafka or nats)", config.Type)
+	}
+}
+
+// EventBus sends device events through a producer on a background goroutine
+// so a slow or unreachable backend never delays rendering. Events that
+// cannot be delivered are kept, up to maxPendingEvents, and retried with the
+// next batch or after eventBusRetryInterval.
+type EventBus struct {
+	producer EventProducer
+	queue    chan []DeviceEvent
+	done     chan struct{}
+	errLog   failureLog
+}
+
+const (
+	// maxPendingEvents bounds the events kept while the backend is unreachable
+	maxPendingEvents = 10000
+	// eventBusRetryInterval is how often undelivered events are retried when
+	// no new ones arrive
+	eventBusRetryInterval = 30 * time.Second
+)
+
+func init() {
+	registerNotifier("event_bus", func(config *Config) (Notifier, error) {
+		if config.EventBus.Type == "" {
+			return nil, nil
+		}
+		producer, err := NewEventProducer(config.EventBus)
+		if err != nil {
+			return nil, err
+		}
+		return NewEventBus(producer), nil
+	})
+}
+
+func NewEventBus(producer EventProducer) *EventBus {
+	eb := &EventBus{
+		producer: producer,
+		queue:    make(chan []DeviceEvent, 64),
+		done:     make(chan struct{}),
+		errLog:   failureLog{name: "event bus"},
+	}
+
+	go eb.run()
+
+	return eb
+}
+
+// Notify queues events for delivery
+func (eb *EventBus) Notify(events []DeviceEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	select {
+	case eb.queue <- events:
+	default:
+		eb.errLog.set(fmt.Errorf("queue full, dropped %d events", len(events)))
+	}
+}
+
+// Close delivers queued events once more and closes the producer
+func (eb *EventBus) Close() {
+	close(eb.queue)
+	<-eb.done
+}
+
+func (eb *EventBus) run() {
+	defer close(eb.done)
+	defer eb.producer.Close()
+
+	retry := time.NewTicker(eventBusRetryInterval)
+	defer retry.Stop()
+
+	var pending []DeviceEvent
+	for {
+		select {
+		case events, ok := <-eb.queue:
+			if !ok {
+				return
+			}
+			pending = append(pending, events...)
+			if dropped := len(pending) - maxPendingEvents; dropped > 0 {
+				eb.errLog.set(fmt.Errorf("too many undelivered events, dropped the oldest %d", dropped))
+				pending = pending[dropped:]
+			}
+		case <-retry.C:
+			if len(pending) == 0 {
+				continue
+			}
+		}
+
+		var err error
+		pending, err = eb.producer.Send(pending)
+		eb.errLog.set(err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+)
+
+// eventLogSize is how many recent device events the event log overlay shows
+const eventLogSize = 15
+
+// ToggleEventLog shows or hides the overlay with the recent device events
+func (dm *DisplayManager) ToggleEventLog() {
+	dm.eventLog = !dm.eventLog
+	dm.flush()
+}
+
+// SetEvents records the recent device events for the event log overlay,
+// oldest first
+func (dm *DisplayManager) SetEvents(events []DeviceEvent) {
+	dm.recentEvents = events
+	dm.RecordFailovers(events)
+}
+
+// eventLogLines lists the recent device events for the overlay, colored by
+// what happened to the inventory or the state
+func (dm *DisplayManager) eventLogLines() []string {
+	reset := dm.getColor(ColorReset)
+	lines := []string{dm.getColor(ColorBold) + "Event log" + reset, ""}
+
+	if len(dm.recentEvents) == 0 {
+		lines = append(lines, "No device events since the monitor started")
+	}
+	for _, event := range dm.recentEvents {
+		color := ""
+		switch event.Type {
+		case EventDeviceAdded:
+			color = dm.getColor(ColorGreen)
+		case EventDeviceRemoved:
+			color = dm.getColor(ColorRed)
+		case EventSerialChanged:
+			color = dm.getColor(ColorYellow)
+		case EventFailover:
+			color = dm.getColor(ColorCyan)
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s%s%s", formatShownTime(event.Time, "01-02 15:04:05"), color, formatEvent(event), reset))
+	}
+
+	return append(lines, "", dm.getColor(ColorDim)+"e: close"+reset)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"time"
+)
+
+// Device event types
+const (
+	EventDeviceAdded   = "device_added"
+	EventDeviceRemoved = "device_removed"
+	EventStateChanged  = "state_changed"
+	EventSerialChanged = "serial_changed" // The device, or a device of the same name replacing it, has a new serial number
+	EventFailover      = "failover"       // Another node of the cluster became ACTIVE; the event's device is the new one
+)
+
+// DeviceEvent describes a change of a physical device between two polls
+type DeviceEvent struct {
+	Time          time.Time `json:"time"`
+	Type          string    `json:"type"`
+	DeviceID      string    `json:"device_id"`
+	DeviceName    string    `json:"device_name"`
+	LogicalDevice string    `json:"logical_device"`
+	Field         string    `json:"field,omitempty"` // connection_state, health_status, role, serial_number or active_node
+	From          string    `json:"from,omitempty"`
+	To            string    `json:"to,omitempty"`
+	Acknowledged  bool      `json:"acknowledged,omitempty"` // The device's alerts are silenced, see AckStore
+}
+
+// indexDevices maps physical device IDs to the devices of data
+func indexDevices(data *GroupedDevices) map[string]PhysicalDevice {
+	devices := make(map[string]PhysicalDevice)
+	if data == nil {
+		return devices
+	}
+
+	for _, group := range data.LogicalDeviceGroups {
+		for _, device := range group.PhysicalDevices {
+			// MISSING rows stand for devices the API does not list
+			if device.Inventory != InventoryMissing {
+				devices[device.ID] = device
+			}
+		}
+	}
+	return devices
+}
+
+// DiffDevices returns the events that turn prev into next. A nil prev means
+// there is nothing to compare against and yields no events. A device that
+// disappears while a device of the same name and logical device appears was
+// replaced, which is one serial_changed event instead of a removal and an
+// addition.
+func DiffDevices(prev, next *GroupedDevices, at time.Time) []DeviceEvent {
+	if prev == nil || next == nil {
+		return nil
+	}
+
+	before := indexDevices(prev)
+	after := indexDevices(next)
+
+	// Removed devices by logical device and name, for spotting replacements
+	removed := make(map[[2]string]PhysicalDevice)
+	for _, device := range before {
+		if _, exists := after[device.ID]; !exists {
+			removed[[2]string{device.LogicalDevice.ID, device.Name}] = device
+		}
+	}
+	replaced := make(map[string]bool)
+
+	var events []DeviceEvent
+
+	for _, group := range sortedGroups(next) {
+		for i := range group.PhysicalDevices {
+			device := &group.PhysicalDevices[i]
+			if device.Inventory == InventoryMissing {
+				continue
+			}
+			newEvent := func(eventType string) DeviceEvent {
+				return DeviceEvent{
+					Time:          at,
+					Type:          eventType,
+					DeviceID:      device.ID,
+					DeviceName:    device.Name,
+					LogicalDevice: device.LogicalDevice.Name,
+					Acknowledged:  device.Ack != nil,
+				}
+			}
+
+			old, existed := before[device.ID]
+			if !existed {
+				key := [2]string{device.LogicalDevice.ID, device.Name}
+				predecessor, ok := removed[key]
+				if !ok {
+					events = append(events, newEvent(EventDeviceAdded))
+					continue
+				}
+				delete(removed, key)
+				replaced[predecessor.ID] = true
+				old = predecessor
+			}
+
+			if old.SerialNumber != device.SerialNumber {
+				event := newEvent(EventSerialChanged)
+				event.Field = "serial_number"
+				event.From = old.SerialNumber
+				event.To = device.SerialNumber
+				events = append(events, event)
+			}
+
+			changes := []struct{ field, from, to string }{
+				{"connection_state", old.GetConnectionStateDisplay(), device.GetConnectionStateDisplay()},
+				{"health_status", old.GetHealthStatusDisplay(), device.GetHealthStatusDisplay()},
+				{"role", old.GetRoleDisplay(), device.GetRoleDisplay()},
+			}
+			for _, change := range changes {
+				if change.from == change.to {
+					continue
+				}
+				event := newEvent(EventStateChanged)
+				event.Field = change.field
+				event.From = change.from
+				event.To = change.to
+				events = append(events, event)
+			}
+		}
+	}
+
+	for _, group := range sortedGroups(prev) {
+		for _, device := range group.PhysicalDevices {
+			if _, exists := after[device.ID]; !exists && !replaced[device.ID] && device.Inventory != InventoryMissing {
+				events = append(events, DeviceEvent{
+					Time:          at,
+					Type:          EventDeviceRemoved,
+					DeviceID:      device.ID,
+					DeviceName:    device.Name,
+					LogicalDevice: device.LogicalDevice.Name,
+					Acknowledged:  device.Ack != nil,
+				})
+			}
+		}
+	}
+
+	return append(events, failoverEvents(prev, next, at)...)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Poll events of the -events stream, next to the device event types
+const (
+	EventPollFailed    = "poll_failed"    // A poll failed, or failed with another error than the last one
+	EventPollRecovered = "poll_recovered" // A poll succeeded after failed ones
+)
+
+// PollEvent is a line of the -events stream about the API rather than a device
+type PollEvent struct {
+	Time  time.Time `json:"time"`
+	Type  string    `json:"type"`
+	Error string    `json:"error,omitempty"`
+}
+
+// EventStream writes every device event and every start, change and end of
+// a poll error as a JSON line, to stdout or -events_file, for log shippers
+// such as Vector or Fluent Bit
+type EventStream struct {
+	out     io.Writer
+	file    *os.File // Nil when writing to stdout
+	failing string   // Error of the last poll, empty after a successful one
+	lines   chan []byte
+	done    chan struct{}
+	errLog  failureLog
+}
+
+func init() {
+	registerExporter("events", func(config *Config) (Exporter, error) {
+		if config.Events == "" {
+			return nil, nil
+		}
+		return NewEventStream(config.EventsFile)
+	})
+}
+
+// NewEventStream appends to path, or writes to stdout when path is empty
+func NewEventStream(path string) (*EventStream, error) {
+	es := &EventStream{
+		out:    os.Stdout,
+		lines:  make(chan []byte, 256),
+		done:   make(chan struct{}),
+		errLog: failureLog{name: "events"},
+	}
+
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open events file: %w", err)
+		}
+		es.out = file
+		es.file = file
+	}
+
+	go es.run()
+
+	return es, nil
+}
+
+// Export writes the events of a poll. Exporters are called from the
+// scheduler only, so failing needs no lock.
+func (es *EventStream) Export(result PollResult) {
+	if result.Err != nil {
+		if message := result.Err.Error(); message != es.failing {
+			es.queue(PollEvent{Time: result.Time, Type: EventPollFailed, Error: message})
+			es.failing = message
+		}
+		return
+	}
+
+	if es.failing != "" {
+		es.queue(PollEvent{Time: result.Time, Type: EventPollRecovered})
+		es.failing = ""
+	}
+	for _, event := range result.Events {
+		es.queue(event)
+	}
+}
+
+// queue hands an event to the writer. Events are dropped rather than holding
+// up polling when the reader of stdout or the disk is stuck.
+func (es *EventStream) queue(event any) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		es.errLog.set(fmt.Errorf("failed to encode event: %w", err))
+		return
+	}
+
+	select {
+	case es.lines <- append(line, '\n'):
+	default:
+		es.errLog.set(fmt.Errorf("write queue full, event dropped"))
+	}
+}
+
+// Close writes the queued events and stops
+func (es *EventStream) Close() {
+	close(es.lines)
+	<-es.done
+	if es.file != nil {
+		es.file.Close()
+	}
+}
+
+func (es *EventStream) run() {
+	defer close(es.done)
+	for line := range es.lines {
+		_, err := es.out.Write(line)
+		if err != nil {
+			err = fmt.Errorf("failed to write event: %w", err)
+		}
+		es.errLog.set(err)
+	}
+}
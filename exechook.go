@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecHookConfig runs a command for every device event, for integrations
+// that are not built in
+type ExecHookConfig struct {
+	Command     []string       `json:"command"`     // Program and arguments, e.g. ["/usr/local/bin/on-change", "--site", "msk"]
+	Timeout     configDuration `json:"timeout"`     // Kill a run after this long (default: 10s)
+	Concurrency int            `json:"concurrency"` // Runs at the same time (default: 4)
+}
+
+const (
+	defaultHookTimeout     = 10 * time.Second
+	defaultHookConcurrency = 4
+)
+
+// maxQueuedHooks bounds the events waiting for a free run slot
+const maxQueuedHooks = 256
+
+// hookPayload is written to the command's stdin as JSON
+type hookPayload struct {
+	Event  DeviceEvent     `json:"event"`
+	Device *PhysicalDevice `json:"device,omitempty"` // Missing for removed devices
+}
+
+// ExecHook runs the configured command once per device event with the
+// event in PT_EVENT_* environment variables and as JSON on stdin. Runs are
+// queued and limited to Concurrency at a time, so a slow command never
+// delays polling.
+type ExecHook struct {
+	config  ExecHookConfig
+	queue   chan hookPayload
+	workers sync.WaitGroup
+}
+
+func init() {
+	registerExporter("exec_hook", func(config *Config) (Exporter, error) {
+		if len(config.ExecHook.Command) == 0 {
+			return nil, nil
+		}
+		return NewExecHook(config.ExecHook), nil
+	})
+}
+
+func NewExecHook(config ExecHookConfig) *ExecHook {
+	if config.Timeout <= 0 {
+		config.Timeout = configDuration(defaultHookTimeout)
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultHookConcurrency
+	}
+
+	eh := &ExecHook{config: config, queue: make(chan hookPayload, maxQueuedHooks)}
+	for i := 0; i < config.Concurrency; i++ {
+		eh.workers.Add(1)
+		go eh.run()
+	}
+	return eh
+}
+
+// Export queues a run for each event of result
+func (eh *ExecHook) Export(result PollResult) {
+	for _, event := range result.Events {
+		payload := hookPayload{Event: event}
+		if device := findDevice(result.Data, event.DeviceID); device != nil {
+			copied := *device
+			payload.Device = &copied
+		}
+
+		select {
+		case eh.queue <- payload:
+		default:
+			logBackground("exec hook: queue full, dropped %s event of %s", event.Type, event.DeviceName)
+		}
+	}
+}
+
+// Close waits for the queued runs to finish
+func (eh *ExecHook) Close() {
+	close(eh.queue)
+	eh.workers.Wait()
+}
+
+func (eh *ExecHook) run() {
+	defer eh.workers.Done()
+	for payload := range eh.queue {
+		if err := eh.exec(payload); err != nil {
+			logBackground("exec hook: %s event of %s: %v", payload.Event.Type, payload.Event.DeviceName, err)
+		}
+	}
+}
+
+func (eh *ExecHook) exec(payload hookPayload) error {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(eh.config.Timeout))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, eh.config.Command[0], eh.config.Command[1:]...)
+	cmd.Env = append(os.Environ(), hookEnv(payload)...)
+	cmd.Stdin = bytes.NewReader(input)
+	// Output pipes held open by the command's children must not block Wait
+	cmd.WaitDelay = time.Second
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("killed after %s", time.Duration(eh.config.Timeout))
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// hookEnv describes the event and its device in environment variables
+func hookEnv(payload hookPayload) []string {
+	event := payload.Event
+	env := []string{
+		"PT_EVENT_TYPE=" + event.Type,
+		"PT_EVENT_TIME=" + event.Time.Format(time.RFC3339),
+		"PT_EVENT_FIELD=" + event.Field,
+		"PT_EVENT_FROM=" + event.From,
+		"PT_EVENT_TO=" + event.To,
+		"PT_EVENT_ACKNOWLEDGED=" + strconv.FormatBool(event.Acknowledged),
+		"PT_EVENT_DEVICE_ID=" + event.DeviceID,
+		"PT_EVENT_DEVICE_NAME=" + event.DeviceName,
+		"PT_EVENT_LOGICAL_DEVICE=" + event.LogicalDevice,
+	}
+
+	if device := payload.Device; device != nil {
+		env = append(env,
+			"PT_EVENT_DEVICE_ADDRESS="+device.Address,
+			"PT_EVENT_DEVICE_SERIAL="+device.SerialNumber,
+			"PT_EVENT_DEVICE_MODEL="+device.Model,
+			"PT_EVENT_DEVICE_STATUS="+device.GetConnectionStateDisplay(),
+			"PT_EVENT_DEVICE_HEALTH="+device.GetHealthStatusDisplay(),
+			"PT_EVENT_DEVICE_ROLE="+device.GetRoleDisplay(),
+		)
+	}
+	return env
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// activeNodeName is the name of the ACTIVE node of a cluster group, or ""
+func activeNodeName(group *LogicalDeviceGroup) string {
+	if active := group.ClusterSummary().Active; active != nil {
+		return active.Name
+	}
+	return ""
+}
+
+// failoverEvents returns the failovers between the clusters of prev and
+// next: the ACTIVE node changed from one node to another. A cluster that had
+// no ACTIVE node before, or has none now, did not fail over (yet).
+func failoverEvents(prev, next *GroupedDevices, at time.Time) []DeviceEvent {
+	before := make(map[string]*LogicalDeviceGroup)
+	for i := range prev.LogicalDeviceGroups {
+		group := &prev.LogicalDeviceGroups[i]
+		before[group.LogicalDevice.ID] = group
+	}
+
+	var events []DeviceEvent
+	for _, group := range sortedGroups(next) {
+		old := before[group.LogicalDevice.ID]
+		if !group.IsCluster || old == nil {
+			continue
+		}
+
+		summary := group.ClusterSummary()
+		from := activeNodeName(old)
+		if summary.Active == nil || from == "" || from == summary.Active.Name {
+			continue
+		}
+		events = append(events, DeviceEvent{
+			Time:          at,
+			Type:          EventFailover,
+			DeviceID:      summary.Active.ID,
+			DeviceName:    summary.Active.Name,
+			LogicalDevice: group.LogicalDevice.Name,
+			Field:         "active_node",
+			From:          from,
+			To:            summary.Active.Name,
+		})
+	}
+	return events
+}
+
+// historyFailovers finds the failovers recorded in the history file, so the
+// group headers show the last one right after a restart
+func historyFailovers(records []HistoryRecord) []DeviceEvent {
+	var events []DeviceEvent
+	active := make(map[string]string) // ACTIVE node by logical device
+	for _, record := range records {
+		current := make(map[string]string)
+		for _, device := range record.Devices {
+			if device.Role == "ACTIVE" && device.State == "CONNECTED" {
+				current[device.LogicalDevice] = device.Name
+			}
+		}
+		for logicalDevice, name := range current {
+			if from := active[logicalDevice]; from != "" && from != name {
+				events = append(events, DeviceEvent{
+					Time:          record.Time,
+					Type:          EventFailover,
+					DeviceName:    name,
+					LogicalDevice: logicalDevice,
+					Field:         "active_node",
+					From:          from,
+					To:            name,
+				})
+			}
+			active[logicalDevice] = name
+		}
+	}
+	return events
+}
+
+// RecordFailovers keeps the latest failover of each cluster from events,
+// oldest first, for the group headers
+func (dm *DisplayManager) RecordFailovers(events []DeviceEvent) {
+	for _, event := range events {
+		if event.Type != EventFailover {
+			continue
+		}
+		if last, ok := dm.failovers[event.LogicalDevice]; !ok || !event.Time.Before(last.Time) {
+			dm.failovers[event.LogicalDevice] = event
+		}
+	}
+}
+
+// failoverDisplay describes the last failover of a cluster, e.g. "last
+// failover: 2d ago (fw2→fw1)", or "" without one
+func (dm *DisplayManager) failoverDisplay(logicalDevice string) string {
+	event, ok := dm.failovers[logicalDevice]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("last failover: %s (%s→%s)", ageDisplay(time.Since(event.Time)), event.From, event.To)
+}
+
+// ageDisplay describes how long ago something happened in its largest unit,
+// e.g. "5m ago" or "2d ago"
+func ageDisplay(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age/time.Minute))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(age/(24*time.Hour)))
+	}
+}
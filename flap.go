@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// FlapDetector marks devices whose connection state changed more than
+// -flap_threshold times within -flap_window. A flapping sync link looks
+// CONNECTED in any single poll.
+type FlapDetector struct {
+	threshold int
+	window    time.Duration
+	mu        sync.Mutex
+	states    map[string]string      // Last connection state by device ID
+	changes   map[string][]time.Time // Connection state changes within the window
+	flapping  bool                   // A device was flapping at the last Annotate
+}
+
+// NewFlapDetector returns the detector for config, or nil when flap
+// detection is off
+func NewFlapDetector(config *Config) *FlapDetector {
+	if config.FlapThreshold <= 0 {
+		return nil
+	}
+
+	return &FlapDetector{
+		threshold: config.FlapThreshold,
+		window:    config.FlapWindow,
+		states:    make(map[string]string),
+		changes:   make(map[string][]time.Time),
+	}
+}
+
+// Annotate records the connection state changes since the previous call and
+// returns a copy of data with Flapping set, leaving data itself untouched for
+// concurrent readers. A nil *FlapDetector marks nothing.
+func (fd *FlapDetector) Annotate(data *GroupedDevices) *GroupedDevices {
+	if fd == nil {
+		return data
+	}
+
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	now := time.Now()
+	fd.flapping = false
+	return mapDevices(data, func(device *PhysicalDevice) {
+		changes := fd.changes[device.ID]
+		if last, ok := fd.states[device.ID]; ok && last != device.ConnectionState {
+			changes = append(changes, now)
+		}
+		fd.states[device.ID] = device.ConnectionState
+
+		for len(changes) > 0 && now.Sub(changes[0]) > fd.window {
+			changes = changes[1:]
+		}
+		if len(changes) == 0 {
+			delete(fd.changes, device.ID)
+		} else {
+			fd.changes[device.ID] = changes
+		}
+
+		device.Flapping = len(changes) > fd.threshold
+		fd.flapping = fd.flapping || device.Flapping
+	})
+}
+
+// Flapping reports whether a device was flapping at the last Annotate, so
+// the mark can be cleared once the window has passed without new changes
+func (fd *FlapDetector) Flapping() bool {
+	if fd == nil {
+		return false
+	}
+
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	return fd.flapping
+}
@@ -4,6 +4,16 @@ go 1.24.0
 
 toolchain go1.24.7
 
-require golang.org/x/term v0.35.0
+require golang.org/x/term v0.37.0
 
-require golang.org/x/sys v0.36.0 // indirect
+require (
+	github.com/gdamore/tcell/v2 v2.13.10
+	golang.org/x/sys v0.38.0
+)
+
+require (
+	github.com/gdamore/encoding v1.0.1 // indirect
+	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/text v0.31.0 // indirect
+)
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// grpcService is the path prefix of the DeviceMonitor service of
+// pt_device_monitor.proto
+const grpcService = "/ptmonitor.v1.DeviceMonitor/"
+
+// gRPC status codes sent in the grpc-status trailer
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcUnavailable       = 14
+)
+
+// maxGRPCRequest bounds the request messages, which carry a few filters
+const maxGRPCRequest = 64 << 10
+
+// grpcEventBuffer is how many events a WatchEvents client may fall behind
+const grpcEventBuffer = 256
+
+// GRPCServer serves the DeviceMonitor service of pt_device_monitor.proto on
+// the web server. It speaks the gRPC wire protocol over the server's
+// HTTP/2 with hand-encoded protobuf messages, and passes what is published
+// to the StateStore on to the streaming calls.
+type GRPCServer struct {
+	store *StateStore
+	done  chan struct{} // Closed by Close to end the streaming calls
+
+	mu            sync.Mutex
+	stateWatchers map[chan struct{}]bool
+	eventWatchers map[*grpcEventWatcher]bool
+}
+
+// grpcEventWatcher is the queue of one WatchEvents call
+type grpcEventWatcher struct {
+	events chan DeviceEvent
+	behind chan struct{} // Closed when the queue overflowed
+}
+
+// queue adds events to the queue and reports whether they fit
+func (watcher *grpcEventWatcher) queue(events []DeviceEvent) bool {
+	for _, event := range events {
+		select {
+		case watcher.events <- event:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func NewGRPCServer(store *StateStore) *GRPCServer {
+	gs := &GRPCServer{
+		store:         store,
+		done:          make(chan struct{}),
+		stateWatchers: make(map[chan struct{}]bool),
+		eventWatchers: make(map[*grpcEventWatcher]bool),
+	}
+	store.Subscribe(gs)
+	return gs
+}
+
+// Close ends the streaming calls with UNAVAILABLE, so the web server can
+// shut down without waiting for the clients to go away. Clients exiting
+// along with the monitor may get only the GOAWAY of the connection, which
+// gRPC reports as UNAVAILABLE too.
+func (gs *GRPCServer) Close() {
+	close(gs.done)
+}
+
+// Dispatch wakes the watchers of a poll result
+func (gs *GRPCServer) Dispatch(result PollResult) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	gs.notifyState()
+	for watcher := range gs.eventWatchers {
+		if !watcher.queue(result.Events) {
+			close(watcher.behind)
+			delete(gs.eventWatchers, watcher)
+		}
+	}
+}
+
+// Unchanged sends nothing: the state is the same
+func (gs *GRPCServer) Unchanged() {}
+
+// Watch wakes the state watchers for the re-annotated data
+func (gs *GRPCServer) Watch(data *GroupedDevices) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+
+	gs.notifyState()
+}
+
+// notifyState wakes every WatchState call; a call still sending the
+// previous state sends only the latest one. Called with gs.mu held.
+func (gs *GRPCServer) notifyState() {
+	for wake := range gs.stateWatchers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ServeHTTP handles a gRPC call
+func (gs *GRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC needs HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.WriteHeader(http.StatusOK)
+
+	call := &grpcCall{w: w, r: r}
+	request, err := call.readRequest()
+	if err != nil {
+		call.finish(grpcInvalidArgument, err.Error())
+		return
+	}
+
+	switch r.URL.Path[len(grpcService):] {
+	case "GetState":
+		if call.send(encodeGRPCState(gs.store.State())) {
+			call.finish(grpcOK, "")
+		}
+	case "WatchState":
+		gs.watchState(call)
+	case "WatchEvents":
+		gs.watchEvents(call, request)
+	default:
+		call.finish(grpcUnimplemented, "unknown method "+r.URL.Path)
+	}
+}
+
+// watchState sends the state until the client goes away
+func (gs *GRPCServer) watchState(call *grpcCall) {
+	wake := make(chan struct{}, 1)
+	gs.mu.Lock()
+	gs.stateWatchers[wake] = true
+	gs.mu.Unlock()
+	defer func() {
+		gs.mu.Lock()
+		delete(gs.stateWatchers, wake)
+		gs.mu.Unlock()
+	}()
+
+	for {
+		if !call.send(encodeGRPCState(gs.store.State())) {
+			return
+		}
+		select {
+		case <-wake:
+		case <-gs.done:
+			call.finish(grpcUnavailable, "server shutting down")
+			return
+		case <-call.r.Context().Done():
+			return
+		}
+	}
+}
+
+// watchEvents replays the stored events after the requested time, then sends
+// new ones until the client goes away or falls behind
+func (gs *GRPCServer) watchEvents(call *grpcCall, request []byte) {
+	since, types, err := decodeWatchEventsRequest(request)
+	if err != nil {
+		call.finish(grpcInvalidArgument, err.Error())
+		return
+	}
+	wanted := func(event DeviceEvent) bool {
+		return len(types) == 0 || slices.Contains(types, event.Type)
+	}
+
+	// Watch before reading the stored events, so none is missed in between
+	watcher := &grpcEventWatcher{
+		events: make(chan DeviceEvent, grpcEventBuffer),
+		behind: make(chan struct{}),
+	}
+	gs.mu.Lock()
+	gs.eventWatchers[watcher] = true
+	gs.mu.Unlock()
+	defer func() {
+		gs.mu.Lock()
+		delete(gs.eventWatchers, watcher)
+		gs.mu.Unlock()
+	}()
+
+	var replayed time.Time
+	if !since.IsZero() {
+		for _, event := range gs.store.Events(since, 0) {
+			if wanted(event) && !call.send(encodeGRPCEvent(event)) {
+				return
+			}
+			replayed = event.Time
+		}
+	}
+
+	for {
+		select {
+		case event := <-watcher.events:
+			// Events of one poll share its time, later polls have later ones
+			if !event.Time.After(replayed) || !wanted(event) {
+				continue
+			}
+			if !call.send(encodeGRPCEvent(event)) {
+				return
+			}
+		case <-watcher.behind:
+			call.finish(grpcResourceExhausted, "client fell behind the events; watch again with since set to the last event's time")
+			return
+		case <-gs.done:
+			call.finish(grpcUnavailable, "server shutting down")
+			return
+		case <-call.r.Context().Done():
+			return
+		}
+	}
+}
+
+// grpcCall frames the messages of one call over its HTTP/2 stream
+type grpcCall struct {
+	w http.ResponseWriter
+	r *http.Request
+}
+
+// readRequest reads the one request message of a call
+func (call *grpcCall) readRequest() ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(call.r.Body, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("compressed requests are not supported")
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxGRPCRequest {
+		return nil, fmt.Errorf("request of %d bytes is too large", length)
+	}
+	message := make([]byte, length)
+	if _, err := io.ReadFull(call.r.Body, message); err != nil {
+		return nil, fmt.Errorf("failed to read request: %w", err)
+	}
+	return message, nil
+}
+
+// send writes a response message and flushes it to the client, and reports
+// whether the client is still there
+func (call *grpcCall) send(message []byte) bool {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	if _, err := call.w.Write(append(frame, message...)); err != nil {
+		return false
+	}
+	return http.NewResponseController(call.w).Flush() == nil
+}
+
+// finish ends the call with a status in the trailers
+func (call *grpcCall) finish(code int, message string) {
+	call.w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		call.w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(message))
+	}
+}
+
+// grpcPercentEncode escapes a status message as the gRPC spec asks
+func grpcPercentEncode(message string) string {
+	var out []byte
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c >= 0x20 && c <= 0x7e && c != '%' {
+			out = append(out, c)
+		} else {
+			out = fmt.Appendf(out, "%%%02X", c)
+		}
+	}
+	return string(out)
+}
+
+// encodeGRPCState encodes the State message of state
+func encodeGRPCState(state MonitorState) []byte {
+	var m protoMessage
+	if state.Data != nil {
+		m.timestamp(1, state.Data.LastUpdated)
+	}
+	m.timestamp(2, state.LastPoll)
+	m.string(3, state.LastError)
+	m.timestamp(4, state.ErrorAt)
+	if state.Data == nil {
+		return m
+	}
+
+	for _, group := range sortedGroups(state.Data) {
+		var g protoMessage
+		g.string(1, group.LogicalDevice.ID)
+		g.string(2, group.LogicalDevice.Name)
+		g.string(3, group.GetTopologyDisplayName())
+		g.string(4, group.GetHealthDisplay())
+		if group.ActiveNode != nil {
+			g.string(5, group.ActiveNode.Name)
+		}
+		for _, vc := range group.LogicalDevice.VirtualContexts {
+			g.string(6, vc.Name)
+		}
+		for i := range group.PhysicalDevices {
+			g.message(7, encodeGRPCDevice(&group.PhysicalDevices[i]))
+		}
+		m.message(5, g)
+	}
+	return m
+}
+
+// encodeGRPCDevice encodes the PhysicalDevice message of device
+func encodeGRPCDevice(device *PhysicalDevice) protoMessage {
+	var m protoMessage
+	m.string(1, device.ID)
+	m.string(2, device.Name)
+	m.string(3, device.Description)
+	m.string(4, device.Model)
+	m.string(5, device.SerialNumber)
+	m.string(6, device.Address)
+	m.string(7, device.GetConnectionStateDisplay())
+	m.string(8, device.GetHealthStatusDisplay())
+	m.string(9, device.GetRoleDisplay())
+	m.string(10, device.ProductVersion)
+	m.string(11, device.SoftwareVersion)
+	if t, err := time.Parse(time.RFC3339, device.LastConnectedAt); err == nil {
+		m.timestamp(12, t)
+	}
+	names := make([]string, 0, len(device.Labels))
+	for name := range device.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var entry protoMessage
+		entry.string(1, name)
+		entry.string(2, device.Labels[name])
+		m.message(13, entry)
+	}
+	m.bool(14, device.Flapping)
+	m.bool(15, device.Outdated)
+	m.bool(16, device.Ack != nil)
+	m.string(17, device.Inventory)
+	return m
+}
+
+// encodeGRPCEvent encodes the Event message of event
+func encodeGRPCEvent(event DeviceEvent) []byte {
+	var m protoMessage
+	m.timestamp(1, event.Time)
+	m.string(2, event.Type)
+	m.string(3, event.DeviceID)
+	m.string(4, event.DeviceName)
+	m.string(5, event.LogicalDevice)
+	m.string(6, event.Field)
+	m.string(7, event.From)
+	m.string(8, event.To)
+	m.bool(9, event.Acknowledged)
+	return m
+}
+
+// decodeWatchEventsRequest decodes the since and types of a
+// WatchEventsRequest message
+func decodeWatchEventsRequest(data []byte) (time.Time, []string, error) {
+	var since time.Time
+	var types []string
+	err := decodeProto(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			var seconds, nanos uint64
+			err := decodeProto(bytes, func(field int, value uint64, _ []byte) error {
+				switch field {
+				case 1:
+					seconds = value
+				case 2:
+					nanos = value
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			since = time.Unix(int64(seconds), int64(int32(nanos)))
+		case 2:
+			types = append(types, string(bytes))
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("invalid WatchEventsRequest: %w", err)
+	}
+	return since, types, nil
+}
+
+// protoMessage encodes a protobuf message field by field. Fields with their
+// zero value are left out, as proto3 does.
+type protoMessage []byte
+
+func (m *protoMessage) tag(field, wireType int) {
+	*m = binary.AppendUvarint(*m, uint64(field<<3|wireType))
+}
+
+func (m *protoMessage) varint(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	m.tag(field, 0)
+	*m = binary.AppendUvarint(*m, value)
+}
+
+func (m *protoMessage) bool(field int, value bool) {
+	if value {
+		m.varint(field, 1)
+	}
+}
+
+func (m *protoMessage) string(field int, value string) {
+	if value != "" {
+		m.bytes(field, []byte(value))
+	}
+}
+
+// message writes an embedded message, even an empty one, as an element of a
+// repeated field needs to be there
+func (m *protoMessage) message(field int, value protoMessage) {
+	m.bytes(field, value)
+}
+
+func (m *protoMessage) bytes(field int, value []byte) {
+	m.tag(field, 2)
+	*m = binary.AppendUvarint(*m, uint64(len(value)))
+	*m = append(*m, value...)
+}
+
+// timestamp writes a google.protobuf.Timestamp, unless t is zero
+func (m *protoMessage) timestamp(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	var ts protoMessage
+	ts.varint(1, uint64(t.Unix()))
+	ts.varint(2, uint64(t.Nanosecond()))
+	m.message(field, ts)
+}
+
+// decodeProto calls fn with each field of a protobuf message: varints with
+// their value, length-delimited fields with their bytes. Fixed-size fields
+// are skipped.
+func decodeProto(data []byte, fn func(field int, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("truncated message")
+		}
+		data = data[n:]
+		field := int(key >> 3)
+
+		var value uint64
+		var bytes []byte
+		switch key & 7 {
+		case 0:
+			value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("truncated message")
+			}
+			data = data[n:]
+		case 1, 5:
+			size := 8
+			if key&7 == 5 {
+				size = 4
+			}
+			if len(data) < size {
+				return fmt.Errorf("truncated message")
+			}
+			data = data[size:]
+			continue
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return fmt.Errorf("truncated message")
+			}
+			bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+
+		if err := fn(field, value, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGRPCStateRoundTrip(t *testing.T) {
+	updated := time.Date(2026, 3, 1, 12, 0, 0, 500, time.UTC)
+	device := PhysicalDevice{
+		ID:              "pd-1",
+		Name:            "fw-a",
+		ConnectionState: "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTED",
+		Labels:          map[string]string{"site": "dc1"},
+		Flapping:        true,
+	}
+	state := MonitorState{
+		Data: &GroupedDevices{
+			LogicalDeviceGroups: []LogicalDeviceGroup{{
+				LogicalDevice:   LogicalDevice{ID: "ld-1", Name: "edge"},
+				PhysicalDevices: []PhysicalDevice{device},
+			}},
+			LastUpdated: updated,
+		},
+		LastError: "poll failed: 100% ünreachable\n",
+	}
+
+	var lastUpdated time.Time
+	var lastError, group, name, label string
+	var flapping bool
+	err := decodeProto(encodeGRPCState(state), func(field int, _ uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			var seconds, nanos uint64
+			decodeProto(bytes, func(field int, value uint64, _ []byte) error {
+				if field == 1 {
+					seconds = value
+				} else if field == 2 {
+					nanos = value
+				}
+				return nil
+			})
+			lastUpdated = time.Unix(int64(seconds), int64(nanos)).UTC()
+		case 3:
+			lastError = string(bytes)
+		case 5:
+			return decodeProto(bytes, func(field int, _ uint64, bytes []byte) error {
+				switch field {
+				case 2:
+					group = string(bytes)
+				case 7:
+					return decodeProto(bytes, func(field int, value uint64, bytes []byte) error {
+						switch field {
+						case 2:
+							name = string(bytes)
+						case 13:
+							return decodeProto(bytes, func(_ int, _ uint64, bytes []byte) error {
+								label += string(bytes) + ";"
+								return nil
+							})
+						case 14:
+							flapping = value == 1
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lastUpdated.Equal(updated) || lastError != state.LastError {
+		t.Errorf("state decoded as updated %v, error %q", lastUpdated, lastError)
+	}
+	if group != "edge" || name != "fw-a" || label != "site;dc1;" || !flapping {
+		t.Errorf("device decoded as group %q, name %q, label %q, flapping %v", group, name, label, flapping)
+	}
+
+	encoded := grpcPercentEncode(lastError)
+	if want := "poll failed: 100%25 %C3%BCnreachable%0A"; encoded != want {
+		t.Errorf("grpc-message %q, want %q", encoded, want)
+	}
+	if decoded, err := url.PathUnescape(encoded); err != nil || decoded != lastError {
+		t.Errorf("grpc-message %q decoded as %q, %v", encoded, decoded, err)
+	}
+}
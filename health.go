@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// minStaleAfter keeps a short poll interval from failing health checks on
+// the first slow poll
+const minStaleAfter = time.Minute
+
+// staleAfter is how long the monitor may go without a successful poll
+// before /readyz fails, and without finishing any poll before /healthz does:
+// -stale_after, or three poll intervals, and at least two of the longest
+// backoff intervals and minStaleAfter
+func staleAfter(config *Config) time.Duration {
+	if config.StaleAfter > 0 {
+		return config.StaleAfter
+	}
+	return max(3*config.PollInterval, 2*config.MaxPollInterval, minStaleAfter)
+}
+
+// selfHealth is the body of /healthz and /readyz
+type selfHealth struct {
+	Status     string     `json:"status"` // ok, starting, failing, stale or stuck
+	LastPoll   *time.Time `json:"last_poll,omitempty"`
+	Age        string     `json:"age,omitempty"` // Since the last successful poll
+	LastError  string     `json:"last_error,omitempty"`
+	ErrorAt    *time.Time `json:"error_at,omitempty"`
+	StaleAfter string     `json:"stale_after"`
+}
+
+func newSelfHealth(state MonitorState, limit time.Duration) selfHealth {
+	health := selfHealth{LastError: state.LastError, StaleAfter: limit.String()}
+	if !state.LastPoll.IsZero() {
+		health.LastPoll = &state.LastPoll
+		health.Age = time.Since(state.LastPoll).Round(time.Second).String()
+	}
+	if !state.ErrorAt.IsZero() {
+		health.ErrorAt = &state.ErrorAt
+	}
+	return health
+}
+
+// handleHealthz answers a liveness check: it fails only when no poll has
+// finished, successfully or not, within -stale_after, as when the poller is
+// stuck. An unreachable API does not fail it; restarting would not help.
+func (ws *WebServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	state := ws.store.State()
+	limit := staleAfter(ws.config)
+	health := newSelfHealth(state, limit)
+
+	lastActivity := state.Started
+	for _, at := range []time.Time{state.LastPoll, state.ErrorAt} {
+		if at.After(lastActivity) {
+			lastActivity = at
+		}
+	}
+	switch {
+	case time.Since(lastActivity) > limit:
+		health.Status = "stuck"
+		writeJSONStatus(w, http.StatusServiceUnavailable, health)
+		return
+	case state.LastError != "":
+		health.Status = "failing"
+	case state.LastPoll.IsZero():
+		health.Status = "starting"
+	default:
+		health.Status = "ok"
+	}
+	writeJSON(w, health)
+}
+
+// handleReadyz answers a readiness check: it only passes while the last
+// poll succeeded within -stale_after, so the data served is current
+func (ws *WebServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	state := ws.store.State()
+	limit := staleAfter(ws.config)
+	health := newSelfHealth(state, limit)
+
+	switch {
+	case state.LastError != "":
+		health.Status = "failing"
+	case state.LastPoll.IsZero():
+		health.Status = "starting"
+	case time.Since(state.LastPoll) > limit:
+		health.Status = "stale"
+	default:
+		health.Status = "ok"
+		writeJSON(w, health)
+		return
+	}
+	writeJSONStatus(w, http.StatusServiceUnavailable, health)
+}
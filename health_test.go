@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// streamingSource is a MockSource whose change stream reports one change
+// and then stays up without any
+type streamingSource struct {
+	*MockSource
+}
+
+func (s streamingSource) SubscribeDeviceChanges(ctx context.Context, events chan<- struct{}) error {
+	select {
+	case events <- struct{}{}:
+	case <-ctx.Done():
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHealthzPassesWhileStreaming(t *testing.T) {
+	cm := NewConfigManager()
+	cm.setDefaults()
+	config := cm.config
+	config.Daemon = true
+	config.StreamEnabled = true
+	config.PollInterval = 20 * time.Millisecond
+	config.StaleAfter = 300 * time.Millisecond
+
+	source := streamingSource{NewMockSource(func() ([]PhysicalDevice, string, error) { return testDevices, "v1", nil })}
+	store := NewStateStore()
+	scheduler := NewScheduler(config, source, &testDisplay{}, store)
+	done := make(chan error, 1)
+	go func() { done <- scheduler.Start() }()
+	defer func() {
+		scheduler.Stop()
+		<-done
+	}()
+
+	// Long past -stale_after without a change on the stream
+	time.Sleep(3 * config.StaleAfter)
+
+	server := NewWebServer(config, store)
+	for _, path := range []string{"/healthz", "/readyz"} {
+		recorder := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s answered %d while streaming: %s", path, recorder.Code, recorder.Body)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Heartbeat pings a dead man's switch such as healthchecks.io after every
+// poll: a GET of the URL after a successful poll, a POST of the error to
+// <url>/fail after a failed one. When the monitor dies the pings stop and
+// the service alerts.
+type Heartbeat struct {
+	url    string
+	client *http.Client
+	latest chan error // Outcome of the newest poll not yet pinged
+	done   chan struct{}
+	errLog failureLog
+}
+
+func init() {
+	registerExporter("heartbeat", func(config *Config) (Exporter, error) {
+		if config.HeartbeatURL == "" {
+			return nil, nil
+		}
+		return NewHeartbeat(config.HeartbeatURL), nil
+	})
+}
+
+func NewHeartbeat(url string) *Heartbeat {
+	h := &Heartbeat{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: webhookTimeout},
+		latest: make(chan error, 1),
+		done:   make(chan struct{}),
+		errLog: failureLog{name: "heartbeat"},
+	}
+
+	go h.run()
+
+	return h
+}
+
+// Export queues a ping for the poll. Only the newest outcome is kept, so a
+// slow heartbeat service never holds up polling.
+func (h *Heartbeat) Export(result PollResult) {
+	h.queue(result.Err)
+}
+
+// Unchanged pings for a successful poll that returned nothing new
+func (h *Heartbeat) Unchanged() {
+	h.queue(nil)
+}
+
+func (h *Heartbeat) queue(pollErr error) {
+	select {
+	case <-h.latest:
+	default:
+	}
+	h.latest <- pollErr
+}
+
+// Close sends the last queued ping and stops
+func (h *Heartbeat) Close() {
+	close(h.latest)
+	<-h.done
+}
+
+func (h *Heartbeat) run() {
+	defer close(h.done)
+	for pollErr := range h.latest {
+		h.errLog.set(h.ping(pollErr))
+	}
+}
+
+func (h *Heartbeat) ping(pollErr error) error {
+	var resp *http.Response
+	var err error
+	if pollErr == nil {
+		resp, err = h.client.Get(h.url)
+	} else {
+		resp, err = h.client.Post(h.url+"/fail", "text/plain; charset=utf-8", bytes.NewBufferString(pollErr.Error()))
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("heartbeat URL returned %s", resp.Status)
+	}
+	return nil
+}
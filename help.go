@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// keyboardShortcuts lists the keys of the TUI, for the usage text and the
+// help overlay
+var keyboardShortcuts = []struct {
+	keys   string
+	action string
+}{
+	{"?", "Show this help with the active filters, sorting and config file (any key closes it)"},
+	{"d", "Show connection timings (DNS, connect, TLS, first byte) of the last poll"},
+	{"w", "Write a snapshot of the current devices to -snapshot_dir"},
+	{"↑/↓ j/k", "Select a device, scrolling the list as needed (also a mouse click, Esc clears)"},
+	{"gg / G", "Select the first / last device (also Home / End)"},
+	{"Space", "Collapse the selected device's logical device to one line, or expand it"},
+	{"c", "Collapse every logical device without problems, or expand them all (-collapse_healthy)"},
+	{"o", "Sort logical devices by name, or by severity with the worst problems first (-group_order)"},
+	{"/", "Show only the devices whose name, serial, address, model or description contains the text (empty: all)"},
+	{"y / Y", "Copy the selected device's address / serial number to the clipboard"},
+	{"Enter", "Show the details of the selected device"},
+	{"v", "List the virtual contexts of the selected device's logical device"},
+	{"e", "Show the event log with the last device events"},
+	{"u", "Show how many devices run each version and those whose software and product versions differ"},
+	{"f", "Show fleet statistics: devices by model, version and state, logical devices by topology and contexts"},
+	{"t", "Switch times between absolute and relative (\"12m ago\")"},
+	{"n", "Write a note for the selected device (empty to remove it)"},
+	{"a", "Acknowledge the selected device's problem for a while, silencing its alerts (again: remove)"},
+	{"s", "Open an SSH session to the selected device (-ssh_command), back to the monitor on exit"},
+	{"Click", "Sort the devices by the clicked column title (again: reverse, a third time: API order)"},
+	{"PgUp/PgDn", "Turn the pages of a device list taller than the screen (the mouse wheel scrolls)"},
+	{"Ctrl+Z", "Suspend to the shell, resume with fg"},
+	{"Ctrl+C", "Exit the application"},
+}
+
+// shortcutsUsage formats keyboardShortcuts for the usage text
+func shortcutsUsage() string {
+	var b strings.Builder
+	for _, shortcut := range keyboardShortcuts {
+		fmt.Fprintf(&b, "  %-9s %s\n", shortcut.keys, shortcut.action)
+	}
+	return b.String()
+}
+
+// ToggleHelp shows or hides the help overlay
+func (dm *DisplayManager) ToggleHelp() {
+	dm.help = !dm.help
+	dm.flush()
+}
+
+// HelpShown reports whether the help overlay is open, so the next key only
+// closes it
+func (dm *DisplayManager) HelpShown() bool {
+	return dm.help
+}
+
+// helpLines lists the keyboard shortcuts and what the TUI currently shows
+// for the help overlay
+func (dm *DisplayManager) helpLines() []string {
+	bold := dm.getColor(ColorBold)
+	dim := dm.getColor(ColorDim)
+	reset := dm.getColor(ColorReset)
+
+	lines := []string{bold + "Keyboard shortcuts" + reset, ""}
+	for _, shortcut := range keyboardShortcuts {
+		lines = append(lines, fmt.Sprintf("%-9s  %s", shortcut.keys, shortcut.action))
+	}
+
+	orNone := func(value string) string {
+		if value == "" {
+			return dim + "none" + reset
+		}
+		return value
+	}
+	sorting := "logical device name"
+	if dm.groupOrder == "severity" {
+		sorting = "severity (o), then logical device name"
+	}
+	if dm.config.GroupBy != "" {
+		sorting = fmt.Sprintf("label %s, then %s", dm.config.GroupBy, sorting)
+	}
+	if column, ok := findColumn(dm.sortKey); ok {
+		sorting += fmt.Sprintf("; devices by %s %s", column.Title, dm.sortArrow())
+	}
+	collapsed := "chosen with Space"
+	if dm.collapseHealthy {
+		collapsed = "logical devices without problems (c), others chosen with Space"
+	}
+	times := "absolute"
+	if displayTime.relative {
+		times = "relative"
+	}
+
+	lines = append(lines, "", bold+"Current view"+reset, "",
+		fmt.Sprintf("Config file   %s", orNone(dm.config.ConfigFile)),
+		fmt.Sprintf("Label filter  %s", orNone(dm.config.LabelFilter)),
+		fmt.Sprintf("Search        %s", orNone(dm.search)),
+		fmt.Sprintf("Group by      %s", orNone(dm.config.GroupBy)),
+		fmt.Sprintf("Target ver.   %s", orNone(dm.config.TargetVersion)),
+		fmt.Sprintf("Sorted by     %s", sorting),
+		fmt.Sprintf("Columns       %s", dm.config.ColumnSpec),
+		fmt.Sprintf("Collapsed     %s", collapsed),
+		fmt.Sprintf("Times         %s", times),
+		"",
+		dim+"any key: close"+reset,
+	)
+	return lines
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// historyCheckpoint is how often the history file records the device states
+// even when nothing changed, so a report can tell a quiet period from one
+// in which the monitor was not running
+const historyCheckpoint = 15 * time.Minute
+
+// historyMaxGap is the longest time a history record speaks for. Longer
+// gaps between records mean the monitor was stopped and count as not
+// monitored.
+const historyMaxGap = 2 * historyCheckpoint
+
+// HistoryRecord is one line of the history file: the connection state of
+// every device at a point in time, or the error of a failed poll, during
+// which the states are unknown
+type HistoryRecord struct {
+	Time    time.Time       `json:"time"`
+	Devices []HistoryDevice `json:"devices,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// HistoryDevice is the state of one device in a HistoryRecord
+type HistoryDevice struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	LogicalDevice string `json:"logical_device"`
+	State         string `json:"state"`          // As GetConnectionStateDisplay
+	Role          string `json:"role,omitempty"` // ACTIVE or STANDBY for cluster nodes
+}
+
+// History appends a record to the history file whenever a device changes
+// its connection state, a poll fails or the API recovers, and at least every
+// historyCheckpoint while the monitor runs
+type History struct {
+	path    string
+	mu      sync.Mutex
+	last    *HistoryRecord // Last record written
+	records chan HistoryRecord
+	done    chan struct{}
+	errLog  failureLog
+}
+
+func init() {
+	registerExporter("history", func(config *Config) (Exporter, error) {
+		if config.HistoryFile == "" {
+			return nil, nil
+		}
+		return NewHistory(config.HistoryFile), nil
+	})
+}
+
+func NewHistory(path string) *History {
+	h := &History{
+		path:    path,
+		records: make(chan HistoryRecord, 64),
+		done:    make(chan struct{}),
+		errLog:  failureLog{name: "history"},
+	}
+
+	go h.run()
+
+	return h
+}
+
+// historyDevices lists the connection states of the devices of data
+func historyDevices(data *GroupedDevices) []HistoryDevice {
+	devices := []HistoryDevice{}
+	for _, group := range sortedGroups(data) {
+		for _, device := range group.PhysicalDevices {
+			if device.Inventory == InventoryMissing {
+				continue
+			}
+			devices = append(devices, HistoryDevice{
+				ID:            device.ID,
+				Name:          device.Name,
+				LogicalDevice: group.LogicalDevice.Name,
+				State:         device.GetConnectionStateDisplay(),
+				Role:          device.GetRoleDisplay(),
+			})
+		}
+	}
+	return devices
+}
+
+// Export records the states of a successful poll, or the error of a failed
+// one, if they differ from the last record or it is due for a checkpoint
+func (h *History) Export(result PollResult) {
+	record := HistoryRecord{Time: result.Time}
+	if result.Data != nil {
+		record.Devices = historyDevices(result.Data)
+	} else if result.Err != nil {
+		record.Error = result.Err.Error()
+	} else {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if last := h.last; last != nil && record.Time.Sub(last.Time) < historyCheckpoint {
+		// A changed error message is still the same outage
+		if (record.Error != "") == (last.Error != "") && slices.Equal(record.Devices, last.Devices) {
+			return
+		}
+	}
+	h.queue(record)
+}
+
+// Unchanged writes a checkpoint of the last states when one is due
+func (h *History) Unchanged() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.last == nil || h.last.Error != "" || time.Since(h.last.Time) < historyCheckpoint {
+		return
+	}
+	h.queue(HistoryRecord{Time: time.Now(), Devices: h.last.Devices})
+}
+
+// queue hands record to the writer; call with h.mu held. Records are
+// dropped rather than holding up polling when the disk is stuck.
+func (h *History) queue(record HistoryRecord) {
+	h.last = &record
+	select {
+	case h.records <- record:
+	default:
+		h.errLog.set(fmt.Errorf("write queue full, record of %s dropped", record.Time.Format(time.RFC3339)))
+	}
+}
+
+// Close writes the queued records and stops
+func (h *History) Close() {
+	close(h.records)
+	<-h.done
+}
+
+func (h *History) run() {
+	defer close(h.done)
+	for record := range h.records {
+		h.errLog.set(h.write(record))
+	}
+}
+
+// write appends record as a JSON line
+func (h *History) write(record HistoryRecord) error {
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode history record: %w", err)
+	}
+
+	file, err := os.OpenFile(h.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return file.Close()
+}
+
+// ReadHistory reads the records of the history file in the order they were
+// written
+func ReadHistory(path string) ([]HistoryRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A crash may leave the last line cut off
+			logBackground("history file %s, line %d: %v", path, line, err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// statusClass is what the color of a connection state, health status or role
+// says: fine (green), worth a look (yellow) or a problem (red). A STANDBY
+// node is yellow too, but nothing is wrong with it.
+type statusClass int
+
+const (
+	classNone statusClass = iota
+	classOK
+	classWarning
+	classProblem
+	classStandby
+)
+
+// statusIndicators maps the -status_indicators styles to the marks shown
+// before a status, so it reads without telling red from green. "color"
+// marks nothing.
+var statusIndicators = map[string]map[statusClass]string{
+	"color":   {},
+	"symbols": {classOK: "✓", classWarning: "~", classProblem: "✗", classStandby: "○"},
+	"labels":  {classOK: "[OK]", classWarning: "[WARN]", classProblem: "[DOWN]"},
+}
+
+// asciiIndicators replaces the symbols on consoles without Unicode fonts
+var asciiIndicators = strings.NewReplacer("✓", "+", "✗", "x", "○", "o")
+
+// checkStatusIndicators rejects an unknown -status_indicators style
+func checkStatusIndicators(style string) error {
+	if _, ok := statusIndicators[style]; ok {
+		return nil
+	}
+	styles := make([]string, 0, len(statusIndicators))
+	for name := range statusIndicators {
+		styles = append(styles, name)
+	}
+	sort.Strings(styles)
+	return fmt.Errorf("unknown status indicators %q (use %s)", style, strings.Join(styles, ", "))
+}
+
+// connectionClass classifies a device's connection state. Flapping and
+// missing devices are problems whatever their last state.
+func connectionClass(device *PhysicalDevice) statusClass {
+	if device.Flapping || device.Inventory == InventoryMissing {
+		return classProblem
+	}
+	switch device.ConnectionState {
+	case stateConnected:
+		return classOK
+	case stateDisconnected:
+		return classProblem
+	default:
+		return classWarning
+	}
+}
+
+// healthClass classifies a health status shown by GetHealthStatusDisplay;
+// UNSPECIFIED has no class
+func healthClass(health string) statusClass {
+	switch health {
+	case "HEALTHY":
+		return classOK
+	case "WARNING":
+		return classWarning
+	case "CRITICAL":
+		return classProblem
+	default:
+		return classNone
+	}
+}
+
+// roleClass classifies a role shown by GetRoleDisplay
+func roleClass(role string) statusClass {
+	switch role {
+	case "":
+		return classNone
+	case "ACTIVE":
+		return classOK
+	case "STANDBY":
+		return classStandby
+	default:
+		return classProblem
+	}
+}
+
+// worstState returns the most severe connection state or health among the
+// devices of group, as the table shows it, and its class. A group of healthy,
+// connected devices is classOK.
+func worstState(group *LogicalDeviceGroup) (string, statusClass) {
+	state, worst := "", classNone
+	for i := range group.PhysicalDevices {
+		device := &group.PhysicalDevices[i]
+		status := device.GetConnectionStateDisplay()
+		if device.Flapping {
+			status = "FLAPPING"
+		}
+		// classStandby is never returned for these, so the classes order by
+		// severity
+		if class := connectionClass(device); class > worst {
+			state, worst = status, class
+		}
+		if health := device.GetHealthStatusDisplay(); healthClass(health) > worst {
+			state, worst = health, healthClass(health)
+		}
+	}
+	return state, worst
+}
+
+// classColor returns the color of class, or "" without colors
+func (dm *DisplayManager) classColor(class statusClass) string {
+	switch class {
+	case classOK:
+		return dm.getColor(ColorGreen)
+	case classWarning, classStandby:
+		return dm.getColor(ColorYellow)
+	case classProblem:
+		return dm.getColor(ColorRed)
+	default:
+		return ""
+	}
+}
+
+// indicate puts the -status_indicators mark of class before value
+func (dm *DisplayManager) indicate(class statusClass, value string) string {
+	mark := statusIndicators[dm.config.StatusIndicators][class]
+	if mark == "" {
+		return value
+	}
+	if dm.ascii {
+		mark = asciiIndicators.Replace(mark)
+	}
+	return mark + " " + value
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// InfluxConfig configures the InfluxDB line-protocol sink
+type InfluxConfig struct {
+	URL         string `json:"url"` // InfluxDB base URL, e.g. http://influx:8086
+	Org         string `json:"org"`
+	Bucket      string `json:"bucket"`
+	Token       string `json:"token"`
+	TokenFile   string `json:"token_file"` // Read the token from this file
+	Stdout      bool   `json:"stdout"`     // Write lines to stdout instead of InfluxDB
+	Measurement string `json:"measurement"`
+}
+
+// InfluxSink writes device states and poll statistics as InfluxDB line
+// protocol, either to the v2 write API or to stdout. Writes happen on a
+// background goroutine; batches are dropped if InfluxDB falls behind.
+type InfluxSink struct {
+	config  InfluxConfig
+	client  *http.Client
+	batches chan []byte
+	done    chan struct{}
+	errLog  failureLog
+}
+
+func init() {
+	registerExporter("influx", func(config *Config) (Exporter, error) {
+		if config.Influx.URL == "" && !config.Influx.Stdout {
+			return nil, nil
+		}
+		return NewInfluxSink(config.Influx), nil
+	})
+}
+
+func NewInfluxSink(config InfluxConfig) *InfluxSink {
+	if config.Measurement == "" {
+		config.Measurement = "pt_device"
+	}
+
+	is := &InfluxSink{
+		config:  config,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		batches: make(chan []byte, 16),
+		done:    make(chan struct{}),
+		errLog:  failureLog{name: "influx"},
+	}
+
+	go is.run()
+
+	return is
+}
+
+// Export queues the result of one poll
+func (is *InfluxSink) Export(result PollResult) {
+	batch := is.encode(result.Data, result.Latency, result.Err, result.Time)
+	select {
+	case is.batches <- batch:
+	default:
+		is.errLog.set(fmt.Errorf("write queue full, batch of %s dropped", result.Time.Format(time.RFC3339)))
+	}
+}
+
+// Close flushes queued batches and stops the sink
+func (is *InfluxSink) Close() {
+	close(is.batches)
+	<-is.done
+}
+
+func (is *InfluxSink) run() {
+	defer close(is.done)
+
+	for batch := range is.batches {
+		is.errLog.set(is.send(batch))
+	}
+}
+
+func (is *InfluxSink) send(batch []byte) error {
+	if is.config.Stdout {
+		_, err := os.Stdout.Write(batch)
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("org", is.config.Org)
+	query.Set("bucket", is.config.Bucket)
+	query.Set("precision", "ns")
+	endpoint := strings.TrimSuffix(is.config.URL, "/") + "/api/v2/write?" + query.Encode()
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(batch))
+	if err != nil {
+		return fmt.Errorf("failed to create influx request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if is.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+is.config.Token)
+	}
+
+	resp, err := is.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write to influx: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("influx write failed: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// encode renders one line per device plus a poll summary line
+func (is *InfluxSink) encode(data *GroupedDevices, latency time.Duration, pollErr error, at time.Time) []byte {
+	var b bytes.Buffer
+	timestamp := at.UnixNano()
+
+	success := 1
+	devices := 0
+	if pollErr != nil || data == nil {
+		success = 0
+	} else {
+		devices = data.TotalDevices
+	}
+	fmt.Fprintf(&b, "%s_poll success=%di,latency_ms=%g,devices=%di %d\n",
+		is.config.Measurement, success, float64(latency)/float64(time.Millisecond), devices, timestamp)
+
+	if data == nil {
+		return b.Bytes()
+	}
+
+	for _, group := range data.LogicalDeviceGroups {
+		for i := range group.PhysicalDevices {
+			device := &group.PhysicalDevices[i]
+
+			connected := 0
+			if device.GetConnectionStateDisplay() == "CONNECTED" {
+				connected = 1
+			}
+
+			fmt.Fprintf(&b, "%s,device_id=%s,device=%s,logical_device=%s,topology=%s",
+				is.config.Measurement,
+				influxTag(device.ID),
+				influxTag(device.Name),
+				influxTag(group.LogicalDevice.Name),
+				influxTag(group.GetTopologyDisplayName()),
+			)
+			if role := device.GetRoleDisplay(); role != "" {
+				fmt.Fprintf(&b, ",role=%s", influxTag(role))
+			}
+			fmt.Fprintf(&b, " connected=%di,connection_state=%s,health=%s,version=%s",
+				connected,
+				influxString(device.GetConnectionStateDisplay()),
+				influxString(device.GetHealthStatusDisplay()),
+				influxString(device.GetProductVersionDisplay()),
+			)
+			if device.AsNode != nil {
+				fmt.Fprintf(&b, ",priority=%di", device.AsNode.Priority)
+			}
+			fmt.Fprintf(&b, " %d\n", timestamp)
+		}
+	}
+
+	return b.Bytes()
+}
+
+// influxTag escapes a tag value; empty values are not allowed in line protocol
+func influxTag(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
+
+func influxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Inventory states of a device, see Inventory
+const (
+	InventoryMissing = "MISSING" // Expected, but not listed by the management API
+	InventoryUnknown = "UNKNOWN" // Listed by the management API, but not expected
+)
+
+// missingGroup holds the missing devices without a logical device
+const missingGroup = "(missing devices)"
+
+// InventoryItem is a device the management API should list. With a serial
+// number it matches by serial, so a renamed device is still found;
+// otherwise by name.
+type InventoryItem struct {
+	Name          string `json:"name"`
+	Serial        string `json:"serial,omitempty"`
+	LogicalDevice string `json:"logical_device,omitempty"` // Where the MISSING row is shown
+}
+
+// Inventory compares the devices of every poll with the expected devices:
+// missing ones are added as MISSING rows, unexpected ones marked UNKNOWN
+type Inventory struct {
+	expected []InventoryItem
+}
+
+// NewInventory returns the inventory of config, or nil without expected
+// devices
+func NewInventory(config *Config) *Inventory {
+	inventory, err := LoadInventory(config)
+	if err != nil {
+		// Rejected by validateConfig
+		return nil
+	}
+	return inventory
+}
+
+// LoadInventory returns the expected devices of the config file and
+// -inventory_file, or nil when there are none
+func LoadInventory(config *Config) (*Inventory, error) {
+	expected := append([]InventoryItem(nil), config.ExpectedDevices...)
+
+	if config.InventoryFile != "" {
+		devices, err := readInventoryFile(config.InventoryFile)
+		if err != nil {
+			return nil, err
+		}
+		expected = append(expected, devices...)
+	}
+
+	if len(expected) == 0 {
+		return nil, nil
+	}
+	return &Inventory{expected: expected}, nil
+}
+
+// readInventoryFile reads expected devices from a CSV file with a header
+// row naming the columns: name, serial_number (or serial) and
+// logical_device; other columns are ignored, so a CMDB export works as is
+func readInventoryFile(path string) ([]InventoryItem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse inventory %s: %w", path, err)
+	}
+
+	columns := map[string]int{"name": -1, "serial": -1, "logical_device": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if name == "serial_number" {
+			name = "serial"
+		}
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	if columns["name"] < 0 && columns["serial"] < 0 {
+		return nil, fmt.Errorf("inventory %s needs a name or serial_number column", path)
+	}
+
+	field := func(record []string, column string) string {
+		if i := columns[column]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var devices []InventoryItem
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse inventory %s: %w", path, err)
+		}
+
+		device := InventoryItem{
+			Name:          field(record, "name"),
+			Serial:        field(record, "serial"),
+			LogicalDevice: field(record, "logical_device"),
+		}
+		if device.Name != "" || device.Serial != "" {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (expected InventoryItem) matches(device *PhysicalDevice) bool {
+	if expected.Serial != "" {
+		return strings.EqualFold(expected.Serial, device.SerialNumber)
+	}
+	return expected.Name == device.Name
+}
+
+// Annotate returns a copy of data with unexpected devices marked UNKNOWN and
+// a MISSING row for each expected device the API did not list, in the group
+// of its logical device. TotalDevices still counts the listed devices only.
+// A nil *Inventory expects nothing.
+func (inv *Inventory) Annotate(data *GroupedDevices) *GroupedDevices {
+	if inv == nil {
+		return data
+	}
+
+	found := make([]bool, len(inv.expected))
+	annotated := mapDevices(data, func(device *PhysicalDevice) {
+		device.Inventory = InventoryUnknown
+		for i, expected := range inv.expected {
+			if expected.matches(device) {
+				found[i] = true
+				device.Inventory = ""
+			}
+		}
+	})
+
+	for i, expected := range inv.expected {
+		if found[i] {
+			continue
+		}
+
+		group := expected.LogicalDevice
+		if group == "" {
+			group = missingGroup
+		}
+		name := expected.Name
+		if name == "" {
+			name = expected.Serial
+		}
+		missing := PhysicalDevice{
+			ID:            "missing:" + name,
+			Name:          name,
+			SerialNumber:  expected.Serial,
+			LogicalDevice: LogicalDevice{ID: "missing:" + group, Name: group},
+			Inventory:     InventoryMissing,
+		}
+
+		added := false
+		for j := range annotated.LogicalDeviceGroups {
+			g := &annotated.LogicalDeviceGroups[j]
+			if g.LogicalDevice.Name == group {
+				missing.LogicalDevice = g.LogicalDevice
+				g.PhysicalDevices = append(g.PhysicalDevices, missing)
+				added = true
+				break
+			}
+		}
+		if !added {
+			annotated.LogicalDeviceGroups = append(annotated.LogicalDeviceGroups, LogicalDeviceGroup{
+				LogicalDevice:   missing.LogicalDevice,
+				PhysicalDevices: []PhysicalDevice{missing},
+			})
+		}
+	}
+
+	return annotated
+}
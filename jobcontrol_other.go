@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// notifyJobControl does nothing; job control only exists on Unix
+func notifyJobControl(ch chan<- os.Signal) {}
+
+func isSuspendSignal(sig os.Signal) bool {
+	return false
+}
+
+func stopProcess() {}
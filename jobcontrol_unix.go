@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyJobControl relays SIGTSTP and SIGCONT to ch, so the screen can be
+// released before the process stops and taken back when it continues
+func notifyJobControl(ch chan<- os.Signal) {
+	signal.Notify(ch, syscall.SIGTSTP, syscall.SIGCONT)
+}
+
+// isSuspendSignal reports whether sig asks the process to stop
+func isSuspendSignal(sig os.Signal) bool {
+	return sig == syscall.SIGTSTP
+}
+
+// stopProcess stops the process like the default SIGTSTP action would
+func stopProcess() {
+	syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+}
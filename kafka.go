@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Kafka API keys and the versions used. Produce v3 with record batches and
+// Metadata v4 are the oldest versions still accepted by current brokers.
+const (
+	kafkaAPIProduce              = 0
+	kafkaAPIMetadata             = 3
+	kafkaAPISaslHandshake        = 17
+	kafkaAPISaslAuthenticate     = 36
+	kafkaProduceVersion          = 3
+	kafkaMetadataVersion         = 4
+	kafkaSaslHandshakeVersion    = 1
+	kafkaSaslAuthenticateVersion = 0
+	kafkaRequestTimeout          = 10 * time.Second
+	kafkaMetadataRefreshAge      = 5 * time.Minute
+
+	// kafkaMaxResponseSize bounds what a broker may make the producer
+	// allocate; the metadata of one topic and produce acks are far smaller
+	kafkaMaxResponseSize = 16 << 20
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaProducer is a minimal Kafka producer (acks=1, no compression) that
+// partitions events by device ID so each device's events stay ordered. With
+// a username it authenticates every connection with SASL/PLAIN.
+type kafkaProducer struct {
+	config        EventBusConfig
+	conns         map[int32]*kafkaConn
+	brokers       map[int32]string
+	leaders       []int32 // Leader node per partition
+	metadataAt    time.Time
+	correlationID int32
+}
+
+type kafkaConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newKafkaProducer(config EventBusConfig) *kafkaProducer {
+	return &kafkaProducer{
+		config: config,
+		conns:  make(map[int32]*kafkaConn),
+	}
+}
+
+// Send produces events to their partitions and returns the events of the
+// partitions that failed, so a retry does not duplicate the others. The
+// partitions of a leader that failed are not tried again in the same call.
+func (kp *kafkaProducer) Send(events []DeviceEvent) ([]DeviceEvent, error) {
+	if kp.leaders == nil || time.Since(kp.metadataAt) > kafkaMetadataRefreshAge {
+		if err := kp.refreshMetadata(); err != nil {
+			return events, err
+		}
+	}
+
+	// Group records by partition
+	partitions := make([]int32, len(events))
+	batches := make(map[int32][]kafkaRecord)
+	for i, event := range events {
+		value, err := json.Marshal(event)
+		if err != nil {
+			return events, err
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(event.DeviceID))
+		partition := int32(hash.Sum32() % uint32(len(kp.leaders)))
+		partitions[i] = partition
+		batches[partition] = append(batches[partition], kafkaRecord{
+			key:       []byte(event.DeviceID),
+			value:     value,
+			timestamp: event.Time,
+		})
+	}
+
+	failed := make(map[int32]bool)
+	failedLeaders := make(map[int32]bool)
+	var firstErr error
+	for _, partition := range slices.Sorted(maps.Keys(batches)) {
+		leader := kp.leaders[partition]
+		if failedLeaders[leader] {
+			failed[partition] = true
+			continue
+		}
+		if err := kp.produce(partition, batches[partition]); err != nil {
+			failed[partition] = true
+			failedLeaders[leader] = true
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr == nil {
+		return nil, nil
+	}
+
+	// Leadership may have moved, refresh on the next attempt
+	kp.leaders = nil
+	kp.closeConns()
+
+	var undelivered []DeviceEvent
+	for i, event := range events {
+		if failed[partitions[i]] {
+			undelivered = append(undelivered, event)
+		}
+	}
+	return undelivered, firstErr
+}
+
+func (kp *kafkaProducer) Close() {
+	kp.closeConns()
+}
+
+func (kp *kafkaProducer) closeConns() {
+	for id, c := range kp.conns {
+		c.conn.Close()
+		delete(kp.conns, id)
+	}
+}
+
+// refreshMetadata asks the bootstrap brokers for the partition leaders of the topic
+func (kp *kafkaProducer) refreshMetadata() error {
+	body := binary.BigEndian.AppendUint32(nil, 1) // One topic
+	body = kafkaAppendString(body, kp.config.Topic)
+	body = append(body, 1) // Allow auto topic creation
+
+	var lastErr error
+	for _, address := range kp.config.Brokers {
+		conn, err := kp.dial(address)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		response, err := kp.roundTrip(conn, kafkaAPIMetadata, kafkaMetadataVersion, body)
+		conn.conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		return kp.parseMetadata(response)
+	}
+
+	return fmt.Errorf("kafka metadata request failed: %w", lastErr)
+}
+
+func (kp *kafkaProducer) parseMetadata(data []byte) error {
+	r := &kafkaReader{data: data}
+	r.int32() // Throttle time
+
+	brokers := make(map[int32]string)
+	for n := r.count(12); n > 0; n-- {
+		nodeID := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // Rack
+		brokers[nodeID] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.string() // Cluster ID
+	r.int32()  // Controller ID
+
+	var leaders []int32
+	for n := r.count(9); n > 0; n-- {
+		errorCode := r.int16()
+		name := r.string()
+		r.int8() // Is internal
+		if errorCode != 0 {
+			return fmt.Errorf("kafka topic %s unavailable (error %d)", name, errorCode)
+		}
+
+		partitions := r.count(18)
+		leaders = make([]int32, partitions)
+		for i := 0; i < partitions; i++ {
+			r.int16() // Partition error code
+			index := r.int32()
+			leader := r.int32()
+			r.int32Array() // Replicas
+			r.int32Array() // ISR
+			if index >= 0 && int(index) < partitions {
+				leaders[index] = leader
+			}
+		}
+	}
+
+	if r.err != nil {
+		return fmt.Errorf("invalid kafka metadata response: %w", r.err)
+	}
+	if len(leaders) == 0 {
+		return fmt.Errorf("kafka topic %s has no partitions", kp.config.Topic)
+	}
+
+	kp.brokers = brokers
+	kp.leaders = leaders
+	kp.metadataAt = time.Now()
+	return nil
+}
+
+func (kp *kafkaProducer) produce(partition int32, records []kafkaRecord) error {
+	leader := kp.leaders[partition]
+	conn, ok := kp.conns[leader]
+	if !ok {
+		address, known := kp.brokers[leader]
+		if !known {
+			return fmt.Errorf("kafka leader %d for partition %d is unknown", leader, partition)
+		}
+		var err error
+		conn, err = kp.dial(address)
+		if err != nil {
+			return err
+		}
+		kp.conns[leader] = conn
+	}
+
+	batch := kafkaRecordBatch(records)
+
+	body := binary.BigEndian.AppendUint16(nil, 0xFFFF) // No transactional ID
+	body = binary.BigEndian.AppendUint16(body, 1)      // acks=1
+	body = binary.BigEndian.AppendUint32(body, uint32(kafkaRequestTimeout/time.Millisecond))
+	body = binary.BigEndian.AppendUint32(body, 1) // One topic
+	body = kafkaAppendString(body, kp.config.Topic)
+	body = binary.BigEndian.AppendUint32(body, 1) // One partition
+	body = binary.BigEndian.AppendUint32(body, uint32(partition))
+	body = binary.BigEndian.AppendUint32(body, uint32(len(batch)))
+	body = append(body, batch...)
+
+	response, err := kp.roundTrip(conn, kafkaAPIProduce, kafkaProduceVersion, body)
+	if err != nil {
+		return err
+	}
+
+	r := &kafkaReader{data: response}
+	for topics := r.count(6); topics > 0; topics-- {
+		r.string()
+		for partitions := r.count(22); partitions > 0; partitions-- {
+			r.int32() // Partition index
+			if errorCode := r.int16(); errorCode != 0 {
+				return fmt.Errorf("kafka produce to partition %d failed (error %d)", partition, errorCode)
+			}
+			r.int64() // Base offset
+			r.int64() // Log append time
+		}
+	}
+
+	return r.err
+}
+
+// roundTrip sends a request and returns the response body after the correlation ID
+func (kp *kafkaProducer) roundTrip(c *kafkaConn, apiKey, apiVersion int16, body []byte) ([]byte, error) {
+	kp.correlationID++
+
+	header := binary.BigEndian.AppendUint16(nil, uint16(apiKey))
+	header = binary.BigEndian.AppendUint16(header, uint16(apiVersion))
+	header = binary.BigEndian.AppendUint32(header, uint32(kp.correlationID))
+	header = kafkaAppendString(header, "pt_device_monitor")
+
+	request := binary.BigEndian.AppendUint32(nil, uint32(len(header)+len(body)))
+	request = append(request, header...)
+	request = append(request, body...)
+
+	c.conn.SetDeadline(time.Now().Add(kafkaRequestTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := c.conn.Write(request); err != nil {
+		return nil, fmt.Errorf("kafka request failed: %w", err)
+	}
+
+	var size uint32
+	if err := binary.Read(c.reader, binary.BigEndian, &size); err != nil {
+		return nil, fmt.Errorf("kafka response failed: %w", err)
+	}
+	if size > kafkaMaxResponseSize {
+		return nil, fmt.Errorf("kafka response of %d bytes exceeds the limit of %d", size, kafkaMaxResponseSize)
+	}
+	response := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, response); err != nil {
+		return nil, fmt.Errorf("kafka response failed: %w", err)
+	}
+
+	if len(response) < 4 || int32(binary.BigEndian.Uint32(response)) != kp.correlationID {
+		return nil, fmt.Errorf("kafka response does not match request")
+	}
+
+	return response[4:], nil
+}
+
+// dial connects to a broker and authenticates when a username is configured
+func (kp *kafkaProducer) dial(address string) (*kafkaConn, error) {
+	conn, err := kafkaDial(address)
+	if err != nil || kp.config.Username == "" {
+		return conn, err
+	}
+
+	if err := kp.authenticate(conn); err != nil {
+		conn.conn.Close()
+		return nil, fmt.Errorf("kafka broker %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// authenticate logs in with SASL/PLAIN, which sends the password in the
+// clear like the rest of the connection
+func (kp *kafkaProducer) authenticate(conn *kafkaConn) error {
+	response, err := kp.roundTrip(conn, kafkaAPISaslHandshake, kafkaSaslHandshakeVersion, kafkaAppendString(nil, "PLAIN"))
+	if err != nil {
+		return err
+	}
+	r := &kafkaReader{data: response}
+	if errorCode := r.int16(); errorCode != 0 {
+		return fmt.Errorf("SASL/PLAIN not enabled on the broker (error %d)", errorCode)
+	}
+
+	token := "\x00" + kp.config.Username + "\x00" + kp.config.Password
+	body := binary.BigEndian.AppendUint32(nil, uint32(len(token)))
+	body = append(body, token...)
+	response, err = kp.roundTrip(conn, kafkaAPISaslAuthenticate, kafkaSaslAuthenticateVersion, body)
+	if err != nil {
+		return err
+	}
+	r = &kafkaReader{data: response}
+	errorCode := r.int16()
+	message := r.string()
+	if r.err != nil {
+		return fmt.Errorf("invalid kafka SASL response: %w", r.err)
+	}
+	if errorCode != 0 {
+		return fmt.Errorf("SASL authentication failed (error %d): %s", errorCode, message)
+	}
+	return nil
+}
+
+func kafkaDial(address string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", address, kafkaRequestTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kafka broker %s: %w", address, err)
+	}
+	return &kafkaConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+type kafkaRecord struct {
+	key       []byte
+	value     []byte
+	timestamp time.Time
+}
+
+// kafkaRecordBatch encodes records as a v2 record batch
+func kafkaRecordBatch(records []kafkaRecord) []byte {
+	baseTimestamp := records[0].timestamp.UnixMilli()
+	maxTimestamp := baseTimestamp
+
+	var encoded []byte
+	for i, record := range records {
+		timestamp := record.timestamp.UnixMilli()
+		if timestamp > maxTimestamp {
+			maxTimestamp = timestamp
+		}
+
+		var r []byte
+		r = append(r, 0) // Attributes
+		r = binary.AppendVarint(r, timestamp-baseTimestamp)
+		r = binary.AppendVarint(r, int64(i))
+		r = binary.AppendVarint(r, int64(len(record.key)))
+		r = append(r, record.key...)
+		r = binary.AppendVarint(r, int64(len(record.value)))
+		r = append(r, record.value...)
+		r = binary.AppendVarint(r, 0) // No headers
+
+		encoded = binary.AppendVarint(encoded, int64(len(r)))
+		encoded = append(encoded, r...)
+	}
+
+	// Everything covered by the CRC, starting at attributes
+	var tail []byte
+	tail = binary.BigEndian.AppendUint16(tail, 0) // Attributes
+	tail = binary.BigEndian.AppendUint32(tail, uint32(len(records)-1))
+	tail = binary.BigEndian.AppendUint64(tail, uint64(baseTimestamp))
+	tail = binary.BigEndian.AppendUint64(tail, uint64(maxTimestamp))
+	tail = binary.BigEndian.AppendUint64(tail, 0xFFFFFFFFFFFFFFFF) // Producer ID
+	tail = binary.BigEndian.AppendUint16(tail, 0xFFFF)             // Producer epoch
+	tail = binary.BigEndian.AppendUint32(tail, 0xFFFFFFFF)         // Base sequence
+	tail = binary.BigEndian.AppendUint32(tail, uint32(len(records)))
+	tail = append(tail, encoded...)
+
+	batch := binary.BigEndian.AppendUint64(nil, 0) // Base offset
+	// Batch length counts from partition leader epoch to the end
+	batch = binary.BigEndian.AppendUint32(batch, uint32(4+1+4+len(tail)))
+	batch = binary.BigEndian.AppendUint32(batch, 0xFFFFFFFF) // Partition leader epoch
+	batch = append(batch, 2)                                 // Magic
+	batch = binary.BigEndian.AppendUint32(batch, crc32.Checksum(tail, crc32c))
+	return append(batch, tail...)
+}
+
+func kafkaAppendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// kafkaReader decodes big-endian protocol fields, remembering the first error
+type kafkaReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kafkaReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.take(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if b := r.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// string reads a (nullable) string; null is returned as ""
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+// count reads the length of an array whose elements take at least size
+// bytes each, so a corrupt length fails instead of allocating or looping
+// without bounds
+func (r *kafkaReader) count(size int) int {
+	n := r.int32()
+	if r.err != nil {
+		return 0
+	}
+	if n < 0 {
+		r.err = fmt.Errorf("negative array length %d", n)
+		return 0
+	}
+	if int(n) > len(r.data)/size {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return int(n)
+}
+
+// int32Array reads a (nullable) array; null is returned as nil
+func (r *kafkaReader) int32Array() []int32 {
+	n := r.int32()
+	if n < 0 || r.err != nil {
+		return nil
+	}
+	if int(n) > len(r.data)/4 {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	values := make([]int32, 0, n)
+	for i := int32(0); i < n && r.err == nil; i++ {
+		values = append(values, r.int32())
+	}
+	return values
+}
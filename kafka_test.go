@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestKafkaRejectsMalformedMetadata(t *testing.T) {
+	header := binary.BigEndian.AppendUint32(nil, 0)   // Throttle time
+	header = binary.BigEndian.AppendUint32(header, 0) // No brokers
+	header = kafkaAppendString(header, "cluster")
+	header = binary.BigEndian.AppendUint32(header, 1) // Controller ID
+
+	topic := binary.BigEndian.AppendUint32(nil, 1) // One topic
+	topic = binary.BigEndian.AppendUint16(topic, 0)
+	topic = kafkaAppendString(topic, "events")
+	topic = append(topic, 0)
+
+	metadata := func(partitions uint32) []byte {
+		return binary.BigEndian.AppendUint32(slices.Concat(header, topic), partitions)
+	}
+
+	tests := map[string][]byte{
+		"negative brokers":    binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 0), 0xFFFFFFFF),
+		"huge broker count":   binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 0), 0x7FFFFFFF),
+		"negative partitions": metadata(0xFFFFFFFF),
+		"huge partitions":     metadata(0x7FFFFFFF),
+	}
+	for name, response := range tests {
+		kp := newKafkaProducer(EventBusConfig{Topic: "events"})
+		if err := kp.parseMetadata(response); err == nil {
+			t.Errorf("%s: metadata accepted", name)
+		}
+	}
+}
+
+func TestKafkaRejectsOversizedResponse(t *testing.T) {
+	client, broker := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer broker.Close()
+		request := make([]byte, 1024)
+		broker.Read(request)
+		broker.Write(binary.BigEndian.AppendUint32(nil, 0xFFFFFFF0))
+	}()
+
+	kp := newKafkaProducer(EventBusConfig{Topic: "events"})
+	conn := &kafkaConn{conn: client, reader: bufio.NewReader(client)}
+	if _, err := kp.roundTrip(conn, kafkaAPIMetadata, kafkaMetadataVersion, nil); err == nil {
+		t.Error("response of 4 GB accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Labels are key=value pairs attached to a device, e.g. site=msk
+type Labels map[string]string
+
+// LabelRule attaches labels such as site, rack or owner to the devices whose
+// name matches Name, a glob pattern like "fw-msk-*", or whose serial number
+// is Serial. Later rules override the labels of earlier ones.
+type LabelRule struct {
+	Name   string `json:"name,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	Labels Labels `json:"labels"`
+}
+
+// noLabel groups the devices without the -group_by label
+const noLabel = "(none)"
+
+func (r LabelRule) matches(device *PhysicalDevice) bool {
+	if r.Serial != "" && r.Serial == device.SerialNumber {
+		return true
+	}
+	if r.Name != "" {
+		matched, _ := path.Match(r.Name, device.Name)
+		return matched
+	}
+	return false
+}
+
+// checkLabelRules describes what is wrong with the label rules of the config
+// file
+func checkLabelRules(rules []LabelRule) []string {
+	var problems []string
+	for i, rule := range rules {
+		switch {
+		case rule.Name == "" && rule.Serial == "":
+			problems = append(problems, fmt.Sprintf("labels[%d] needs a name pattern or a serial", i))
+		case len(rule.Labels) == 0:
+			problems = append(problems, fmt.Sprintf("labels[%d] has no labels", i))
+		}
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			problems = append(problems, fmt.Sprintf("labels[%d] has an invalid name pattern %q", i, rule.Name))
+		}
+	}
+	return problems
+}
+
+// parseLabelFilter parses a -label_filter setting, comma-separated key=value
+// pairs that must all match
+func parseLabelFilter(spec string) (map[string]string, error) {
+	filter := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid label filter %q (use key=value)", pair)
+		}
+		filter[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return filter, nil
+}
+
+// ApplyLabels sets the labels of each device of response from the label
+// rules, then drops the devices that do not match -label_filter
+func ApplyLabels(response *APIResponse, config *Config) {
+	if len(config.Labels) == 0 && config.LabelFilter == "" {
+		return
+	}
+
+	// Validated by validateConfig
+	filter, _ := parseLabelFilter(config.LabelFilter)
+
+	var kept []PhysicalDevice
+	for _, device := range response.PhysicalDevices {
+		var labels Labels
+		for _, rule := range config.Labels {
+			if !rule.matches(&device) {
+				continue
+			}
+			if labels == nil {
+				labels = make(Labels)
+			}
+			for key, value := range rule.Labels {
+				labels[key] = value
+			}
+		}
+		device.Labels = labels
+
+		if hasLabels(labels, filter) {
+			kept = append(kept, device)
+		}
+	}
+
+	response.PhysicalDevices = kept
+}
+
+func hasLabels(labels Labels, filter map[string]string) bool {
+	for key, value := range filter {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// GetLabelsDisplay returns the device's labels as key=value pairs sorted by
+// key
+func (pd *PhysicalDevice) GetLabelsDisplay() string {
+	if len(pd.Labels) == 0 {
+		return "-"
+	}
+
+	keys := make([]string, 0, len(pd.Labels))
+	for key := range pd.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + pd.Labels[key]
+	}
+	return strings.Join(pairs, " ")
+}
+
+// groupLabel returns the value of label key for a logical device: that of
+// its first physical device carrying the label
+func groupLabel(group *LogicalDeviceGroup, key string) string {
+	for _, device := range group.PhysicalDevices {
+		if value, ok := device.Labels[key]; ok {
+			return value
+		}
+	}
+	return noLabel
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lastState is the state file: the devices of the last successful poll and
+// the API they came from
+type lastState struct {
+	BaseURL string          `json:"base_url"`
+	Data    *GroupedDevices `json:"data"`
+}
+
+// StateFile writes the devices of every successful poll to a file, so the
+// next start shows them as last known data right away instead of waiting
+// for the first poll
+type StateFile struct {
+	path    string
+	baseURL string
+	latest  chan *GroupedDevices // Newest data not yet written
+	done    chan struct{}
+	errLog  failureLog
+}
+
+func init() {
+	registerExporter("state_file", func(config *Config) (Exporter, error) {
+		if config.StateFile == "" {
+			return nil, nil
+		}
+		return NewStateFile(config.StateFile, config.BaseURL), nil
+	})
+}
+
+// defaultStateFile is state.json in the user's config directory
+func defaultStateFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "pt_device_monitor_state.json"
+	}
+	return filepath.Join(dir, "pt_device_monitor", "state.json")
+}
+
+func NewStateFile(path, baseURL string) *StateFile {
+	sf := &StateFile{
+		path:    path,
+		baseURL: baseURL,
+		latest:  make(chan *GroupedDevices, 1),
+		done:    make(chan struct{}),
+		errLog:  failureLog{name: "state file"},
+	}
+
+	go sf.run()
+
+	return sf
+}
+
+// LoadLastState reads the devices saved by a previous run against the same
+// API. A missing file, or one written for another API, means no data.
+func LoadLastState(path, baseURL string) (*GroupedDevices, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state lastState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if state.BaseURL != baseURL || state.Data == nil {
+		return nil, nil
+	}
+
+	// Acks, flapping and probe results belonged to the previous run
+	return mapDevices(state.Data, func(device *PhysicalDevice) {
+		device.Ack = nil
+		device.Flapping = false
+		device.Probe = nil
+	}), nil
+}
+
+// Export queues the data of a successful poll to be written. Only the newest
+// data is kept, so a slow disk never holds up polling.
+func (sf *StateFile) Export(result PollResult) {
+	if result.Data == nil {
+		return
+	}
+
+	select {
+	case <-sf.latest:
+	default:
+	}
+	sf.latest <- result.Data
+}
+
+// Close writes the last queued data and stops
+func (sf *StateFile) Close() {
+	close(sf.latest)
+	<-sf.done
+}
+
+func (sf *StateFile) run() {
+	defer close(sf.done)
+	for data := range sf.latest {
+		sf.errLog.set(sf.write(data))
+	}
+}
+
+// write saves data to a temporary file first, so a crash never leaves a
+// truncated state file behind
+func (sf *StateFile) write(data *GroupedDevices) error {
+	if err := os.MkdirAll(filepath.Dir(sf.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	content, err := json.Marshal(lastState{BaseURL: sf.baseURL, Data: data})
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp := sf.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, sf.path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// leaderLock is where instances sharing a -leader_lock take turns holding
+// the lease to send notifications
+type leaderLock interface {
+	// Acquire takes the lock for id for lease, or renews it if id holds it,
+	// and reports whether id holds it now
+	Acquire(id string, lease time.Duration) (bool, error)
+	// Release gives the lock up if id holds it
+	Release(id string) error
+}
+
+// LeaderElection decides which of the monitors watching the same management
+// server sends the notifications, so redundant monitors page only once. The
+// instance holding the lock is the leader and renews it every third of the
+// lease; when it stops, another one takes over within a lease.
+type LeaderElection struct {
+	lock    leaderLock
+	id      string
+	lease   time.Duration
+	leader  atomic.Bool
+	expires time.Time // End of the lease held, while leading
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewLeaderElection tries to take the lock of config.LeaderLock, so the
+// first poll already knows whether to notify, and keeps trying in the
+// background
+func NewLeaderElection(config *Config) (*LeaderElection, error) {
+	lock, err := parseLeaderLock(config.LeaderLock, config.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	le := &LeaderElection{
+		lock:    lock,
+		id:      fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		lease:   config.LeaderLease,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	le.renew()
+	if !le.Leading() {
+		logBackground("leader election: another instance sends the notifications, %s stands by", le.id)
+	}
+	go le.run()
+
+	return le, nil
+}
+
+// Leading reports whether this instance sends the notifications; without
+// leader election every instance does
+func (le *LeaderElection) Leading() bool {
+	return le == nil || le.leader.Load()
+}
+
+// Close stops renewing and releases the lock, so another instance takes
+// over without waiting for the lease to run out
+func (le *LeaderElection) Close() {
+	close(le.done)
+	<-le.stopped
+
+	if le.leader.Load() {
+		if err := le.lock.Release(le.id); err != nil {
+			logBackground("leader election: failed to release the lock: %v", err)
+		}
+	}
+}
+
+func (le *LeaderElection) run() {
+	defer close(le.stopped)
+
+	ticker := time.NewTicker(le.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			le.renew()
+		case <-le.done:
+			return
+		}
+	}
+}
+
+// renew takes or renews the lock. When the lock cannot be reached, the
+// leader keeps leading until its lease runs out, as no other instance can
+// take the lock before.
+func (le *LeaderElection) renew() {
+	start := time.Now()
+	held, err := le.lock.Acquire(le.id, le.lease)
+	if err != nil {
+		logBackground("leader election: %v", err)
+		if le.leader.Load() && time.Now().Before(le.expires) {
+			return
+		}
+		held = false
+	}
+	if held {
+		le.expires = start.Add(le.lease)
+	}
+
+	if le.leader.Swap(held) != held {
+		if held {
+			logBackground("leader election: %s now sends the notifications", le.id)
+		} else {
+			logBackground("leader election: %s lost the lock, another instance sends the notifications", le.id)
+		}
+	}
+}
+
+// parseLeaderLock returns the lock of a -leader_lock value: file:<path> or
+// redis://[user:password@]host[:port][/db][?key=<key>] (rediss:// for TLS).
+// The Redis key defaults to one per management server.
+func parseLeaderLock(spec, baseURL string) (leaderLock, error) {
+	if path, ok := strings.CutPrefix(spec, "file:"); ok {
+		if path == "" {
+			return nil, fmt.Errorf("leader_lock file: needs a path, e.g. file:/shared/pt_device_monitor.lock")
+		}
+		return &fileLeaderLock{path: path}, nil
+	}
+
+	server, err := url.Parse(spec)
+	if err != nil || (server.Scheme != "redis" && server.Scheme != "rediss") || server.Host == "" {
+		return nil, fmt.Errorf("invalid leader_lock %q (use file:<path> or redis://host:port)", spec)
+	}
+	lock := &redisLeaderLock{
+		address: withDefaultPort(server.Host, "6379"),
+		useTLS:  server.Scheme == "rediss",
+		key:     server.Query().Get("key"),
+	}
+	if server.User != nil {
+		lock.username = server.User.Username()
+		lock.password, _ = server.User.Password()
+	}
+	if db := strings.Trim(server.Path, "/"); db != "" {
+		if _, err := strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid leader_lock Redis database %q", db)
+		}
+		lock.db = db
+	}
+	if lock.key == "" {
+		lock.key = "pt_device_monitor:leader:" + baseURL
+	}
+	return lock, nil
+}
+
+// fileLeaderLock keeps the lease in a file on storage the instances share,
+// as the owner and the end of the lease. A missing lock is taken with a
+// hard link, which fails when another instance was first; two instances
+// taking over an expired lease at the same moment both lead until the
+// next renewal.
+type fileLeaderLock struct {
+	path string
+}
+
+// fileLease is the content of a file lock
+type fileLease struct {
+	Owner string    `json:"owner"`
+	Until time.Time `json:"until"`
+}
+
+func (fl *fileLeaderLock) Acquire(id string, lease time.Duration) (bool, error) {
+	current, err := fl.read()
+	if err != nil {
+		return false, err
+	}
+	if current != nil && current.Owner != id && time.Now().Before(current.Until) {
+		return false, nil
+	}
+
+	data, _ := json.Marshal(fileLease{Owner: id, Until: time.Now().Add(lease)})
+	temp, err := os.CreateTemp(filepath.Dir(fl.path), ".leader-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	if current == nil {
+		if err := os.Link(temp.Name(), fl.path); err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to create lock file: %w", err)
+		}
+		return true, nil
+	}
+	if err := os.Rename(temp.Name(), fl.path); err != nil {
+		return false, fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	// Of two instances taking over at once, the one renamed last holds it
+	current, err = fl.read()
+	return err == nil && current != nil && current.Owner == id, err
+}
+
+func (fl *fileLeaderLock) Release(id string) error {
+	current, err := fl.read()
+	if err != nil || current == nil || current.Owner != id {
+		return err
+	}
+	return os.Remove(fl.path)
+}
+
+// read returns the lease in the lock file, or nil without one
+func (fl *fileLeaderLock) read() (*fileLease, error) {
+	data, err := os.ReadFile(fl.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	var lease fileLease
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", fl.path, err)
+	}
+	return &lease, nil
+}
+
+// Lua scripts, so checking the owner and changing the key are atomic
+const (
+	redisAcquireScript = `local owner = redis.call('GET', KEYS[1])
+if owner == false or owner == ARGV[1] then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return 1
+end
+return 0`
+	redisReleaseScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`
+)
+
+// redisMaxBulkLength bounds what a server may make the lock allocate; the
+// replies are at most an owner id
+const redisMaxBulkLength = 64 << 10
+
+// redisLeaderLock holds the lease as a Redis key with the owner as value
+// that expires with the lease, speaking RESP over one connection
+type redisLeaderLock struct {
+	address  string
+	useTLS   bool
+	username string
+	password string
+	db       string
+	key      string
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (rl *redisLeaderLock) Acquire(id string, lease time.Duration) (bool, error) {
+	reply, err := rl.command("EVAL", redisAcquireScript, "1", rl.key, id, strconv.FormatInt(lease.Milliseconds(), 10))
+	return reply == "1", err
+}
+
+func (rl *redisLeaderLock) Release(id string) error {
+	_, err := rl.command("EVAL", redisReleaseScript, "1", rl.key, id)
+	return err
+}
+
+// command sends a command and returns its reply, connecting first if
+// needed and again after an error
+func (rl *redisLeaderLock) command(args ...string) (string, error) {
+	if rl.conn == nil {
+		if err := rl.connect(); err != nil {
+			return "", err
+		}
+	}
+
+	reply, err := rl.roundTrip(args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		rl.conn.Close()
+		rl.conn = nil
+	}
+	return reply, err
+}
+
+func (rl *redisLeaderLock) connect() error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.Dial("tcp", rl.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	if rl.useTLS {
+		host, _, _ := net.SplitHostPort(rl.address)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return fmt.Errorf("Redis TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+	}
+	rl.conn, rl.reader = conn, bufio.NewReader(conn)
+
+	var setup [][]string
+	if rl.password != "" {
+		if rl.username != "" {
+			setup = append(setup, []string{"AUTH", rl.username, rl.password})
+		} else {
+			setup = append(setup, []string{"AUTH", rl.password})
+		}
+	}
+	if rl.db != "" {
+		setup = append(setup, []string{"SELECT", rl.db})
+	}
+	for _, args := range setup {
+		if _, err := rl.roundTrip(args...); err != nil {
+			rl.conn.Close()
+			rl.conn = nil
+			return fmt.Errorf("Redis %s failed: %w", args[0], err)
+		}
+	}
+	return nil
+}
+
+// redisError is an error reply of the server, after which the connection
+// is still usable
+type redisError string
+
+func (e redisError) Error() string {
+	return "Redis: " + string(e)
+}
+
+// roundTrip writes args as a RESP array and reads a simple, integer or bulk
+// string reply
+func (rl *redisLeaderLock) roundTrip(args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	rl.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := rl.conn.Write([]byte(b.String())); err != nil {
+		return "", fmt.Errorf("failed to send to Redis: %w", err)
+	}
+
+	line, err := rl.reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read Redis reply: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", redisError(line[1:])
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid Redis reply %q", line)
+		}
+		if length < 0 {
+			return "", nil
+		}
+		if length > redisMaxBulkLength {
+			return "", fmt.Errorf("Redis reply of %d bytes exceeds the limit of %d", length, redisMaxBulkLength)
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(rl.reader, data); err != nil {
+			return "", fmt.Errorf("failed to read Redis reply: %w", err)
+		}
+		return string(data[:length]), nil
+	default:
+		return "", fmt.Errorf("unexpected Redis reply %q", line)
+	}
+}
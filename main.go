@@ -1,15 +1,30 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
 )
 
 type Application struct {
 	config    *Config
+	configErr *ValidationError // Reported by config validate instead of failing Initialize
+	logFile   *os.File
+	audit     *AuditLog
+	recorder  *Recorder
 	apiClient *APIClient
 	display   *DisplayManager
 	scheduler *Scheduler
+	store     *StateStore
+	webServer *WebServer
+	sinks     *Sinks
 }
 
 func NewApplication() *Application {
@@ -20,35 +35,351 @@ func (app *Application) Initialize() error {
 	configManager := NewConfigManager()
 	config, err := configManager.LoadConfig()
 	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) && configManager.GetConfig().Command == "config validate" {
+			// Let the command report the problems with its other checks
+			app.config = configManager.GetConfig()
+			app.configErr = validationErr
+			return nil
+		}
+
 		configManager.printUsage()
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 	app.config = config
+	setTimeDisplay(config)
+	// Only the TUI, on a terminal, keeps the log off stderr
+	backgroundLog = config.Daemon || config.LogFile != "" || !term.IsTerminal(int(os.Stdout.Fd()))
+
+	if config.Daemon || config.LogFile != "" {
+		if err := app.setupLogging(); err != nil {
+			return err
+		}
+	}
+
+	if config.Demo {
+		if err := startDemo(config); err != nil {
+			return err
+		}
+	}
+	if config.Replay != "" {
+		if err := startReplay(config); err != nil {
+			return err
+		}
+	}
+
+	StartTelemetry(config.Telemetry)
+
+	audit, err := NewAuditLog(config)
+	if err != nil {
+		return err
+	}
+	app.audit = audit
 
 	app.apiClient = NewAPIClient(config)
+	app.apiClient.SetAuditLog(audit)
+
+	recorder, err := NewRecorder(config)
+	if err != nil {
+		return err
+	}
+	app.recorder = recorder
+	app.apiClient.SetRecorder(recorder)
 
 	app.display = NewDisplayManager(config)
 
-	app.scheduler = NewScheduler(config, app.apiClient, app.display)
+	return nil
+}
+
+// initMonitor sets up the parts only the long-running monitor needs, so
+// one-shot commands do not connect sinks or open listeners
+func (app *Application) initMonitor() error {
+	app.store = NewStateStore()
+
+	sinks, err := NewSinks(app.config)
+	if err != nil {
+		return err
+	}
+	app.sinks = sinks
+
+	notes, err := LoadNotes(app.config.NotesFile)
+	if err != nil {
+		return err
+	}
+
+	// Sinks subscribe first, so notifications do not wait for the screen
+	app.store.Subscribe(app.sinks)
+	app.scheduler = NewScheduler(app.config, app.apiClient, app.display, app.store)
+	app.scheduler.SetNotes(notes)
+
+	// Show the devices of the previous run until the first poll succeeds
+	last, err := LoadLastState(app.config.StateFile, app.config.BaseURL)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	if last != nil {
+		app.store.Update(last, nil)
+		app.display.SetLastKnown(last)
+	}
+
+	// Show the last failover of each cluster before the next one happens
+	if app.config.HistoryFile != "" {
+		records, err := ReadHistory(app.config.HistoryFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("%v", err)
+		}
+		app.display.RecordFailovers(historyFailovers(records))
+	}
+
+	acks := NewAckStore()
+	app.scheduler.SetAcks(acks)
+
+	if app.config.WebListen != "" {
+		app.webServer = NewWebServer(app.config, app.store)
+		app.webServer.SetAcks(acks)
+	}
 
 	return nil
 }
 
-func (app *Application) Run() error {
+// Run executes the command given on the command line and shuts down. It
+// returns the exit code, so main exits only after the sinks, logs and
+// recorder are closed: 1 when the command failed, e.g. a failed -assert,
+// with its error printed.
+func (app *Application) Run() int {
+	if err := app.runCommand(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func (app *Application) runCommand() error {
+	defer app.Shutdown()
+	defer app.display.RestoreOnPanic()
+
+	return findCommand(app.config.Command).run(app)
+}
+
+// runMonitor runs the TUI, or the daemon with -daemon. -output and -assert
+// still select a one-shot run, as they did before there were commands.
+func (app *Application) runMonitor() error {
+	if app.config.OutputFormat != "tui" || app.config.Assert != "" {
+		return app.runOnce(app.config.OutputFormat)
+	}
+
+	if err := app.initMonitor(); err != nil {
+		return err
+	}
+
+	if app.webServer != nil {
+		if err := app.webServer.Start(); err != nil {
+			return fmt.Errorf("failed to start web server: %w", err)
+		}
+	}
+
 	if err := app.scheduler.TestInitialConnection(); err != nil {
-		if app.display != nil {
-			app.display.RestoreTerminal()
+		if isAuthError(err) {
+			if app.display != nil {
+				app.display.RestoreTerminal()
+			}
+			return fmt.Errorf("initial connection test failed: %w", err)
 		}
-		return fmt.Errorf("initial connection test failed: %w", err)
+
+		// The management API is often still booting when the monitor starts,
+		// so show the error and keep retrying with backoff instead of exiting
+		app.scheduler.SetStartupError(err)
+	}
+
+	if app.config.Daemon {
+		log.Printf("monitoring %s every %s", app.config.BaseURL, app.config.PollInterval)
 	}
 
 	return app.scheduler.Start()
 }
 
+// runOnce fetches the device list once and writes it in format instead of
+// starting the TUI; format tui writes nothing but the assertion result. With
+// -assert the result is checked and a failed assertion is returned as an
+// error, so the process exits non-zero.
+func (app *Application) runOnce(format string) error {
+	ctx := context.Background()
+
+	if err := app.apiClient.Authenticate(ctx, AuthLogin); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	grouped, err := app.fetchForReport(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch devices: %w", err)
+	}
+
+	if prober := NewProber(app.config); prober != nil {
+		prober.Run(ctx, grouped)
+		grouped = prober.Annotate(grouped)
+	}
+
+	var assertErr error
+	if app.config.Assert != "" {
+		assertErr = CheckAssertion(app.config.Assert, grouped)
+	}
+
+	if format == "tui" {
+		if assertErr == nil {
+			fmt.Printf("%s: OK (%d devices)\n", app.config.Assert, grouped.TotalDevices)
+		}
+		return assertErr
+	}
+
+	out, closeOutput, err := app.createOutput()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	if err := WriteReport(out, format, grouped, app.config); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return assertErr
+}
+
+// createOutput opens -output_file for a one-shot command, or returns stdout
+// without it
+func (app *Application) createOutput() (io.Writer, func(), error) {
+	if app.config.OutputFile == "" {
+		return os.Stdout, func() {}, nil
+	}
+
+	file, err := os.Create(app.config.OutputFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+	return file, func() { file.Close() }, nil
+}
+
+// fetchForReport fetches the device list for runOnce. With -assert and
+// -wait_timeout it keeps polling every poll interval, riding out API errors,
+// until the assertion holds or the timeout passes.
+func (app *Application) fetchForReport(ctx context.Context) (*GroupedDevices, error) {
+	deadline := time.Now().Add(app.config.WaitTimeout)
+
+	notes, err := LoadNotes(app.config.NotesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var grouped *GroupedDevices
+		response, err := app.apiClient.FetchDevicesWithRetry(ctx, 2)
+		if err == nil {
+			ApplyLabels(response, app.config)
+			ApplyTargetVersion(response, app.config)
+			notes.Apply(response)
+			grouped = NewInventory(app.config).Annotate(GroupDevicesByLogicalDevice(response))
+			if app.config.Assert == "" {
+				return grouped, nil
+			}
+			err = CheckAssertion(app.config.Assert, grouped)
+			if err == nil {
+				return grouped, nil
+			}
+		}
+
+		if app.config.Assert == "" || !time.Now().Before(deadline) {
+			if grouped != nil {
+				// The assertion failed; the caller reports it with the data
+				return grouped, nil
+			}
+			return nil, err
+		}
+
+		log.Printf("waiting for %s: %v", app.config.Assert, err)
+
+		// The last attempt is made at the deadline, not a poll interval past it
+		wait := time.NewTimer(min(app.config.PollInterval, time.Until(deadline)))
+		select {
+		case <-wait.C:
+		case <-ctx.Done():
+			wait.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// setupLogging sends log output to the configured file. Without one it stays
+// on stderr, which journald records in daemon mode; timestamps are then left
+// to journald.
+func (app *Application) setupLogging() error {
+	if app.config.LogFile == "" {
+		log.SetFlags(0)
+		return nil
+	}
+
+	file, err := os.OpenFile(app.config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	app.logFile = file
+	log.SetOutput(file)
+
+	return nil
+}
+
+// backgroundLog is whether sinks and other background tasks log their
+// failures and state changes, which they do not while the log shares the
+// terminal with the TUI
+var backgroundLog bool
+
+// logBackground logs a message of a background task, see backgroundLog
+func logBackground(format string, args ...interface{}) {
+	if backgroundLog {
+		log.Printf(format, args...)
+	}
+}
+
+// failureLog logs the failures of a background task with logBackground,
+// each new one once, so a sink failing on every attempt does not flood the
+// log. Safe for concurrent use.
+type failureLog struct {
+	name string // Prefix of the messages, e.g. "influx"
+	mu   sync.Mutex
+	last error
+}
+
+// set records the outcome of an attempt; nil is a success
+func (fl *failureLog) set(err error) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	if err != nil && (fl.last == nil || fl.last.Error() != err.Error()) {
+		logBackground("%s: %v", fl.name, err)
+	}
+	fl.last = err
+}
+
 func (app *Application) Shutdown() {
+	if app.config != nil && app.config.Daemon {
+		sdNotify("STOPPING=1")
+	}
+
 	if app.scheduler != nil {
 		app.scheduler.Stop()
 	}
+	if app.webServer != nil {
+		app.webServer.Stop()
+	}
+	if app.sinks != nil {
+		app.sinks.Close()
+	}
+	StopTelemetry()
+	app.audit.Close()
+	app.recorder.Close()
+	if app.logFile != nil {
+		log.SetOutput(os.Stderr)
+		app.logFile.Close()
+		app.logFile = nil
+	}
 	if app.display != nil {
 		app.display.RestoreTerminal()
 	}
@@ -58,18 +389,9 @@ func main() {
 	app := NewApplication()
 
 	if err := app.Initialize(); err != nil {
+		app.Shutdown()
 		log.Fatalf("Failed to initialize application: %v", err)
 	}
 
-	defer func() {
-		app.Shutdown()
-
-		if app.display != nil {
-			app.display.RestoreTerminal()
-		}
-	}()
-
-	if err := app.Run(); err != nil {
-		log.Fatalf("Application error: %v", err)
-	}
+	os.Exit(app.Run())
 }
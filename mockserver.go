@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// mockSource returns the devices the mock API lists and a version that
+// changes with them, for the ETag. An error answers the request with 503.
+type mockSource func() (devices []PhysicalDevice, version string, err error)
+
+// MockServer answers Login and ListPhysicalDevices like the management API,
+// with devices from a fixture file or the demo fleet and, for testing the
+// monitor's error handling, optional latency and random failures
+type MockServer struct {
+	source          mockSource
+	username        string // Login checks the credentials unless empty
+	password        string
+	latency         time.Duration // Added to every response
+	errorRate       int           // Percent of device list requests answered with a 5xx
+	authFailureRate int           // Percent of device list requests answered with 401, as for an expired session
+}
+
+// NewMockServer returns a mock API for the mockserver command. -fixture
+// selects the device file, the demo fleet without one.
+func NewMockServer(config *Config) *MockServer {
+	server := &MockServer{
+		username:        config.Username,
+		password:        config.Password,
+		latency:         config.MockLatency,
+		errorRate:       config.MockErrorRate,
+		authFailureRate: config.MockAuthFailureRate,
+	}
+
+	if config.MockFixture != "" {
+		server.source = fixtureSource(config.MockFixture)
+	} else {
+		fleet := NewDemoFleet()
+		go fleet.Run()
+		server.source = fleet.Devices
+	}
+	return server
+}
+
+// fixtureSource reads the devices from path, in the format of a
+// ListPhysicalDevices response, on every request, so the file can be edited
+// while the mock server runs
+func fixtureSource(path string) mockSource {
+	return func() ([]PhysicalDevice, string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read fixture: %w", err)
+		}
+
+		var response APIResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, "", fmt.Errorf("failed to parse fixture %s: %w", path, err)
+		}
+
+		sum := sha256.Sum256(data)
+		return response.PhysicalDevices, hex.EncodeToString(sum[:8]), nil
+	}
+}
+
+// writeMockError answers with a structured error body, as the API does
+func writeMockError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiErrorBody{Code: status, Message: message})
+}
+
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.latency > 0 {
+		select {
+		case <-time.After(m.latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case "/api/v2/Login":
+		m.login(w, r)
+	case "/api/v2/ListPhysicalDevices":
+		m.listDevices(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MockServer) login(w http.ResponseWriter, r *http.Request) {
+	var request LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeMockError(w, http.StatusBadRequest, "invalid login request")
+		return
+	}
+	if m.username != "" && (request.Login != m.username || request.Password != m.password) {
+		writeMockError(w, http.StatusUnauthorized, "invalid credentials")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "Authorization", Value: "mock", Path: "/"})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *MockServer) listDevices(w http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie("Authorization"); err != nil {
+		writeMockError(w, http.StatusUnauthorized, "not logged in")
+		return
+	}
+
+	switch roll := rand.N(100); {
+	case roll < m.authFailureRate:
+		writeMockError(w, http.StatusUnauthorized, "session expired (injected)")
+		return
+	case roll < m.authFailureRate+m.errorRate:
+		status := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}[rand.N(3)]
+		writeMockError(w, status, "injected failure")
+		return
+	}
+
+	devices, version, err := m.source()
+	if err != nil {
+		writeMockError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	etag := strconv.Quote(version)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(APIResponse{PhysicalDevices: devices, Total: len(devices)})
+}
+
+// runMockServer serves the mock API on -mock_listen until interrupted
+func (app *Application) runMockServer() error {
+	mock := NewMockServer(app.config)
+	server := &http.Server{Addr: app.config.MockListen, Handler: mock}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+
+	source := "the demo fleet"
+	if app.config.MockFixture != "" {
+		source = app.config.MockFixture
+	}
+	log.Printf("mock API serving %s at http://%s/api/v2/", source, app.config.MockListen)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("mock server failed: %w", err)
+	case <-ctx.Done():
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdown)
+	}
+}
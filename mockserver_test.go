@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testDevices = []PhysicalDevice{
+	{ID: "pd-1", Name: "fw-1", ConnectionState: "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTED"},
+	{ID: "pd-2", Name: "fw-2", ConnectionState: "PHYSICAL_DEVICE_CONNECTION_STATE_DISCONNECTED"},
+}
+
+// mockSequence answers the nth device list request with the nth mock, and
+// every later one with the last, so a test decides which requests fail
+// without depending on the random fault injection rates
+type mockSequence struct {
+	mocks []*MockServer
+
+	mu     sync.Mutex
+	logins int
+	lists  int
+}
+
+func (s *mockSequence) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	mock := s.mocks[len(s.mocks)-1]
+	switch r.URL.Path {
+	case "/api/v2/Login":
+		s.logins++
+	case "/api/v2/ListPhysicalDevices":
+		if s.lists < len(s.mocks) {
+			mock = s.mocks[s.lists]
+		}
+		s.lists++
+	}
+	s.mu.Unlock()
+
+	mock.ServeHTTP(w, r)
+}
+
+func (s *mockSequence) counts() (logins, lists int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins, s.lists
+}
+
+// newTestMock returns a mock API with testDevices and the admin/admin
+// credentials of the default config
+func newTestMock() *MockServer {
+	return &MockServer{
+		source:   func() ([]PhysicalDevice, string, error) { return testDevices, "v1", nil },
+		username: "admin",
+		password: "admin",
+	}
+}
+
+// startMockAPI serves mocks and returns a client for them with the default
+// config
+func startMockAPI(t *testing.T, mocks ...*MockServer) (*APIClient, *mockSequence) {
+	t.Helper()
+
+	sequence := &mockSequence{mocks: mocks}
+	server := httptest.NewServer(sequence)
+	t.Cleanup(server.Close)
+
+	cm := NewConfigManager()
+	cm.setDefaults()
+	cm.config.BaseURL = server.URL + "/api/v2/"
+	return NewAPIClient(cm.config), sequence
+}
+
+func TestMockServerLogin(t *testing.T) {
+	client, _ := startMockAPI(t, newTestMock())
+	ctx := context.Background()
+
+	if err := client.Authenticate(ctx, AuthLogin); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if !client.IsAuthenticated() {
+		t.Fatal("not authenticated after login")
+	}
+
+	response, err := client.FetchDevicesWithRetry(ctx, 0)
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if response.Total != len(testDevices) || response.NotModified {
+		t.Fatalf("got %d devices (not modified: %v), want %d", response.Total, response.NotModified, len(testDevices))
+	}
+
+	// The ETag of the first response makes the second a 304
+	response, err = client.FetchDevicesWithRetry(ctx, 0)
+	if err != nil {
+		t.Fatalf("second fetch failed: %v", err)
+	}
+	if !response.NotModified {
+		t.Error("unchanged device list not reported as not modified")
+	}
+}
+
+func TestMockServerRejectsCredentials(t *testing.T) {
+	mock := newTestMock()
+	mock.password = "secret"
+	client, _ := startMockAPI(t, mock)
+
+	err := client.Authenticate(context.Background(), AuthLogin)
+	if !isAuthError(err) {
+		t.Fatalf("got %v, want an authentication error", err)
+	}
+	if client.IsAuthenticated() {
+		t.Error("authenticated with wrong credentials")
+	}
+}
+
+func TestAPIClientReauthenticatesExpiredSession(t *testing.T) {
+	expired := newTestMock()
+	expired.authFailureRate = 100
+	client, sequence := startMockAPI(t, expired, newTestMock())
+	ctx := context.Background()
+
+	if err := client.Authenticate(ctx, AuthLogin); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	response, err := client.FetchDevicesWithRetry(ctx, 0)
+	if err != nil {
+		t.Fatalf("fetch after an expired session failed: %v", err)
+	}
+	if response.Total != len(testDevices) {
+		t.Errorf("got %d devices, want %d", response.Total, len(testDevices))
+	}
+
+	if logins, lists := sequence.counts(); logins != 2 || lists != 2 {
+		t.Errorf("got %d logins and %d device list requests, want 2 and 2", logins, lists)
+	}
+}
+
+func TestAPIClientRetriesServerErrors(t *testing.T) {
+	failing := newTestMock()
+	failing.errorRate = 100
+	client, sequence := startMockAPI(t, failing, newTestMock())
+	ctx := context.Background()
+
+	if err := client.Authenticate(ctx, AuthLogin); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	response, err := client.FetchDevicesWithRetry(ctx, 1)
+	if err != nil {
+		t.Fatalf("fetch with a retry failed: %v", err)
+	}
+	if response.Total != len(testDevices) {
+		t.Errorf("got %d devices, want %d", response.Total, len(testDevices))
+	}
+	if _, lists := sequence.counts(); lists != 2 {
+		t.Errorf("got %d device list requests, want 2", lists)
+	}
+}
+
+func TestAPIClientGivesUpOnServerErrors(t *testing.T) {
+	failing := newTestMock()
+	failing.errorRate = 100
+	client, sequence := startMockAPI(t, failing)
+	ctx := context.Background()
+
+	if err := client.Authenticate(ctx, AuthLogin); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	_, err := client.FetchDevicesWithRetry(ctx, 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode < 500 {
+		t.Fatalf("got %v, want a 5xx API error", err)
+	}
+	if _, lists := sequence.counts(); lists != 2 {
+		t.Errorf("got %d device list requests, want 2", lists)
+	}
+}
+
+func TestAPIClientTimesOutSlowServer(t *testing.T) {
+	slow := newTestMock()
+	slow.latency = time.Second
+	client, _ := startMockAPI(t, slow)
+	client.config.RequestTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err := client.Authenticate(context.Background(), AuthLogin)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > slow.latency/2 {
+		t.Errorf("login gave up after %s, want about %s", elapsed, client.config.RequestTimeout)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"net"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -8,6 +10,8 @@ import (
 type APIResponse struct {
 	PhysicalDevices []PhysicalDevice `json:"physicalDevices"`
 	Total           int              `json:"total"`
+	NotModified     bool             `json:"-"` // Server answered 304, content equals the previous response
+	Latency         time.Duration    `json:"-"` // Time the poll took, including retries
 }
 
 type PhysicalDevice struct {
@@ -30,6 +34,13 @@ type PhysicalDevice struct {
 	ConfigurationStatus string        `json:"configurationStatus"` // PHYSICAL_DEVICE_CONFIGURATION_STATUS_UNSPECIFIED
 	ProductVersion      string        `json:"productVersion"`
 	LogicalDeviceChange string        `json:"logicalDeviceChange"` // LOGICAL_DEVICE_CHANGE_UNSPECIFIED
+	Probe               *ProbeResult  `json:"probe,omitempty"`     // Set by -probe, not by the API
+	Labels              Labels        `json:"labels,omitempty"`    // Set by the config file's label rules
+	Note                *Note         `json:"note,omitempty"`      // Local annotation, see NoteStore
+	Ack                 *Ack          `json:"ack,omitempty"`       // Alerts silenced, see AckStore
+	Flapping            bool          `json:"flapping,omitempty"`  // See FlapDetector
+	Outdated            bool          `json:"outdated,omitempty"`  // Product version below -target_version, see ApplyTargetVersion
+	Inventory           string        `json:"inventory,omitempty"` // MISSING or UNKNOWN against the expected devices, see Inventory
 }
 
 type LogicalDevice struct {
@@ -54,14 +65,106 @@ type AsNode struct {
 }
 
 type Config struct {
-	BaseURL        string        `json:"base_url"`
-	APIEndpoint    string        `json:"api_endpoint"`
-	PollInterval   time.Duration `json:"poll_interval"`
-	RequestTimeout time.Duration `json:"request_timeout"`
-	ShowTimestamp  bool          `json:"show_timestamp"`
-	ColorOutput    bool          `json:"color_output"`
-	Username       string        `json:"username"`
-	Password       string        `json:"password"`
+	BaseURL         string          `json:"base_url"`
+	APIEndpoint     string          `json:"api_endpoint"`
+	PollInterval    time.Duration   `json:"poll_interval"`
+	MaxPollInterval time.Duration   `json:"max_poll_interval"`
+	PollJitter      int             `json:"poll_jitter"`     // Percent of the poll interval
+	RenderInterval  time.Duration   `json:"render_interval"` // Redraw the TUI between polls this often, 0: on polls only
+	SnapshotDir     string          `json:"snapshot_dir"`
+	SnapshotFormat  string          `json:"snapshot_format"` // json or csv
+	OutputFormat    string          `json:"output_format"`   // tui or a one-shot report format
+	OutputFile      string          `json:"output_file"`
+	ConfigFile      string          `json:"-"` // Path of the loaded config file, shown by the help overlay
+	ColumnSpec      string          `json:"columns"`
+	Columns         []Column        `json:"-"` // Resolved from ColumnSpec
+	WebListen       string          `json:"web_listen"`
+	MQTT            MQTTConfig      `json:"mqtt"`      // Config file or PT_<KEY> variables
+	Influx          InfluxConfig    `json:"influx"`    // Config file or PT_<KEY> variables
+	EventBus        EventBusConfig  `json:"event_bus"` // Config file or PT_<KEY> variables
+	Alerts          AlertConfig     `json:"alerts"`    // Config file or PT_<KEY> variables
+	ExecHook        ExecHookConfig  `json:"exec_hook"` // Config file or PT_<KEY> variables
+	SNMPTrap        SNMPTrapConfig  `json:"snmp_trap"` // Config file or PT_<KEY> variables
+	Telemetry       TelemetryConfig `json:"telemetry"`
+	TLS             TLSConfig       `json:"tls"`
+	Daemon          bool            `json:"daemon"`
+	Demo            bool            `json:"demo"` // Poll a built-in simulated API instead of BaseURL, see DemoFleet
+	Record          string          `json:"-"`    // Device list responses for -replay, see Recorder
+	Replay          string          `json:"-"`    // Poll this recording instead of BaseURL, see Replay
+	ReplaySpeed     float64         `json:"-"`
+
+	// Settings of the mockserver command
+	MockListen          string          `json:"-"`
+	MockFixture         string          `json:"-"` // ListPhysicalDevices response to serve; empty is the demo fleet
+	MockLatency         time.Duration   `json:"-"`
+	MockErrorRate       int             `json:"-"` // Percent of requests
+	MockAuthFailureRate int             `json:"-"` // Percent of requests
+	LogFile             string          `json:"log_file"`
+	AuditLog            string          `json:"audit_log"`   // Authentication events as JSON lines
+	Events              string          `json:"events"`      // jsonl streams device and poll events, see EventStream
+	EventsFile          string          `json:"events_file"` // Empty is stdout
+	ASCIIBorders        bool            `json:"ascii"`
+	StatusIndicators    string          `json:"status_indicators"`  // color, symbols or labels, see statusIndicators
+	Timezone            string          `json:"timezone"`           // IANA name, UTC or Local (default)
+	TimeFormat          string          `json:"time_format"`        // Go layout, rfc3339 or iso; empty keeps each place's own
+	RelativeTime        bool            `json:"relative_time"`      // Start with "12m ago" in the TUI, see formatShownTime
+	DeviceURL           string          `json:"device_url"`         // Management UI page of a device, see objectURL
+	LogicalURL          string          `json:"logical_device_url"` // Management UI page of a logical device
+	SSHCommand          string          `json:"ssh_command"`        // Run for the selected device by the 's' key
+	SSHUser             string          `json:"ssh_user"`
+	Quiet               bool            `json:"quiet"`
+	Debug               bool            `json:"debug"`
+	Command             string          `json:"-"` // Subcommand from the command line
+	Assert              string          `json:"-" env:"PT_ASSERT"`
+	WaitTimeout         time.Duration   `json:"-" env:"PT_WAIT_TIMEOUT"`
+	RequestTimeout      time.Duration   `json:"request_timeout"`         // Whole request, including reading the response
+	DialTimeout         time.Duration   `json:"dial_timeout"`            // Connecting; 0 leaves only RequestTimeout
+	TLSHandshakeTimeout time.Duration   `json:"tls_handshake_timeout"`   // Likewise
+	HeaderTimeout       time.Duration   `json:"response_header_timeout"` // Likewise, from sending the request to the response headers
+	SessionRenew        time.Duration   `json:"session_renew_interval"`
+	ShowTimestamp       bool            `json:"show_timestamp"`
+	ColorOutput         bool            `json:"color_output"`
+	Username            string          `json:"username"`
+	Password            string          `json:"password"`
+	PasswordFile        string          `json:"password_file"` // Read the password from this file
+	VaultPath           string          `json:"vault_path"`    // Read username and password from this Vault KV secret
+	StreamEnabled       bool            `json:"stream_enabled"`
+	StreamEndpoint      string          `json:"stream_endpoint"`
+	Gzip                bool            `json:"gzip"`
+	Probe               string          `json:"probe"`  // icmp or tcp:<port>, checks device addresses from this host
+	Labels              []LabelRule     `json:"labels"` // Config file or PT_<KEY> variables
+	LabelFilter         string          `json:"label_filter"`
+	GroupBy             string          `json:"group_by"`         // Label to group logical devices by
+	CollapseHealthy     bool            `json:"collapse_healthy"` // Start with groups without problems on one line each
+	GroupOrder          string          `json:"group_order"`      // name or severity, see groupOrders
+	TargetVersion       string          `json:"target_version"`   // Devices below this product version are OUTDATED
+	NotesFile           string          `json:"notes_file"`
+	StateFile           string          `json:"state_file"`       // Last known devices, shown at startup; empty is off
+	ExpectedDevices     []InventoryItem `json:"expected_devices"` // Config file or PT_<KEY> variables
+	InventoryFile       string          `json:"inventory_file"`   // CSV of further expected devices
+	WebAckToken         string          `json:"web_ack_token"`    // Bearer token for acknowledging through the web API
+	WebUsername         string          `json:"web_username"`     // Basic auth for the web server, with WebPassword
+	WebPassword         string          `json:"web_password"`
+	WebPasswordFile     string          `json:"web_password_file"`
+	WebToken            string          `json:"web_token"` // Bearer token for reading the web server
+	WebTokenFile        string          `json:"web_token_file"`
+	WebTLSCert          string          `json:"web_tls_cert"` // Serve the web server over HTTPS with this certificate and WebTLSKey
+	WebTLSKey           string          `json:"web_tls_key"`
+	StaleAfter          time.Duration   `json:"stale_after"`    // /healthz and /readyz fail without polls this long; 0 is 3 poll intervals
+	FlapThreshold       int             `json:"flap_threshold"` // Connection state changes within FlapWindow; 0 is off
+	FlapWindow          time.Duration   `json:"flap_window"`
+	HeartbeatURL        string          `json:"heartbeat_url"` // Pinged after every poll, see Heartbeat
+	LeaderLock          string          `json:"leader_lock"`   // Only the monitor holding this lock notifies, see LeaderElection
+	LeaderLease         time.Duration   `json:"leader_lease"`
+	HistoryFile         string          `json:"history_file"` // Device state changes as JSON lines, see History
+	ReportFrom          string          `json:"-"`            // Period of report sla
+	ReportTo            string          `json:"-"`
+	PollLog             string          `json:"poll_log"`          // CSV row per device and poll, see PollLog
+	PollLogMaxSize      int             `json:"poll_log_max_size"` // MB; 0 rotates by day only
+
+	// Sent with every API request
+	UserAgent string            `json:"user_agent"`
+	Headers   map[string]string `json:"headers"`
 }
 
 type GroupedDevices struct {
@@ -89,6 +192,40 @@ func (g *LogicalDeviceGroup) GetTopologyDisplayName() string {
 	}
 }
 
+// GetHealthDisplay summarizes the connection state of the group members:
+// OK when all are connected, DOWN when none are, DEGRADED otherwise
+func (g *LogicalDeviceGroup) GetHealthDisplay() string {
+	connected := g.ConnectedCount()
+	switch {
+	case len(g.PhysicalDevices) == 0 || connected == 0:
+		return "DOWN"
+	case connected == len(g.PhysicalDevices):
+		return "OK"
+	default:
+		return "DEGRADED"
+	}
+}
+
+// ConnectedCount returns the number of connected physical devices in the group
+func (g *LogicalDeviceGroup) ConnectedCount() int {
+	connected := 0
+	for i := range g.PhysicalDevices {
+		if g.PhysicalDevices[i].ConnectionState == "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTED" {
+			connected++
+		}
+	}
+	return connected
+}
+
+// ConnectedCount returns the number of connected physical devices
+func (gd *GroupedDevices) ConnectedCount() int {
+	connected := 0
+	for i := range gd.LogicalDeviceGroups {
+		connected += gd.LogicalDeviceGroups[i].ConnectedCount()
+	}
+	return connected
+}
+
 func (g *LogicalDeviceGroup) GetVirtualContextsDisplay() string {
 	var contexts []string
 	for _, vc := range g.LogicalDevice.VirtualContexts {
@@ -116,6 +253,9 @@ func (pd *PhysicalDevice) GetRoleDisplay() string {
 }
 
 func (pd *PhysicalDevice) GetConnectionStateDisplay() string {
+	if pd.Inventory == InventoryMissing {
+		return InventoryMissing
+	}
 	switch pd.ConnectionState {
 	case "PHYSICAL_DEVICE_CONNECTION_STATE_CONNECTED":
 		return "CONNECTED"
@@ -152,7 +292,19 @@ func (pd *PhysicalDevice) GetLastConnectedDisplay() string {
 		return "Invalid"
 	}
 
-	return t.Format("2006-01-02 15:04")
+	return formatShownTime(t, "2006-01-02 15:04")
+}
+
+// GetReachableDisplay returns the result of the monitor's own probe of the
+// device address, e.g. "YES 1.2ms", or "-" before the first probe
+func (pd *PhysicalDevice) GetReachableDisplay() string {
+	if pd.Probe == nil {
+		return "-"
+	}
+	if !pd.Probe.Reachable {
+		return "NO"
+	}
+	return "YES " + roundTiming(pd.Probe.RTT).String()
 }
 
 func (pd *PhysicalDevice) GetProductVersionDisplay() string {
@@ -163,9 +315,90 @@ func (pd *PhysicalDevice) GetProductVersionDisplay() string {
 	return string(pd.ProductVersion)
 }
 
+// GetSoftwareVersionDisplay returns the software version the device runs, or
+// "-" when the API reports none
+func (pd *PhysicalDevice) GetSoftwareVersionDisplay() string {
+	if pd.SoftwareVersion == "" {
+		return "-"
+	}
+
+	return pd.SoftwareVersion
+}
+
+// VersionMismatch reports whether the software and product versions of the
+// device differ, as after an upgrade that did not complete
+func (pd *PhysicalDevice) VersionMismatch() bool {
+	return pd.SoftwareVersion != "" && pd.ProductVersion != "" && pd.SoftwareVersion != pd.ProductVersion
+}
+
+// GetSuspendModeDisplay returns the suspend mode of a cluster node, ON or
+// OFF, or "" for other devices
+func (pd *PhysicalDevice) GetSuspendModeDisplay() string {
+	if pd.AsNode == nil {
+		return ""
+	}
+	switch pd.AsNode.SuspendMode {
+	case "PHYSICAL_DEVICE_SUSPEND_MODE_ON":
+		return "ON"
+	case "PHYSICAL_DEVICE_SUSPEND_MODE_OFF":
+		return "OFF"
+	default:
+		return "UNSPECIFIED"
+	}
+}
+
+// Suspended reports whether the device is a suspended cluster node, which
+// does not take over when the ACTIVE node fails
+func (pd *PhysicalDevice) Suspended() bool {
+	return pd.GetSuspendModeDisplay() == "ON"
+}
+
+// GetSyncLinkDisplay returns the sync link address of a cluster node, e.g.
+// "192.168.1.1:4000", or "-"
+func (pd *PhysicalDevice) GetSyncLinkDisplay() string {
+	if pd.AsNode == nil || pd.AsNode.SyncLinkIP == "" {
+		return "-"
+	}
+	if pd.AsNode.SyncLinkPort == 0 {
+		return pd.AsNode.SyncLinkIP
+	}
+	return net.JoinHostPort(pd.AsNode.SyncLinkIP, strconv.Itoa(pd.AsNode.SyncLinkPort))
+}
+
 func (pd *PhysicalDevice) GetPriorityDisplay() string {
 	if pd.AsNode != nil {
 		return string(rune(pd.AsNode.Priority + '0'))
 	}
 	return ""
 }
+
+// mapDevices returns a copy of data with fn applied to each physical device,
+// leaving data itself untouched for concurrent readers
+func mapDevices(data *GroupedDevices, fn func(device *PhysicalDevice)) *GroupedDevices {
+	copied := *data
+	copied.LogicalDeviceGroups = make([]LogicalDeviceGroup, len(data.LogicalDeviceGroups))
+	for i, group := range data.LogicalDeviceGroups {
+		group.PhysicalDevices = append([]PhysicalDevice(nil), group.PhysicalDevices...)
+		for j := range group.PhysicalDevices {
+			fn(&group.PhysicalDevices[j])
+		}
+		copied.LogicalDeviceGroups[i] = group
+	}
+	return &copied
+}
+
+// findDevice returns the physical device of data with the given ID, or nil
+func findDevice(data *GroupedDevices, id string) *PhysicalDevice {
+	if data == nil {
+		return nil
+	}
+	for i := range data.LogicalDeviceGroups {
+		devices := data.LogicalDeviceGroups[i].PhysicalDevices
+		for j := range devices {
+			if devices[j].ID == id {
+				return &devices[j]
+			}
+		}
+	}
+	return nil
+}
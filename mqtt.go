@@ -0,0 +1,349 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// MQTTConfig configures state publishing to an MQTT broker
+type MQTTConfig struct {
+	Broker       string `json:"broker"` // tcp://host:1883 or ssl://host:8883
+	ClientID     string `json:"client_id"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"password_file"` // Read the password from this file
+	TopicPrefix  string `json:"topic_prefix"`
+}
+
+// mqttKeepAlive is the keep-alive interval announced to the broker
+const mqttKeepAlive = 60 * time.Second
+
+// mqttClient is a minimal MQTT 3.1.1 client supporting QoS 0 publishing
+type mqttClient struct {
+	config   MQTTConfig
+	conn     net.Conn
+	lastSent time.Time
+}
+
+func (mc *mqttClient) connect() error {
+	broker, err := url.Parse(mc.config.Broker)
+	if err != nil {
+		return fmt.Errorf("invalid MQTT broker URL: %w", err)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	switch broker.Scheme {
+	case "tcp", "mqtt", "":
+		conn, err = dialer.Dial("tcp", withDefaultPort(broker.Host, "1883"))
+	case "ssl", "tls", "mqtts":
+		conn, err = tls.DialWithDialer(dialer, "tcp", withDefaultPort(broker.Host, "8883"), &tls.Config{ServerName: broker.Hostname()})
+	default:
+		return fmt.Errorf("unsupported MQTT broker scheme: %s", broker.Scheme)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker: %w", err)
+	}
+
+	// Variable header: protocol name, level 4 (3.1.1), flags, keep-alive
+	flags := byte(0x02) // Clean session
+	payload := mqttString(mc.config.ClientID)
+	if mc.config.Username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(mc.config.Username)...)
+		if mc.config.Password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(mc.config.Password)...)
+		}
+	}
+
+	body := append(mqttString("MQTT"), 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(mqttKeepAlive/time.Second))
+	body = append(body, payload...)
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(mqttPacket(0x10, body)); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send MQTT CONNECT: %w", err)
+	}
+
+	connack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, connack); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to read MQTT CONNACK: %w", err)
+	}
+	if connack[0] != 0x20 || connack[3] != 0 {
+		conn.Close()
+		return fmt.Errorf("MQTT broker refused connection (code %d)", connack[3])
+	}
+
+	mc.conn = conn
+	mc.lastSent = time.Now()
+	go drainIncoming(conn)
+	return nil
+}
+
+// publish sends a QoS 0 message, connecting first if needed
+func (mc *mqttClient) publish(topic string, payload []byte, retain bool) error {
+	if mc.conn == nil {
+		if err := mc.connect(); err != nil {
+			return err
+		}
+	}
+
+	header := byte(0x30)
+	if retain {
+		header |= 0x01
+	}
+
+	body := append(mqttString(topic), payload...)
+	mc.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := mc.conn.Write(mqttPacket(header, body)); err != nil {
+		mc.close()
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+
+	mc.lastSent = time.Now()
+	return nil
+}
+
+// ping keeps the connection alive between polls
+func (mc *mqttClient) ping() {
+	if mc.conn == nil || time.Since(mc.lastSent) < mqttKeepAlive/2 {
+		return
+	}
+
+	mc.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := mc.conn.Write([]byte{0xC0, 0x00}); err != nil {
+		mc.close()
+		return
+	}
+	mc.lastSent = time.Now()
+}
+
+func (mc *mqttClient) close() {
+	if mc.conn != nil {
+		mc.conn.Write([]byte{0xE0, 0x00}) // DISCONNECT
+		mc.conn.Close()
+		mc.conn = nil
+	}
+}
+
+// drainIncoming discards broker packets (PINGRESP) so the socket buffer never fills
+func drainIncoming(conn net.Conn) {
+	io.Copy(io.Discard, conn)
+}
+
+func mqttString(s string) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttPacket prefixes body with the fixed header and variable-length size
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func withDefaultPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// MQTTPublisher publishes retained per-device state and transition events.
+// Publishing happens on a background goroutine so a slow broker never
+// delays rendering; if it falls behind, intermediate updates are dropped.
+type MQTTPublisher struct {
+	client  *mqttClient
+	prefix  string
+	updates chan mqttUpdate
+	done    chan struct{}
+	errLog  failureLog
+}
+
+type mqttUpdate struct {
+	data   *GroupedDevices
+	events []DeviceEvent
+}
+
+// mqttDeviceState is the retained payload published for each device
+type mqttDeviceState struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	LogicalDevice string    `json:"logical_device"`
+	Topology      string    `json:"topology"`
+	Connection    string    `json:"connection"`
+	Health        string    `json:"health"`
+	Role          string    `json:"role,omitempty"`
+	Address       string    `json:"address"`
+	Model         string    `json:"model"`
+	Version       string    `json:"version"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func init() {
+	registerExporter("mqtt", func(config *Config) (Exporter, error) {
+		if config.MQTT.Broker == "" {
+			return nil, nil
+		}
+		return NewMQTTPublisher(config.MQTT), nil
+	})
+}
+
+func NewMQTTPublisher(config MQTTConfig) *MQTTPublisher {
+	if config.ClientID == "" {
+		hostname, _ := os.Hostname()
+		config.ClientID = fmt.Sprintf("pt_device_monitor-%s-%d", hostname, os.Getpid())
+	}
+	if config.TopicPrefix == "" {
+		config.TopicPrefix = "pt_device_monitor"
+	}
+
+	mp := &MQTTPublisher{
+		client:  &mqttClient{config: config},
+		prefix:  strings.TrimSuffix(config.TopicPrefix, "/"),
+		updates: make(chan mqttUpdate, 1),
+		done:    make(chan struct{}),
+		errLog:  failureLog{name: "mqtt"},
+	}
+
+	go mp.run()
+
+	return mp
+}
+
+// Export queues a poll result for publishing; failed polls only carry no state
+func (mp *MQTTPublisher) Export(result PollResult) {
+	if result.Data == nil && len(result.Events) == 0 {
+		return
+	}
+
+	update := mqttUpdate{data: result.Data, events: result.Events}
+	select {
+	case mp.updates <- update:
+	default:
+		// Replace the pending update, keeping its events
+		select {
+		case pending := <-mp.updates:
+			update.events = append(pending.events, update.events...)
+		default:
+		}
+		select {
+		case mp.updates <- update:
+		default:
+		}
+	}
+}
+
+// Close disconnects from the broker
+func (mp *MQTTPublisher) Close() {
+	close(mp.updates)
+	<-mp.done
+}
+
+func (mp *MQTTPublisher) run() {
+	defer close(mp.done)
+	defer mp.client.close()
+
+	keepAlive := time.NewTicker(mqttKeepAlive / 2)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case update, ok := <-mp.updates:
+			if !ok {
+				return
+			}
+			mp.errLog.set(mp.publishUpdate(update))
+
+		case <-keepAlive.C:
+			mp.client.ping()
+		}
+	}
+}
+
+func (mp *MQTTPublisher) publishUpdate(update mqttUpdate) error {
+	for _, event := range update.events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if err := mp.client.publish(mp.prefix+"/events", payload, false); err != nil {
+			return err
+		}
+
+		// An empty retained message deletes the removed device's state, so new
+		// subscribers do not see it forever
+		if event.Type == EventDeviceRemoved {
+			if err := mp.client.publish(mp.deviceTopic(event.DeviceID), nil, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	if update.data == nil {
+		return nil
+	}
+
+	for _, group := range update.data.LogicalDeviceGroups {
+		for i := range group.PhysicalDevices {
+			device := &group.PhysicalDevices[i]
+			payload, err := json.Marshal(mqttDeviceState{
+				ID:            device.ID,
+				Name:          device.Name,
+				LogicalDevice: group.LogicalDevice.Name,
+				Topology:      group.GetTopologyDisplayName(),
+				Connection:    device.GetConnectionStateDisplay(),
+				Health:        device.GetHealthStatusDisplay(),
+				Role:          device.GetRoleDisplay(),
+				Address:       device.Address,
+				Model:         device.Model,
+				Version:       device.GetProductVersionDisplay(),
+				UpdatedAt:     update.data.LastUpdated,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := mp.client.publish(mp.deviceTopic(device.ID), payload, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deviceTopic is where the retained state of the device with id is published
+func (mp *MQTTPublisher) deviceTopic(id string) string {
+	return fmt.Sprintf("%s/devices/%s", mp.prefix, mqttTopicSegment(id))
+}
+
+// mqttTopicSegment replaces characters with special meaning in MQTT topics
+func mqttTopicSegment(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_").Replace(s)
+}
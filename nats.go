@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsProducer publishes events using the NATS text protocol
+type natsProducer struct {
+	config EventBusConfig
+	mu     sync.Mutex
+	conn   net.Conn
+}
+
+func newNATSProducer(config EventBusConfig) *natsProducer {
+	return &natsProducer{config: config}
+}
+
+func (np *natsProducer) connect() error {
+	server, err := url.Parse(np.config.URL)
+	if err != nil {
+		return fmt.Errorf("invalid NATS URL: %w", err)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.Dial("tcp", withDefaultPort(server.Host, "4222"))
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	// The server greets with INFO {...}
+	info, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting: %q", strings.TrimSpace(info))
+	}
+
+	var serverInfo struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(info, "INFO ")), &serverInfo)
+	if serverInfo.TLSRequired || server.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: server.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return fmt.Errorf("NATS TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	options := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "pt_device_monitor",
+		"lang":     "go",
+	}
+	username, password := np.config.Username, np.config.Password
+	if server.User != nil {
+		username = server.User.Username()
+		password, _ = server.User.Password()
+	}
+	if username != "" {
+		options["user"] = username
+		options["pass"] = password
+	}
+	connectJSON, _ := json.Marshal(options)
+
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connectJSON); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send NATS CONNECT: %w", err)
+	}
+
+	// PONG confirms the connection was accepted; -ERR means it was not
+	reply, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to read NATS reply: %w", err)
+	}
+	if !strings.HasPrefix(reply, "PONG") {
+		conn.Close()
+		return fmt.Errorf("NATS connection refused: %s", strings.TrimSpace(reply))
+	}
+
+	conn.SetDeadline(time.Time{})
+	np.conn = conn
+	go np.readLoop(conn, reader)
+
+	return nil
+}
+
+// readLoop answers server pings so the connection is not considered stale
+func (np *natsProducer) readLoop(conn net.Conn, reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if strings.HasPrefix(line, "PING") {
+			np.mu.Lock()
+			conn.Write([]byte("PONG\r\n"))
+			np.mu.Unlock()
+		}
+	}
+}
+
+// Send publishes events in one write, so on failure none count as delivered
+func (np *natsProducer) Send(events []DeviceEvent) ([]DeviceEvent, error) {
+	np.mu.Lock()
+	defer np.mu.Unlock()
+
+	if np.conn == nil {
+		if err := np.connect(); err != nil {
+			return events, err
+		}
+	}
+
+	var b strings.Builder
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return events, err
+		}
+		fmt.Fprintf(&b, "PUB %s %d\r\n%s\r\n", np.config.Topic, len(payload), payload)
+	}
+
+	np.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := np.conn.Write([]byte(b.String())); err != nil {
+		np.conn.Close()
+		np.conn = nil
+		return events, fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+
+	return nil, nil
+}
+
+func (np *natsProducer) Close() {
+	np.mu.Lock()
+	defer np.mu.Unlock()
+
+	if np.conn != nil {
+		np.conn.Close()
+		np.conn = nil
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Note is a free-text annotation an operator attached to a device, e.g.
+// "Replacement PSU ordered, ticket #1234"
+type Note struct {
+	Text    string    `json:"text"`
+	Author  string    `json:"author"`
+	Updated time.Time `json:"updated"`
+}
+
+// NoteStore keeps device notes in a local JSON file, keyed by device ID, so
+// they survive restarts and are shared by everyone using the same file
+type NoteStore struct {
+	path  string
+	mu    sync.Mutex
+	notes map[string]Note
+}
+
+// defaultNotesFile is notes.json in the user's config directory
+func defaultNotesFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "pt_device_monitor_notes.json"
+	}
+	return filepath.Join(dir, "pt_device_monitor", "notes.json")
+}
+
+// LoadNotes reads the notes file; a missing file means no notes yet
+func LoadNotes(path string) (*NoteStore, error) {
+	store := &NoteStore{path: path, notes: make(map[string]Note)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &store.notes); err != nil {
+		return nil, fmt.Errorf("failed to parse notes file %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Get returns the note of a device, or nil. A nil *NoteStore has no notes.
+func (ns *NoteStore) Get(deviceID string) *Note {
+	if ns == nil {
+		return nil
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if note, ok := ns.notes[deviceID]; ok {
+		return &note
+	}
+	return nil
+}
+
+// Set replaces the note of a device and saves the file. Empty text removes
+// the note.
+func (ns *NoteStore) Set(deviceID, text string) error {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		delete(ns.notes, deviceID)
+	} else {
+		ns.notes[deviceID] = Note{Text: text, Author: localUsername(), Updated: time.Now()}
+	}
+
+	return ns.save()
+}
+
+// save writes the notes to a temporary file first, so a crash never leaves
+// a truncated notes file behind
+func (ns *NoteStore) save() error {
+	if err := os.MkdirAll(filepath.Dir(ns.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create notes directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(ns.notes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode notes: %w", err)
+	}
+
+	tmp := ns.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write notes: %w", err)
+	}
+	if err := os.Rename(tmp, ns.path); err != nil {
+		return fmt.Errorf("failed to write notes: %w", err)
+	}
+	return nil
+}
+
+// Apply attaches the stored notes to the devices of response
+func (ns *NoteStore) Apply(response *APIResponse) {
+	for i := range response.PhysicalDevices {
+		response.PhysicalDevices[i].Note = ns.Get(response.PhysicalDevices[i].ID)
+	}
+}
+
+// Annotate returns a copy of data with the current notes, leaving data
+// itself untouched for concurrent readers
+func (ns *NoteStore) Annotate(data *GroupedDevices) *GroupedDevices {
+	return mapDevices(data, func(device *PhysicalDevice) {
+		device.Note = ns.Get(device.ID)
+	})
+}
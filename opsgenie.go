@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OpsgenieConfig configures alerts to the Opsgenie Alert API
+type OpsgenieConfig struct {
+	APIKey     string `json:"api_key"`      // API key of an API integration
+	APIKeyFile string `json:"api_key_file"` // Read the API key from this file
+	URL        string `json:"url"`          // Default: https://api.opsgenie.com, https://api.eu.opsgenie.com for EU accounts
+	Team       string `json:"team"`         // Responder team name
+
+	Templates MessageTemplates `json:"templates"` // The title is the alert message, the body its description
+}
+
+const defaultOpsgenieURL = "https://api.opsgenie.com"
+
+// opsgenieMessageLimit is the longest alert message Opsgenie accepts
+const opsgenieMessageLimit = 130
+
+// OpsgenieSender creates an Opsgenie alert when an alert fires and closes it
+// when the alert clears; the alert key is the Opsgenie alias
+type OpsgenieSender struct {
+	config OpsgenieConfig
+	format *messageFormat
+	client *http.Client
+}
+
+func init() {
+	registerAlertSender("opsgenie", func(config *Config) (AlertSender, error) {
+		og := config.Alerts.Opsgenie
+		if og.APIKey == "" {
+			return nil, nil
+		}
+		if og.URL == "" {
+			og.URL = defaultOpsgenieURL
+		}
+		og.URL = strings.TrimSuffix(og.URL, "/")
+		format, err := loadMessageFormat("opsgenie", og.Templates, config.Alerts.Templates)
+		if err != nil {
+			return nil, err
+		}
+		return &OpsgenieSender{config: og, format: format, client: &http.Client{Timeout: webhookTimeout}}, nil
+	})
+}
+
+type opsgenieResponder struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type opsgenieAlert struct {
+	Message     string              `json:"message"`
+	Alias       string              `json:"alias"`
+	Description string              `json:"description"`
+	Responders  []opsgenieResponder `json:"responders,omitempty"`
+	Tags        []string            `json:"tags"`
+	Details     map[string]string   `json:"details"`
+	Entity      string              `json:"entity"`
+	Source      string              `json:"source"`
+	Priority    string              `json:"priority"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+func (og *OpsgenieSender) Send(alert Alert) error {
+	header := http.Header{"Authorization": {"GenieKey " + og.config.APIKey}}
+
+	if alert.Status == AlertResolved {
+		endpoint := og.config.URL + "/v2/alerts/" + url.PathEscape(alert.Key) + "/close?identifierType=alias"
+		return postJSON(og.client, endpoint, header, opsgenieClose{Source: "pt_device_monitor", Note: og.format.Title(alert, alert.Summary)})
+	}
+
+	message := og.format.Title(alert, alert.Summary)
+	if runes := []rune(message); len(runes) > opsgenieMessageLimit {
+		message = string(runes[:opsgenieMessageLimit])
+	}
+
+	// P2 for outages, P3 for warnings such as flapping
+	priority := "P3"
+	if alert.Severity == "critical" {
+		priority = "P2"
+	}
+
+	body := opsgenieAlert{
+		Message:     message,
+		Alias:       alert.Key,
+		Description: og.format.Body(alert, alert.Summary+"\nSince "+alert.Since.Format(time.RFC3339)),
+		Tags:        []string{"pt_device_monitor", alert.Rule},
+		Details: map[string]string{
+			"device_id":      alert.DeviceID,
+			"logical_device": alert.LogicalDevice,
+			"address":        alert.Address,
+		},
+		Entity:   alert.DeviceName,
+		Source:   "pt_device_monitor",
+		Priority: priority,
+	}
+	if og.config.Team != "" {
+		body.Responders = []opsgenieResponder{{Name: og.config.Team, Type: "team"}}
+	}
+
+	return postJSON(og.client, og.config.URL+"/v2/alerts", header, body)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// PagerDutyConfig configures alerts to the PagerDuty Events API v2
+type PagerDutyConfig struct {
+	RoutingKey     string `json:"routing_key"`      // Integration key of the service
+	RoutingKeyFile string `json:"routing_key_file"` // Read the routing key from this file
+	URL            string `json:"url"`              // Default: https://events.pagerduty.com/v2/enqueue
+
+	Templates MessageTemplates `json:"templates"` // The title is the incident summary, the body a custom detail
+}
+
+const defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutySender triggers a PagerDuty incident when an alert fires and
+// resolves it when the alert clears; the alert key is the dedup key
+type PagerDutySender struct {
+	config PagerDutyConfig
+	format *messageFormat
+	client *http.Client
+}
+
+func init() {
+	registerAlertSender("pagerduty", func(config *Config) (AlertSender, error) {
+		pd := config.Alerts.PagerDuty
+		if pd.RoutingKey == "" {
+			return nil, nil
+		}
+		if pd.URL == "" {
+			pd.URL = defaultPagerDutyURL
+		}
+		format, err := loadMessageFormat("pagerduty", pd.Templates, config.Alerts.Templates)
+		if err != nil {
+			return nil, err
+		}
+		return &PagerDutySender{config: pd, format: format, client: &http.Client{Timeout: webhookTimeout}}, nil
+	})
+}
+
+type pagerDutyPayload struct {
+	Summary   string            `json:"summary"`
+	Source    string            `json:"source"`
+	Severity  string            `json:"severity"` // critical, error, warning or info
+	Timestamp string            `json:"timestamp"`
+	Component string            `json:"component"`
+	Group     string            `json:"group"`
+	Class     string            `json:"class"`
+	Details   map[string]string `json:"custom_details"`
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // trigger or resolve
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+func (ps *PagerDutySender) Send(alert Alert) error {
+	event := pagerDutyEvent{
+		RoutingKey:  ps.config.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    alert.Key,
+	}
+
+	if alert.Status == AlertFiring {
+		source := alert.Address
+		if source == "" {
+			source = alert.DeviceName
+		}
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:   ps.format.Title(alert, alert.Summary),
+			Source:    source,
+			Severity:  alert.Severity,
+			Timestamp: alert.Time.Format(time.RFC3339),
+			Component: alert.DeviceName,
+			Group:     alert.LogicalDevice,
+			Class:     alert.Rule,
+			Details: map[string]string{
+				"device_id": alert.DeviceID,
+				"since":     alert.Since.Format(time.RFC3339),
+			},
+		}
+		if ps.format.HasBody() {
+			event.Payload.Details["body"] = ps.format.Body(alert, "")
+		}
+	}
+
+	return postJSON(ps.client, ps.config.URL, nil, event)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// RenderPlain appends timestamped status lines for one poll result instead of
+// drawing the screen. It is used when stdout is not a terminal (nohup, docker
+// logs), so the output contains no escape codes and is never redrawn.
+//
+// The full device list is printed on the first successful poll and after a
+// recovery; later polls print a summary line plus one line per device event.
+// With -quiet only changes are printed: device events, and the start and end
+// of a poll error.
+func (dm *DisplayManager) RenderPlain(data *GroupedDevices, events []DeviceEvent, err error) {
+	timestamp := formatTime(time.Now(), "2006-01-02 15:04:05")
+	quiet := dm.config.Quiet
+
+	if err != nil {
+		failing := dm.errorMessage != ""
+		dm.errorMessage = err.Error()
+		dm.lastError = err
+		if !quiet || !failing {
+			fmt.Printf("%s ERROR: %s\n", timestamp, dm.describeError(err))
+		}
+		return
+	}
+
+	if quiet && dm.errorMessage != "" {
+		fmt.Printf("%s RECOVERED\n", timestamp)
+	}
+
+	if !quiet && (dm.lastData == nil || dm.errorMessage != "") {
+		for _, group := range sortedGroups(data) {
+			for _, device := range group.PhysicalDevices {
+				fmt.Printf("%s %s/%s %s %s\n", timestamp, group.LogicalDevice.Name, device.Name,
+					device.GetConnectionStateDisplay(), device.Address)
+			}
+		}
+	}
+	dm.errorMessage = ""
+	dm.lastError = nil
+	dm.lastData = data
+
+	for _, event := range events {
+		fmt.Printf("%s %s\n", timestamp, formatEvent(event))
+	}
+
+	if quiet {
+		return
+	}
+
+	summary := NewReportSummary(data)
+	fmt.Printf("%s %d devices: %d connected, %d connecting, %d disconnected\n",
+		timestamp, summary.Total, summary.Connected, summary.Connecting, summary.Disconnected)
+}
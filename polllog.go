@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultPollLogMaxSize is the size in MB at which the poll log is rotated
+const defaultPollLogMaxSize = 10
+
+// pollLogColumns is the header row of the poll log
+var pollLogColumns = []string{"time", "logical_device", "device", "id", "state", "health", "role"}
+
+// PollLog appends a CSV row per device for every successful poll, for
+// spreadsheets and scripts that need no more than that. The file is rotated
+// when a new day begins and when it reaches its maximum size; rotated files
+// keep the day they cover in their name, e.g. polls-2026-10-15.csv.
+type PollLog struct {
+	path    string
+	maxSize int64 // Bytes; 0 rotates by day only
+	mu      sync.Mutex
+	last    [][]string // Rows of the last poll, without the time
+	rows    chan [][]string
+	done    chan struct{}
+	errLog  failureLog
+}
+
+func init() {
+	registerExporter("poll_log", func(config *Config) (Exporter, error) {
+		if config.PollLog == "" {
+			return nil, nil
+		}
+		return NewPollLog(config.PollLog, int64(config.PollLogMaxSize)*1024*1024), nil
+	})
+}
+
+func NewPollLog(path string, maxSize int64) *PollLog {
+	p := &PollLog{
+		path:    path,
+		maxSize: maxSize,
+		rows:    make(chan [][]string, 64),
+		done:    make(chan struct{}),
+		errLog:  failureLog{name: "poll log"},
+	}
+
+	go p.run()
+
+	return p
+}
+
+// pollLogRows lists the devices of data as poll log rows without the time
+func pollLogRows(data *GroupedDevices) [][]string {
+	var rows [][]string
+	for _, group := range sortedGroups(data) {
+		for _, device := range group.PhysicalDevices {
+			if device.Inventory == InventoryMissing {
+				continue
+			}
+			rows = append(rows, []string{
+				group.LogicalDevice.Name,
+				device.Name,
+				device.ID,
+				device.GetConnectionStateDisplay(),
+				device.GetHealthStatusDisplay(),
+				device.GetRoleDisplay(),
+			})
+		}
+	}
+	return rows
+}
+
+// Export writes the devices of a successful poll; failed polls leave a gap
+func (p *PollLog) Export(result PollResult) {
+	if result.Data == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.last = pollLogRows(result.Data)
+	p.queue(result.Time, p.last)
+}
+
+// Unchanged writes the devices of the last poll again, as they still are
+func (p *PollLog) Unchanged() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.last != nil {
+		p.queue(time.Now(), p.last)
+	}
+}
+
+// queue hands the rows of a poll to the writer; call with p.mu held. Rows
+// are dropped rather than holding up polling when the disk is stuck.
+func (p *PollLog) queue(at time.Time, devices [][]string) {
+	timestamp := inDisplayZone(at).Format(time.RFC3339)
+	rows := make([][]string, 0, len(devices))
+	for _, device := range devices {
+		rows = append(rows, append([]string{timestamp}, device...))
+	}
+
+	select {
+	case p.rows <- rows:
+	default:
+		p.errLog.set(fmt.Errorf("write queue full, poll of %s dropped", timestamp))
+	}
+}
+
+// Close writes the queued rows and stops
+func (p *PollLog) Close() {
+	close(p.rows)
+	<-p.done
+}
+
+func (p *PollLog) run() {
+	defer close(p.done)
+	for rows := range p.rows {
+		p.errLog.set(p.write(rows))
+	}
+}
+
+// write appends rows, rotating the file first when it is due
+func (p *PollLog) write(rows [][]string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create poll log directory: %w", err)
+	}
+	if err := p.rotate(time.Now()); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open poll log: %w", err)
+	}
+
+	writer := csv.NewWriter(file)
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		writer.Write(pollLogColumns)
+	}
+	writer.WriteAll(rows)
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write poll log: %w", err)
+	}
+	return file.Close()
+}
+
+// rotate renames the poll log when it was last written on another day than
+// now or has reached its maximum size
+func (p *PollLog) rotate(now time.Time) error {
+	info, err := os.Stat(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check poll log: %w", err)
+	}
+
+	day := info.ModTime().Format("2006-01-02")
+	if day == now.Format("2006-01-02") && (p.maxSize <= 0 || info.Size() < p.maxSize) {
+		return nil
+	}
+
+	if err := os.Rename(p.path, rotatedName(p.path, day)); err != nil {
+		return fmt.Errorf("failed to rotate poll log: %w", err)
+	}
+	return nil
+}
+
+// rotatedName returns the first free name for a rotated file of day, e.g.
+// polls-2026-10-15.csv, then polls-2026-10-15.1.csv for the next file
+// rotated by size on the same day
+func rotatedName(path, day string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext) + "-" + day
+
+	name := base + ext
+	for n := 1; ; n++ {
+		if _, err := os.Stat(name); errors.Is(err, fs.ErrNotExist) {
+			return name
+		}
+		name = base + "." + strconv.Itoa(n) + ext
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pollStatsWindow is how far back the success rate looks
+const pollStatsWindow = time.Hour
+
+// connectedTrendLen is how many polls the connected-count sparkline in the
+// header covers
+const connectedTrendLen = 30
+
+// PollStats summarises recent polls for the footer
+type PollStats struct {
+	LastLatency time.Duration // Round-trip time of the last successful poll, including retries
+	LastFailed  bool
+	LastSuccess time.Time     // When a poll last succeeded, with or without changes
+	SuccessRate float64       // Percentage of successful polls within Window
+	Window      time.Duration // Time covered by SuccessRate, shorter than pollStatsWindow right after start
+	Polls       int
+	Connected   []int // Connected devices at the last polls, oldest first; -1 for a failed poll
+}
+
+type pollOutcome struct {
+	at time.Time
+	ok bool
+}
+
+// pollHistory keeps the outcome of the polls within pollStatsWindow. It is
+// owned by the Scheduler's Start loop.
+type pollHistory struct {
+	started  time.Time
+	outcomes []pollOutcome
+	last     PollStats
+
+	connected     []int
+	lastConnected int // Connected devices at the last poll with data, -1 before it
+}
+
+func newPollHistory() *pollHistory {
+	return &pollHistory{started: time.Now(), lastConnected: -1}
+}
+
+// Record adds the outcome of a poll; latency is ignored for failed polls. A
+// successful poll repeats the last connected count until SetConnected
+// replaces it, as one without changes keeps it.
+func (h *pollHistory) Record(ok bool, latency time.Duration) {
+	switch {
+	case !ok:
+		h.addConnected(-1)
+	case h.lastConnected >= 0:
+		h.addConnected(h.lastConnected)
+	}
+
+	now := time.Now()
+	h.outcomes = append(h.outcomes, pollOutcome{at: now, ok: ok})
+
+	cutoff := now.Add(-pollStatsWindow)
+	drop := 0
+	for drop < len(h.outcomes) && h.outcomes[drop].at.Before(cutoff) {
+		drop++
+	}
+	h.outcomes = h.outcomes[drop:]
+
+	h.last.LastFailed = !ok
+	if ok {
+		h.last.LastLatency = latency
+		h.last.LastSuccess = now
+	}
+}
+
+// Stats returns the latency of the last poll and the success rate over the
+// polls still in the window
+func (h *pollHistory) Stats() PollStats {
+	stats := h.last
+	stats.Polls = len(h.outcomes)
+	stats.Connected = slices.Clone(h.connected)
+	stats.Window = min(time.Since(h.started), pollStatsWindow)
+
+	if stats.Polls > 0 {
+		succeeded := 0
+		for _, outcome := range h.outcomes {
+			if outcome.ok {
+				succeeded++
+			}
+		}
+		stats.SuccessRate = float64(succeeded) * 100 / float64(stats.Polls)
+	}
+
+	return stats
+}
+
+// SetConnected sets the connected devices of the latest poll, which
+// returned changed data
+func (h *pollHistory) SetConnected(count int) {
+	if h.lastConnected >= 0 && len(h.connected) > 0 {
+		h.connected[len(h.connected)-1] = count
+	} else {
+		h.addConnected(count)
+	}
+	h.lastConnected = count
+}
+
+func (h *pollHistory) addConnected(count int) {
+	h.connected = append(h.connected, count)
+	if len(h.connected) > connectedTrendLen {
+		h.connected = h.connected[len(h.connected)-connectedTrendLen:]
+	}
+}
+
+// SeedConnected starts the connected counts with the ones of the history
+// file in the polls before the monitor started, so a restart keeps the
+// sparkline; records too old to speak for a poll count as failed polls
+func (h *pollHistory) SeedConnected(records []HistoryRecord, interval time.Duration) {
+	if len(records) == 0 || interval <= 0 {
+		return
+	}
+
+	var counts []int
+	for i := connectedTrendLen; i > 0; i-- {
+		at := h.started.Add(-time.Duration(i) * interval)
+		j, _ := slices.BinarySearchFunc(records, at, func(r HistoryRecord, t time.Time) int { return r.Time.Compare(t) })
+		// The record at or before at
+		if j == len(records) || records[j].Time.After(at) {
+			j--
+		}
+		switch {
+		case j < 0:
+			// Before the history file
+		case records[j].Error != "" || at.Sub(records[j].Time) > historyMaxGap:
+			counts = append(counts, -1)
+		default:
+			connected := 0
+			for _, device := range records[j].Devices {
+				if device.State == "CONNECTED" {
+					connected++
+				}
+			}
+			counts = append(counts, connected)
+		}
+	}
+	// Nothing is known of a monitor stopped long ago
+	if !slices.ContainsFunc(counts, func(count int) bool { return count >= 0 }) {
+		return
+	}
+	h.connected = counts
+}
+
+// countSparkline draws counts relative to their lowest and highest value, so
+// a dip of a few devices in a large fleet shows; failed polls are marked
+func countSparkline(counts []int) string {
+	lowest, highest := -1, 0
+	for _, count := range counts {
+		if count >= 0 && (lowest < 0 || count < lowest) {
+			lowest = count
+		}
+		highest = max(highest, count)
+	}
+
+	var b strings.Builder
+	for _, count := range counts {
+		switch {
+		case count < 0:
+			b.WriteRune(sparkFailed)
+		case highest == lowest:
+			b.WriteRune(sparkBlocks[len(sparkBlocks)-1])
+		default:
+			b.WriteRune(sparkBlocks[(count-lowest)*(len(sparkBlocks)-1)/(highest-lowest)])
+		}
+	}
+	return b.String()
+}
+
+// connectedTrend draws the connected counts of the header with their range,
+// e.g. "██▅█ 8-10", or "" before there are two polls
+func connectedTrend(counts []int) string {
+	lowest, highest := -1, -1
+	for _, count := range counts {
+		if count >= 0 && (lowest < 0 || count < lowest) {
+			lowest = count
+		}
+		highest = max(highest, count)
+	}
+	if len(counts) < 2 || highest < 0 {
+		return ""
+	}
+	if lowest == highest {
+		return fmt.Sprintf("%s %d", countSparkline(counts), highest)
+	}
+	return fmt.Sprintf("%s %d-%d", countSparkline(counts), lowest, highest)
+}
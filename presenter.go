@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Display shows poll results and the poller's status. DisplayManager is the
+// one for the terminal, in both the TUI and plain output.
+type Display interface {
+	Render(data *GroupedDevices, err error)
+	RenderPlain(data *GroupedDevices, events []DeviceEvent, err error)
+	Redraw()
+	UpdateTerminalSize()
+	SetEvents(events []DeviceEvent)
+	SetNextPoll(at time.Time)
+	SetEffectiveInterval(interval time.Duration)
+	SetTiming(timing *RequestTiming)
+	SetPollStats(stats PollStats)
+	SetStreaming(streaming bool)
+}
+
+// Presenter shows what is published to the StateStore in the current output
+// mode: the daemon log, plain status lines or the TUI. Like every
+// StateSubscriber it runs on the poller's goroutine, which also handles the
+// TUI's input, so the display needs no locking.
+type Presenter struct {
+	config     *Config
+	display    Display
+	store      *StateStore
+	plain      bool   // Stdout is not a terminal; print status lines instead of drawing
+	lastLogged string // Last error written to the daemon log
+}
+
+func NewPresenter(config *Config, display Display, store *StateStore) *Presenter {
+	return &Presenter{config: config, display: display, store: store}
+}
+
+// tui reports whether the results are drawn on the screen
+func (p *Presenter) tui() bool {
+	return !p.config.Daemon && !p.plain
+}
+
+// Dispatch shows a poll result and keeps the event log current
+func (p *Presenter) Dispatch(result PollResult) {
+	if len(result.Events) > 0 {
+		p.display.SetEvents(p.store.Events(time.Time{}, eventLogSize))
+	}
+
+	switch {
+	case p.config.Daemon:
+		p.logPollResult(result.Data, result.Events, result.Err)
+	case p.plain:
+		p.display.RenderPlain(result.Data, result.Events, result.Err)
+	default:
+		if result.Err == nil {
+			p.display.UpdateTerminalSize()
+		}
+		p.render(result.Data, result.Err)
+	}
+}
+
+// Unchanged keeps the footer's poll health current
+func (p *Presenter) Unchanged() {
+	if p.tui() {
+		p.display.Redraw()
+	}
+}
+
+// Watch shows re-annotated data without waiting for the next poll
+func (p *Presenter) Watch(data *GroupedDevices) {
+	if p.tui() {
+		p.display.Render(data, nil)
+	}
+}
+
+// render draws a poll result inside a telemetry span
+func (p *Presenter) render(data *GroupedDevices, err error) {
+	_, span := otel.StartSpan(context.Background(), "render")
+	p.display.Render(data, err)
+	span.End(nil)
+}
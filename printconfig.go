@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// configEntry is one setting of the flattened configuration, keyed like the
+// config file ("mqtt.password")
+type configEntry struct {
+	key   string
+	value string // Unmasked, used to tell which source changed the setting
+	shown string // Masked for printing
+}
+
+// configSnapshot records the configuration after one loading stage, so
+// PrintConfig can tell which stage set each value
+type configSnapshot struct {
+	source  string
+	entries []configEntry
+}
+
+// maskedValue replaces secrets in -print_config output
+const maskedValue = "******"
+
+// snapshot records the current configuration as set by source
+func (cm *ConfigManager) snapshot(source string) {
+	var entries []configEntry
+	flattenConfig("", reflect.ValueOf(cm.config).Elem(), &entries)
+	cm.snapshots = append(cm.snapshots, configSnapshot{source: source, entries: entries})
+}
+
+// PrintConfig writes every setting with its effective value and the source
+// that set it. Passwords, tokens and credential headers are masked.
+func (cm *ConfigManager) PrintConfig(w io.Writer) {
+	var entries []configEntry
+	flattenConfig("", reflect.ValueOf(cm.config).Elem(), &entries)
+
+	fmt.Fprintln(w, "# Effective configuration (default < env < file < flag) and the environment variable of each setting")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, entry := range entries {
+		// The earliest stage from which the value stayed unchanged set it
+		last := len(cm.snapshots) - 1
+		value := cm.snapshots[last].entries[i].value
+		s := last
+		for s > 0 && cm.snapshots[s-1].entries[i].value == value {
+			s--
+		}
+		source := cm.snapshots[s].source
+		if value != entry.value {
+			// Changed by validateConfig, e.g. a missing trailing slash
+			source += ", normalized"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\t%s\n", entry.key, entry.shown, source, envName(entry.key))
+	}
+	tw.Flush()
+}
+
+// flattenConfig appends the settings of v, a config struct, to entries;
+// nested structs become dotted keys
+func flattenConfig(prefix string, v reflect.Value, entries *[]configEntry) {
+	eachSetting(prefix, v, func(key string, value reflect.Value) {
+		name := key[strings.LastIndex(key, ".")+1:]
+		raw, shown := formatConfigValue(name, value)
+		*entries = append(*entries, configEntry{key: key, value: raw, shown: shown})
+	})
+}
+
+// formatConfigValue returns a setting as text, and as shown with secrets
+// masked
+func formatConfigValue(name string, value reflect.Value) (string, string) {
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v.String(), v.String()
+	case []string:
+		joined := strings.Join(v, ",")
+		return joined, joined
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var raw, shown []string
+		for _, k := range keys {
+			raw = append(raw, k+"="+v[k])
+			if isSecretName(k) {
+				shown = append(shown, k+"="+maskedValue)
+			} else {
+				shown = append(shown, k+"="+v[k])
+			}
+		}
+		return strings.Join(raw, ","), strings.Join(shown, ",")
+	case string:
+		if v != "" && isSecretName(name) {
+			return v, maskedValue
+		}
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			return v, u.Redacted()
+		}
+		return v, v
+	default:
+		text := fmt.Sprint(v)
+		if value.Kind() == reflect.Slice || value.Kind() == reflect.Map {
+			// e.g. the label rules
+			data, _ := json.Marshal(v)
+			text = string(data)
+		}
+		return text, text
+	}
+}
+
+// isSecretName reports whether a setting or header name holds a credential;
+// chat webhook and heartbeat URLs carry their token in the path. The *_file
+// settings hold only the path to one.
+func isSecretName(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, "_file") {
+		return false
+	}
+	for _, word := range []string{"password", "token", "secret", "authorization", "cookie", "key", "webhook", "heartbeat"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds each reachability check of a device address
+const probeTimeout = 2 * time.Second
+
+// maxConcurrentProbes limits how many addresses are checked at once
+const maxConcurrentProbes = 16
+
+// probeHistoryLen is how many recent round-trip times each device keeps,
+// one sparkline character each
+const probeHistoryLen = 12
+
+// sparkBlocks draw a round-trip time relative to the slowest one shown
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkFailed marks a probe without reply in a sparkline
+const sparkFailed = '×'
+
+// ProbeResult is the outcome of the monitor's own reachability check of a
+// device address, independent of what the management API reports
+type ProbeResult struct {
+	Reachable bool          `json:"reachable"`
+	RTT       time.Duration `json:"rtt_ns,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Time      time.Time     `json:"time"`
+
+	// Recent round-trip times, oldest first; 0 for a probe without reply
+	History []time.Duration `json:"history_ns,omitempty"`
+}
+
+// Prober checks device addresses with a TCP connect or the system ping
+// command. Results are kept by address for Annotate.
+type Prober struct {
+	method  string // tcp or icmp
+	port    string
+	mu      sync.Mutex
+	results map[string]*ProbeResult
+}
+
+// pingTime matches the round-trip time in ping output, e.g. "time=1.23 ms"
+// or "time<1ms"
+var pingTime = regexp.MustCompile(`time[=<]\s*([0-9.]+)\s*ms`)
+
+// parseProbe parses a -probe setting: icmp, or tcp:<port>
+func parseProbe(spec string) (method, port string, err error) {
+	method, port, _ = strings.Cut(strings.ToLower(spec), ":")
+	switch method {
+	case "icmp":
+		if port != "" {
+			return "", "", fmt.Errorf("probe icmp takes no port: %s", spec)
+		}
+	case "tcp":
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", "", fmt.Errorf("probe must be tcp:<port> with a port from 1 to 65535: %s", spec)
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported probe %q (use icmp or tcp:<port>)", spec)
+	}
+	return method, port, nil
+}
+
+// NewProber returns the prober for config's -probe setting, or nil when
+// probing is off
+func NewProber(config *Config) *Prober {
+	if config.Probe == "" {
+		return nil
+	}
+
+	method, port, err := parseProbe(config.Probe)
+	if err != nil {
+		// Rejected by validateConfig
+		return nil
+	}
+
+	return &Prober{method: method, port: port, results: make(map[string]*ProbeResult)}
+}
+
+// Run checks every device address of data once, concurrently
+func (p *Prober) Run(ctx context.Context, data *GroupedDevices) {
+	addresses := make(map[string]bool)
+	for _, group := range data.LogicalDeviceGroups {
+		for _, device := range group.PhysicalDevices {
+			if device.Address != "" {
+				addresses[device.Address] = true
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, maxConcurrentProbes)
+	for address := range addresses {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func() {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+
+			result := p.probe(ctx, address)
+			if ctx.Err() != nil {
+				// Cut short by shutdown, not a failed probe
+				return
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if previous := p.results[address]; previous != nil {
+				// Results already handed out are shared, so copy
+				result.History = append(result.History, previous.History...)
+			}
+			result.History = append(result.History, result.RTT)
+			if len(result.History) > probeHistoryLen {
+				result.History = result.History[len(result.History)-probeHistoryLen:]
+			}
+			p.results[address] = &result
+		}()
+	}
+	wg.Wait()
+}
+
+// Annotate returns a copy of data with the latest probe result of each
+// device, leaving data itself untouched for concurrent readers
+func (p *Prober) Annotate(data *GroupedDevices) *GroupedDevices {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return mapDevices(data, func(device *PhysicalDevice) {
+		device.Probe = p.results[device.Address]
+	})
+}
+
+// probe checks one address
+func (p *Prober) probe(ctx context.Context, address string) ProbeResult {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	var rtt time.Duration
+	var err error
+	if p.method == "tcp" {
+		err = probeTCP(ctx, net.JoinHostPort(address, p.port))
+		rtt = time.Since(start)
+	} else {
+		rtt, err = probePing(ctx, address)
+	}
+
+	result := ProbeResult{Reachable: err == nil, Time: start}
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.RTT = rtt
+	}
+	return result
+}
+
+func probeTCP(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// probePing sends one echo request with the system ping command, which has
+// the privileges raw ICMP sockets need
+func probePing(ctx context.Context, address string) (time.Duration, error) {
+	seconds := strconv.Itoa(int(probeTimeout / time.Second))
+	var args []string
+	switch runtime.GOOS {
+	case "windows":
+		args = []string{"-n", "1", "-w", strconv.Itoa(int(probeTimeout / time.Millisecond)), address}
+	case "darwin":
+		args = []string{"-c", "1", "-t", seconds, address}
+	default:
+		args = []string{"-c", "1", "-W", seconds, address}
+	}
+
+	start := time.Now()
+	output, err := exec.CommandContext(ctx, "ping", args...).Output()
+	elapsed := time.Since(start)
+	if errors.Is(err, exec.ErrNotFound) {
+		return 0, fmt.Errorf("ping command not found")
+	}
+	// Windows ping succeeds on "Destination host unreachable" replies
+	if err != nil || (runtime.GOOS == "windows" && !bytes.Contains(output, []byte("TTL="))) {
+		return 0, fmt.Errorf("no reply to ping")
+	}
+
+	if match := pingTime.FindSubmatch(output); match != nil {
+		if ms, err := strconv.ParseFloat(string(match[1]), 64); err == nil {
+			return time.Duration(ms * float64(time.Millisecond)), nil
+		}
+	}
+	return elapsed, nil
+}
+
+// sparkline draws round-trip times as block characters scaled to the
+// slowest of them
+func sparkline(rtts []time.Duration) string {
+	slowest := time.Duration(0)
+	for _, rtt := range rtts {
+		slowest = max(slowest, rtt)
+	}
+
+	var b strings.Builder
+	for _, rtt := range rtts {
+		switch {
+		case rtt <= 0:
+			b.WriteRune(sparkFailed)
+		default:
+			level := int(int64(rtt) * int64(len(sparkBlocks)-1) / int64(slowest))
+			b.WriteRune(sparkBlocks[level])
+		}
+	}
+	return b.String()
+}
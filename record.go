@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// minReplayInterval bounds the poll interval of a fast replay
+const minReplayInterval = 200 * time.Millisecond
+
+// RecordedResponse is one line of a -record file: what a device list request
+// returned, or why it returned nothing
+type RecordedResponse struct {
+	Time   time.Time       `json:"time"`
+	Status int             `json:"status,omitempty"` // 0 when no response arrived
+	Body   json.RawMessage `json:"body,omitempty"`   // Response of a 200, decompressed
+	Error  string          `json:"error,omitempty"`  // Message of an error status, or the request error
+}
+
+// Recorder appends every device list response to the -record file as a JSON
+// line, for -replay. A nil *Recorder records nothing.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder opens the -record file of config, or returns nil without one
+func NewRecorder(config *Config) (*Recorder, error) {
+	if config.Record == "" {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(config.Record, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open record file: %w", err)
+	}
+	return &Recorder{file: file}, nil
+}
+
+// Record writes a response; status 0 is a request that failed before a
+// response arrived, with message saying why
+func (r *Recorder) Record(status int, body []byte, message string) {
+	if r == nil {
+		return
+	}
+
+	recorded := RecordedResponse{Time: time.Now(), Status: status, Error: message}
+	if status == http.StatusOK && json.Valid(body) {
+		recorded.Body = body
+	}
+
+	line, encodeErr := json.Marshal(recorded)
+	if encodeErr != nil {
+		logBackground("record: failed to encode response: %v", encodeErr)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		logBackground("record: failed to write response: %v", err)
+	}
+}
+
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.file.Close()
+}
+
+// ReadRecording reads the responses of a -record file
+func ReadRecording(path string) ([]RecordedResponse, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	var responses []RecordedResponse
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var response RecordedResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			return nil, fmt.Errorf("recording %s, line %d: %w", path, line, err)
+		}
+		responses = append(responses, response)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("recording %s has no responses", path)
+	}
+	return responses, nil
+}
+
+// Replay serves a recording as the management API: each response from the
+// time it was recorded, relative to the first one and sped up by speed,
+// until the next one. The last response stays after the end.
+type Replay struct {
+	responses []RecordedResponse
+	speed     float64
+	start     time.Time
+	toLog     bool // Log the end, unless the log shares the terminal with the TUI
+	mu        sync.Mutex
+	finished  bool
+}
+
+// current returns the index of the response due now, the one a 304 stands
+// for when it has no body of its own
+func (rp *Replay) current() (index, body int) {
+	elapsed := time.Duration(float64(time.Since(rp.start)) * rp.speed)
+	first := rp.responses[0].Time
+
+	index = 0
+	for i, response := range rp.responses {
+		if response.Time.Sub(first) > elapsed {
+			break
+		}
+		index = i
+	}
+
+	body = -1
+	for i := index; i >= 0; i-- {
+		if rp.responses[i].Body != nil {
+			body = i
+			break
+		}
+	}
+	return index, body
+}
+
+// ServeHTTP answers Login with a session cookie for any credentials and
+// ListPhysicalDevices with the response due now
+func (rp *Replay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/v2/Login":
+		http.SetCookie(w, &http.Cookie{Name: "Authorization", Value: "replay", Path: "/"})
+		w.WriteHeader(http.StatusOK)
+
+	case "/api/v2/ListPhysicalDevices":
+		index, body := rp.current()
+		if index == len(rp.responses)-1 && rp.toLog {
+			rp.mu.Lock()
+			if !rp.finished {
+				log.Printf("replay: reached the end of the recording, showing its last response")
+				rp.finished = true
+			}
+			rp.mu.Unlock()
+		}
+
+		response := rp.responses[index]
+		switch {
+		case response.Status == 0:
+			// The recorded request got no response; a 503 fails the poll likewise
+			http.Error(w, response.Error, http.StatusServiceUnavailable)
+		case response.Status != http.StatusOK && response.Status != http.StatusNotModified:
+			http.Error(w, response.Error, response.Status)
+		case body < 0:
+			http.Error(w, "recording starts without a device list", http.StatusServiceUnavailable)
+		default:
+			// Responses without changes keep the ETag, so the client sees 304s
+			etag := strconv.Quote(strconv.Itoa(body))
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(rp.responses[body].Body)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// replayInterval is the typical time between the recorded responses, sped
+// up, so the replay polls about as often as the recording did
+func replayInterval(responses []RecordedResponse, speed float64) time.Duration {
+	if len(responses) < 2 {
+		return 0
+	}
+
+	gaps := make([]time.Duration, 0, len(responses)-1)
+	for i := 1; i < len(responses); i++ {
+		gaps = append(gaps, responses[i].Time.Sub(responses[i-1].Time))
+	}
+	slices.Sort(gaps)
+	return max(minReplayInterval, time.Duration(float64(gaps[len(gaps)/2])/speed).Round(time.Millisecond))
+}
+
+// startReplay serves the -replay recording on a local port and points the
+// config at it. The server runs until the process exits.
+func startReplay(config *Config) error {
+	responses, err := ReadRecording(config.Replay)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start replay: %w", err)
+	}
+
+	replay := &Replay{
+		responses: responses,
+		speed:     config.ReplaySpeed,
+		start:     time.Now(),
+		toLog:     config.Daemon || config.LogFile != "",
+	}
+	go func() {
+		if err := http.Serve(listener, replay); err != nil {
+			log.Printf("replay: %v", err)
+		}
+	}()
+
+	config.BaseURL = fmt.Sprintf("http://%s/api/v2/", listener.Addr())
+	// The last known devices of a real API must not be replaced by old ones
+	config.StateFile = ""
+	config.StreamEnabled = false
+	if interval := replayInterval(responses, config.ReplaySpeed); interval > 0 {
+		config.PollInterval = interval
+	}
+	return nil
+}
+
+// replayName describes the source of replayed data in the footer
+func replayName(config *Config) string {
+	return fmt.Sprintf("REPLAY %s (x%g)", filepath.Base(config.Replay), config.ReplaySpeed)
+}
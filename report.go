@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ReportSummary holds the fleet-wide counts shown at the top of reports
+type ReportSummary struct {
+	Total        int `json:"total"`
+	Connected    int `json:"connected"`
+	Connecting   int `json:"connecting"`
+	Disconnected int `json:"disconnected"`
+	Unspecified  int `json:"unspecified"`
+	Groups       int `json:"groups"`
+
+	Versions        []VersionCount `json:"versions"`
+	VersionMismatch int            `json:"version_mismatch"` // Devices whose software and product versions differ
+	Outdated        int            `json:"outdated"`         // Devices below -target_version
+}
+
+// NewReportSummary counts devices of data by connection state and version
+func NewReportSummary(data *GroupedDevices) ReportSummary {
+	summary := ReportSummary{
+		Total:           data.TotalDevices,
+		Groups:          len(data.LogicalDeviceGroups),
+		Versions:        NewVersionSpread(data),
+		VersionMismatch: len(versionMismatches(data)),
+		Outdated:        countOutdated(data),
+	}
+
+	for _, group := range data.LogicalDeviceGroups {
+		for _, device := range group.PhysicalDevices {
+			switch device.GetConnectionStateDisplay() {
+			case "CONNECTED":
+				summary.Connected++
+			case "CONNECTING":
+				summary.Connecting++
+			case "DISCONNECTED":
+				summary.Disconnected++
+			default:
+				summary.Unspecified++
+			}
+		}
+	}
+
+	return summary
+}
+
+// sortedGroups returns the groups of data ordered by logical device name,
+// matching the order used by the TUI
+func sortedGroups(data *GroupedDevices) []LogicalDeviceGroup {
+	groups := make([]LogicalDeviceGroup, len(data.LogicalDeviceGroups))
+	copy(groups, data.LogicalDeviceGroups)
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].LogicalDevice.Name < groups[j].LogicalDevice.Name
+	})
+	return groups
+}
+
+// WriteReport renders data to w in the given output format
+func WriteReport(w io.Writer, format string, data *GroupedDevices, config *Config) error {
+	switch format {
+	case "html":
+		return writeHTMLReport(w, data, config)
+	case "csv":
+		return writeCSVReport(w, data, config.Columns)
+	case "markdown":
+		return writeMarkdownReport(w, data, config.Columns)
+	case "text":
+		return writeTextReport(w, data, config.Columns)
+	case "json":
+		return writeJSONReport(w, data)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+type htmlReportData struct {
+	Title     string
+	MGMT      string
+	Generated string
+	Summary   ReportSummary
+	Groups    []LogicalDeviceGroup
+	Refresh   int    // Auto-refresh period in seconds, 0 for static reports
+	Error     string // Last poll error, shown above the last known data
+	Waiting   bool   // No poll has completed yet
+}
+
+func newHTMLReportData(data *GroupedDevices, config *Config) htmlReportData {
+	return htmlReportData{
+		Title:     "Physical Devices Monitor",
+		MGMT:      extractHostFromURL(config.BaseURL),
+		Generated: formatTime(time.Now(), "2006-01-02 15:04:05"),
+		Summary:   NewReportSummary(data),
+		Groups:    sortedGroups(data),
+	}
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"stateClass": func(device PhysicalDevice) string {
+		if device.Ack != nil && device.GetConnectionStateDisplay() != "CONNECTED" {
+			return "meta"
+		}
+		switch device.GetConnectionStateDisplay() {
+		case "CONNECTED":
+			return "ok"
+		case "DISCONNECTED":
+			return "bad"
+		default:
+			return "warn"
+		}
+	},
+	"roleClass": func(role string) string {
+		switch role {
+		case "ACTIVE":
+			return "ok"
+		case "STANDBY":
+			return "warn"
+		default:
+			return "bad"
+		}
+	},
+	"formatVersionSpread": formatVersionSpread,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{if .Refresh}}<meta http-equiv="refresh" content="{{.Refresh}}">
+{{end}}<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+.summary td { font-weight: bold; }
+.topology { color: #2a5db0; font-weight: normal; }
+.ok { color: #1a7f37; }
+.warn { color: #b58100; }
+.bad { color: #cf222e; }
+.meta { color: #666; }
+.error { color: #cf222e; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">MGMT: {{.MGMT}} &middot; {{if .Refresh}}Last Updated{{else}}Generated{{end}}: {{.Generated}}</p>
+{{with .Error}}<p class="error">ERROR: {{.}}</p>
+{{end}}<table class="summary">
+<tr><th>Logical devices</th><th>Total</th><th>Connected</th><th>Connecting</th><th>Disconnected</th><th>Unspecified</th></tr>
+<tr><td>{{.Summary.Groups}}</td><td>{{.Summary.Total}}</td><td class="ok">{{.Summary.Connected}}</td><td class="warn">{{.Summary.Connecting}}</td><td class="bad">{{.Summary.Disconnected}}</td><td>{{.Summary.Unspecified}}</td></tr>
+</table>
+{{with .Summary.Versions}}<p class="meta">Versions: {{formatVersionSpread .}}{{with $.Summary.VersionMismatch}} &middot; <span class="warn">software version differs on {{.}} of {{$.Summary.Total}} devices</span>{{end}}{{with $.Summary.Outdated}} &middot; <span class="warn">{{.}} OUTDATED</span>{{end}}</p>
+{{end}}{{range .Groups}}
+<h2>{{.LogicalDevice.Name}} <span class="topology">({{.GetTopologyDisplayName}})</span>{{with .GetVirtualContextsDisplay}} <span class="meta">Contexts: {{.}}</span>{{end}}</h2>
+{{if .IsCluster}}{{with .ClusterSummary}}<p class="{{if .Degraded}}bad{{else}}ok{{end}}">{{if .Degraded}}Cluster degraded{{else}}Cluster OK{{end}}: {{.}}</p>
+{{end}}{{range .RoleAnomalies}}<p class="{{if .Warning}}warn{{else}}error{{end}}">{{.Title}}: {{.Summary}}</p>
+{{end}}{{end}}<table>
+<tr><th>Device Name</th><th>Role</th><th>Model</th><th>Status</th><th>Address</th><th>Priority</th><th>Version</th><th>Last Connected</th></tr>
+{{range .PhysicalDevices}}<tr>
+<td>{{.Name}}{{with .GetAckDisplay}} <span class="meta">{{.}}</span>{{end}}</td>
+<td>{{with .GetRoleDisplay}}<span class="{{roleClass .}}">{{.}}</span>{{else}}-{{end}}</td>
+<td>{{.Model}}</td>
+<td class="{{stateClass .}}">{{.GetConnectionStateDisplay}}</td>
+<td>{{.Address}}</td>
+<td>{{if .AsNode}}{{.AsNode.Priority}}{{else}}-{{end}}</td>
+<td{{if or .VersionMismatch .Outdated}} class="warn"{{end}}{{if .VersionMismatch}} title="Software {{.SoftwareVersion}}"{{end}}>{{.GetProductVersionDisplay}}{{if .Outdated}} OUTDATED{{end}}</td>
+<td>{{.GetLastConnectedDisplay}}</td>
+</tr>
+{{end}}</table>
+{{else}}
+<p>{{if .Waiting}}Waiting for data...{{else}}No devices found{{end}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+func writeHTMLReport(w io.Writer, data *GroupedDevices, config *Config) error {
+	return htmlReportTemplate.Execute(w, newHTMLReportData(data, config))
+}
+
+// reportRows flattens data into a header and one row per device, prefixed
+// with the logical device name and topology
+func reportRows(data *GroupedDevices, columns []Column) ([]string, [][]string) {
+	header := []string{"Logical Device", "Topology"}
+	for _, column := range columns {
+		header = append(header, column.Title)
+	}
+
+	var rows [][]string
+	for _, group := range sortedGroups(data) {
+		for i := range group.PhysicalDevices {
+			row := []string{group.LogicalDevice.Name, group.GetTopologyDisplayName()}
+			for _, column := range columns {
+				row = append(row, column.Value(&group.PhysicalDevices[i]))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return header, rows
+}
+
+func writeCSVReport(w io.Writer, data *GroupedDevices, columns []Column) error {
+	header, rows := reportRows(data, columns)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+// writeTextReport prints the device rows as an aligned plain-text table
+func writeTextReport(w io.Writer, data *GroupedDevices, columns []Column) error {
+	header, rows := reportRows(data, columns)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// writeJSONReport writes the summary and the grouped devices as one JSON
+// document
+func writeJSONReport(w io.Writer, data *GroupedDevices) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Summary ReportSummary `json:"summary"`
+		*GroupedDevices
+	}{NewReportSummary(data), data})
+}
+
+func writeMarkdownReport(w io.Writer, data *GroupedDevices, columns []Column) error {
+	header, rows := reportRows(data, columns)
+
+	escape := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = strings.ReplaceAll(cell, "|", "\\|")
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	var b strings.Builder
+	b.WriteString(escape(header))
+	b.WriteString(escape(separator))
+	for _, row := range rows {
+		b.WriteString(escape(row))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
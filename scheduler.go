@@ -2,37 +2,101 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
+	"math/rand/v2"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/term"
 )
 
 type Scheduler struct {
 	config       *Config
-	apiClient    *APIClient
-	display      *DisplayManager
+	source       DeviceSource
+	display      Display
+	tui          *DisplayManager // The display if it is the terminal, which also takes the input; nil otherwise
+	store        *StateStore
+	presenter    *Presenter
 	ctx          context.Context
 	cancel       context.CancelFunc
 	ticker       *time.Ticker
 	running      bool
 	dataChannel  chan *APIResponse
 	errorChannel chan error
+	streamEvents chan struct{}
+	streamErrors chan error
+	streaming    bool
+	workers      sync.WaitGroup
+	interval     time.Duration
+	pollFailed   bool      // The most recent poll returned an error
+	plain        bool      // Stdout is not a terminal; print status lines instead of drawing
+	startupErr   error     // Initial connection failure to show before the first poll
+	fetching     bool      // A fetch worker is in flight; owned by the Start loop
+	fetchPending bool      // A change arrived during the fetch in flight; fetch again after it
+	lastFetch    time.Time // Start of the latest fetch; owned by the Start loop
+	history      *pollHistory
+	signals      chan os.Signal // Interrupt and termination requests
+	prober       *Prober        // Nil without -probe
+	flaps        *FlapDetector  // Nil with -flap_threshold 0
+	inventory    *Inventory     // Nil without expected devices
+	probing      bool           // A probe round is in flight; owned by the Start loop
+	probeDone    chan struct{}
+	notes        *NoteStore
+	acks         *AckStore
+	onInput      func(text string) // Takes the text entered in the footer's field
+	pendingG     bool              // 'g' was pressed and waits for the second one of gg
 }
 
-func NewScheduler(config *Config, apiClient *APIClient, display *DisplayManager) *Scheduler {
+// flashDuration is how long footer notifications stay visible
+const flashDuration = 5 * time.Second
+
+// streamRetryDelay is how long to keep polling before re-opening a failed change stream
+const streamRetryDelay = 30 * time.Second
+
+// NewScheduler returns a scheduler that polls source and publishes the
+// results to store, whose subscribers include a Presenter on display
+func NewScheduler(config *Config, source DeviceSource, display Display, store *StateStore) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	tui, _ := display.(*DisplayManager)
+	presenter := NewPresenter(config, display, store)
+	store.Subscribe(presenter)
+
+	history := newPollHistory()
+	if config.HistoryFile != "" {
+		records, err := ReadHistory(config.HistoryFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Connected device trend starts empty: %v", err)
+		}
+		history.SeedConnected(records, config.PollInterval)
+	}
+
 	return &Scheduler{
 		config:       config,
-		apiClient:    apiClient,
+		source:       source,
 		display:      display,
+		tui:          tui,
+		store:        store,
+		presenter:    presenter,
 		ctx:          ctx,
 		cancel:       cancel,
 		running:      false,
 		dataChannel:  make(chan *APIResponse, 1),
 		errorChannel: make(chan error, 1),
+		streamEvents: make(chan struct{}, 1),
+		streamErrors: make(chan error, 1),
+		history:      history,
+		prober:       NewProber(config),
+		flaps:        NewFlapDetector(config),
+		inventory:    NewInventory(config),
+		probeDone:    make(chan struct{}, 1),
 	}
 }
 
@@ -41,15 +105,57 @@ func (s *Scheduler) Start() error {
 		return fmt.Errorf("scheduler is already running")
 	}
 
-	s.display.StartFullScreenMode()
+	s.plain = !s.config.Daemon && !term.IsTerminal(int(os.Stdout.Fd()))
+	s.presenter.plain = s.plain
+	if !s.config.Daemon && !s.plain && s.tui != nil {
+		s.tui.StartFullScreenMode()
+	}
 
 	s.running = true
-	s.ticker = time.NewTicker(s.config.PollInterval)
+	s.interval = s.config.PollInterval
+	s.ticker = time.NewTicker(s.interval)
+	s.display.SetNextPoll(time.Now().Add(s.interval))
+
+	s.signals = make(chan os.Signal, 1)
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+
+	// Ctrl+Z and fg must release and re-enter the alternate screen
+	jobControl := make(chan os.Signal, 1)
+	if s.fullScreen() {
+		notifyJobControl(jobControl)
+		defer signal.Stop(jobControl)
+	}
+
+	if s.startupErr != nil {
+		// Handled by the loop like a failed poll, which also starts the backoff
+		s.errorChannel <- s.startupErr
+	} else {
+		s.startFetch(s.fetchDataWithJitter)
+	}
 
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+	if s.config.StreamEnabled {
+		s.spawn(s.runStream)
+	}
+
+	// Between polls the TUI is redrawn for the clock, countdown and relative
+	// times, so a long poll interval does not look like a hang
+	var render <-chan time.Time
+	if s.fullScreen() && s.config.RenderInterval > 0 {
+		renderTicker := time.NewTicker(s.config.RenderInterval)
+		defer renderTicker.Stop()
+		render = renderTicker.C
+	}
 
-	go s.fetchData()
+	// The watchdog is fed from this loop, so a stuck loop gets the service restarted
+	var watchdog <-chan time.Time
+	if s.config.Daemon {
+		sdNotify("READY=1")
+		if interval := sdWatchdogInterval(); interval > 0 {
+			watchdogTicker := time.NewTicker(interval)
+			defer watchdogTicker.Stop()
+			watchdog = watchdogTicker.C
+		}
+	}
 
 	for {
 		select {
@@ -58,29 +164,163 @@ func (s *Scheduler) Start() error {
 			s.cleanup()
 			return nil
 
-		case <-signalChan:
+		case <-s.signals:
 
-			s.display.RestoreTerminal()
+			s.restoreTerminal()
 			s.Stop()
+			s.cleanup()
 			return nil
 
 		case <-s.ticker.C:
 
-			go s.fetchData()
+			s.display.SetNextPoll(time.Now().Add(s.interval))
+
+			// While the change stream is up, polling is only a fallback, kept
+			// at a third of -stale_after so the health checks pass while
+			// nothing changes. A tick during a slow fetch is dropped rather
+			// than piling up requests.
+			if !s.fetching && (!s.streaming || time.Since(s.lastFetch) >= staleAfter(s.config)/3) {
+				s.startFetch(s.fetchDataWithJitter)
+			}
+
+		case <-s.streamEvents:
+
+			if !s.streaming {
+				s.streaming = true
+				s.display.SetStreaming(true)
+			}
+			if s.fetching {
+				s.fetchPending = true
+			} else {
+				s.startFetch(s.fetchData)
+			}
+
+		case sig := <-jobControl:
+
+			if isSuspendSignal(sig) {
+				s.suspend()
+			} else {
+				s.tui.Resume()
+			}
+
+		case <-watchdog:
+
+			sdNotify("WATCHDOG=1")
+
+		case <-render:
+
+			// The success rate's window grows with the uptime
+			s.display.SetPollStats(s.history.Stats())
+			s.display.Redraw()
+
+		case ev := <-s.input():
+
+			// The screen runs in raw mode, so Ctrl+C arrives as a key, not a signal
+			if key, ok := ev.(*tcell.EventKey); ok && key.Key() == tcell.KeyCtrlC {
+				s.tui.RestoreTerminal()
+				s.Stop()
+				s.cleanup()
+				return nil
+			}
+			if key, ok := ev.(*tcell.EventKey); ok && key.Key() == tcell.KeyCtrlZ {
+				s.suspend()
+				continue
+			}
+			s.handleEvent(ev)
+
+		case <-s.streamErrors:
+
+			s.streaming = false
+			s.display.SetStreaming(false)
 
 		case response := <-s.dataChannel:
 
-			grouped := GroupDevicesByLogicalDevice(response)
-			s.display.UpdateTerminalSize()
-			s.display.Render(grouped, nil)
+			// Read before fetchDone, which may start the next request
+			s.display.SetTiming(s.source.LastTiming())
+			s.fetchDone()
+
+			s.history.Record(true, response.Latency)
+			s.display.SetPollStats(s.history.Stats())
+
+			// Nothing changed since the last render, unless an error needs clearing
+			if response.NotModified && !s.pollFailed {
+				s.store.PublishUnchanged()
+				s.startProbe()
+				if s.acks.Expired() || s.flaps.Flapping() {
+					s.refresh()
+				}
+				continue
+			}
+
+			s.adjustInterval(true)
+
+			ApplyLabels(response, s.config)
+			ApplyTargetVersion(response, s.config)
+			s.notes.Apply(response)
+			grouped := s.inventory.Annotate(GroupDevicesByLogicalDevice(response))
+			if s.prober != nil {
+				grouped = s.prober.Annotate(grouped)
+			}
+			grouped = s.flaps.Annotate(grouped)
+			grouped = s.acks.Annotate(grouped)
+			s.history.SetConnected(grouped.ConnectedCount())
+			s.display.SetPollStats(s.history.Stats())
+			s.store.Publish(PollResult{
+				Time:    grouped.LastUpdated,
+				Data:    grouped,
+				Latency: response.Latency,
+			})
+			s.recordDeviceMetrics(grouped)
+			s.pollFailed = false
+			s.startProbe()
+
+		case <-s.probeDone:
+
+			s.probing = false
+
+			// Show the new results without waiting for the next change
+			s.refresh()
+
+		case <-s.acks.Changed():
+
+			s.refresh()
 
 		case err := <-s.errorChannel:
 
-			s.display.Render(nil, err)
+			s.display.SetTiming(s.source.LastTiming())
+			s.fetchDone()
+
+			s.history.Record(false, 0)
+			s.display.SetPollStats(s.history.Stats())
+
+			if delay := RetryAfter(err); delay > 0 {
+				s.delayNextPoll(delay)
+			} else {
+				s.adjustInterval(false)
+			}
+			s.store.Publish(PollResult{Time: time.Now(), Err: err})
+			s.pollFailed = true
 		}
 	}
 }
 
+// SetNotes sets where device notes are kept
+func (s *Scheduler) SetNotes(notes *NoteStore) {
+	s.notes = notes
+}
+
+// SetAcks sets the acknowledgements that silence notifiers, shared with the
+// web API
+func (s *Scheduler) SetAcks(acks *AckStore) {
+	s.acks = acks
+}
+
+// SetStartupError makes Start begin with err on screen and retry on the
+// backoff schedule instead of polling right away
+func (s *Scheduler) SetStartupError(err error) {
+	s.startupErr = err
+}
+
 func (s *Scheduler) Stop() {
 	if !s.running {
 		return
@@ -89,18 +329,352 @@ func (s *Scheduler) Stop() {
 	s.cancel()
 }
 
+// handleEvent reacts to terminal input and resize events
+func (s *Scheduler) handleEvent(ev tcell.Event) {
+	switch ev := ev.(type) {
+	case *tcell.EventResize:
+		s.tui.Resize()
+	case *tcell.EventMouse:
+		switch ev.Buttons() {
+		case tcell.Button1:
+			x, y := ev.Position()
+			if !s.tui.SortAt(x, y) {
+				s.tui.SelectAt(y)
+			}
+		case tcell.WheelUp:
+			s.tui.Scroll(-1)
+		case tcell.WheelDown:
+			s.tui.Scroll(1)
+		}
+	case *tcell.EventKey:
+		if s.tui.Editing() {
+			if text, done, accepted := s.tui.InputKey(ev); done && accepted {
+				s.onInput(text)
+			}
+			return
+		}
+
+		// Any key closes the help, and does nothing else
+		if s.tui.HelpShown() {
+			s.tui.ToggleHelp()
+			return
+		}
+
+		// gg jumps to the first device, as in vi; any other key drops the g
+		pendingG := s.pendingG
+		s.pendingG = false
+
+		switch ev.Key() {
+		case tcell.KeyEnter:
+			s.tui.ToggleDetails()
+		case tcell.KeyUp:
+			s.tui.Select(-1)
+		case tcell.KeyDown:
+			s.tui.Select(1)
+		case tcell.KeyHome:
+			s.tui.SelectFirst()
+		case tcell.KeyEnd:
+			s.tui.SelectLast()
+		case tcell.KeyEscape:
+			s.tui.ClearSelection()
+		case tcell.KeyPgUp:
+			s.tui.TurnPage(-1)
+		case tcell.KeyPgDn:
+			s.tui.TurnPage(1)
+		case tcell.KeyRune:
+			if ev.Rune() == 'g' {
+				if pendingG {
+					s.tui.SelectFirst()
+				} else {
+					s.pendingG = true
+				}
+				return
+			}
+			s.handleKey(ev.Rune())
+		}
+	}
+}
+
+// handleKey reacts to a hotkey pressed in the TUI
+func (s *Scheduler) handleKey(key rune) {
+	switch key {
+	case '?':
+		s.tui.ToggleHelp()
+	case 'j':
+		s.tui.Select(1)
+	case 'k':
+		s.tui.Select(-1)
+	case 'G':
+		s.tui.SelectLast()
+	case '/':
+		s.onInput = s.tui.SetSearch
+		s.tui.StartInput("Search name, serial, address, model or description:", s.tui.Search())
+	case 'o', 'O':
+		s.tui.Flash(fmt.Sprintf("Logical devices sorted by %s", s.tui.ToggleGroupOrder()), flashDuration)
+		s.tui.Redraw()
+	case 'c', 'C':
+		if s.tui.ToggleCollapseHealthy() {
+			s.tui.Flash("Collapsed the logical devices without problems (c: expand all)", flashDuration)
+		} else {
+			s.tui.Flash("Expanded all logical devices", flashDuration)
+		}
+		s.tui.Redraw()
+	case ' ':
+		if s.tui.Selected() == nil {
+			s.tui.Flash("Select a device with the arrow keys or the mouse first", flashDuration)
+			s.tui.Redraw()
+			return
+		}
+		s.tui.ToggleGroup()
+	case 'd', 'D':
+		s.tui.ToggleDiagnostics()
+	case 'e', 'E':
+		s.tui.SetEvents(s.store.Events(time.Time{}, eventLogSize))
+		s.tui.ToggleEventLog()
+	case 'u', 'U':
+		s.tui.ToggleVersions()
+	case 'f', 'F':
+		s.tui.ToggleStats()
+	case 'w', 'W':
+		path, err := WriteSnapshot(s.config.SnapshotDir, s.config.SnapshotFormat, s.tui.LastData())
+		if err != nil {
+			s.tui.Flash(fmt.Sprintf("Snapshot failed: %v", err), flashDuration)
+		} else {
+			s.tui.Flash(fmt.Sprintf("Snapshot saved: %s", path), flashDuration)
+		}
+		s.tui.Redraw()
+	case 'y', 'Y':
+		s.copySelected(key == 'Y')
+	case 's', 'S':
+		s.connectSelected()
+	case 'n', 'N':
+		s.editNote()
+	case 'a', 'A':
+		s.acknowledgeSelected()
+	case 't', 'T':
+		displayTime.relative = !displayTime.relative
+		s.tui.Redraw()
+	case 'v', 'V':
+		if s.tui.Selected() == nil {
+			s.tui.Flash("Select a device with the arrow keys or the mouse first", flashDuration)
+			s.tui.Redraw()
+			return
+		}
+		s.tui.ToggleContexts()
+	}
+}
+
+// editNote opens the text field for the selected device's note
+func (s *Scheduler) editNote() {
+	device := s.tui.Selected()
+	if device == nil {
+		s.tui.Flash("Select a device with the arrow keys or the mouse first", flashDuration)
+		s.tui.Redraw()
+		return
+	}
+
+	text := ""
+	if device.Note != nil {
+		text = device.Note.Text
+	}
+	id := device.ID
+	s.onInput = func(text string) {
+		s.saveNote(id, text)
+	}
+	s.tui.StartInput(fmt.Sprintf("Note for %s:", device.Name), text)
+}
+
+// saveNote stores the note entered for a device and shows it right away
+func (s *Scheduler) saveNote(deviceID, text string) {
+	if err := s.notes.Set(deviceID, text); err != nil {
+		s.tui.Flash(fmt.Sprintf("Saving the note failed: %v", err), flashDuration)
+		s.tui.Redraw()
+		return
+	}
+	s.refresh()
+}
+
+// refresh re-applies notes, probe results, flapping and acks to the current
+// data and shows it, without waiting for the next poll
+func (s *Scheduler) refresh() {
+	data := s.store.State().Data
+	if data == nil || s.pollFailed {
+		if !s.config.Daemon && !s.plain {
+			s.display.Redraw()
+		}
+		return
+	}
+
+	data = s.notes.Annotate(data)
+	if s.prober != nil {
+		data = s.prober.Annotate(data)
+	}
+	data = s.flaps.Annotate(data)
+	data = s.acks.Annotate(data)
+	s.store.Republish(data)
+}
+
+// copySelected copies the selected device's address, or its serial number,
+// to the clipboard
+func (s *Scheduler) copySelected(serial bool) {
+	device := s.tui.Selected()
+	if device == nil {
+		s.tui.Flash("Select a device with the arrow keys or the mouse first", flashDuration)
+		s.tui.Redraw()
+		return
+	}
+
+	what, value := "address", device.Address
+	if serial {
+		what, value = "serial number", device.SerialNumber
+	}
+
+	if value == "" {
+		s.tui.Flash(fmt.Sprintf("%s has no %s", device.Name, what), flashDuration)
+	} else {
+		via := s.tui.CopyToClipboard(value)
+		s.tui.Flash(fmt.Sprintf("Copied %s %s (%s)", what, value, via), flashDuration)
+	}
+	s.tui.Redraw()
+}
+
+// startProbe checks the device addresses of the latest data in the
+// background, unless a probe round is still running
+func (s *Scheduler) startProbe() {
+	data := s.store.State().Data
+	if s.prober == nil || s.probing || data == nil {
+		return
+	}
+
+	s.probing = true
+	s.spawn(func() {
+		s.prober.Run(s.ctx, data)
+		select {
+		case s.probeDone <- struct{}{}:
+		case <-s.ctx.Done():
+		}
+	})
+}
+
+// adjustInterval doubles the effective poll interval after a failed poll, up to
+// MaxPollInterval, and returns to the configured interval after a success
+func (s *Scheduler) adjustInterval(success bool) {
+	next := s.config.PollInterval
+	if !success {
+		next = s.interval * 2
+		if next > s.config.MaxPollInterval {
+			next = s.config.MaxPollInterval
+		}
+	}
+
+	if next == s.interval {
+		return
+	}
+
+	s.interval = next
+	s.ticker.Reset(next)
+	s.display.SetEffectiveInterval(next)
+	s.display.SetNextPoll(time.Now().Add(next))
+}
+
+// startFetch runs fetch as the single fetch worker. Only one fetch is in
+// flight at a time, since the API client keeps session and cache state that
+// concurrent requests would race on.
+func (s *Scheduler) startFetch(fetch func()) {
+	s.fetching = true
+	s.lastFetch = time.Now()
+	s.spawn(fetch)
+}
+
+// fetchDone marks the fetch worker finished once its result has been received,
+// and starts the fetch a stream event asked for in the meantime
+func (s *Scheduler) fetchDone() {
+	s.fetching = false
+	if s.fetchPending {
+		s.fetchPending = false
+		s.startFetch(s.fetchData)
+	}
+}
+
+// suspend releases the screen and stops the process; the SIGCONT sent on
+// resume takes the screen back
+func (s *Scheduler) suspend() {
+	s.tui.Suspend()
+	stopProcess()
+}
+
+// delayNextPoll holds off polling for delay, as asked by a rate-limited
+// response; the next successful poll returns to the configured interval
+func (s *Scheduler) delayNextPoll(delay time.Duration) {
+	s.interval = max(delay, s.config.PollInterval)
+	s.ticker.Reset(s.interval)
+	s.display.SetEffectiveInterval(s.interval)
+	s.display.SetNextPoll(time.Now().Add(s.interval))
+}
+
+// fullScreen reports whether the TUI has the terminal
+func (s *Scheduler) fullScreen() bool {
+	return s.tui != nil && s.tui.fullScreen
+}
+
+// input returns the terminal's keys, mouse and resize events; without the
+// terminal display it is nil, so it never delivers
+func (s *Scheduler) input() <-chan tcell.Event {
+	if s.tui == nil {
+		return nil
+	}
+	return s.tui.Events()
+}
+
+// restoreTerminal gives the terminal back before exiting
+func (s *Scheduler) restoreTerminal() {
+	if s.tui != nil {
+		s.tui.RestoreTerminal()
+	}
+}
+
+// spawn runs fn in a goroutine that cleanup waits for before closing channels
+func (s *Scheduler) spawn(fn func()) {
+	s.workers.Add(1)
+	go func() {
+		defer s.tui.RestoreOnPanic()
+		defer s.workers.Done()
+		fn()
+	}()
+}
+
+// fetchDataWithJitter delays the poll by a random fraction of the interval so
+// instances started together do not hit the management API in lockstep
+func (s *Scheduler) fetchDataWithJitter() {
+	if s.config.PollJitter > 0 {
+		maxDelay := s.config.PollInterval * time.Duration(s.config.PollJitter) / 100
+		if maxDelay > 0 {
+			select {
+			case <-time.After(rand.N(maxDelay)):
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}
+
+	s.fetchData()
+}
+
 func (s *Scheduler) fetchData() {
 	select {
 	case <-s.ctx.Done():
 		return
 	default:
-		response, err := s.apiClient.FetchDevicesWithRetry(2)
+		start := time.Now()
+		response, err := s.source.FetchDevicesWithRetry(s.ctx, 2)
+		s.recordPollMetrics(time.Since(start), err)
 		if err != nil {
 			select {
 			case s.errorChannel <- err:
 			case <-s.ctx.Done():
 			}
 		} else {
+			response.Latency = time.Since(start)
 			select {
 			case s.dataChannel <- response:
 			case <-s.ctx.Done():
@@ -109,12 +683,63 @@ func (s *Scheduler) fetchData() {
 	}
 }
 
+// recordPollMetrics counts polls by outcome and records how long they took
+func (s *Scheduler) recordPollMetrics(duration time.Duration, err error) {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	otel.AddCounter("pt_monitor.polls", 1, "result", result)
+	otel.SetGauge("pt_monitor.poll.duration_ms", float64(duration)/float64(time.Millisecond))
+}
+
+// recordDeviceMetrics publishes the number of devices per connection state
+func (s *Scheduler) recordDeviceMetrics(data *GroupedDevices) {
+	summary := NewReportSummary(data)
+	otel.SetGauge("pt_monitor.devices", float64(summary.Connected), "state", "connected")
+	otel.SetGauge("pt_monitor.devices", float64(summary.Connecting), "state", "connecting")
+	otel.SetGauge("pt_monitor.devices", float64(summary.Disconnected), "state", "disconnected")
+	otel.SetGauge("pt_monitor.devices", float64(summary.Unspecified), "state", "unspecified")
+}
+
+// runStream keeps a change-stream subscription open for the scheduler lifetime,
+// re-subscribing after streamRetryDelay whenever it fails
+func (s *Scheduler) runStream() {
+	for {
+		err := s.source.SubscribeDeviceChanges(s.ctx, s.streamEvents)
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		select {
+		case s.streamErrors <- err:
+		case <-s.ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(streamRetryDelay):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
 func (s *Scheduler) cleanup() {
 	if s.ticker != nil {
 		s.ticker.Stop()
 	}
 	s.running = false
 
+	// In-flight requests are cancelled with the context, so this returns
+	// promptly. Every worker sends with a select on the context, so once they
+	// have all exited nothing can send on the channels closed below.
+	s.workers.Wait()
+
 	close(s.dataChannel)
 	close(s.errorChannel)
 }
@@ -123,8 +748,10 @@ func (s *Scheduler) UpdateConfig(config *Config) {
 	s.config = config
 
 	if s.running && s.ticker != nil {
-		s.ticker.Stop()
-		s.ticker = time.NewTicker(config.PollInterval)
+		s.interval = config.PollInterval
+		s.ticker.Reset(config.PollInterval)
+		s.display.SetEffectiveInterval(config.PollInterval)
+		s.display.SetNextPoll(time.Now().Add(config.PollInterval))
 	}
 }
 
@@ -141,12 +768,12 @@ func (s *Scheduler) GetStats() map[string]interface{} {
 }
 
 func (s *Scheduler) TestInitialConnection() error {
-	err := s.apiClient.Login(s.config.Username, s.config.Password)
+	err := s.source.Authenticate(s.ctx, AuthLogin)
 	if err != nil {
 		return fmt.Errorf("login failed: %w", err)
 	}
 
-	err = s.apiClient.TestConnection()
+	err = s.source.TestConnection(s.ctx)
 	if err != nil {
 		return fmt.Errorf("initial connection test failed: %w", err)
 	}
@@ -155,12 +782,14 @@ func (s *Scheduler) TestInitialConnection() error {
 }
 
 func (s *Scheduler) RunOnce() error {
-	response, err := s.apiClient.FetchDevicesWithRetry(2)
+	response, err := s.source.FetchDevicesWithRetry(s.ctx, 2)
 	if err != nil {
 		s.display.Render(nil, err)
 		return err
 	}
 
+	ApplyLabels(response, s.config)
+	ApplyTargetVersion(response, s.config)
 	grouped := GroupDevicesByLogicalDevice(response)
 	s.display.Render(grouped, nil)
 	return nil
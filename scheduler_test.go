@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDisplay records what the scheduler shows instead of drawing it
+type testDisplay struct {
+	intervals []time.Duration // Effective poll intervals, in the order set
+	pollStats PollStats
+}
+
+func (d *testDisplay) Render(data *GroupedDevices, err error)                            {}
+func (d *testDisplay) RenderPlain(data *GroupedDevices, events []DeviceEvent, err error) {}
+func (d *testDisplay) Redraw()                                                           {}
+func (d *testDisplay) UpdateTerminalSize()                                               {}
+func (d *testDisplay) SetEvents(events []DeviceEvent)                                    {}
+func (d *testDisplay) SetNextPoll(at time.Time)                                          {}
+func (d *testDisplay) SetEffectiveInterval(interval time.Duration) {
+	d.intervals = append(d.intervals, interval)
+}
+func (d *testDisplay) SetTiming(timing *RequestTiming) {}
+func (d *testDisplay) SetPollStats(stats PollStats)    { d.pollStats = stats }
+func (d *testDisplay) SetStreaming(streaming bool)     {}
+
+// resultRecorder passes what the store publishes to the test. It never
+// blocks the scheduler, which runs the subscribers.
+type resultRecorder struct {
+	results   chan PollResult
+	unchanged chan struct{}
+}
+
+func newResultRecorder() *resultRecorder {
+	return &resultRecorder{results: make(chan PollResult, 16), unchanged: make(chan struct{}, 16)}
+}
+
+func (r *resultRecorder) Dispatch(result PollResult) {
+	select {
+	case r.results <- result:
+	default:
+	}
+}
+
+func (r *resultRecorder) Unchanged() {
+	select {
+	case r.unchanged <- struct{}{}:
+	default:
+	}
+}
+
+func (r *resultRecorder) Watch(data *GroupedDevices) {}
+
+func (r *resultRecorder) next(t *testing.T) PollResult {
+	t.Helper()
+	select {
+	case result := <-r.results:
+		return result
+	case <-time.After(5 * time.Second):
+		t.Fatal("no poll result published")
+		return PollResult{}
+	}
+}
+
+// startScheduler polls source every 20ms in daemon mode and returns a
+// function that stops the scheduler and waits for Start to return
+func startScheduler(t *testing.T, source DeviceSource) (*testDisplay, *resultRecorder, func()) {
+	t.Helper()
+
+	cm := NewConfigManager()
+	cm.setDefaults()
+	config := cm.config
+	config.Daemon = true
+	config.StreamEnabled = false
+	config.PollInterval = 20 * time.Millisecond
+
+	display := &testDisplay{}
+	store := NewStateStore()
+	recorder := newResultRecorder()
+	store.Subscribe(recorder)
+	scheduler := NewScheduler(config, source, display, store)
+
+	done := make(chan error, 1)
+	go func() { done <- scheduler.Start() }()
+
+	stop := func() {
+		t.Helper()
+		scheduler.Stop()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("scheduler failed: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("scheduler did not stop")
+		}
+	}
+	return display, recorder, stop
+}
+
+func TestSchedulerPublishesSourceDevices(t *testing.T) {
+	source := NewMockSource(func() ([]PhysicalDevice, string, error) { return testDevices, "v1", nil })
+	display, recorder, stop := startScheduler(t, source)
+
+	result := recorder.next(t)
+	if result.Err != nil || result.Data == nil {
+		t.Fatalf("got error %v and data %v, want the devices", result.Err, result.Data)
+	}
+	if result.Data.TotalDevices != len(testDevices) {
+		t.Errorf("got %d devices, want %d", result.Data.TotalDevices, len(testDevices))
+	}
+
+	// The same version again is not modified and not published as a result
+	select {
+	case <-recorder.unchanged:
+	case <-time.After(5 * time.Second):
+		t.Fatal("unchanged poll not reported")
+	}
+	stop()
+
+	if stats := display.pollStats; stats.Polls < 2 || stats.SuccessRate != 100 {
+		t.Errorf("display shows %d polls with %.0f%% success, want at least 2 without failures", stats.Polls, stats.SuccessRate)
+	}
+}
+
+func TestSchedulerBacksOffAfterFailedPoll(t *testing.T) {
+	var calls atomic.Int32
+	source := NewMockSource(func() ([]PhysicalDevice, string, error) {
+		if calls.Add(1) == 1 {
+			return nil, "", errors.New("management API unreachable")
+		}
+		return testDevices, "v1", nil
+	})
+	display, recorder, stop := startScheduler(t, source)
+
+	if result := recorder.next(t); result.Err == nil {
+		t.Fatal("failed poll published without its error")
+	}
+	if result := recorder.next(t); result.Err != nil || result.Data == nil {
+		t.Fatalf("got error %v after the source recovered, want the devices", result.Err)
+	}
+	stop()
+
+	want := []time.Duration{40 * time.Millisecond, 20 * time.Millisecond}
+	if !slices.Equal(display.intervals, want) {
+		t.Errorf("effective intervals %v, want %v", display.intervals, want)
+	}
+}
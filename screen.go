@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// headerLines and footerLines are the rows pinned to the top and bottom of
+// the screen; everything in between scrolls when it does not fit
+const (
+	headerLines = 5
+	footerLines = 3
+)
+
+// pageTurn is how often the pages of a device list taller than the screen
+// turn by themselves where there is no keyboard input, without tcell
+const pageTurn = 10 * time.Second
+
+// sgrRegex matches the SGR escape sequences used by the color constants and
+// the OSC 8 sequences that start and end a hyperlink
+var sgrRegex = regexp.MustCompile(`\033\[([0-9;]*)m|\033\]8;[^;\033]*;([^\033]*)\033\\`)
+
+// startScreen takes over the terminal with tcell. Input, resize and mouse
+// events are delivered on dm.events until RestoreTerminal is called.
+func (dm *DisplayManager) startScreen() error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+
+	screen.HideCursor()
+	screen.EnableMouse(tcell.MouseButtonEvents)
+	screen.Clear()
+
+	dm.screen = screen
+	dm.events = make(chan tcell.Event, 16)
+	dm.termWidth, dm.termHeight = screen.Size()
+
+	go dm.pollEvents(screen)
+
+	return nil
+}
+
+func (dm *DisplayManager) pollEvents(screen tcell.Screen) {
+	defer dm.RestoreOnPanic()
+
+	for {
+		ev := screen.PollEvent()
+		if ev == nil {
+			// Screen was finalized
+			return
+		}
+
+		select {
+		case dm.events <- ev:
+		default:
+			// Drop events nobody is consuming
+		}
+	}
+}
+
+// Events returns terminal input and resize events. Without a screen the
+// channel is nil and never delivers.
+func (dm *DisplayManager) Events() <-chan tcell.Event {
+	return dm.events
+}
+
+// Scroll moves the device list by delta lines; the header and footer stay put
+func (dm *DisplayManager) Scroll(delta int) {
+	dm.scroll += delta
+	dm.flush()
+}
+
+// PageSize is the number of device lines visible between header and footer
+func (dm *DisplayManager) PageSize() int {
+	return max(1, dm.termHeight-headerLines-footerLines)
+}
+
+// TurnPage shows the next page of the device list, or a previous one when
+// delta is negative
+func (dm *DisplayManager) TurnPage(delta int) {
+	if !dm.scrolling() {
+		return
+	}
+	page, pages := dm.page()
+	dm.scroll = max(0, min(pages-1, page+delta)) * dm.PageSize()
+	dm.flush()
+}
+
+// page returns the page of the device list in view and the number of pages.
+// Scrolled to the end, the view is on the last page.
+func (dm *DisplayManager) page() (int, int) {
+	body := len(dm.lines) - headerLines - footerLines
+	visible := dm.PageSize()
+	pages := (body + visible - 1) / visible
+	if dm.scroll >= body-visible {
+		return pages - 1, pages
+	}
+	return dm.scroll / visible, pages
+}
+
+// visibleLines returns the lines of the frame that fit on the screen: the
+// header, the device lines from dm.scroll on and the footer, whose bottom
+// border shows the page. Without tcell nobody can turn the pages, so they
+// turn every pageTurn.
+func (dm *DisplayManager) visibleLines() []string {
+	lines := dm.lines
+	if !dm.scrolling() {
+		dm.scroll = 0
+		return dm.withPreview(lines)
+	}
+
+	body := lines[headerLines : len(lines)-footerLines]
+	visible := dm.PageSize()
+	if dm.screen == nil {
+		_, pages := dm.page()
+		dm.scroll = int(time.Now().Unix()/int64(pageTurn/time.Second)) % pages * visible
+	}
+	dm.scroll = max(0, min(dm.scroll, len(body)-visible))
+
+	scrolled := append([]string{}, lines[:headerLines]...)
+	scrolled = append(scrolled, body[dm.scroll:dm.scroll+visible]...)
+	scrolled = append(scrolled, lines[len(lines)-footerLines:len(lines)-1]...)
+	return dm.withPreview(append(scrolled, dm.pageBorder()))
+}
+
+// withPreview shows the description of the selected device in the top
+// border of the footer, e.g. "fw-msk-1: Rack 12, DC Moscow", replacing the
+// border in lines, which visibleLines owns unless nothing scrolls
+func (dm *DisplayManager) withPreview(lines []string) []string {
+	device := dm.Selected()
+	if device == nil || device.Description == "" || len(lines) < footerLines {
+		return lines
+	}
+
+	label := " " + truncateString(fmt.Sprintf("%s: %s", device.Name, device.Description), max(0, dm.termWidth-8)) + " "
+	border := "├─" + label + strings.Repeat("─", max(0, dm.termWidth-3-displayWidth(label))) + "┤"
+	if dm.ascii {
+		border = asciiBorders.Replace(border)
+	}
+
+	lines = append([]string(nil), lines...)
+	lines[len(lines)-footerLines] = border
+	return lines
+}
+
+// pageBorder is the bottom border with the page in view, e.g.
+// "page 2/5 (PgUp/PgDn)"
+func (dm *DisplayManager) pageBorder() string {
+	page, pages := dm.page()
+	label := fmt.Sprintf(" page %d/%d (PgUp/PgDn) ", page+1, pages)
+	if dm.screen == nil {
+		label = fmt.Sprintf(" page %d/%d ", page+1, pages)
+	}
+
+	fill := max(0, dm.termWidth-2-displayWidth(label)-2)
+	border := "└" + strings.Repeat("─", fill) + label + "──┘"
+	if dm.ascii {
+		border = asciiBorders.Replace(border)
+	}
+	return border
+}
+
+// flush draws the buffered lines onto the screen. tcell only sends the cells
+// that changed since the last frame, in one write.
+func (dm *DisplayManager) flush() {
+	if dm.screen == nil {
+		dm.writeFrame()
+		return
+	}
+
+	lines := dm.visibleLines()
+	dm.screen.Clear()
+	for y, line := range lines {
+		dm.drawLine(0, y, line)
+	}
+	if i := dm.selectedRow(); i >= 0 {
+		if y, visible := dm.screenRow(dm.rows[i].line); visible {
+			dm.highlightRow(y)
+		}
+	}
+	if dm.help {
+		dm.drawOverlay(dm.helpLines())
+	} else if dm.diagnostics {
+		dm.drawOverlay(dm.diagnosticsLines())
+	} else if dm.eventLog {
+		dm.drawOverlay(dm.eventLogLines())
+	} else if dm.versions {
+		dm.drawOverlay(dm.versionLines())
+	} else if dm.stats {
+		dm.drawOverlay(dm.statsLines())
+	} else if group := dm.selectedGroup(); dm.contexts && group != nil {
+		dm.drawOverlay(dm.contextLines(group))
+	} else if device := dm.Selected(); dm.details && device != nil {
+		dm.drawOverlay(dm.detailLines(device))
+	}
+	dm.drawInput()
+	dm.screen.Show()
+}
+
+// drawOverlay draws lines in a box centered over the device list
+func (dm *DisplayManager) drawOverlay(lines []string) {
+	width := 0
+	for _, line := range lines {
+		width = max(width, displayWidth(line))
+	}
+
+	border := strings.Repeat("─", width+2)
+	box := []string{"┌" + border + "┐"}
+	for _, line := range lines {
+		box = append(box, "│ "+line+strings.Repeat(" ", width-displayWidth(line))+" │")
+	}
+	box = append(box, "└"+border+"┘")
+
+	x := max(0, (dm.termWidth-width-4)/2)
+	for i, line := range box {
+		if dm.ascii {
+			line = asciiBorders.Replace(line)
+		}
+		dm.drawLine(x, headerLines+i, line)
+	}
+}
+
+// ToggleDiagnostics shows or hides the connection timing overlay
+func (dm *DisplayManager) ToggleDiagnostics() {
+	dm.diagnostics = !dm.diagnostics
+	dm.flush()
+}
+
+// SetTiming records the phase timings of the latest poll for the
+// diagnostics overlay
+func (dm *DisplayManager) SetTiming(timing *RequestTiming) {
+	dm.timing = timing
+}
+
+// diagnosticsLines describes the latest poll's timings for the overlay
+func (dm *DisplayManager) diagnosticsLines() []string {
+	title := dm.getColor(ColorBold) + "Connection diagnostics" + dm.getColor(ColorReset)
+	hint := dm.getColor(ColorDim) + "d: close" + dm.getColor(ColorReset)
+
+	timing := dm.timing
+	if timing == nil {
+		return []string{title, "", "No request measured yet", "", hint}
+	}
+
+	phase := func(d time.Duration) string {
+		if timing.Reused {
+			return "- (reused connection)"
+		}
+		return roundTiming(d).String()
+	}
+
+	return []string{
+		title,
+		"",
+		fmt.Sprintf("DNS lookup        %s", phase(timing.DNS)),
+		fmt.Sprintf("TCP connect       %s", phase(timing.Connect)),
+		fmt.Sprintf("TLS handshake     %s", phase(timing.TLS)),
+		fmt.Sprintf("Time to 1st byte  %s", roundTiming(timing.TTFB)),
+		fmt.Sprintf("Total             %s", roundTiming(timing.Total)),
+		fmt.Sprintf("Measured at       %s", formatTime(timing.Start, "15:04:05")),
+		"",
+		hint,
+	}
+}
+
+// drawLine puts a line containing SGR color codes onto row y, starting at
+// column x
+func (dm *DisplayManager) drawLine(x, y int, line string) {
+	style := tcell.StyleDefault
+	link := "" // A color reset inside a hyperlink keeps the link
+
+	draw := func(text string) {
+		for text != "" && x < dm.termWidth {
+			var width int
+			text, width = dm.screen.Put(x, y, text, style)
+			if width == 0 {
+				return
+			}
+			x += width
+		}
+	}
+
+	last := 0
+	for _, match := range sgrRegex.FindAllStringSubmatchIndex(line, -1) {
+		draw(line[last:match[0]])
+		if match[2] >= 0 {
+			style = applySGR(style, line[match[2]:match[3]]).Url(link)
+		} else {
+			link = line[match[4]:match[5]]
+			style = style.Url(link)
+		}
+		last = match[1]
+	}
+	draw(line[last:])
+}
+
+// applySGR updates style with the parameters of one SGR sequence
+func applySGR(style tcell.Style, params string) tcell.Style {
+	if params == "" {
+		return tcell.StyleDefault
+	}
+
+	for _, param := range strings.Split(params, ";") {
+		code, err := strconv.Atoi(param)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case code == 0:
+			style = tcell.StyleDefault
+		case code == 1:
+			style = style.Bold(true)
+		case code == 2:
+			style = style.Dim(true)
+		case code >= 30 && code <= 37:
+			style = style.Foreground(tcell.PaletteColor(code - 30))
+		}
+	}
+
+	return style
+}
+
+// Suspend gives the terminal back to the shell before the process stops
+func (dm *DisplayManager) Suspend() {
+	if dm.screen != nil {
+		dm.screen.Suspend()
+	}
+}
+
+// Resume takes the terminal over again after the process continues
+func (dm *DisplayManager) Resume() {
+	if dm.screen == nil {
+		return
+	}
+
+	dm.screen.Resume()
+	dm.Resize()
+}
+
+// RestoreOnPanic puts the terminal back before a panic propagates, so the
+// panic message is readable and the shell stays usable. Use it with defer at
+// the top of every goroutine that can run while the screen is active.
+func (dm *DisplayManager) RestoreOnPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	if dm != nil {
+		dm.RestoreTerminal()
+	}
+	panic(r)
+}
+
+// Resize picks up a new terminal size and repaints the whole screen
+func (dm *DisplayManager) Resize() {
+	if dm.screen == nil {
+		return
+	}
+
+	dm.UpdateTerminalSize()
+	dm.screen.Sync()
+	dm.Redraw()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetSearch shows only the devices whose name, serial number, address, model
+// or description contains query, regardless of case, and selects the first one. A
+// match of the logical device name shows all its devices; an empty query
+// shows everything again.
+func (dm *DisplayManager) SetSearch(query string) {
+	dm.search = strings.TrimSpace(query)
+	dm.scroll = 0
+	dm.Redraw()
+	if dm.Selected() == nil && len(dm.rows) > 0 {
+		dm.selectRow(0)
+	}
+}
+
+// Search returns the query set with SetSearch
+func (dm *DisplayManager) Search() string {
+	return dm.search
+}
+
+// searchGroups returns the groups with the devices matching the search
+func (dm *DisplayManager) searchGroups(groups []LogicalDeviceGroup) []LogicalDeviceGroup {
+	if dm.search == "" {
+		return groups
+	}
+
+	query := strings.ToLower(dm.search)
+	var found []LogicalDeviceGroup
+	for _, group := range groups {
+		if strings.Contains(strings.ToLower(group.LogicalDevice.Name), query) {
+			found = append(found, group)
+			continue
+		}
+
+		var devices []PhysicalDevice
+		for _, device := range group.PhysicalDevices {
+			if matchesSearch(&device, query) {
+				devices = append(devices, device)
+			}
+		}
+		if len(devices) > 0 {
+			group.PhysicalDevices = devices
+			found = append(found, group)
+		}
+	}
+	return found
+}
+
+// unsearched returns the group of the shown data with the same logical
+// device as group, with the devices the search left out
+func (dm *DisplayManager) unsearched(group *LogicalDeviceGroup) *LogicalDeviceGroup {
+	if dm.search == "" || dm.lastData == nil {
+		return group
+	}
+	for i := range dm.lastData.LogicalDeviceGroups {
+		if full := &dm.lastData.LogicalDeviceGroups[i]; full.LogicalDevice.ID == group.LogicalDevice.ID {
+			return full
+		}
+	}
+	return group
+}
+
+// matchesSearch reports whether a field of device contains query, which is
+// lowercase
+func matchesSearch(device *PhysicalDevice, query string) bool {
+	for _, field := range []string{device.Name, device.SerialNumber, device.Address, device.Model, device.Description} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchTitle describes the search for the header, or "" without one
+func (dm *DisplayManager) searchTitle() string {
+	if dm.search == "" {
+		return ""
+	}
+	return fmt.Sprintf(" - Search: %q", dm.search)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readSecretFile reads a secret mounted as a file by Docker or Kubernetes
+// secrets or systemd LoadCredential. The trailing newline editors and
+// `echo` add is not part of the secret.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+
+	return secret, nil
+}
+
+// loadSecretFiles replaces each secret whose *_file setting is set with the
+// contents of that file. A file takes precedence over the plain setting.
+func (cm *ConfigManager) loadSecretFiles() {
+	secrets := []struct {
+		path  string
+		value *string
+	}{
+		{cm.config.PasswordFile, &cm.config.Password},
+		{cm.config.MQTT.PasswordFile, &cm.config.MQTT.Password},
+		{cm.config.Influx.TokenFile, &cm.config.Influx.Token},
+		{cm.config.EventBus.PasswordFile, &cm.config.EventBus.Password},
+		{cm.config.Alerts.PagerDuty.RoutingKeyFile, &cm.config.Alerts.PagerDuty.RoutingKey},
+		{cm.config.Alerts.Opsgenie.APIKeyFile, &cm.config.Alerts.Opsgenie.APIKey},
+		{cm.config.SNMPTrap.AuthPasswordFile, &cm.config.SNMPTrap.AuthPassword},
+		{cm.config.SNMPTrap.PrivPasswordFile, &cm.config.SNMPTrap.PrivPassword},
+		{cm.config.WebPasswordFile, &cm.config.WebPassword},
+		{cm.config.WebTokenFile, &cm.config.WebToken},
+	}
+
+	for _, secret := range secrets {
+		if secret.path == "" {
+			continue
+		}
+
+		value, err := readSecretFile(secret.path)
+		if err != nil {
+			cm.problems = append(cm.problems, err.Error())
+			continue
+		}
+		*secret.value = value
+	}
+}
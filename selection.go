@@ -0,0 +1,199 @@
+package main
+
+// frameRow is a device line of the frame being built, so the arrow keys and
+// the mouse can select devices
+type frameRow struct {
+	line   int // Index in dm.lines
+	device *PhysicalDevice
+	group  *LogicalDeviceGroup // Set on the line of a collapsed group
+}
+
+// Select moves the selection delta device rows down, or up when negative.
+// Without a selection it starts at the first device on screen.
+func (dm *DisplayManager) Select(delta int) {
+	if len(dm.rows) == 0 {
+		dm.Scroll(delta)
+		return
+	}
+
+	i := dm.selectedRow()
+	if i < 0 {
+		i = 0
+		for i < len(dm.rows)-1 {
+			if _, visible := dm.screenRow(dm.rows[i].line); visible {
+				break
+			}
+			i++
+		}
+	} else {
+		i = max(0, min(len(dm.rows)-1, i+delta))
+	}
+	dm.selectRow(i)
+}
+
+// SelectFirst selects the first device of the list, SelectLast the last one
+func (dm *DisplayManager) SelectFirst() {
+	if len(dm.rows) == 0 {
+		dm.Scroll(-len(dm.lines))
+		return
+	}
+	dm.selectRow(0)
+}
+
+func (dm *DisplayManager) SelectLast() {
+	if len(dm.rows) == 0 {
+		dm.Scroll(len(dm.lines))
+		return
+	}
+	dm.selectRow(len(dm.rows) - 1)
+}
+
+// selectRow selects the device of dm.rows[i] and scrolls it into view
+func (dm *DisplayManager) selectRow(i int) {
+	dm.selected = dm.rows[i].device.ID
+	switch i {
+	case 0:
+		// Show the header of the first group too
+		dm.scroll = 0
+	case len(dm.rows) - 1:
+		dm.scroll = len(dm.lines) // flush clamps it to the end
+	default:
+		dm.scrollTo(dm.rows[i].line)
+	}
+	dm.flush()
+}
+
+// SelectAt selects the device drawn on screen row y, e.g. on a mouse click
+func (dm *DisplayManager) SelectAt(y int) {
+	for _, row := range dm.rows {
+		if screenY, visible := dm.screenRow(row.line); visible && screenY == y {
+			dm.selected = row.device.ID
+			dm.flush()
+			return
+		}
+	}
+}
+
+// ToggleGroup collapses the selected device's logical device to a single
+// line, or expands it again. The line of a collapsed group selects its first
+// device, so the selection moves there.
+func (dm *DisplayManager) ToggleGroup() {
+	group := dm.selectedGroup()
+	if group == nil || len(group.PhysicalDevices) == 0 {
+		return
+	}
+
+	collapse := !dm.isCollapsed(group)
+	dm.collapsed[group.LogicalDevice.ID] = collapse
+	if collapse {
+		dm.selected = group.PhysicalDevices[0].ID
+	}
+	dm.Redraw()
+}
+
+// ToggleCollapseHealthy switches collapsing the groups without problems on
+// or off, forgetting which groups were collapsed or expanded one by one. It
+// returns the new setting; the caller redraws.
+func (dm *DisplayManager) ToggleCollapseHealthy() bool {
+	dm.collapseHealthy = !dm.collapseHealthy
+	clear(dm.collapsed)
+	return dm.collapseHealthy
+}
+
+// isCollapsed reports whether group is drawn as a single line: as chosen
+// with the space key, otherwise with -collapse_healthy while all its devices
+// are connected and healthy
+func (dm *DisplayManager) isCollapsed(group *LogicalDeviceGroup) bool {
+	if len(group.PhysicalDevices) == 0 {
+		return false
+	}
+	if collapsed, chosen := dm.collapsed[group.LogicalDevice.ID]; chosen {
+		return collapsed
+	}
+	_, class := worstState(group)
+	return dm.collapseHealthy && class == classOK
+}
+
+// ClearSelection removes the selection highlight and closes the details
+// and the virtual contexts
+func (dm *DisplayManager) ClearSelection() {
+	dm.selected = ""
+	dm.details = false
+	dm.contexts = false
+	dm.flush()
+}
+
+// Selected returns the selected device, or nil when nothing is selected or
+// the device is no longer listed
+func (dm *DisplayManager) Selected() *PhysicalDevice {
+	if i := dm.selectedRow(); i >= 0 {
+		return dm.rows[i].device
+	}
+	return nil
+}
+
+func (dm *DisplayManager) selectedRow() int {
+	if dm.selected == "" {
+		return -1
+	}
+	for i, row := range dm.rows {
+		if row.device.ID == dm.selected {
+			return i
+		}
+	}
+	// A device of a group collapsed since it was selected is on the
+	// group's line
+	for i, row := range dm.rows {
+		if row.group == nil {
+			continue
+		}
+		for _, device := range row.group.PhysicalDevices {
+			if device.ID == dm.selected {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// scrolling reports whether the frame is taller than the screen, so its
+// device lines scroll between the header and footer
+func (dm *DisplayManager) scrolling() bool {
+	return len(dm.lines) > dm.termHeight && dm.termHeight > headerLines+footerLines
+}
+
+// screenRow returns the screen row on which line of the frame is drawn, and
+// whether it is visible at the current scroll position
+func (dm *DisplayManager) screenRow(line int) (int, bool) {
+	if !dm.scrolling() {
+		return line, line < dm.termHeight
+	}
+
+	body := line - headerLines - dm.scroll
+	if body < 0 || body >= dm.PageSize() {
+		return 0, false
+	}
+	return headerLines + body, true
+}
+
+// scrollTo scrolls just enough to bring line of the frame into view
+func (dm *DisplayManager) scrollTo(line int) {
+	if !dm.scrolling() {
+		return
+	}
+
+	body := line - headerLines
+	if body < dm.scroll {
+		dm.scroll = body
+	} else if body >= dm.scroll+dm.PageSize() {
+		dm.scroll = body - dm.PageSize() + 1
+	}
+}
+
+// highlightRow shows screen row y in reverse video, inside the outer border
+func (dm *DisplayManager) highlightRow(y int) {
+	for x := 1; x < dm.termWidth-1; x++ {
+		primary, combining, style, _ := dm.screen.GetContent(x, y)
+		dm.screen.SetContent(x, y, primary, combining, style.Reverse(true))
+	}
+}
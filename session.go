@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sessionRenewMargin is how long before the session expires it is renewed;
+// lifetimes shorter than four margins renew at three quarters instead
+const sessionRenewMargin = time.Minute
+
+// sessionExpiry works out when the session behind the Authorization cookie
+// ends: from the cookie's Max-Age or Expires attributes, or from the exp claim
+// when the cookie holds a JWT. It returns the zero time when unknown.
+func sessionExpiry(cookie *http.Cookie, issued time.Time) time.Time {
+	switch {
+	case cookie.MaxAge > 0:
+		return issued.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		return cookie.Expires
+	}
+
+	return jwtExpiry(strings.TrimPrefix(cookie.Value, "Bearer "))
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
+
+// sessionRenewAt returns when a session issued at issued and ending at expiry
+// should be renewed, and false when there is nothing to go by
+func sessionRenewAt(issued, expiry time.Time, interval time.Duration) (time.Time, bool) {
+	var renewAt time.Time
+
+	if !expiry.IsZero() {
+		lifetime := expiry.Sub(issued)
+		margin := sessionRenewMargin
+		if lifetime < 4*margin {
+			margin = lifetime / 4
+		}
+		renewAt = expiry.Add(-margin)
+	}
+
+	if interval > 0 {
+		if scheduled := issued.Add(interval); renewAt.IsZero() || scheduled.Before(renewAt) {
+			renewAt = scheduled
+		}
+	}
+
+	return renewAt, !renewAt.IsZero()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// PollResult is what the scheduler hands to exporters after every poll
+type PollResult struct {
+	Time    time.Time
+	Data    *GroupedDevices // nil when the poll failed
+	Events  []DeviceEvent   // Changes since the previous successful poll
+	Latency time.Duration
+	Err     error
+}
+
+// Exporter receives every poll result, e.g. to store time series or state
+type Exporter interface {
+	Export(result PollResult)
+	Close()
+}
+
+// Notifier receives device events only, e.g. to alert or stream changes
+type Notifier interface {
+	Notify(events []DeviceEvent)
+	Close()
+}
+
+// StateWatcher is an optional interface of exporters that also want the
+// data re-annotated between polls, e.g. with new probe results or acks
+type StateWatcher interface {
+	Watch(data *GroupedDevices)
+}
+
+// UnchangedWatcher is an optional interface of exporters that also want to
+// know about successful polls that returned nothing new (304 Not Modified),
+// which are not exported
+type UnchangedWatcher interface {
+	Unchanged()
+}
+
+// LeaderWatcher is an optional interface of leader-only exporters that also
+// act between polls, e.g. on a timer, and must hold back while another
+// instance leads
+type LeaderWatcher interface {
+	SetLeader(leader *LeaderElection)
+}
+
+// Sink factories return nil (and no error) when their section of the
+// config is not set, so every registered sink is optional
+type (
+	exporterFactory func(config *Config) (Exporter, error)
+	notifierFactory func(config *Config) (Notifier, error)
+)
+
+type exporterRegistration struct {
+	name    string
+	factory exporterFactory
+}
+
+type notifierRegistration struct {
+	name    string
+	factory notifierFactory
+}
+
+var (
+	exporterRegistry []exporterRegistration
+	notifierRegistry []notifierRegistration
+)
+
+// registerExporter makes an exporter available; call it from init()
+func registerExporter(name string, factory exporterFactory) {
+	exporterRegistry = append(exporterRegistry, exporterRegistration{name, factory})
+}
+
+// registerNotifier makes a notifier available; call it from init()
+func registerNotifier(name string, factory notifierFactory) {
+	notifierRegistry = append(notifierRegistry, notifierRegistration{name, factory})
+}
+
+// leaderOnlyExporters notify people or other systems, like the notifiers,
+// and run only on the leader when monitors share a -leader_lock. The other
+// exporters keep the state and metrics of each monitor.
+var leaderOnlyExporters = []string{"alerts", "exec_hook"}
+
+// Sinks fans poll results out to all configured exporters and notifiers
+type Sinks struct {
+	exporters []Exporter
+	alerting  []Exporter // Of leaderOnlyExporters
+	notifiers []Notifier
+	leader    *LeaderElection // nil without -leader_lock
+}
+
+// NewSinks creates every registered sink enabled in config
+func NewSinks(config *Config) (*Sinks, error) {
+	sinks := &Sinks{}
+
+	for _, registration := range exporterRegistry {
+		exporter, err := registration.factory(config)
+		if err != nil {
+			sinks.Close()
+			return nil, fmt.Errorf("failed to configure %s exporter: %w", registration.name, err)
+		}
+		switch {
+		case exporter == nil:
+		case slices.Contains(leaderOnlyExporters, registration.name):
+			sinks.alerting = append(sinks.alerting, exporter)
+		default:
+			sinks.exporters = append(sinks.exporters, exporter)
+		}
+	}
+
+	for _, registration := range notifierRegistry {
+		notifier, err := registration.factory(config)
+		if err != nil {
+			sinks.Close()
+			return nil, fmt.Errorf("failed to configure %s notifier: %w", registration.name, err)
+		}
+		if notifier != nil {
+			sinks.notifiers = append(sinks.notifiers, notifier)
+		}
+	}
+
+	if config.LeaderLock != "" {
+		leader, err := NewLeaderElection(config)
+		if err != nil {
+			sinks.Close()
+			return nil, fmt.Errorf("failed to configure leader election: %w", err)
+		}
+		sinks.leader = leader
+		for _, exporter := range sinks.alerting {
+			if watcher, ok := exporter.(LeaderWatcher); ok {
+				watcher.SetLeader(leader)
+			}
+		}
+	}
+
+	return sinks, nil
+}
+
+// exportersNow returns the exporters to pass a result to: all of them on
+// the leader, only those of the monitor itself on the others
+func (s *Sinks) exportersNow() []Exporter {
+	if !s.leader.Leading() {
+		return s.exporters
+	}
+	return append(slices.Clip(s.exporters), s.alerting...)
+}
+
+// Dispatch passes result to all exporters and its events to all notifiers,
+// except the events of acknowledged devices. With -leader_lock only the
+// leader alerts and notifies. Sinks must not block; slow ones are expected
+// to queue internally.
+func (s *Sinks) Dispatch(result PollResult) {
+	for _, exporter := range s.exportersNow() {
+		exporter.Export(result)
+	}
+
+	var alerts []DeviceEvent
+	for _, event := range result.Events {
+		if !event.Acknowledged {
+			alerts = append(alerts, event)
+		}
+	}
+	if len(alerts) == 0 || !s.leader.Leading() {
+		return
+	}
+	for _, notifier := range s.notifiers {
+		notifier.Notify(alerts)
+	}
+}
+
+// Watch passes data re-annotated between polls to the exporters that
+// implement StateWatcher
+func (s *Sinks) Watch(data *GroupedDevices) {
+	for _, exporter := range s.exportersNow() {
+		if watcher, ok := exporter.(StateWatcher); ok {
+			watcher.Watch(data)
+		}
+	}
+}
+
+// Unchanged tells the exporters that implement UnchangedWatcher about a
+// successful poll without changes
+func (s *Sinks) Unchanged() {
+	for _, exporter := range s.exportersNow() {
+		if watcher, ok := exporter.(UnchangedWatcher); ok {
+			watcher.Unchanged()
+		}
+	}
+}
+
+// Close flushes and stops all sinks
+func (s *Sinks) Close() {
+	for _, exporter := range append(s.exporters, s.alerting...) {
+		exporter.Close()
+	}
+	for _, notifier := range s.notifiers {
+		notifier.Close()
+	}
+	if s.leader != nil {
+		s.leader.Close()
+	}
+	s.exporters = nil
+	s.alerting = nil
+	s.notifiers = nil
+	s.leader = nil
+}
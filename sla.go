@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultSLAPeriod is reported when -from is not given
+const defaultSLAPeriod = 30 * 24 * time.Hour
+
+// reportTimeLayouts are accepted by -from and -to, in local time unless the
+// value has a zone
+var reportTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// SLAStats is the availability of a device, or of a logical device, which
+// is up while at least one of its members is connected
+type SLAStats struct {
+	Name          string
+	LogicalDevice string        // Empty for logical devices
+	Monitored     time.Duration // Time covered by the history
+	Up            time.Duration
+	LongestOutage time.Duration
+	OutageStart   time.Time // Start of the longest outage
+	Transitions   int       // Connection state changes
+}
+
+// Availability is the percentage of the monitored time the device was up,
+// or -1 when it was not monitored at all
+func (s SLAStats) Availability() float64 {
+	if s.Monitored <= 0 {
+		return -1
+	}
+	return 100 * float64(s.Up) / float64(s.Monitored)
+}
+
+// GetAvailabilityDisplay formats Availability, e.g. "99.982%"
+func (s SLAStats) GetAvailabilityDisplay() string {
+	availability := s.Availability()
+	if availability < 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(availability, 'f', 3, 64) + "%"
+}
+
+// GetLongestOutageDisplay describes the longest outage and when it started
+func (s SLAStats) GetLongestOutageDisplay() string {
+	if s.LongestOutage <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%s (from %s)", s.LongestOutage.Round(time.Second), formatTime(s.OutageStart, "2006-01-02 15:04"))
+}
+
+// SLAReport is the availability of every device and logical device seen in
+// the history between From and To
+type SLAReport struct {
+	From           time.Time
+	To             time.Time
+	Devices        []SLAStats
+	LogicalDevices []SLAStats
+}
+
+// slaTracker accumulates the stats of one device over the history records
+type slaTracker struct {
+	stats   SLAStats
+	state   string    // Last state seen, for counting transitions
+	outage  time.Time // Start of the current outage; zero while up
+	lastEnd time.Time // End of the last counted interval
+}
+
+// add counts the interval from start to end, in which the device was up or
+// not. An outage continues only across adjacent intervals; time that was not
+// monitored ends it.
+func (t *slaTracker) add(start, end time.Time, up bool) {
+	t.stats.Monitored += end.Sub(start)
+	if up {
+		t.stats.Up += end.Sub(start)
+		t.outage = time.Time{}
+	} else {
+		if t.outage.IsZero() || !start.Equal(t.lastEnd) {
+			t.outage = start
+		}
+		if outage := end.Sub(t.outage); outage > t.stats.LongestOutage {
+			t.stats.LongestOutage = outage
+			t.stats.OutageStart = t.outage
+		}
+	}
+	t.lastEnd = end
+}
+
+// see records the state of the device in a record at time at, counting
+// a transition if it changed within the report period
+func (t *slaTracker) see(state string, at, from, to time.Time) {
+	if t.state != "" && t.state != state && !at.Before(from) && at.Before(to) {
+		t.stats.Transitions++
+	}
+	t.state = state
+}
+
+// ParseReportTime parses a -from or -to value: a date, a date and time, or
+// RFC 3339
+func ParseReportTime(value string) (time.Time, error) {
+	for _, layout := range reportTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use e.g. 2026-01-31, \"2026-01-31 18:00\" or RFC 3339)", value)
+}
+
+// NewSLAReport computes availability from the history records between from
+// and to. Each record stands for the time until the next one, at most
+// historyMaxGap; records of failed polls and longer gaps are not monitored.
+func NewSLAReport(records []HistoryRecord, from, to time.Time) SLAReport {
+	now := time.Now()
+	devices := make(map[string]*slaTracker)
+	logical := make(map[string]*slaTracker)
+	tracker := func(trackers map[string]*slaTracker, key string) *slaTracker {
+		if trackers[key] == nil {
+			trackers[key] = &slaTracker{}
+		}
+		return trackers[key]
+	}
+
+	for i, record := range records {
+		end := record.Time.Add(historyMaxGap)
+		if i+1 < len(records) && records[i+1].Time.Before(end) {
+			end = records[i+1].Time
+		}
+		start := maxTime(record.Time, from)
+		end = minTime(end, minTime(to, now))
+		counted := record.Error == "" && end.After(start)
+
+		groups := make(map[string]bool)
+		for _, device := range record.Devices {
+			up := device.State == "CONNECTED"
+			t := tracker(devices, device.ID)
+			t.stats.Name = device.Name
+			t.stats.LogicalDevice = device.LogicalDevice
+			t.see(device.State, record.Time, from, to)
+			if counted {
+				t.add(start, end, up)
+			}
+			groups[device.LogicalDevice] = groups[device.LogicalDevice] || up
+		}
+
+		for name, up := range groups {
+			t := tracker(logical, name)
+			t.stats.Name = name
+			state := "DOWN"
+			if up {
+				state = "UP"
+			}
+			t.see(state, record.Time, from, to)
+			if counted {
+				t.add(start, end, up)
+			}
+		}
+	}
+
+	report := SLAReport{From: from, To: to}
+	for _, t := range devices {
+		if t.stats.Monitored > 0 || t.stats.Transitions > 0 {
+			report.Devices = append(report.Devices, t.stats)
+		}
+	}
+	for _, t := range logical {
+		if t.stats.Monitored > 0 || t.stats.Transitions > 0 {
+			report.LogicalDevices = append(report.LogicalDevices, t.stats)
+		}
+	}
+	sort.Slice(report.Devices, func(i, j int) bool {
+		a, b := report.Devices[i], report.Devices[j]
+		if a.LogicalDevice != b.LogicalDevice {
+			return a.LogicalDevice < b.LogicalDevice
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(report.LogicalDevices, func(i, j int) bool {
+		return report.LogicalDevices[i].Name < report.LogicalDevices[j].Name
+	})
+	return report
+}
+
+// minutesDisplay formats d rounded to minutes, e.g. "719h58m"
+func minutesDisplay(d time.Duration) string {
+	if d = d.Round(time.Minute); d == 0 {
+		return "0m"
+	}
+	return strings.TrimSuffix(d.String(), "0s")
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// WriteSLAReport renders report as text, csv or html
+func WriteSLAReport(w io.Writer, format string, report SLAReport) error {
+	switch format {
+	case "text":
+		return writeSLAText(w, report)
+	case "csv":
+		return writeSLACSV(w, report)
+	case "html":
+		return slaHTMLTemplate.Execute(w, report)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+func writeSLAText(w io.Writer, report SLAReport) error {
+	fmt.Fprintf(w, "Availability from %s to %s\n\n", formatTime(report.From, "2006-01-02 15:04"), formatTime(report.To, "2006-01-02 15:04"))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOGICAL DEVICE\tAVAILABILITY\tMONITORED\tLONGEST OUTAGE\tTRANSITIONS")
+	for _, stats := range report.LogicalDevices {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\n", stats.Name, stats.GetAvailabilityDisplay(),
+			minutesDisplay(stats.Monitored), stats.GetLongestOutageDisplay(), stats.Transitions)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOGICAL DEVICE\tDEVICE\tAVAILABILITY\tMONITORED\tLONGEST OUTAGE\tTRANSITIONS")
+	for _, stats := range report.Devices {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\n", stats.LogicalDevice, stats.Name, stats.GetAvailabilityDisplay(),
+			minutesDisplay(stats.Monitored), stats.GetLongestOutageDisplay(), stats.Transitions)
+	}
+	return tw.Flush()
+}
+
+// writeSLACSV writes logical devices and devices as one table, told apart
+// by the type column
+func writeSLACSV(w io.Writer, report SLAReport) error {
+	writer := csv.NewWriter(w)
+	header := []string{"type", "logical_device", "device", "availability_percent", "monitored_seconds",
+		"up_seconds", "longest_outage_seconds", "longest_outage_start", "transitions"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	row := func(kind, logicalDevice, device string, stats SLAStats) []string {
+		availability := ""
+		if stats.Availability() >= 0 {
+			availability = strconv.FormatFloat(stats.Availability(), 'f', 3, 64)
+		}
+		outageStart := ""
+		if !stats.OutageStart.IsZero() {
+			outageStart = stats.OutageStart.Format(time.RFC3339)
+		}
+		return []string{kind, logicalDevice, device, availability,
+			strconv.Itoa(int(stats.Monitored.Seconds())), strconv.Itoa(int(stats.Up.Seconds())),
+			strconv.Itoa(int(stats.LongestOutage.Seconds())), outageStart, strconv.Itoa(stats.Transitions)}
+	}
+	for _, stats := range report.LogicalDevices {
+		if err := writer.Write(row("logical_device", stats.Name, "", stats)); err != nil {
+			return err
+		}
+	}
+	for _, stats := range report.Devices {
+		if err := writer.Write(row("device", stats.LogicalDevice, stats.Name, stats)); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+var slaHTMLTemplate = template.Must(template.New("sla").Funcs(template.FuncMap{
+	"time":    func(t time.Time) string { return formatTime(t, "2006-01-02 15:04") },
+	"minutes": minutesDisplay,
+	"availabilityClass": func(stats SLAStats) string {
+		switch availability := stats.Availability(); {
+		case availability < 0:
+			return "meta"
+		case availability >= 99.9:
+			return "ok"
+		case availability >= 99:
+			return "warn"
+		default:
+			return "bad"
+		}
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Availability Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+.ok { color: #1a7f37; }
+.warn { color: #b58100; }
+.bad { color: #cf222e; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>Availability Report</h1>
+<p class="meta">From {{time .From}} to {{time .To}}</p>
+<h2>Logical devices</h2>
+<table>
+<tr><th>Logical Device</th><th>Availability</th><th>Monitored</th><th>Longest Outage</th><th>Transitions</th></tr>
+{{range .LogicalDevices}}<tr><td>{{.Name}}</td><td class="{{availabilityClass .}}">{{.GetAvailabilityDisplay}}</td><td>{{minutes .Monitored}}</td><td>{{.GetLongestOutageDisplay}}</td><td>{{.Transitions}}</td></tr>
+{{else}}<tr><td colspan="5">No history in this period</td></tr>
+{{end}}</table>
+<h2>Devices</h2>
+<table>
+<tr><th>Logical Device</th><th>Device Name</th><th>Availability</th><th>Monitored</th><th>Longest Outage</th><th>Transitions</th></tr>
+{{range .Devices}}<tr><td>{{.LogicalDevice}}</td><td>{{.Name}}</td><td class="{{availabilityClass .}}">{{.GetAvailabilityDisplay}}</td><td>{{minutes .Monitored}}</td><td>{{.GetLongestOutageDisplay}}</td><td>{{.Transitions}}</td></tr>
+{{else}}<tr><td colspan="6">No history in this period</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// runSLAReport writes the availability report of the -history_file
+func (app *Application) runSLAReport() error {
+	format, err := app.oneShotFormat("text", "text", "csv", "html")
+	if err != nil {
+		return err
+	}
+	if app.config.HistoryFile == "" {
+		return fmt.Errorf("report sla needs -history_file, written by a running monitor")
+	}
+
+	to := time.Now()
+	if app.config.ReportTo != "" {
+		if to, err = ParseReportTime(app.config.ReportTo); err != nil {
+			return err
+		}
+	}
+	from := to.Add(-defaultSLAPeriod)
+	if app.config.ReportFrom != "" {
+		if from, err = ParseReportTime(app.config.ReportFrom); err != nil {
+			return err
+		}
+	}
+
+	records, err := ReadHistory(app.config.HistoryFile)
+	if err != nil {
+		return err
+	}
+
+	out, closeOutput, err := app.createOutput()
+	if err != nil {
+		return err
+	}
+	defer closeOutput()
+
+	if err := WriteSLAReport(out, format, NewSLAReport(records, from, to)); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Snapshot is the on-disk representation of the device list at a point in time
+type Snapshot struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Devices   *GroupedDevices `json:"devices"`
+}
+
+// WriteSnapshot saves data to a new timestamped file in dir using the given
+// format ("json" or "csv") and returns the path of the written file
+func WriteSnapshot(dir, format string, data *GroupedDevices) (string, error) {
+	if data == nil {
+		return "", fmt.Errorf("no data to export yet")
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("snapshot-%s.%s", now.Format("20060102-150405"), format))
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer file.Close()
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(Snapshot{Timestamp: now, Devices: data})
+	case "csv":
+		err = writeSnapshotCSV(file, now, data)
+	default:
+		err = fmt.Errorf("unsupported snapshot format: %s", format)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return path, nil
+}
+
+// writeSnapshotCSV writes one row per physical device
+func writeSnapshotCSV(file *os.File, timestamp time.Time, data *GroupedDevices) error {
+	writer := csv.NewWriter(file)
+
+	header := []string{
+		"timestamp", "logical_device", "topology", "device_name", "model", "serial_number",
+		"connection_state", "health_status", "address", "role", "priority", "version", "last_connected",
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, group := range data.LogicalDeviceGroups {
+		for _, device := range group.PhysicalDevices {
+			priority := ""
+			if device.AsNode != nil {
+				priority = strconv.Itoa(device.AsNode.Priority)
+			}
+
+			row := []string{
+				inDisplayZone(timestamp).Format(time.RFC3339),
+				group.LogicalDevice.Name,
+				group.GetTopologyDisplayName(),
+				device.Name,
+				device.Model,
+				device.SerialNumber,
+				device.GetConnectionStateDisplay(),
+				device.GetHealthStatusDisplay(),
+				device.Address,
+				device.GetRoleDisplay(),
+				priority,
+				device.ProductVersion,
+				device.LastConnectedAt,
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SNMPTrapConfig sends device events as SNMP traps to a legacy NMS
+type SNMPTrapConfig struct {
+	Target    string `json:"target"`     // Trap receiver, host or host:port (default port: 162)
+	Version   string `json:"version"`    // 2c (default) or 3
+	Community string `json:"community"`  // SNMPv2c community (default: public)
+	OIDPrefix string `json:"oid_prefix"` // Enterprise OID the traps and their objects are under, e.g. 1.3.6.1.4.1.12345.1
+
+	// SNMPv3 user-based security. The receiver needs the user with the same
+	// engine ID, e.g. createUser -e <engine_id> in snmptrapd.conf.
+	EngineID         string `json:"engine_id"` // Hex, 5 to 32 bytes, e.g. 80001f8804707464
+	Username         string `json:"username"`
+	AuthProtocol     string `json:"auth_protocol"` // MD5, SHA (default) or SHA256; empty password: no authentication
+	AuthPassword     string `json:"auth_password"`
+	AuthPasswordFile string `json:"auth_password_file"` // Read the auth password from this file
+	PrivProtocol     string `json:"priv_protocol"`      // AES (128-bit); empty password: no encryption
+	PrivPassword     string `json:"priv_password"`
+	PrivPasswordFile string `json:"priv_password_file"` // Read the privacy password from this file
+}
+
+// Traps sent, under <oid_prefix>.0
+const (
+	trapDisconnected   = 1
+	trapConnected      = 2 // Clears trapDisconnected
+	trapFailover       = 3
+	trapHealthCritical = 4
+	trapHealthCleared  = 5 // Clears trapHealthCritical
+)
+
+// Objects of every trap, under <oid_prefix>.1
+const (
+	trapObjectDevice = iota + 1
+	trapObjectLogicalDevice
+	trapObjectDeviceID
+	trapObjectFrom
+	trapObjectTo
+)
+
+// maxQueuedTraps bounds the traps waiting to be sent
+const maxQueuedTraps = 256
+
+// Standard OIDs of an SNMPv2 trap
+var (
+	oidSysUpTime   = []uint32{1, 3, 6, 1, 2, 1, 1, 3, 0}
+	oidSNMPTrapOID = []uint32{1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0}
+)
+
+// snmpAuthProtocols are the SNMPv3 authentication protocols by name, with the
+// length of their truncated HMAC
+var snmpAuthProtocols = map[string]struct {
+	hash   func() hash.Hash
+	macLen int
+}{
+	"MD5":    {md5.New, 12},
+	"SHA":    {sha1.New, 12},
+	"SHA256": {sha256.New, 24},
+}
+
+// SNMPTrapSender sends a trap for disconnects, failovers and health changes
+// to and from CRITICAL, and the reconnects that clear them. Traps are sent
+// on a background goroutine over UDP, so an unreachable receiver never
+// delays polling.
+type SNMPTrapSender struct {
+	config    SNMPTrapConfig
+	prefix    []uint32
+	engineID  []byte
+	authKey   []byte
+	privKey   []byte
+	started   time.Time
+	requestID atomic.Int32
+	salt      atomic.Uint64
+	conn      net.Conn
+	queue     chan snmpTrap
+	done      chan struct{}
+}
+
+// snmpTrap is one trap: its number under <oid_prefix>.0 and the event
+type snmpTrap struct {
+	number int
+	event  DeviceEvent
+}
+
+func init() {
+	registerNotifier("snmp_trap", func(config *Config) (Notifier, error) {
+		if config.SNMPTrap.Target == "" {
+			return nil, nil
+		}
+		return NewSNMPTrapSender(config.SNMPTrap)
+	})
+}
+
+func NewSNMPTrapSender(config SNMPTrapConfig) (*SNMPTrapSender, error) {
+	if config.Version == "" {
+		config.Version = "2c"
+	}
+	if config.Community == "" {
+		config.Community = "public"
+	}
+	config.Target = withDefaultPort(config.Target, "162")
+
+	prefix, err := parseOID(config.OIDPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("snmp_trap.oid_prefix: %w", err)
+	}
+	ts := &SNMPTrapSender{
+		config:  config,
+		prefix:  prefix,
+		started: time.Now(),
+		queue:   make(chan snmpTrap, maxQueuedTraps),
+		done:    make(chan struct{}),
+	}
+
+	switch config.Version {
+	case "2c":
+	case "3":
+		if err := ts.setupUSM(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported snmp_trap.version %q (use 2c or 3)", config.Version)
+	}
+
+	var start [12]byte
+	rand.Read(start[:])
+	ts.requestID.Store(int32(binary.BigEndian.Uint32(start[:4]) & 0x7fffffff))
+	ts.salt.Store(binary.BigEndian.Uint64(start[4:]))
+
+	go ts.run()
+
+	return ts, nil
+}
+
+// setupUSM checks the SNMPv3 settings and localizes the keys to the engine ID
+func (ts *SNMPTrapSender) setupUSM() error {
+	config := ts.config
+	engineID, err := hex.DecodeString(strings.TrimPrefix(config.EngineID, "0x"))
+	if err != nil || len(engineID) < 5 || len(engineID) > 32 {
+		return fmt.Errorf("snmp_trap.engine_id must be 5 to 32 bytes in hex for SNMPv3, e.g. 80001f8804707464")
+	}
+	if config.Username == "" {
+		return fmt.Errorf("snmp_trap.username is required for SNMPv3")
+	}
+	ts.engineID = engineID
+
+	if config.AuthPassword == "" {
+		if config.PrivPassword != "" {
+			return fmt.Errorf("snmp_trap.priv_password needs an auth_password: SNMPv3 does not encrypt without authentication")
+		}
+		return nil
+	}
+	if config.AuthProtocol == "" {
+		config.AuthProtocol = "SHA"
+	}
+	auth, ok := snmpAuthProtocols[strings.ToUpper(config.AuthProtocol)]
+	if !ok {
+		return fmt.Errorf("unsupported snmp_trap.auth_protocol %q (use MD5, SHA or SHA256)", config.AuthProtocol)
+	}
+	if len(config.AuthPassword) < 8 {
+		return fmt.Errorf("snmp_trap.auth_password must have at least 8 characters")
+	}
+	ts.config.AuthProtocol = strings.ToUpper(config.AuthProtocol)
+	ts.authKey = localizedKey(auth.hash, config.AuthPassword, engineID)
+
+	if config.PrivPassword == "" {
+		return nil
+	}
+	if config.PrivProtocol != "" && !strings.EqualFold(config.PrivProtocol, "AES") {
+		return fmt.Errorf("unsupported snmp_trap.priv_protocol %q (use AES)", config.PrivProtocol)
+	}
+	if len(config.PrivPassword) < 8 {
+		return fmt.Errorf("snmp_trap.priv_password must have at least 8 characters")
+	}
+	ts.privKey = localizedKey(auth.hash, config.PrivPassword, engineID)[:16]
+	return nil
+}
+
+// Notify queues the traps of events
+func (ts *SNMPTrapSender) Notify(events []DeviceEvent) {
+	for _, event := range events {
+		number := trapNumber(event)
+		if number == 0 {
+			continue
+		}
+
+		select {
+		case ts.queue <- snmpTrap{number, event}:
+		default:
+			logBackground("snmp trap: queue full, dropped %s event of %s", event.Type, event.DeviceName)
+		}
+	}
+}
+
+// trapNumber returns the trap of event, or 0 when event is not sent
+func trapNumber(event DeviceEvent) int {
+	if event.Type == EventFailover {
+		return trapFailover
+	}
+	if event.Type != EventStateChanged {
+		return 0
+	}
+
+	switch {
+	case event.Field == "connection_state" && event.To == "DISCONNECTED":
+		return trapDisconnected
+	case event.Field == "connection_state" && event.From == "DISCONNECTED":
+		return trapConnected
+	case event.Field == "health_status" && event.To == "CRITICAL":
+		return trapHealthCritical
+	case event.Field == "health_status" && event.From == "CRITICAL":
+		return trapHealthCleared
+	}
+	return 0
+}
+
+// Close sends the queued traps
+func (ts *SNMPTrapSender) Close() {
+	close(ts.queue)
+	<-ts.done
+}
+
+func (ts *SNMPTrapSender) run() {
+	defer close(ts.done)
+	for trap := range ts.queue {
+		if err := ts.send(trap); err != nil {
+			logBackground("snmp trap: %s event of %s: %v", trap.event.Type, trap.event.DeviceName, err)
+		}
+	}
+	if ts.conn != nil {
+		ts.conn.Close()
+	}
+}
+
+// send encodes trap and sends it, resolving the receiver on first use and
+// again after an error
+func (ts *SNMPTrapSender) send(trap snmpTrap) error {
+	message, err := ts.encode(trap)
+	if err != nil {
+		return err
+	}
+
+	if ts.conn == nil {
+		conn, err := net.DialTimeout("udp", ts.config.Target, 10*time.Second)
+		if err != nil {
+			return fmt.Errorf("failed to reach trap receiver: %w", err)
+		}
+		ts.conn = conn
+	}
+	ts.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := ts.conn.Write(message); err != nil {
+		ts.conn.Close()
+		ts.conn = nil
+		return fmt.Errorf("failed to send trap: %w", err)
+	}
+	return nil
+}
+
+// encode builds the SNMPv2-Trap PDU of trap and wraps it in a message of
+// the configured version
+func (ts *SNMPTrapSender) encode(trap snmpTrap) ([]byte, error) {
+	event := trap.event
+	object := func(n int) []uint32 { return appendOID(ts.prefix, 1, uint32(n)) }
+	uptime := time.Since(ts.started) / (10 * time.Millisecond)
+
+	varbinds := berTLV(0x30,
+		snmpVarbind(oidSysUpTime, berUint(0x43, uint64(uint32(uptime)))),
+		snmpVarbind(oidSNMPTrapOID, berTLV(0x06, berOID(appendOID(ts.prefix, 0, uint32(trap.number))))),
+		snmpVarbind(object(trapObjectDevice), berTLV(0x04, []byte(event.DeviceName))),
+		snmpVarbind(object(trapObjectLogicalDevice), berTLV(0x04, []byte(event.LogicalDevice))),
+		snmpVarbind(object(trapObjectDeviceID), berTLV(0x04, []byte(event.DeviceID))),
+		snmpVarbind(object(trapObjectFrom), berTLV(0x04, []byte(event.From))),
+		snmpVarbind(object(trapObjectTo), berTLV(0x04, []byte(event.To))),
+	)
+	requestID := ts.requestID.Add(1) & 0x7fffffff
+	pdu := berTLV(0xa7, berUint(0x02, uint64(requestID)), berUint(0x02, 0), berUint(0x02, 0), varbinds)
+
+	if ts.config.Version == "2c" {
+		return berTLV(0x30, berUint(0x02, 1), berTLV(0x04, []byte(ts.config.Community)), pdu), nil
+	}
+	return ts.encodeV3(pdu, uint64(requestID))
+}
+
+// encodeV3 wraps pdu in an SNMPv3 message with user-based security (RFC
+// 3414), encrypted with AES (RFC 3826) and authenticated as configured. The
+// monitor is the authoritative engine of its traps: boots is always 1 and
+// the engine time counts from the start.
+func (ts *SNMPTrapSender) encodeV3(pdu []byte, messageID uint64) ([]byte, error) {
+	const boots = 1
+	engineTime := uint32(time.Since(ts.started) / time.Second)
+
+	flags := byte(0)
+	scoped := berTLV(0x30, berTLV(0x04, ts.engineID), berTLV(0x04, nil), pdu)
+	var privParams []byte
+	if ts.privKey != nil {
+		flags |= 0x02
+		privParams = binary.BigEndian.AppendUint64(nil, ts.salt.Add(1))
+		iv := binary.BigEndian.AppendUint32(nil, boots)
+		iv = binary.BigEndian.AppendUint32(iv, engineTime)
+		iv = append(iv, privParams...)
+
+		block, err := aes.NewCipher(ts.privKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt trap: %w", err)
+		}
+		encrypted := make([]byte, len(scoped))
+		cipher.NewCFBEncrypter(block, iv).XORKeyStream(encrypted, scoped)
+		scoped = berTLV(0x04, encrypted)
+	}
+	var authParams []byte
+	if ts.authKey != nil {
+		flags |= 0x01
+		authParams = make([]byte, snmpAuthProtocols[ts.config.AuthProtocol].macLen)
+	}
+
+	header := berTLV(0x30, berUint(0x02, messageID), berUint(0x02, 65507), berTLV(0x04, []byte{flags}), berUint(0x02, 3))
+	usmStart := concatBytes(
+		berTLV(0x04, ts.engineID),
+		berUint(0x02, boots),
+		berUint(0x02, uint64(engineTime)),
+		berTLV(0x04, []byte(ts.config.Username)),
+	)
+	authTLV := berTLV(0x04, authParams)
+	privTLV := berTLV(0x04, privParams)
+	usm := berTLV(0x30, usmStart, authTLV, privTLV)
+	security := berTLV(0x04, usm)
+	version := berUint(0x02, 3)
+	message := berTLV(0x30, version, header, security, scoped)
+
+	if ts.authKey == nil {
+		return message, nil
+	}
+
+	// The MAC is computed over the message with zeros in its place
+	body := len(version) + len(header) + len(security) + len(scoped)
+	offset := len(message) - body + len(version) + len(header) +
+		(len(security) - len(usm)) + (len(usm) - len(usmStart) - len(authTLV) - len(privTLV)) +
+		len(usmStart) + (len(authTLV) - len(authParams))
+	mac := hmac.New(snmpAuthProtocols[ts.config.AuthProtocol].hash, ts.authKey)
+	mac.Write(message)
+	copy(message[offset:], mac.Sum(nil)[:len(authParams)])
+	return message, nil
+}
+
+// localizedKey derives the key of password for engineID (RFC 3414 A.2)
+func localizedKey(newHash func() hash.Hash, password string, engineID []byte) []byte {
+	h := newHash()
+	chunk := []byte(strings.Repeat(password, 64/len(password)+2))
+	for written := 0; written < 1<<20; written += 64 {
+		h.Write(chunk[written%len(password):][:64])
+	}
+	key := h.Sum(nil)
+
+	h = newHash()
+	h.Write(key)
+	h.Write(engineID)
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// parseOID parses a dotted OID such as 1.3.6.1.4.1.12345
+func parseOID(oid string) ([]uint32, error) {
+	if oid == "" {
+		return nil, fmt.Errorf("required, e.g. 1.3.6.1.4.1.<your enterprise number>.1")
+	}
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid OID %q", oid)
+	}
+
+	parsed := make([]uint32, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OID %q", oid)
+		}
+		parsed[i] = uint32(n)
+	}
+	if parsed[0] > 2 || (parsed[0] < 2 && parsed[1] > 39) {
+		return nil, fmt.Errorf("invalid OID %q", oid)
+	}
+	return parsed, nil
+}
+
+// appendOID returns oid followed by arcs, without changing oid
+func appendOID(oid []uint32, arcs ...uint32) []uint32 {
+	return append(append([]uint32(nil), oid...), arcs...)
+}
+
+// snmpVarbind encodes a variable binding of oid to the encoded value
+func snmpVarbind(oid []uint32, value []byte) []byte {
+	return berTLV(0x30, berTLV(0x06, berOID(oid)), value)
+}
+
+// berTLV encodes the concatenated values with tag and their BER length
+func berTLV(tag byte, values ...[]byte) []byte {
+	value := concatBytes(values...)
+	out := []byte{tag}
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, value...)
+}
+
+// berUint encodes a non-negative integer with tag, e.g. INTEGER or TimeTicks
+func berUint(tag byte, n uint64) []byte {
+	value := new(big.Int).SetUint64(n).Bytes()
+	// A set high bit would make the number negative
+	if len(value) == 0 || value[0]&0x80 != 0 {
+		value = append([]byte{0}, value...)
+	}
+	return berTLV(tag, value)
+}
+
+// berOID encodes the contents of an OBJECT IDENTIFIER, whose first two arcs
+// share a subidentifier
+func berOID(oid []uint32) []byte {
+	var out []byte
+	for _, arc := range append([]uint32{oid[0]*40 + oid[1]}, oid[2:]...) {
+		var digits []byte
+		for {
+			digits = append([]byte{byte(arc & 0x7f)}, digits...)
+			arc >>= 7
+			if arc == 0 {
+				break
+			}
+		}
+		for i := 0; i < len(digits)-1; i++ {
+			digits[i] |= 0x80
+		}
+		out = append(out, digits...)
+	}
+	return out
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// columnTitleRow is the screen row of the column titles, under the title
+const columnTitleRow = headerLines - 2
+
+// SortAt sorts the devices of each logical device by the column whose title
+// is at x, when y is the row of the column titles, and reports whether it
+// was. Clicking the sorted column again reverses the order, a third time
+// restores the API's order.
+func (dm *DisplayManager) SortAt(x, y int) bool {
+	if y != columnTitleRow {
+		return false
+	}
+
+	column, ok := dm.columnAt(x)
+	if !ok {
+		return true
+	}
+	switch {
+	case column.Key != dm.sortKey:
+		dm.sortKey, dm.sortDesc = column.Key, false
+	case !dm.sortDesc:
+		dm.sortDesc = true
+	default:
+		dm.sortKey, dm.sortDesc = "", false
+	}
+	dm.Redraw()
+	return true
+}
+
+// columnAt returns the column drawn at screen column x, laid out as by
+// renderPhysicalDevice: the tree, then the cells separated by " │ "
+func (dm *DisplayManager) columnAt(x int) (Column, bool) {
+	widths := dm.calculateColumnWidths()
+	start := widths[0] + 4
+	for i, column := range dm.config.Columns {
+		if x >= start-1 && x <= start+widths[i+1] {
+			return column, true
+		}
+		start += widths[i+1] + 3
+	}
+	return Column{}, false
+}
+
+// sortArrow marks the title of the sorted column
+func (dm *DisplayManager) sortArrow() string {
+	switch {
+	case dm.sortDesc && dm.ascii:
+		return "v"
+	case dm.sortDesc:
+		return "▼"
+	case dm.ascii:
+		return "^"
+	default:
+		return "▲"
+	}
+}
+
+// sortedDevices returns devices in the order chosen by clicking a column
+// title, or as listed by the API
+func (dm *DisplayManager) sortedDevices(devices []PhysicalDevice) []PhysicalDevice {
+	column, ok := findColumn(dm.sortKey)
+	if !ok {
+		return devices
+	}
+
+	sorted := slices.Clone(devices)
+	slices.SortStableFunc(sorted, func(a, b PhysicalDevice) int {
+		order := compareValues(column.Value(&a), column.Value(&b))
+		if dm.sortDesc {
+			return -order
+		}
+		return order
+	})
+	return sorted
+}
+
+// groupOrders are the -group_order values: logical devices sorted by name,
+// or with the most severe problems first
+var groupOrders = []string{"name", "severity"}
+
+// checkGroupOrder rejects an unknown -group_order
+func checkGroupOrder(order string) error {
+	if !slices.Contains(groupOrders, order) {
+		return fmt.Errorf("unknown group order %q (use %s)", order, strings.Join(groupOrders, ", "))
+	}
+	return nil
+}
+
+// ToggleGroupOrder switches between sorting logical devices by name and by
+// severity, and returns the new order; the caller redraws
+func (dm *DisplayManager) ToggleGroupOrder() string {
+	if dm.groupOrder == "severity" {
+		dm.groupOrder = "name"
+	} else {
+		dm.groupOrder = "severity"
+	}
+	return dm.groupOrder
+}
+
+// compareSeverity puts the logical device whose worst device state is more
+// severe first, and of equal ones a cluster before a standalone device
+func compareSeverity(a, b *LogicalDeviceGroup) int {
+	_, classA := worstState(a)
+	_, classB := worstState(b)
+	if classA != classB {
+		return cmp.Compare(classB, classA)
+	}
+	if a.IsCluster != b.IsCluster {
+		if a.IsCluster {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// compareValues orders column values as numbers when both are, otherwise
+// as text regardless of case
+func compareValues(a, b string) int {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return cmp.Compare(x, y)
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DeviceSource is where the scheduler gets the device list from. APIClient
+// is the implementation for the management API; MockSource serves devices
+// from memory.
+type DeviceSource interface {
+	// Authenticate logs in, recording the attempt as event (AuthLogin,
+	// AuthRenew or AuthReauth)
+	Authenticate(ctx context.Context, event string) error
+
+	// TestConnection checks that the device list can be requested
+	TestConnection(ctx context.Context) error
+
+	// FetchDevicesWithRetry returns the device list, trying again up to
+	// maxRetries times. A response with NotModified set equals the previous.
+	FetchDevicesWithRetry(ctx context.Context, maxRetries int) (*APIResponse, error)
+
+	// SubscribeDeviceChanges signals events on every change until the
+	// subscription fails or ctx is cancelled
+	SubscribeDeviceChanges(ctx context.Context, events chan<- struct{}) error
+
+	// LastTiming returns the connection timings of the last request, or nil
+	LastTiming() *RequestTiming
+}
+
+var _ DeviceSource = (*APIClient)(nil)
+
+// MockSource is a DeviceSource without a server: it lists the devices of a
+// mockSource, such as a DemoFleet, and reports the lists that did not change
+// as not modified. Failures of the mockSource fail the fetch.
+type MockSource struct {
+	source  mockSource
+	mu      sync.Mutex
+	version string
+	last    *APIResponse
+}
+
+func NewMockSource(source mockSource) *MockSource {
+	return &MockSource{source: source}
+}
+
+// Authenticate always succeeds
+func (ms *MockSource) Authenticate(ctx context.Context, event string) error {
+	return nil
+}
+
+func (ms *MockSource) TestConnection(ctx context.Context) error {
+	_, _, err := ms.source()
+	return err
+}
+
+// FetchDevicesWithRetry returns the devices; there is nothing to retry
+func (ms *MockSource) FetchDevicesWithRetry(ctx context.Context, maxRetries int) (*APIResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	devices, version, err := ms.source()
+	if err != nil {
+		return nil, err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.last != nil && version == ms.version {
+		cached := *ms.last
+		cached.NotModified = true
+		return &cached, nil
+	}
+	ms.version = version
+	ms.last = &APIResponse{PhysicalDevices: devices, Total: len(devices)}
+	return ms.last, nil
+}
+
+func (ms *MockSource) SubscribeDeviceChanges(ctx context.Context, events chan<- struct{}) error {
+	return fmt.Errorf("change stream not supported by the mock source")
+}
+
+// LastTiming returns nil, as no connection is made
+func (ms *MockSource) LastTiming() *RequestTiming {
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// defaultSSHCommand opens a shell on the selected device
+const defaultSSHCommand = "ssh {user}@{address}"
+
+// sshArgs fills the -ssh_command template for device and splits it into the
+// program and its arguments
+func sshArgs(template, sshUser string, device *PhysicalDevice) []string {
+	replacer := strings.NewReplacer(
+		"{user}", sshUser,
+		"{address}", device.Address,
+		"{name}", device.Name,
+		"{serial}", device.SerialNumber,
+		"{id}", device.ID,
+	)
+
+	args := strings.Fields(template)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+	return args
+}
+
+// localUsername is the login name ssh itself would use
+func localUsername() string {
+	current, err := user.Current()
+	if err != nil {
+		return os.Getenv("USER")
+	}
+
+	// Windows names are DOMAIN\name
+	name := current.Username
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// connectSelected gives the terminal to the -ssh_command for the selected
+// device and takes the screen back when the session ends. Polling pauses
+// meanwhile.
+func (s *Scheduler) connectSelected() {
+	device := s.tui.Selected()
+	if device == nil {
+		s.tui.Flash("Select a device with the arrow keys or the mouse first", flashDuration)
+		s.tui.Redraw()
+		return
+	}
+	if device.Address == "" {
+		s.tui.Flash(fmt.Sprintf("%s has no address", device.Name), flashDuration)
+		s.tui.Redraw()
+		return
+	}
+
+	args := sshArgs(s.config.SSHCommand, s.config.SSHUser, device)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	s.tui.Suspend()
+	fmt.Printf("Connecting to %s: %s\n", device.Name, strings.Join(args, " "))
+	err := cmd.Run()
+
+	// ssh exits with 255 when it cannot connect; other codes come from the
+	// remote shell. Keep the error message readable until Enter.
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || exitErr.ExitCode() == 255) {
+		fmt.Printf("\n%s: %v. Press Enter to return to the monitor.", args[0], err)
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+
+	// Ctrl+C in the session also reached this process
+	select {
+	case sig := <-s.signals:
+		if sig != os.Interrupt {
+			s.signals <- sig
+		}
+	default:
+	}
+
+	s.tui.Resume()
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// StateStore holds the latest poll result. The poller publishes every
+// result to it, and it passes them on to its subscribers: the sinks and the
+// TUI, daemon log or plain output. The web server reads it when asked. All
+// methods are safe for concurrent use.
+type StateStore struct {
+	mu          sync.RWMutex
+	data        *GroupedDevices
+	lastError   string
+	errorAt     time.Time
+	events      []DeviceEvent
+	removed     map[string]PhysicalDevice // Removed devices by logical device and name, see pairReplacements
+	subscribers []StateSubscriber
+	started     time.Time
+	lastPoll    time.Time // Last successful poll, with or without changes
+}
+
+// StateSubscriber receives what is published to a StateStore. Subscribers
+// are called in turn on the publisher's goroutine, so they must not block;
+// slow ones are expected to queue internally.
+type StateSubscriber interface {
+	Dispatch(result PollResult) // A poll finished, with the events it caused
+	Unchanged()                 // A poll returned nothing new (304 Not Modified)
+	Watch(data *GroupedDevices) // The data was re-annotated between polls, e.g. with probe results or acks
+}
+
+// maxStoredEvents bounds the change history kept in memory
+const maxStoredEvents = 1000
+
+// MonitorState is a point-in-time copy of the store contents
+type MonitorState struct {
+	Data      *GroupedDevices `json:"data"`
+	LastError string          `json:"last_error,omitempty"`
+	ErrorAt   time.Time       `json:"error_at,omitempty"`
+	LastPoll  time.Time       `json:"-"` // Zero before the first successful poll; restored data is not one
+	Started   time.Time       `json:"-"`
+}
+
+func NewStateStore() *StateStore {
+	return &StateStore{started: time.Now()}
+}
+
+// Update records a poll result and returns the device events it caused.
+// On error the last successful data is kept.
+func (st *StateStore) Update(data *GroupedDevices, err error) []DeviceEvent {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if err != nil {
+		st.lastError = err.Error()
+		st.errorAt = time.Now()
+		return nil
+	}
+
+	events := st.pairReplacements(DiffDevices(st.data, data, time.Now()), data)
+	st.events = append(st.events, events...)
+	if len(st.events) > maxStoredEvents {
+		st.events = append([]DeviceEvent(nil), st.events[len(st.events)-maxStoredEvents:]...)
+	}
+
+	st.data = data
+	st.lastError = ""
+	st.errorAt = time.Time{}
+
+	return events
+}
+
+// Subscribe adds a subscriber for everything published from now on
+func (st *StateStore) Subscribe(subscriber StateSubscriber) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.subscribers = append(st.subscribers, subscriber)
+}
+
+// subscribed returns the subscribers to call without holding st.mu, so they
+// may read the store
+func (st *StateStore) subscribed() []StateSubscriber {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	return st.subscribers
+}
+
+// Publish records a poll result, fills in the device events it caused and
+// passes it to the subscribers
+func (st *StateStore) Publish(result PollResult) {
+	result.Events = st.Update(result.Data, result.Err)
+	if result.Err == nil {
+		st.polled()
+	}
+	for _, subscriber := range st.subscribed() {
+		subscriber.Dispatch(result)
+	}
+}
+
+// PublishUnchanged tells the subscribers about a poll without changes
+func (st *StateStore) PublishUnchanged() {
+	st.polled()
+	for _, subscriber := range st.subscribed() {
+		subscriber.Unchanged()
+	}
+}
+
+// polled records the time of a successful poll
+func (st *StateStore) polled() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.lastPoll = time.Now()
+}
+
+// Republish replaces the data with a re-annotated copy and passes it to the
+// subscribers
+func (st *StateStore) Republish(data *GroupedDevices) {
+	st.Update(data, nil)
+	for _, subscriber := range st.subscribed() {
+		subscriber.Watch(data)
+	}
+}
+
+// pairReplacements turns the addition of a device into a serial_changed
+// event when an earlier poll removed a device of the same name and logical
+// device with another serial number, as when a failed unit is deleted from
+// management and its replacement registered a while later. Replacements
+// within one poll are paired by DiffDevices. Called with st.mu held.
+func (st *StateStore) pairReplacements(events []DeviceEvent, data *GroupedDevices) []DeviceEvent {
+	if st.removed == nil {
+		st.removed = make(map[string]PhysicalDevice)
+	}
+
+	for i, event := range events {
+		key := event.LogicalDevice + "/" + event.DeviceName
+		switch event.Type {
+		case EventDeviceRemoved:
+			if device := findDevice(st.data, event.DeviceID); device != nil {
+				st.removed[key] = *device
+			}
+		case EventDeviceAdded:
+			old, ok := st.removed[key]
+			if !ok {
+				continue
+			}
+			delete(st.removed, key)
+			if device := findDevice(data, event.DeviceID); device != nil && device.SerialNumber != old.SerialNumber {
+				events[i].Type = EventSerialChanged
+				events[i].Field = "serial_number"
+				events[i].From = old.SerialNumber
+				events[i].To = device.SerialNumber
+			}
+		}
+	}
+	return events
+}
+
+// State returns the current contents of the store
+func (st *StateStore) State() MonitorState {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	return MonitorState{
+		Data:      st.data,
+		LastError: st.lastError,
+		ErrorAt:   st.errorAt,
+		LastPoll:  st.lastPoll,
+		Started:   st.started,
+	}
+}
+
+// Events returns the stored change history, oldest first, limited to events
+// after since (if non-zero) and to the last limit entries (if positive)
+func (st *StateStore) Events(since time.Time, limit int) []DeviceEvent {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	events := []DeviceEvent{}
+	for _, event := range st.events {
+		if !since.IsZero() && !event.Time.After(since) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+
+	return events
+}
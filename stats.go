@@ -0,0 +1,129 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// statsBarWidth is the length of the longest bar of the statistics overlay
+const statsBarWidth = 30
+
+// statCount is one bar of the statistics overlay
+type statCount struct {
+	label string
+	count int
+}
+
+// ToggleStats shows or hides the fleet statistics overlay
+func (dm *DisplayManager) ToggleStats() {
+	dm.stats = !dm.stats
+	dm.flush()
+}
+
+// countDevices counts the devices of data by the value key returns, the
+// largest count first
+func countDevices(data *GroupedDevices, key func(device *PhysicalDevice) string) []statCount {
+	counts := make(map[string]int)
+	for _, group := range data.LogicalDeviceGroups {
+		for i := range group.PhysicalDevices {
+			counts[key(&group.PhysicalDevices[i])]++
+		}
+	}
+	return sortedCounts(counts)
+}
+
+// sortedCounts orders counts by count, then by label
+func sortedCounts(counts map[string]int) []statCount {
+	sorted := make([]statCount, 0, len(counts))
+	for label, count := range counts {
+		if label == "" {
+			label = "-"
+		}
+		sorted = append(sorted, statCount{label, count})
+	}
+	slices.SortFunc(sorted, func(a, b statCount) int {
+		if a.count != b.count {
+			return cmp.Compare(b.count, a.count)
+		}
+		return compareValues(a.label, b.label)
+	})
+	return sorted
+}
+
+// statsLines draws the devices by model, version and state, and the logical
+// devices by topology and number of virtual contexts as bar charts. Each
+// chart shows as many bars as fit on the screen.
+func (dm *DisplayManager) statsLines() []string {
+	bold := dm.getColor(ColorBold)
+	dim := dm.getColor(ColorDim)
+	reset := dm.getColor(ColorReset)
+
+	lines := []string{bold + "Fleet statistics" + reset, ""}
+	if dm.lastData == nil || dm.lastData.TotalDevices == 0 {
+		return append(lines, "No devices yet", "", dim+"f: close"+reset)
+	}
+	data := dm.lastData
+
+	topologies := make(map[string]int)
+	contexts := make(map[string]int)
+	for i := range data.LogicalDeviceGroups {
+		group := &data.LogicalDeviceGroups[i]
+		topologies[group.GetTopologyDisplayName()]++
+		contexts[group.LogicalDevice.Name] = len(group.LogicalDevice.VirtualContexts)
+	}
+
+	charts := []struct {
+		title  string
+		counts []statCount
+	}{
+		{"Devices by model", countDevices(data, func(d *PhysicalDevice) string { return d.Model })},
+		{"Devices by version", countDevices(data, (*PhysicalDevice).GetProductVersionDisplay)},
+		{"Devices by state", countDevices(data, flappingState)},
+		{"Logical devices by topology", sortedCounts(topologies)},
+		{"Virtual contexts per logical device", sortedCounts(contexts)},
+	}
+
+	// Title, hint, a title and a blank line per chart, and the box borders
+	room := max(1, (dm.termHeight-headerLines-footerLines-6-2*len(charts))/len(charts))
+	labelWidth := 0
+	for _, chart := range charts {
+		for _, count := range chart.counts[:min(room, len(chart.counts))] {
+			labelWidth = max(labelWidth, displayWidth(count.label))
+		}
+	}
+	labelWidth = min(labelWidth, max(8, dm.termWidth-statsBarWidth-20))
+
+	for _, chart := range charts {
+		lines = append(lines, bold+chart.title+reset)
+		largest := 0
+		for _, count := range chart.counts {
+			largest = max(largest, count.count)
+		}
+		for i, count := range chart.counts {
+			if i == room-1 && len(chart.counts) > room {
+				lines = append(lines, dim+fmt.Sprintf("... and %d more", len(chart.counts)-i)+reset)
+				break
+			}
+			lines = append(lines, fmt.Sprintf("%s  %s %d",
+				padString(truncateString(count.label, labelWidth), labelWidth, true), dm.statsBar(count.count, largest), count.count))
+		}
+		lines = append(lines, "")
+	}
+
+	return append(lines, dim+"f: close"+reset)
+}
+
+// statsBar draws count as a bar, the largest count filling statsBarWidth
+func (dm *DisplayManager) statsBar(count, largest int) string {
+	length := count * statsBarWidth / max(1, largest)
+	if count > 0 {
+		length = max(1, length)
+	}
+	block := "█"
+	if dm.ascii {
+		block = "#"
+	}
+	return dm.getColor(ColorCyan) + strings.Repeat(block, length) + dm.getColor(ColorReset) + strings.Repeat(" ", statsBarWidth-length)
+}
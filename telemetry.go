@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TelemetryConfig configures OpenTelemetry export of the monitor's own traces
+// and metrics. The standard OTEL_EXPORTER_OTLP_* variables are honoured too.
+type TelemetryConfig struct {
+	Endpoint    string            `json:"otlp_endpoint"` // OTLP/HTTP base URL, e.g. http://collector:4318
+	ServiceName string            `json:"service_name"`
+	Headers     map[string]string `json:"headers"`
+}
+
+// telemetryExportInterval is how often spans and metrics are pushed
+const telemetryExportInterval = 10 * time.Second
+
+// otel is the process-wide telemetry instance, nil (and a no-op) unless
+// an OTLP endpoint is configured
+var otel *Telemetry
+
+// Telemetry records spans and metrics and exports them via OTLP/HTTP JSON
+type Telemetry struct {
+	config  TelemetryConfig
+	client  *http.Client
+	started time.Time
+
+	mu       sync.Mutex
+	spans    []*Span
+	counters map[string]map[string]int64   // metric -> attribute key -> value
+	gauges   map[string]map[string]float64 // metric -> attribute key -> value
+	labels   map[string][]otlpKeyValue     // attribute key -> attributes
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Span is a single timed operation; a nil *Span is a valid no-op
+type Span struct {
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes []otlpKeyValue
+	err        error
+}
+
+type spanContextKey struct{}
+
+// applyTelemetryEnvironment fills config from the standard OTEL_* variables
+func applyTelemetryEnvironment(config *TelemetryConfig) {
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		config.Endpoint = endpoint
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		config.ServiceName = name
+	}
+	if headers := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); headers != "" {
+		if config.Headers == nil {
+			config.Headers = make(map[string]string)
+		}
+		for _, pair := range strings.Split(headers, ",") {
+			if key, value, ok := strings.Cut(pair, "="); ok {
+				config.Headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+}
+
+// StartTelemetry enables the global telemetry instance when an endpoint is configured
+func StartTelemetry(config TelemetryConfig) {
+	if config.Endpoint == "" {
+		return
+	}
+	if config.ServiceName == "" {
+		config.ServiceName = "pt_device_monitor"
+	}
+
+	otel = &Telemetry{
+		config:   config,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		started:  time.Now(),
+		counters: make(map[string]map[string]int64),
+		gauges:   make(map[string]map[string]float64),
+		labels:   make(map[string][]otlpKeyValue),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go otel.run()
+}
+
+// StopTelemetry flushes pending data and disables telemetry
+func StopTelemetry() {
+	if otel == nil {
+		return
+	}
+	close(otel.stop)
+	<-otel.done
+	otel = nil
+}
+
+// StartSpan begins a span named name, as a child of the span in ctx if any
+func (t *Telemetry) StartSpan(ctx context.Context, name string, attributes ...string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		spanID:     randomHex(8),
+		name:       name,
+		start:      time.Now(),
+		attributes: otlpAttributes(attributes),
+	}
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok && parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		span.traceID = randomHex(16)
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// End finishes the span, marking it failed when err is not nil
+func (s *Span) End(err error) {
+	if s == nil || otel == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+
+	otel.mu.Lock()
+	otel.spans = append(otel.spans, s)
+	otel.mu.Unlock()
+}
+
+// AddCounter increments a monotonic counter; attributes are key/value pairs
+func (t *Telemetry) AddCounter(name string, delta int64, attributes ...string) {
+	if t == nil {
+		return
+	}
+	key := t.labelKey(attributes)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.counters[name] == nil {
+		t.counters[name] = make(map[string]int64)
+	}
+	t.counters[name][key] += delta
+}
+
+// SetGauge records the current value of a gauge
+func (t *Telemetry) SetGauge(name string, value float64, attributes ...string) {
+	if t == nil {
+		return
+	}
+	key := t.labelKey(attributes)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.gauges[name] == nil {
+		t.gauges[name] = make(map[string]float64)
+	}
+	t.gauges[name][key] = value
+}
+
+func (t *Telemetry) labelKey(attributes []string) string {
+	key := strings.Join(attributes, "\x00")
+
+	t.mu.Lock()
+	if _, ok := t.labels[key]; !ok {
+		t.labels[key] = otlpAttributes(attributes)
+	}
+	t.mu.Unlock()
+
+	return key
+}
+
+func (t *Telemetry) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(telemetryExportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.export()
+		case <-t.stop:
+			t.export()
+			return
+		}
+	}
+}
+
+// export pushes finished spans and the current metric values; failures are
+// dropped silently so the monitor never suffers from a collector outage
+func (t *Telemetry) export() {
+	t.mu.Lock()
+	spans := t.spans
+	t.spans = nil
+	metrics := t.metricsPayload()
+	t.mu.Unlock()
+
+	if len(spans) > 0 {
+		t.post("/v1/traces", t.tracesPayload(spans))
+	}
+	t.post("/v1/metrics", metrics)
+}
+
+func (t *Telemetry) post(path string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(t.config.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// OTLP JSON encoding types
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+func otlpAttributes(pairs []string) []otlpKeyValue {
+	var attributes []otlpKeyValue
+	for i := 0; i+1 < len(pairs); i += 2 {
+		attributes = append(attributes, otlpKeyValue{Key: pairs[i], Value: otlpAnyValue{StringValue: pairs[i+1]}})
+	}
+	return attributes
+}
+
+func (t *Telemetry) resource() otlpResource {
+	return otlpResource{Attributes: otlpAttributes([]string{"service.name", t.config.ServiceName, "service.version", version})}
+}
+
+func unixNano(at time.Time) string {
+	return strconv.FormatInt(at.UnixNano(), 10)
+}
+
+func (t *Telemetry) tracesPayload(spans []*Span) interface{} {
+	type otlpStatus struct {
+		Code    int    `json:"code"` // 1 ok, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	type otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+
+	encoded := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		status := otlpStatus{Code: 1}
+		if span.err != nil {
+			status = otlpStatus{Code: 2, Message: span.err.Error()}
+		}
+		encoded[i] = otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              1, // Internal
+			StartTimeUnixNano: unixNano(span.start),
+			EndTimeUnixNano:   unixNano(span.end),
+			Attributes:        span.attributes,
+			Status:            status,
+		}
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": t.resource(),
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": otlpScope{Name: "pt_device_monitor"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
+
+// metricsPayload must be called with t.mu held
+func (t *Telemetry) metricsPayload() interface{} {
+	now := unixNano(time.Now())
+	start := unixNano(t.started)
+
+	var metrics []interface{}
+	for name, values := range t.counters {
+		var points []interface{}
+		for key, value := range values {
+			points = append(points, map[string]interface{}{
+				"attributes":        t.labels[key],
+				"startTimeUnixNano": start,
+				"timeUnixNano":      now,
+				"asInt":             strconv.FormatInt(value, 10),
+			})
+		}
+		metrics = append(metrics, map[string]interface{}{
+			"name": name,
+			"sum": map[string]interface{}{
+				"aggregationTemporality": 2, // Cumulative
+				"isMonotonic":            true,
+				"dataPoints":             points,
+			},
+		})
+	}
+	for name, values := range t.gauges {
+		var points []interface{}
+		for key, value := range values {
+			points = append(points, map[string]interface{}{
+				"attributes":   t.labels[key],
+				"timeUnixNano": now,
+				"asDouble":     value,
+			})
+		}
+		metrics = append(metrics, map[string]interface{}{
+			"name":  name,
+			"gauge": map[string]interface{}{"dataPoints": points},
+		})
+	}
+
+	return map[string]interface{}{
+		"resourceMetrics": []interface{}{map[string]interface{}{
+			"resource": t.resource(),
+			"scopeMetrics": []interface{}{map[string]interface{}{
+				"scope":   otlpScope{Name: "pt_device_monitor"},
+				"metrics": metrics,
+			}},
+		}},
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%0*x", n*2, time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// MessageTemplates names Go text/template files that replace the built-in
+// title and body of alert messages
+type MessageTemplates struct {
+	Title string `json:"title"` // Template file of the title or summary line
+	Body  string `json:"body"`  // Template file of the message body
+}
+
+// alertMessage is what message templates see: the alert fields, such as
+// .DeviceName, .Status, .OldState, .NewState, .Since and .Time, plus the
+// device and its group
+type alertMessage struct {
+	Alert
+	Group string // Name of the logical device
+}
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// messageFormat renders alert messages of one channel; a nil template keeps
+// the built-in text
+type messageFormat struct {
+	channel string
+	title   *template.Template
+	body    *template.Template
+}
+
+// loadMessageFormat parses the templates of a channel, falling back to the
+// alerts section's templates for the ones the channel does not set
+func loadMessageFormat(channel string, templates, defaults MessageTemplates) (*messageFormat, error) {
+	if templates.Title == "" {
+		templates.Title = defaults.Title
+	}
+	if templates.Body == "" {
+		templates.Body = defaults.Body
+	}
+
+	mf := &messageFormat{channel: channel}
+	var err error
+	if mf.title, err = parseTemplateFile(templates.Title); err != nil {
+		return nil, err
+	}
+	if mf.body, err = parseTemplateFile(templates.Body); err != nil {
+		return nil, err
+	}
+	return mf, nil
+}
+
+func parseTemplateFile(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	t, err := template.New(path).Funcs(templateFuncs).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	return t, nil
+}
+
+// Title renders the title of alert, or returns fallback without a title
+// template
+func (mf *messageFormat) Title(alert Alert, fallback string) string {
+	return mf.render(mf.title, alert, fallback)
+}
+
+// Body renders the body of alert, or returns fallback without a body
+// template
+func (mf *messageFormat) Body(alert Alert, fallback string) string {
+	return mf.render(mf.body, alert, fallback)
+}
+
+// HasBody reports whether a body template replaces the built-in details
+func (mf *messageFormat) HasBody() bool {
+	return mf != nil && mf.body != nil
+}
+
+// render executes t; a failing template is logged and the built-in text
+// sent instead, so a mistake in a template never loses an alert
+func (mf *messageFormat) render(t *template.Template, alert Alert, fallback string) string {
+	if mf == nil || t == nil {
+		return fallback
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, alertMessage{Alert: alert, Group: alert.LogicalDevice}); err != nil {
+		logBackground("%s alerts: %v", mf.channel, err)
+		return fallback
+	}
+	return strings.TrimSpace(b.String())
+}
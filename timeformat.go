@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // -timezone works without a system zone database, e.g. on Windows
+)
+
+// displayTime is how timestamps are shown, set from -timezone, -time_format
+// and -relative_time by setTimeDisplay. Machine-readable output keeps RFC 3339
+// and only takes the time zone.
+var displayTime = struct {
+	location *time.Location
+	layout   string // Replaces the layout of every timestamp shown, unless empty
+	relative bool   // The TUI shows "12m ago" instead; toggled with the 't' key
+}{location: time.Local}
+
+// timeFormatNames are the -time_format values that name a layout
+var timeFormatNames = map[string]string{
+	"rfc3339": time.RFC3339,
+	"iso":     "2006-01-02T15:04:05",
+}
+
+// setTimeDisplay applies the time settings of config, already checked by
+// validateConfig
+func setTimeDisplay(config *Config) {
+	if location, err := loadTimezone(config.Timezone); err == nil {
+		displayTime.location = location
+	}
+	displayTime.layout = timeLayout(config.TimeFormat)
+	displayTime.relative = config.RelativeTime
+}
+
+// loadTimezone returns the zone named by -timezone: an IANA name such as
+// Europe/Moscow, UTC, or Local, which is also the default
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q (use an IANA name such as Europe/Moscow, or UTC)", name)
+	}
+	return location, nil
+}
+
+// timeLayout returns the Go layout of a -time_format value
+func timeLayout(format string) string {
+	if layout, ok := timeFormatNames[format]; ok {
+		return layout
+	}
+	return format
+}
+
+// checkTimeFormat rejects a -time_format without any element of Go's
+// reference time, which would show the same text for every timestamp
+func checkTimeFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	sample := time.Date(1999, time.December, 31, 23, 58, 59, 0, time.UTC)
+	if layout := timeLayout(format); sample.Format(layout) == layout {
+		return fmt.Errorf("time format %q has no date or time elements; write it as Go's reference time, e.g. \"02.01.2006 15:04\", or use rfc3339 or iso", format)
+	}
+	return nil
+}
+
+// formatTime shows t in the -timezone zone, in layout unless -time_format
+// replaces it
+func formatTime(t time.Time, layout string) string {
+	if displayTime.layout != "" {
+		layout = displayTime.layout
+	}
+	return t.In(displayTime.location).Format(layout)
+}
+
+// formatShownTime is formatTime for the timestamps of the device table, the
+// header and the event log, which -relative_time and the 't' key switch to
+// relative ones
+func formatShownTime(t time.Time, layout string) string {
+	if displayTime.relative {
+		return relativeTime(t)
+	}
+	return formatTime(t, layout)
+}
+
+// relativeTime describes t relative to now in its largest unit, e.g.
+// "12m ago", or "in 2h" for a time ahead
+func relativeTime(t time.Time) string {
+	age := time.Since(t).Round(time.Second)
+	format := "%s ago"
+	if age < 0 {
+		age, format = -age, "in %s"
+	}
+
+	var amount string
+	switch {
+	case age < time.Minute:
+		amount = fmt.Sprintf("%ds", int(age/time.Second))
+	case age < time.Hour:
+		amount = fmt.Sprintf("%dm", int(age/time.Minute))
+	case age < 24*time.Hour:
+		amount = fmt.Sprintf("%dh", int(age/time.Hour))
+	default:
+		amount = fmt.Sprintf("%dd", int(age/(24*time.Hour)))
+	}
+	return fmt.Sprintf(format, amount)
+}
+
+// inDisplayZone returns t in the -timezone zone, for timestamps written in
+// a fixed format
+func inDisplayZone(t time.Time) time.Time {
+	return t.In(displayTime.location)
+}
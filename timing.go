@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"time"
+)
+
+// RequestTiming breaks the duration of one API request down by phase. Phases
+// that did not happen, such as DNS and TLS on a reused connection, are zero.
+type RequestTiming struct {
+	Start   time.Time
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration // From the request being sent to the first response byte
+	Total   time.Duration
+	Reused  bool // The request went over a kept-alive connection
+}
+
+// traceRequest returns a context that records the phases of the request made
+// with it into the returned RequestTiming; call finish once the response body
+// has been read
+func traceRequest(ctx context.Context) (context.Context, *RequestTiming) {
+	timing := &RequestTiming{Start: time.Now()}
+	var dnsStart, connectStart, tlsStart, wroteRequest time.Time
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { timing.DNS = time.Since(dnsStart) },
+		ConnectStart: func(string, string) {
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone:       func(string, string, error) { timing.Connect = time.Since(connectStart) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timing.TLS = time.Since(tlsStart)
+		},
+		GotConn:              func(info httptrace.GotConnInfo) { timing.Reused = info.Reused },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { timing.TTFB = time.Since(wroteRequest) },
+	}
+
+	return httptrace.WithClientTrace(ctx, trace), timing
+}
+
+func (t *RequestTiming) finish() {
+	t.Total = time.Since(t.Start)
+}
+
+func (t *RequestTiming) String() string {
+	var b strings.Builder
+	if t.Reused {
+		b.WriteString("reused connection")
+	} else {
+		fmt.Fprintf(&b, "dns=%s connect=%s tls=%s", roundTiming(t.DNS), roundTiming(t.Connect), roundTiming(t.TLS))
+	}
+	fmt.Fprintf(&b, " ttfb=%s total=%s", roundTiming(t.TTFB), roundTiming(t.Total))
+	return b.String()
+}
+
+// roundTiming rounds a phase duration for display; sub-millisecond phases
+// keep microseconds so a local connect does not show as 0s
+func roundTiming(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(100 * time.Microsecond)
+}
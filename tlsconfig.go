@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TLSConfig holds the TLS settings of the connection to the management API
+type TLSConfig struct {
+	MinVersion   string   `json:"min_version"`   // "1.2" or "1.3"
+	CipherSuites []string `json:"cipher_suites"` // IANA names, TLS 1.2 only
+	ServerName   string   `json:"server_name"`   // SNI name, when it differs from the URL host; also checked unless pinned or insecure
+	Pins         []string `json:"pins"`          // Accepted SPKI hashes, "sha256/<base64>"
+	CAFile       string   `json:"ca_file"`       // PEM CA certificates to verify the server with instead of the system's
+	Insecure     bool     `json:"insecure"`      // Accept any certificate, e.g. a self-signed one without pins
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the client TLS configuration for the API transport.
+// The server certificate is verified against CAFile or the system's CAs,
+// unless Insecure is set or pins are set without CAFile: the management
+// server usually has a self-signed certificate, which its pin identifies.
+func newTLSConfig(config TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.Insecure || (len(config.Pins) > 0 && config.CAFile == ""),
+		ServerName:         config.ServerName,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls_ca_file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.MinVersion != "" {
+		version, ok := tlsVersions[strings.TrimPrefix(config.MinVersion, "TLS")]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version: %s (use 1.0, 1.1, 1.2 or 1.3)", config.MinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if len(config.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+		for _, suite := range tls.InsecureCipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+
+		for _, name := range config.CipherSuites {
+			id, ok := suites[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown cipher suite: %s", name)
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	if len(config.Pins) > 0 {
+		pins := make(map[string]bool)
+		for _, pin := range config.Pins {
+			pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+			if hash, err := base64.StdEncoding.DecodeString(pin); err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid certificate pin: %s (use sha256/<base64 SPKI hash>)", pin)
+			}
+			pins[pin] = true
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.VerifiedChains) > 0 {
+				return verifyChainPins(state.VerifiedChains, pins)
+			}
+			return verifyPins(state.PeerCertificates, pins)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// spkiPin returns the pin of a certificate: the base64 SHA-256 hash of its
+// SubjectPublicKeyInfo, as used by HPKP and curl's --pinnedpubkey
+func spkiPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// PinMismatchError is returned when the server's certificate does not match
+// a configured pin
+type PinMismatchError struct {
+	Presented string // pin of the leaf certificate, empty when none was sent
+}
+
+func (e *PinMismatchError) Error() string {
+	if e.Presented == "" {
+		return "certificate pin mismatch: no certificate presented"
+	}
+	return fmt.Sprintf("certificate pin mismatch: server presented sha256/%s", e.Presented)
+}
+
+// verifyPins accepts the connection when the leaf certificate matches a pin.
+// Without verification of the chain the other certificates the server sends
+// prove nothing: an impostor could append the real server's certificate to
+// its own.
+func verifyPins(chain []*x509.Certificate, pins map[string]bool) error {
+	if len(chain) == 0 {
+		return &PinMismatchError{}
+	}
+	if pin := spkiPin(chain[0]); !pins[pin] {
+		return &PinMismatchError{Presented: pin}
+	}
+	return nil
+}
+
+// verifyChainPins accepts the connection when a certificate of a verified
+// chain matches a pin, so pinning an intermediate CA also works
+func verifyChainPins(chains [][]*x509.Certificate, pins map[string]bool) error {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if pins[spkiPin(cert)] {
+				return nil
+			}
+		}
+	}
+	return &PinMismatchError{Presented: spkiPin(chains[0][0])}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCertificate returns a certificate for mgmt.test signed by parent,
+// or self-signed when parent is nil
+func newTestCertificate(t *testing.T, name string, ca bool, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{"mgmt.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+
+	signer, signerKey := template, any(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// handshake connects a client with config to a server presenting chain and
+// returns the client's error
+func handshake(t *testing.T, config TLSConfig, chain tls.Certificate) error {
+	t.Helper()
+
+	clientConfig, err := newTLSConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig.ServerName = "mgmt.test"
+
+	// A loopback listener rather than net.Pipe: the pipe is unbuffered, so a
+	// handshake one side abandons can leave the other blocked in a write.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer server.Close()
+		server.SetDeadline(time.Now().Add(5 * time.Second))
+		tls.Server(server, &tls.Config{Certificates: []tls.Certificate{chain}}).Handshake()
+	}()
+
+	client, err := net.DialTimeout("tcp", listener.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	return tls.Client(client, clientConfig).Handshake()
+}
+
+func TestPinnedCertificate(t *testing.T) {
+	real := newTestCertificate(t, "real", false, nil)
+	impostor := newTestCertificate(t, "impostor", false, nil)
+	pins := TLSConfig{Pins: []string{"sha256/" + spkiPin(real.Leaf)}}
+
+	if err := handshake(t, pins, real); err != nil {
+		t.Errorf("pinned certificate rejected: %v", err)
+	}
+
+	// The real certificate is public, so an impostor can send it along
+	impostor.Certificate = append(impostor.Certificate, real.Certificate[0])
+	var mismatch *PinMismatchError
+	if err := handshake(t, pins, impostor); !errors.As(err, &mismatch) {
+		t.Errorf("impostor with the pinned certificate appended: got %v, want a pin mismatch", err)
+	}
+}
+
+func TestCertificateVerification(t *testing.T) {
+	ca := newTestCertificate(t, "ca", true, nil)
+	signed := newTestCertificate(t, "signed", false, &ca)
+	selfSigned := newTestCertificate(t, "self-signed", false, nil)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config TLSConfig
+		chain  tls.Certificate
+		ok     bool
+	}{
+		{"self-signed by default", TLSConfig{}, selfSigned, false},
+		{"self-signed with insecure", TLSConfig{Insecure: true}, selfSigned, true},
+		{"signed by the CA file", TLSConfig{CAFile: caFile}, signed, true},
+		{"self-signed with a CA file", TLSConfig{CAFile: caFile}, selfSigned, false},
+		{"pinned CA", TLSConfig{CAFile: caFile, Pins: []string{"sha256/" + spkiPin(ca.Leaf)}}, signed, true},
+		{"other pin", TLSConfig{CAFile: caFile, Pins: []string{"sha256/" + spkiPin(selfSigned.Leaf)}}, signed, false},
+	}
+	for _, test := range tests {
+		if err := handshake(t, test.config, test.chain); (err == nil) != test.ok {
+			t.Errorf("%s: got error %v, want success %v", test.name, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// vaultTimeout bounds each request to Vault
+const vaultTimeout = 10 * time.Second
+
+// VaultCredentials reads the API username and password from a Vault KV
+// secret. Vault is located with the standard VAULT_ADDR, VAULT_TOKEN,
+// VAULT_NAMESPACE and VAULT_CACERT variables.
+type VaultCredentials struct {
+	addr      string
+	token     string
+	namespace string
+	path      string
+	client    *http.Client
+}
+
+// vaultResponse is the envelope of a Vault read. KV v1 returns the secret's
+// fields in data, KV v2 in data.data next to data.metadata.
+type vaultResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []string        `json:"errors"`
+}
+
+type vaultKV2Data struct {
+	Data     map[string]interface{} `json:"data"`
+	Metadata json.RawMessage        `json:"metadata"`
+}
+
+// NewVaultCredentials reads the Vault environment for the secret at path,
+// e.g. secret/data/pt-monitor for KV v2 or secret/pt-monitor for KV v1
+func NewVaultCredentials(path string) (*VaultCredentials, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return nil, fmt.Errorf("vault_path requires VAULT_ADDR and VAULT_TOKEN")
+	}
+
+	tlsConfig := &tls.Config{}
+	if caFile := os.Getenv("VAULT_CACERT"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VAULT_CACERT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in VAULT_CACERT %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &VaultCredentials{
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     token,
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		path:      strings.Trim(path, "/"),
+		client: &http.Client{
+			Timeout:   vaultTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+// Credentials fetches the "username" and "password" fields of the secret.
+// A secret without a username keeps defaultUsername.
+func (v *VaultCredentials) Credentials(ctx context.Context, defaultUsername string) (username, password string, err error) {
+	ctx, span := otel.StartSpan(ctx, "vault.read", "path", v.path)
+	defer func() { span.End(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", v.addr+"/v1/"+v.path, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read credentials from vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var secret vaultResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&secret)
+
+	if resp.StatusCode != http.StatusOK {
+		if len(secret.Errors) > 0 {
+			return "", "", fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(secret.Errors, "; "))
+		}
+		return "", "", fmt.Errorf("vault returned %s for %s", resp.Status, v.path)
+	}
+	if decodeErr != nil {
+		return "", "", fmt.Errorf("failed to parse vault response: %w", decodeErr)
+	}
+
+	var fields map[string]interface{}
+	var kv2 vaultKV2Data
+	if err := json.Unmarshal(secret.Data, &kv2); err == nil && kv2.Metadata != nil && kv2.Data != nil {
+		fields = kv2.Data
+	} else if err := json.Unmarshal(secret.Data, &fields); err != nil {
+		return "", "", fmt.Errorf("failed to parse vault secret: %w", err)
+	}
+
+	username = defaultUsername
+	if value, ok := fields["username"].(string); ok && value != "" {
+		username = value
+	}
+	password, _ = fields["password"].(string)
+	if password == "" {
+		return "", "", fmt.Errorf("vault secret %s has no password field", v.path)
+	}
+
+	return username, password, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Build information, injected at release builds with
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// A plain go build inside the git checkout still fills commit from the VCS
+// information Go embeds in the binary.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// pseudoVersion matches the timestamp and commit part of a Go pseudo-version
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}$`)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+
+	// Installed with go install ...@vX.Y.Z. Checkout builds get a pseudo-version
+	// from the go command, which dev-<commit> says more readably.
+	if v := info.Main.Version; version == "dev" && v != "" && v != "(devel)" &&
+		!strings.Contains(v, "+") && !pseudoVersion.MatchString(v) {
+		version = v
+	}
+
+	injected := commit != ""
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if commit == "" && len(setting.Value) >= 7 {
+				commit = setting.Value[:7]
+			}
+		case "vcs.modified":
+			if setting.Value == "true" && commit != "" && !injected {
+				commit += "-dirty"
+			}
+		}
+	}
+}
+
+// shortVersion identifies the build in the User-Agent and the TUI header:
+// the release version, or dev-<commit> for development builds
+func shortVersion() string {
+	if version == "dev" && commit != "" {
+		return "dev-" + commit
+	}
+	return version
+}
+
+// versionString describes the running build for -version and bug reports
+func versionString() string {
+	s := "pt_device_monitor " + version
+	if commit != "" {
+		s += " (commit " + commit
+		if buildDate != "" {
+			s += ", built " + buildDate
+		}
+		s += ")"
+	}
+	return fmt.Sprintf("%s %s/%s %s", s, runtime.GOOS, runtime.GOARCH, runtime.Version())
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// VersionCount is how many devices run one product version
+type VersionCount struct {
+	Version string `json:"version"`
+	Devices int    `json:"devices"`
+}
+
+// NewVersionSpread counts the devices of data by product version, the most
+// common version first
+func NewVersionSpread(data *GroupedDevices) []VersionCount {
+	counts := make(map[string]int)
+	for _, group := range data.LogicalDeviceGroups {
+		for i := range group.PhysicalDevices {
+			counts[group.PhysicalDevices[i].GetProductVersionDisplay()]++
+		}
+	}
+
+	spread := make([]VersionCount, 0, len(counts))
+	for version, devices := range counts {
+		spread = append(spread, VersionCount{version, devices})
+	}
+	slices.SortFunc(spread, func(a, b VersionCount) int {
+		if a.Devices != b.Devices {
+			return cmp.Compare(b.Devices, a.Devices)
+		}
+		return compareValues(a.Version, b.Version)
+	})
+	return spread
+}
+
+// versionMismatches returns the devices of data whose software and product
+// versions differ, by name
+func versionMismatches(data *GroupedDevices) []*PhysicalDevice {
+	var devices []*PhysicalDevice
+	for i := range data.LogicalDeviceGroups {
+		group := &data.LogicalDeviceGroups[i]
+		for j := range group.PhysicalDevices {
+			if device := &group.PhysicalDevices[j]; device.VersionMismatch() {
+				devices = append(devices, device)
+			}
+		}
+	}
+	slices.SortFunc(devices, func(a, b *PhysicalDevice) int { return strings.Compare(a.Name, b.Name) })
+	return devices
+}
+
+// formatVersionSpread describes spread on one line, e.g. "R81.20 (12), R81.10 (3)"
+func formatVersionSpread(spread []VersionCount) string {
+	parts := make([]string, len(spread))
+	for i, count := range spread {
+		parts[i] = fmt.Sprintf("%s (%d)", count.Version, count.Devices)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ToggleVersions shows or hides the version spread overlay
+func (dm *DisplayManager) ToggleVersions() {
+	dm.versions = !dm.versions
+	dm.flush()
+}
+
+// versionLines lists how many devices run each product version and the
+// devices whose software version differs, as many as fit on the screen
+func (dm *DisplayManager) versionLines() []string {
+	bold := dm.getColor(ColorBold)
+	dim := dm.getColor(ColorDim)
+	yellow := dm.getColor(ColorYellow)
+	reset := dm.getColor(ColorReset)
+
+	lines := []string{bold + "Version spread" + reset, ""}
+	if dm.lastData == nil || dm.lastData.TotalDevices == 0 {
+		return append(lines, "No devices yet", "", dim+"u: close"+reset)
+	}
+
+	spread := NewVersionSpread(dm.lastData)
+	versionWidth := len("Version")
+	for _, count := range spread {
+		versionWidth = max(versionWidth, displayWidth(count.Version))
+	}
+	lines = append(lines, dim+padString("Version", versionWidth, true)+"  Devices"+reset)
+	for _, count := range spread {
+		line := fmt.Sprintf("%s  %7d", padString(count.Version, versionWidth, true), count.Devices)
+		if target := dm.config.TargetVersion; target != "" && count.Version != "-" && compareVersions(count.Version, target) < 0 {
+			line += fmt.Sprintf("  %sOUTDATED%s (target %s)", yellow, reset, target)
+		}
+		lines = append(lines, line)
+	}
+
+	mismatches := versionMismatches(dm.lastData)
+	lines = append(lines, "")
+	if len(mismatches) == 0 {
+		lines = append(lines, dm.getColor(ColorGreen)+"Software and product versions match on every device"+reset)
+		return append(lines, "", dim+"u: close"+reset)
+	}
+
+	lines = append(lines, fmt.Sprintf("%sSoftware version differs%s on %d of %d devices (upgrade not completed?)", yellow, reset, len(mismatches), dm.lastData.TotalDevices))
+	// Title, table, blank lines, hint and the box borders
+	room := max(1, dm.termHeight-headerLines-footerLines-len(lines)-5)
+	for i, device := range mismatches {
+		if i == room-1 && len(mismatches) > room {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(mismatches)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%s  product %s, software %s", device.Name, device.ProductVersion, device.SoftwareVersion))
+	}
+
+	return append(lines, "", dim+"u: close"+reset)
+}
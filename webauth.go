@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webAuthRequired reports whether the web server asks for credentials
+func webAuthRequired(config *Config) bool {
+	return config.WebPassword != "" || config.WebToken != ""
+}
+
+// requireAuth lets requests through to next with the -web_username and
+// -web_password or a bearer token, -web_token or -web_ack_token, as the ack
+// endpoint needs the Authorization header for its own. The health checks
+// stay open for the probes of orchestrators, which cannot log in.
+func (ws *WebServer) requireAuth(next http.Handler) http.Handler {
+	if !webAuthRequired(ws.config) {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || ws.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ws.config.WebPassword != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="pt_device_monitor", charset="UTF-8"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+	})
+}
+
+// authorized reports whether r carries valid credentials
+func (ws *WebServer) authorized(r *http.Request) bool {
+	if username, password, ok := r.BasicAuth(); ok {
+		return ws.config.WebPassword != "" &&
+			secretEqual(username, ws.config.WebUsername) && secretEqual(password, ws.config.WebPassword)
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	for _, valid := range []string{ws.config.WebToken, ws.config.WebAckToken} {
+		if valid != "" && secretEqual(token, valid) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretEqual compares a credential in constant time, also regardless of
+// its length
+func secretEqual(given, valid string) bool {
+	a := sha256.Sum256([]byte(given))
+	b := sha256.Sum256([]byte(valid))
+	return subtle.ConstantTimeCompare(a[:], b[:]) == 1
+}
+
+// certificateLoader serves the -web_tls_cert and -web_tls_key pair, loading
+// it again when the certificate file changes, so a renewed certificate, such
+// as one cert-manager rotates in a mounted secret, needs no restart
+type certificateLoader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+func newCertificateLoader(certFile, keyFile string) (*certificateLoader, error) {
+	loader := &certificateLoader{certFile: certFile, keyFile: keyFile}
+	if _, err := loader.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config. A renewed
+// pair that fails to load leaves the previous one in use.
+func (cl *certificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	info, err := os.Stat(cl.certFile)
+	if err == nil && cl.cert != nil && info.ModTime().Equal(cl.modified) {
+		return cl.cert, nil
+	}
+	if err != nil {
+		if cl.cert != nil {
+			return cl.cert, nil
+		}
+		return nil, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(cl.certFile, cl.keyFile)
+	if err != nil {
+		if cl.cert != nil {
+			return cl.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	cl.cert, cl.modified = &cert, info.ModTime()
+	return cl.cert, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds each alert delivery
+const webhookTimeout = 10 * time.Second
+
+// WebhookSender posts every alert as JSON to alerts.webhook
+type WebhookSender struct {
+	url    string
+	client *http.Client
+}
+
+func init() {
+	registerAlertSender("webhook", func(config *Config) (AlertSender, error) {
+		if config.Alerts.Webhook == "" {
+			return nil, nil
+		}
+		return &WebhookSender{url: config.Alerts.Webhook, client: &http.Client{Timeout: webhookTimeout}}, nil
+	})
+}
+
+func (ws *WebhookSender) Send(alert Alert) error {
+	return postJSON(ws.client, ws.url, nil, alert)
+}
+
+// postJSON posts payload as JSON and fails on a non-2xx status. header adds
+// request headers such as credentials.
+func postJSON(client *http.Client, url string, header http.Header, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pt_device_monitor/"+shortVersion())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, bytes.TrimSpace(message))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
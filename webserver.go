@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebServer serves a read-only dashboard and JSON API of the data in a
+// StateStore, over TLS with -web_tls_cert and behind -web_password or
+// -web_token. Acknowledging device problems is the one write, allowed with
+// -web_ack_token.
+type WebServer struct {
+	config *Config
+	store  *StateStore
+	acks   *AckStore
+	grpc   *GRPCServer
+	server *http.Server
+}
+
+func NewWebServer(config *Config, store *StateStore) *WebServer {
+	ws := &WebServer{
+		config: config,
+		store:  store,
+		grpc:   NewGRPCServer(store),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ws.handleDashboard)
+	mux.HandleFunc("/api/state", ws.handleState)
+	mux.HandleFunc("/api/devices", ws.handleDevices)
+	mux.HandleFunc("/api/events", ws.handleEvents)
+	mux.HandleFunc("POST /api/devices/{id}/ack", ws.handleAck)
+	mux.HandleFunc("DELETE /api/devices/{id}/ack", ws.handleAck)
+	mux.HandleFunc("/healthz", ws.handleHealthz)
+	mux.HandleFunc("/readyz", ws.handleReadyz)
+	mux.Handle("POST "+grpcService, ws.grpc)
+
+	// gRPC clients speak HTTP/2 without TLS too
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	ws.server = &http.Server{
+		Addr:              config.WebListen,
+		Handler:           ws.requireAuth(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+		Protocols:         protocols,
+	}
+	ws.server.RegisterOnShutdown(ws.grpc.Close)
+
+	return ws
+}
+
+// SetAcks sets the acknowledgements changed by /api/devices/{id}/ack
+func (ws *WebServer) SetAcks(acks *AckStore) {
+	ws.acks = acks
+}
+
+// Start binds the listen address and begins serving in the background
+func (ws *WebServer) Start() error {
+	listener, err := net.Listen("tcp", ws.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", ws.server.Addr, err)
+	}
+	if ws.config.WebTLSCert != "" {
+		loader, err := newCertificateLoader(ws.config.WebTLSCert, ws.config.WebTLSKey)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		listener = tls.NewListener(listener, &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			NextProtos:     []string{"h2", "http/1.1"},
+			GetCertificate: loader.GetCertificate,
+		})
+	}
+
+	go func() {
+		if err := ws.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logBackground("web server error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop shuts the server down, waiting briefly for open requests
+func (ws *WebServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ws.server.Shutdown(ctx)
+}
+
+func (ws *WebServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	state := ws.store.State()
+
+	data := state.Data
+	if data == nil {
+		data = &GroupedDevices{}
+	}
+
+	// Refresh the page at the poll interval, but not more than once a second
+	refresh := int(ws.config.PollInterval / time.Second)
+	if refresh < 1 {
+		refresh = 1
+	}
+
+	report := newHTMLReportData(data, ws.config)
+	report.Refresh = refresh
+	report.Waiting = state.Data == nil && state.LastError == ""
+	if state.LastError != "" {
+		report.Error = fmt.Sprintf("%s (at %s)", state.LastError, formatTime(state.ErrorAt, "2006-01-02 15:04:05"))
+		if state.Data != nil {
+			report.Generated = formatTime(state.Data.LastUpdated, "2006-01-02 15:04:05")
+		}
+	} else if state.Data != nil {
+		report.Generated = formatTime(state.Data.LastUpdated, "2006-01-02 15:04:05")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := htmlReportTemplate.Execute(w, report); err != nil {
+		logBackground("web server: failed to render dashboard: %v", err)
+	}
+}
+
+// apiGroupState is the /api/state view of a logical device group
+type apiGroupState struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	Topology         string   `json:"topology"`
+	Health           string   `json:"health"`
+	DevicesTotal     int      `json:"devices_total"`
+	DevicesConnected int      `json:"devices_connected"`
+	ActiveNode       string   `json:"active_node,omitempty"`
+	VirtualContexts  []string `json:"virtual_contexts,omitempty"`
+}
+
+type apiState struct {
+	LastUpdated  *time.Time      `json:"last_updated,omitempty"`
+	LastError    string          `json:"last_error,omitempty"`
+	ErrorAt      *time.Time      `json:"error_at,omitempty"`
+	PollInterval string          `json:"poll_interval"`
+	Summary      *ReportSummary  `json:"summary,omitempty"`
+	Groups       []apiGroupState `json:"groups"`
+}
+
+// apiDevice is the /api/devices view of a physical device with computed fields
+type apiDevice struct {
+	PhysicalDevice
+	LogicalDeviceName string `json:"logical_device_name"`
+	GroupHealth       string `json:"group_health"`
+	Connection        string `json:"connection"`
+	Health            string `json:"health"`
+	Role              string `json:"role,omitempty"`
+}
+
+func (ws *WebServer) handleState(w http.ResponseWriter, r *http.Request) {
+	state := ws.store.State()
+
+	response := apiState{
+		LastError:    state.LastError,
+		PollInterval: ws.config.PollInterval.String(),
+		Groups:       []apiGroupState{},
+	}
+	if !state.ErrorAt.IsZero() {
+		response.ErrorAt = &state.ErrorAt
+	}
+
+	if state.Data != nil {
+		response.LastUpdated = &state.Data.LastUpdated
+		summary := NewReportSummary(state.Data)
+		response.Summary = &summary
+
+		for _, group := range sortedGroups(state.Data) {
+			groupState := apiGroupState{
+				ID:               group.LogicalDevice.ID,
+				Name:             group.LogicalDevice.Name,
+				Topology:         group.GetTopologyDisplayName(),
+				Health:           group.GetHealthDisplay(),
+				DevicesTotal:     len(group.PhysicalDevices),
+				DevicesConnected: group.ConnectedCount(),
+			}
+			if group.ActiveNode != nil {
+				groupState.ActiveNode = group.ActiveNode.Name
+			}
+			for _, vc := range group.LogicalDevice.VirtualContexts {
+				groupState.VirtualContexts = append(groupState.VirtualContexts, vc.Name)
+			}
+			response.Groups = append(response.Groups, groupState)
+		}
+	}
+
+	writeJSON(w, response)
+}
+
+func (ws *WebServer) handleDevices(w http.ResponseWriter, r *http.Request) {
+	state := ws.store.State()
+
+	devices := []apiDevice{}
+	if state.Data != nil {
+		for _, group := range sortedGroups(state.Data) {
+			for i := range group.PhysicalDevices {
+				device := &group.PhysicalDevices[i]
+				devices = append(devices, apiDevice{
+					PhysicalDevice:    *device,
+					LogicalDeviceName: group.LogicalDevice.Name,
+					GroupHealth:       group.GetHealthDisplay(),
+					Connection:        device.GetConnectionStateDisplay(),
+					Health:            device.GetHealthStatusDisplay(),
+					Role:              device.GetRoleDisplay(),
+				})
+			}
+		}
+	}
+
+	writeJSON(w, devices)
+}
+
+// handleEvents returns the change history; ?since=<RFC3339> and ?limit=<n> narrow it down
+func (ws *WebServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			http.Error(w, "invalid since parameter, expected RFC3339", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			http.Error(w, "invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	writeJSON(w, ws.store.Events(since, limit))
+}
+
+// apiAckRequest is the optional body of POST /api/devices/{id}/ack
+type apiAckRequest struct {
+	By       string `json:"by"`
+	Duration string `json:"duration"` // e.g. 2h; empty: until the device recovers
+	Comment  string `json:"comment"`
+}
+
+// handleAck acknowledges the problem of a device with POST, silencing its
+// alerts, and removes the acknowledgement with DELETE
+func (ws *WebServer) handleAck(w http.ResponseWriter, r *http.Request) {
+	if ws.config.WebAckToken == "" || ws.acks == nil {
+		http.Error(w, "acknowledging is disabled, see -web_ack_token", http.StatusForbidden)
+		return
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(ws.config.WebAckToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	id := r.PathValue("id")
+	if r.Method == http.MethodDelete {
+		if !ws.acks.Clear(id) {
+			http.Error(w, "device is not acknowledged", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	device := findDevice(ws.store.State().Data, id)
+	if device == nil {
+		http.Error(w, "unknown device", http.StatusNotFound)
+		return
+	}
+
+	var request apiAckRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&request); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+	duration, err := parseAckDuration(request.Duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.By == "" {
+		request.By = "api"
+	}
+
+	ack, err := ws.acks.Acknowledge(device, request.By, duration, request.Comment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, ack)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	writeJSONStatus(w, http.StatusOK, value)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		logBackground("web server: failed to encode response: %v", err)
+	}
+}